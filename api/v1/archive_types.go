@@ -17,24 +17,85 @@ limitations under the License.
 package v1
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
 )
 
+// DefaultBackupSyncInterval is how often the backup sets are synchronized by default
+const DefaultBackupSyncInterval = 5 * time.Minute
+
+// ConditionVerified tells whether the last verification of the repository
+// found all the backups and WAL archives valid
+const ConditionVerified = "Verified"
+
+// ConditionConfigurationValid tells whether the additional command line
+// arguments of the configuration are accepted by the plugin
+const ConditionConfigurationValid = "ConfigurationValid"
+
 // InstanceSidecarConfiguration defines the configuration for the sidecar that runs in the instance pods.
 type InstanceSidecarConfiguration struct {
 	// The environment to be explicitly passed to the sidecar
 	// +optional
 	Env []corev1.EnvVar `json:"env,omitempty"`
-	// TODO: Add resources for the restore job sidecar
 	// Resources allocated for the sidecar
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 	// SecurityContext for the sidecar container
 	// +optional
 	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// Additional volumes added to the instance pods, e.g. CA bundles from
+	// ConfigMaps or a dedicated spool volume
+	// +optional
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+	// Where to mount the additional volumes in the sidecar
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+	// The sidecar image to use instead of the one the plugin was deployed with
+	// +optional
+	Image string `json:"image,omitempty"`
+	// The pull policy of the sidecar image. Defaults to the one of the cluster.
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// The maximum number of pgBackRest processes the sidecar runs at once,
+	// a backup counting for as many processes as its jobs. WAL archiving
+	// is served first and can always use the last one. Unlimited when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxProcesses *int32 `json:"maxProcesses,omitempty"`
+	// The maximum size of the spool holding the prefetched WAL files. Once
+	// it is exceeded, the WAL files which would be replayed last are evicted,
+	// and fewer WAL files are prefetched when it is near. Unlimited when unset.
+	// +optional
+	SpoolQuota *resource.Quantity `json:"spoolQuota,omitempty"`
+	// How long an entry can stay in the spool before being evicted.
+	// Defaults to 1h.
+	// +optional
+	SpoolMaxAge *metav1.Duration `json:"spoolMaxAge,omitempty"`
+}
+
+// RestoreJobSidecarConfiguration defines the configuration for the sidecar that runs
+// in the full-recovery Job restoring a cluster from this archive.
+type RestoreJobSidecarConfiguration struct {
+	// The environment to be explicitly passed to the sidecar
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+	// Resources allocated for the sidecar
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// SecurityContext for the sidecar container
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// Additional volumes added to the restore Job, e.g. a larger spool
+	// +optional
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+	// Where to mount the additional volumes in the sidecar
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
 }
 
 // ArchiveSpec defines the desired state of Archive.
@@ -43,12 +104,251 @@ type ArchiveSpec struct {
 
 	// +optional
 	InstanceSidecarConfiguration InstanceSidecarConfiguration `json:"instanceSidecarConfiguration,omitempty"`
+
+	// The configuration of the sidecar of the full-recovery Job restoring from
+	// this archive. When not set, the instance sidecar configuration is used.
+	// +optional
+	RestoreJobSidecarConfiguration *RestoreJobSidecarConfiguration `json:"restoreJobSidecarConfiguration,omitempty"`
+
+	// The synchronization of the backup sets of the repository into
+	// Backup objects of the clusters archiving into this archive
+	// +optional
+	BackupSync *BackupSyncConfiguration `json:"backupSync,omitempty"`
+
+	// The periodic verification of the backups and WAL archives of the
+	// stanzas of the clusters archiving into this archive. ClusterArchives
+	// are not verified.
+	// +optional
+	Verification *VerificationConfiguration `json:"verification,omitempty"`
+}
+
+// VerificationConfiguration defines when the repository is verified
+type VerificationConfiguration struct {
+	// The schedule of the verification, in Cron format, e.g. "0 3 * * 0"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Resources allocated for the Job running the verification
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// BackupSyncConfiguration defines how the backup sets of the repository are
+// synchronized into Backup objects
+type BackupSyncConfiguration struct {
+	// Whether a Backup object is created for each backup set of the stanza
+	// of the clusters, including the ones taken outside of CloudNativePG,
+	// and the Backup objects whose set expired are deleted. Not supported
+	// by ClusterArchives, which have no status to list the backup sets in.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// How often the backup sets are synchronized. Defaults to 5m.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// GetInterval returns how often the backup sets are synchronized
+func (configuration *BackupSyncConfiguration) GetInterval() time.Duration {
+	if configuration == nil || configuration.Interval == nil {
+		return DefaultBackupSyncInterval
+	}
+	return configuration.Interval.Duration
+}
+
+// IsEnabled tells whether the backup sets are synchronized
+func (configuration *BackupSyncConfiguration) IsEnabled() bool {
+	return configuration != nil && configuration.Enabled
+}
+
+// ArchiveUsage is the way a cluster uses an Archive
+// +kubebuilder:validation:Enum=archive;recovery;replicaSource
+type ArchiveUsage string
+
+const (
+	// ArchiveUsageArchive is used by clusters archiving WALs and taking backups
+	// into the Archive
+	ArchiveUsageArchive ArchiveUsage = "archive"
+
+	// ArchiveUsageRecovery is used by clusters bootstrapped from the Archive
+	ArchiveUsageRecovery ArchiveUsage = "recovery"
+
+	// ArchiveUsageReplicaSource is used by replica clusters following the Archive
+	ArchiveUsageReplicaSource ArchiveUsage = "replicaSource"
+)
+
+// ArchiveConsumer is a cluster referring to the Archive
+type ArchiveConsumer struct {
+	// The name of the cluster, living in the namespace of the Archive
+	Name string `json:"name"`
+
+	// How the cluster uses the Archive
+	Usages []ArchiveUsage `json:"usages"`
 }
 
 // ArchiveStatus defines the observed state of Archive.
 type ArchiveStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// The clusters referring to this Archive. The Archive can't be
+	// deleted until this list is empty.
+	// +optional
+	Consumers []ArchiveConsumer `json:"consumers,omitempty"`
+
+	// The stanzas whose WAL archive is broken because pgBackRest dropped
+	// WAL files, as the archive queue exceeded wal.archivePushQueueMax.
+	// Point in time recovery isn't possible across the dropped WAL files
+	// until a full backup of the stanza is taken.
+	// +optional
+	BrokenWALArchives map[string]BrokenWALArchive `json:"brokenWALArchives,omitempty"`
+
+	// The named restore points created through RestorePoint objects, by
+	// stanza and name. They are used to choose the backup when recovering
+	// with a targetName.
+	// +optional
+	RestorePoints map[string]map[string]RecordedRestorePoint `json:"restorePoints,omitempty"`
+
+	// The storage used by each stanza, updated after each backup
+	// +optional
+	Storage map[string]StanzaStorage `json:"storage,omitempty"`
+
+	// The result of the last verification of each stanza
+	// +optional
+	Verification map[string]StanzaVerification `json:"verification,omitempty"`
+
+	// The backup sets of each stanza, listed by the primary of the cluster
+	// archiving into it when the backup sync is enabled
+	// +optional
+	BackupSets map[string]StanzaBackupSets `json:"backupSets,omitempty"`
+
+	// The conditions of the Archive, such as Verified and ConfigurationValid
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// StanzaVerification describes the result of the verification of a stanza
+type StanzaVerification struct {
+	// The status reported by pgBackRest, "ok" or "error"
+	// +optional
+	Status string `json:"status,omitempty"`
+
+	// The backup sets which are not valid
+	// +optional
+	InvalidBackups []InvalidBackup `json:"invalidBackups,omitempty"`
+
+	// The WAL archives having files which are not valid
+	// +optional
+	InvalidWALArchives []InvalidWALArchive `json:"invalidWALArchives,omitempty"`
+
+	// Why the verification couldn't be run
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// When the stanza was verified
+	VerifiedAt metav1.Time `json:"verifiedAt"`
+}
+
+// VerifiedFiles counts the files checked by the verification
+type VerifiedFiles struct {
+	// The number of files checked
+	Checked int64 `json:"checked"`
+
+	// The number of valid files
+	Valid int64 `json:"valid"`
+
+	// The number of missing files
+	Missing int64 `json:"missing"`
+
+	// The number of files whose checksum doesn't match
+	ChecksumInvalid int64 `json:"checksumInvalid"`
+
+	// The number of files whose size doesn't match
+	SizeInvalid int64 `json:"sizeInvalid"`
+
+	// The number of files which couldn't be verified for other reasons
+	Other int64 `json:"other"`
+}
+
+// InvalidBackup is a backup set which is not valid
+type InvalidBackup struct {
+	// The label of the backup set
+	Label string `json:"label"`
+
+	// The status reported by pgBackRest, e.g. "invalid" or "manifest missing"
+	Status string `json:"status"`
+
+	VerifiedFiles `json:",inline"`
+}
+
+// InvalidWALArchive is a WAL archive having files which are not valid
+type InvalidWALArchive struct {
+	// The ID of the WAL archive, e.g. "17-1"
+	ArchiveID string `json:"archiveID"`
+
+	VerifiedFiles `json:",inline"`
+}
+
+// StanzaBackupSets describes the backup sets of a stanza
+type StanzaBackupSets struct {
+	// The cluster which listed the backup sets
+	Cluster string `json:"cluster"`
+
+	// The IDs of the completed backup sets
+	// +optional
+	IDs []string `json:"ids,omitempty"`
+
+	// When the backup sets were listed
+	ListedAt metav1.Time `json:"listedAt"`
+}
+
+// StanzaStorage describes the storage used by the backups of a stanza
+type StanzaStorage struct {
+	// The cluster which took the last backup
+	Cluster string `json:"cluster"`
+
+	// The size of the database when the last backup was taken
+	DatabaseSize int64 `json:"databaseSize"`
+
+	// The growth of the database size per day, from the first to the
+	// last backup in the repositories
+	DatabaseGrowthPerDay int64 `json:"databaseGrowthPerDay"`
+
+	// The size of the files stored in the repository by the last backup
+	LastBackupDelta int64 `json:"lastBackupDelta"`
+
+	// The storage used in each repository
+	// +optional
+	Repositories []RepositoryStorage `json:"repositories,omitempty"`
+
+	// When the storage was last measured
+	UpdatedAt metav1.Time `json:"updatedAt"`
+}
+
+// RepositoryStorage describes the storage used by the backups of a stanza in a repository
+type RepositoryStorage struct {
+	// The key of the repository, starting from 1
+	Repository int64 `json:"repository"`
+
+	// The number of backups in the repository
+	Backups int64 `json:"backups"`
+
+	// The size of the files stored by the backups, WAL files excluded
+	Size int64 `json:"size"`
+}
+
+// BrokenWALArchive describes the WAL files dropped from the archive of a stanza
+type BrokenWALArchive struct {
+	// The cluster which dropped the WAL files
+	Cluster string `json:"cluster"`
+
+	// The first WAL file which was dropped
+	FirstDroppedWAL string `json:"firstDroppedWAL"`
+
+	// The last WAL file which was dropped
+	LastDroppedWAL string `json:"lastDroppedWAL"`
+
+	// When the first WAL file was dropped
+	Since metav1.Time `json:"since"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,105 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AllNamespaces can be used in ClusterArchiveSpec.AllowedNamespaces to allow clusters
+// from every namespace to use the ClusterArchive.
+const AllNamespaces = "*"
+
+// ClusterArchiveSpec defines the desired state of ClusterArchive. A
+// ClusterArchive has no status, so the features recording their state in the
+// status of an Archive are rejected.
+// +kubebuilder:validation:XValidation:rule="!has(self.verification)",message="verification is not supported by ClusterArchives"
+// +kubebuilder:validation:XValidation:rule="!has(self.backupSync) || !has(self.backupSync.enabled) || !self.backupSync.enabled",message="backupSync is not supported by ClusterArchives"
+// +kubebuilder:validation:XValidation:rule="!has(self.configuration.wal) || !has(self.configuration.wal.archivePushQueueMax)",message="configuration.wal.archivePushQueueMax is not supported by ClusterArchives"
+type ClusterArchiveSpec struct {
+	ArchiveSpec `json:",inline"`
+
+	// The namespace containing the Secrets referenced by the configuration,
+	// usually the one where the plugin is installed. Clusters using this
+	// ClusterArchive are granted read access to those Secrets only.
+	// +kubebuilder:validation:MinLength=1
+	CredentialsNamespace string `json:"credentialsNamespace"`
+
+	// The list of namespaces whose clusters may use this ClusterArchive.
+	// Use "*" to allow every namespace. When empty, no cluster can use it.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// ClusterArchiveStatus defines the observed state of ClusterArchive. It is
+// empty: the clusters using a ClusterArchive aren't tracked, it isn't
+// protected from deletion while in use, and neither the storage of its
+// stanzas nor their broken WAL archives are recorded.
+type ClusterArchiveStatus struct{}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:storageversion
+
+// ClusterArchive is the Schema for the cluster-scoped archives API. It can be shared
+// by clusters from every namespace listed in its allow-list.
+type ClusterArchive struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec ClusterArchiveSpec `json:"spec"`
+	// +optional
+	Status ClusterArchiveStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterArchiveList contains a list of ClusterArchive.
+type ClusterArchiveList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterArchive `json:"items"`
+}
+
+// IsNamespaceAllowed checks whether clusters in the passed namespace may use
+// this ClusterArchive
+func (archive *ClusterArchive) IsNamespaceAllowed(namespace string) bool {
+	return slices.Contains(archive.Spec.AllowedNamespaces, AllNamespaces) ||
+		slices.Contains(archive.Spec.AllowedNamespaces, namespace)
+}
+
+// ToArchive returns an Archive equivalent to this ClusterArchive, placed in the
+// credentials namespace so that the Secrets referenced by its configuration are
+// looked up there.
+func (archive *ClusterArchive) ToArchive() *Archive {
+	return &Archive{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupVersion.String(),
+			Kind:       "Archive",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      archive.Name,
+			Namespace: archive.Spec.CredentialsNamespace,
+		},
+		Spec: *archive.Spec.ArchiveSpec.DeepCopy(),
+	}
+}
@@ -40,6 +40,9 @@ var (
 func addKnownTypes(s *runtime.Scheme) error {
 	s.AddKnownTypes(GroupVersion,
 		&Archive{}, &ArchiveList{},
+		&ClusterArchive{}, &ClusterArchiveList{},
+		&RestorePoint{}, &RestorePointList{},
+		&RestoreTest{}, &RestoreTestList{},
 	)
 	metav1.AddToGroupVersion(s, GroupVersion)
 	return nil
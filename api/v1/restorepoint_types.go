@@ -0,0 +1,116 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestorePointPhase is the phase of a RestorePoint
+type RestorePointPhase string
+
+const (
+	// RestorePointPhaseCompleted means the restore point was created and recorded
+	RestorePointPhaseCompleted RestorePointPhase = "completed"
+
+	// RestorePointPhaseFailed means the restore point couldn't be created
+	RestorePointPhaseFailed RestorePointPhase = "failed"
+)
+
+// RestorePointSpec defines the desired state of RestorePoint.
+type RestorePointSpec struct {
+	// The cluster where the restore point is created
+	Cluster cnpgv1.LocalObjectReference `json:"cluster"`
+
+	// The name of the restore point in PostgreSQL, used as recovery
+	// targetName. Defaults to the name of the RestorePoint.
+	// +kubebuilder:validation:MaxLength=63
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// RestorePointStatus defines the observed state of RestorePoint.
+type RestorePointStatus struct {
+	// The phase of the restore point
+	// +optional
+	Phase RestorePointPhase `json:"phase,omitempty"`
+
+	// The stanza where the restore point is recorded
+	// +optional
+	Stanza string `json:"stanza,omitempty"`
+
+	// The location of the restore point
+	// +optional
+	RecordedRestorePoint `json:",inline"`
+
+	// The reason why the restore point couldn't be created
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// RecordedRestorePoint is the location of a named restore point in the WAL
+type RecordedRestorePoint struct {
+	// The LSN of the restore point
+	// +optional
+	LSN string `json:"lsn,omitempty"`
+
+	// The timeline of the restore point
+	// +optional
+	Timeline int64 `json:"timeline,omitempty"`
+
+	// When the restore point was created
+	// +optional
+	Time *metav1.Time `json:"time,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.cluster.name"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="LSN",type="string",JSONPath=".status.lsn"
+// +genclient
+// +kubebuilder:storageversion
+
+// RestorePoint is the Schema for the restore points API. It creates a named
+// restore point on the primary of a cluster, which can be used as targetName
+// when recovering from the Archive of the cluster.
+type RestorePoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec RestorePointSpec `json:"spec"`
+	// +optional
+	Status RestorePointStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RestorePointList contains a list of RestorePoint.
+type RestorePointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestorePoint `json:"items"`
+}
+
+// GetRestorePointName returns the name of the restore point in PostgreSQL
+func (restorePoint *RestorePoint) GetRestorePointName() string {
+	if restorePoint.Spec.Name != "" {
+		return restorePoint.Spec.Name
+	}
+	return restorePoint.Name
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestoreTestPhase is the phase of the last run of a RestoreTest
+type RestoreTestPhase string
+
+const (
+	// RestoreTestPhaseRestoring means the backup is being restored
+	RestoreTestPhaseRestoring RestoreTestPhase = "restoring"
+
+	// RestoreTestPhaseRecovering means PostgreSQL is replaying the WAL files
+	RestoreTestPhaseRecovering RestoreTestPhase = "recovering"
+
+	// RestoreTestPhaseSucceeded means the backup was restored, PostgreSQL
+	// was promoted and all the assertions passed
+	RestoreTestPhaseSucceeded RestoreTestPhase = "succeeded"
+
+	// RestoreTestPhaseFailed means the restore, the recovery or an assertion failed
+	RestoreTestPhaseFailed RestoreTestPhase = "failed"
+)
+
+// RestoreTestSpec defines the desired state of RestoreTest.
+type RestoreTestSpec struct {
+	// The schedule of the restore test, in Cron format, e.g. "0 4 * * 0"
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// The Archive holding the backups to restore, living in the namespace
+	// of the RestoreTest
+	Archive cnpgv1.LocalObjectReference `json:"archive"`
+
+	// The stanza to restore
+	// +kubebuilder:validation:MinLength=1
+	Stanza string `json:"stanza"`
+
+	// The recovery target. The latest backup is restored and all the
+	// archived WAL files are replayed when not set.
+	// +optional
+	RecoveryTarget *cnpgv1.RecoveryTarget `json:"recoveryTarget,omitempty"`
+
+	// The PostgreSQL image starting the restored database, which must
+	// match the major version of the stanza
+	// +kubebuilder:validation:MinLength=1
+	ImageName string `json:"imageName"`
+
+	// The queries checking the restored database
+	// +optional
+	Assertions []RestoreTestAssertion `json:"assertions,omitempty"`
+
+	// Resources allocated for PostgreSQL
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// The volume the backup is restored into
+	// +optional
+	Storage RestoreTestStorage `json:"storage,omitempty"`
+
+	// Whether the restore test is suspended
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// DefaultRestoreTestSizeLimit is the size limit of the emptyDir volume the
+// backup is restored into when none is set
+const DefaultRestoreTestSizeLimit = "10Gi"
+
+// RestoreTestStorage is the volume the backup is restored into. An emptyDir
+// volume, limited to SizeLimit, is used unless a VolumeClaimTemplate is set.
+type RestoreTestStorage struct {
+	// The size limit of the emptyDir volume. The Pod is evicted when the
+	// restored data exceeds it. Defaults to 10Gi, and is ignored when a
+	// volumeClaimTemplate is set.
+	// +optional
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+
+	// The template of the ephemeral PersistentVolumeClaim used instead of an
+	// emptyDir volume, for the data directories which don't fit into the
+	// ephemeral storage of the nodes
+	// +optional
+	VolumeClaimTemplate *corev1.PersistentVolumeClaimTemplate `json:"volumeClaimTemplate,omitempty"`
+}
+
+// GetSizeLimit returns the size limit of the emptyDir volume
+func (storage *RestoreTestStorage) GetSizeLimit() resource.Quantity {
+	if storage.SizeLimit != nil {
+		return *storage.SizeLimit
+	}
+	return resource.MustParse(DefaultRestoreTestSizeLimit)
+}
+
+// RestoreTestAssertion is a query checking the restored database
+type RestoreTestAssertion struct {
+	// The name of the assertion
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// The database where the query runs. Defaults to postgres.
+	// +optional
+	Database string `json:"database,omitempty"`
+
+	// The query, returning a single boolean which is true when the
+	// assertion holds, e.g. "SELECT count(*) > 0 FROM orders"
+	// +kubebuilder:validation:MinLength=1
+	Query string `json:"query"`
+}
+
+// GetDatabase returns the database where the query of the assertion runs
+func (assertion *RestoreTestAssertion) GetDatabase() string {
+	if assertion.Database != "" {
+		return assertion.Database
+	}
+	return "postgres"
+}
+
+// RestoreTestAssertionResult is the result of an assertion
+type RestoreTestAssertionResult struct {
+	// The name of the assertion
+	Name string `json:"name"`
+
+	// Whether the assertion holds
+	Passed bool `json:"passed"`
+
+	// The reason why the query failed
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// RestoreTestStatus defines the observed state of RestoreTest.
+type RestoreTestStatus struct {
+	// The phase of the last run
+	// +optional
+	Phase RestoreTestPhase `json:"phase,omitempty"`
+
+	// The ID of the backup restored by the last run
+	// +optional
+	BackupID string `json:"backupID,omitempty"`
+
+	// When the last run started restoring the backup
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// When the data files of the backup were restored
+	// +optional
+	RestoredAt *metav1.Time `json:"restoredAt,omitempty"`
+
+	// When PostgreSQL was promoted, after replaying the WAL files
+	// +optional
+	RecoveredAt *metav1.Time `json:"recoveredAt,omitempty"`
+
+	// How long restoring the data files took
+	// +optional
+	RestoreDuration *metav1.Duration `json:"restoreDuration,omitempty"`
+
+	// How long it took for the database to be available, from the start of
+	// the restore to the promotion of PostgreSQL: the actual recovery time
+	// +optional
+	RecoveryDuration *metav1.Duration `json:"recoveryDuration,omitempty"`
+
+	// The results of the assertions
+	// +optional
+	Assertions []RestoreTestAssertionResult `json:"assertions,omitempty"`
+
+	// The reason why the last run failed
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// When the last run completed
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// When the last successful run completed
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Archive",type="string",JSONPath=".spec.archive.name"
+// +kubebuilder:printcolumn:name="Stanza",type="string",JSONPath=".spec.stanza"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Recovery",type="string",JSONPath=".status.recoveryDuration"
+// +kubebuilder:printcolumn:name="Last Success",type="date",JSONPath=".status.lastSuccessfulTime"
+// +genclient
+// +kubebuilder:storageversion
+
+// RestoreTest is the Schema for the restore tests API. It periodically
+// restores a backup of a stanza into a throwaway Job, starts PostgreSQL on it
+// and checks the restored database.
+type RestoreTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	Spec RestoreTestSpec `json:"spec"`
+	// +optional
+	Status RestoreTestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RestoreTestList contains a list of RestoreTest.
+type RestoreTestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RestoreTest `json:"items"`
+}
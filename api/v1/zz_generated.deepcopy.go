@@ -21,7 +21,9 @@ limitations under the License.
 package v1
 
 import (
+	apiv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -31,7 +33,7 @@ func (in *Archive) DeepCopyInto(out *Archive) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Archive.
@@ -52,6 +54,26 @@ func (in *Archive) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchiveConsumer) DeepCopyInto(out *ArchiveConsumer) {
+	*out = *in
+	if in.Usages != nil {
+		in, out := &in.Usages, &out.Usages
+		*out = make([]ArchiveUsage, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveConsumer.
+func (in *ArchiveConsumer) DeepCopy() *ArchiveConsumer {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchiveConsumer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArchiveList) DeepCopyInto(out *ArchiveList) {
 	*out = *in
@@ -89,6 +111,21 @@ func (in *ArchiveSpec) DeepCopyInto(out *ArchiveSpec) {
 	*out = *in
 	in.Configuration.DeepCopyInto(&out.Configuration)
 	in.InstanceSidecarConfiguration.DeepCopyInto(&out.InstanceSidecarConfiguration)
+	if in.RestoreJobSidecarConfiguration != nil {
+		in, out := &in.RestoreJobSidecarConfiguration, &out.RestoreJobSidecarConfiguration
+		*out = new(RestoreJobSidecarConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BackupSync != nil {
+		in, out := &in.BackupSync, &out.BackupSync
+		*out = new(BackupSyncConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(VerificationConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveSpec.
@@ -104,6 +141,66 @@ func (in *ArchiveSpec) DeepCopy() *ArchiveSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArchiveStatus) DeepCopyInto(out *ArchiveStatus) {
 	*out = *in
+	if in.Consumers != nil {
+		in, out := &in.Consumers, &out.Consumers
+		*out = make([]ArchiveConsumer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BrokenWALArchives != nil {
+		in, out := &in.BrokenWALArchives, &out.BrokenWALArchives
+		*out = make(map[string]BrokenWALArchive, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.RestorePoints != nil {
+		in, out := &in.RestorePoints, &out.RestorePoints
+		*out = make(map[string]map[string]RecordedRestorePoint, len(*in))
+		for key, val := range *in {
+			var outVal map[string]RecordedRestorePoint
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make(map[string]RecordedRestorePoint, len(*in))
+				for key, val := range *in {
+					(*out)[key] = *val.DeepCopy()
+				}
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = make(map[string]StanzaStorage, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = make(map[string]StanzaVerification, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.BackupSets != nil {
+		in, out := &in.BackupSets, &out.BackupSets
+		*out = make(map[string]StanzaBackupSets, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveStatus.
@@ -116,6 +213,137 @@ func (in *ArchiveStatus) DeepCopy() *ArchiveStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupSyncConfiguration) DeepCopyInto(out *BackupSyncConfiguration) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupSyncConfiguration.
+func (in *BackupSyncConfiguration) DeepCopy() *BackupSyncConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupSyncConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BrokenWALArchive) DeepCopyInto(out *BrokenWALArchive) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BrokenWALArchive.
+func (in *BrokenWALArchive) DeepCopy() *BrokenWALArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(BrokenWALArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterArchive) DeepCopyInto(out *ClusterArchive) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterArchive.
+func (in *ClusterArchive) DeepCopy() *ClusterArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterArchive) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterArchiveList) DeepCopyInto(out *ClusterArchiveList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterArchive, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterArchiveList.
+func (in *ClusterArchiveList) DeepCopy() *ClusterArchiveList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterArchiveList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterArchiveList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterArchiveSpec) DeepCopyInto(out *ClusterArchiveSpec) {
+	*out = *in
+	in.ArchiveSpec.DeepCopyInto(&out.ArchiveSpec)
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterArchiveSpec.
+func (in *ClusterArchiveSpec) DeepCopy() *ClusterArchiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterArchiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterArchiveStatus) DeepCopyInto(out *ClusterArchiveStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterArchiveStatus.
+func (in *ClusterArchiveStatus) DeepCopy() *ClusterArchiveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterArchiveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstanceSidecarConfiguration) DeepCopyInto(out *InstanceSidecarConfiguration) {
 	*out = *in
@@ -132,6 +360,35 @@ func (in *InstanceSidecarConfiguration) DeepCopyInto(out *InstanceSidecarConfigu
 		*out = new(corev1.SecurityContext)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ExtraVolumes != nil {
+		in, out := &in.ExtraVolumes, &out.ExtraVolumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumeMounts != nil {
+		in, out := &in.ExtraVolumeMounts, &out.ExtraVolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaxProcesses != nil {
+		in, out := &in.MaxProcesses, &out.MaxProcesses
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SpoolQuota != nil {
+		in, out := &in.SpoolQuota, &out.SpoolQuota
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.SpoolMaxAge != nil {
+		in, out := &in.SpoolMaxAge, &out.SpoolMaxAge
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceSidecarConfiguration.
@@ -143,3 +400,493 @@ func (in *InstanceSidecarConfiguration) DeepCopy() *InstanceSidecarConfiguration
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvalidBackup) DeepCopyInto(out *InvalidBackup) {
+	*out = *in
+	out.VerifiedFiles = in.VerifiedFiles
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvalidBackup.
+func (in *InvalidBackup) DeepCopy() *InvalidBackup {
+	if in == nil {
+		return nil
+	}
+	out := new(InvalidBackup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvalidWALArchive) DeepCopyInto(out *InvalidWALArchive) {
+	*out = *in
+	out.VerifiedFiles = in.VerifiedFiles
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvalidWALArchive.
+func (in *InvalidWALArchive) DeepCopy() *InvalidWALArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(InvalidWALArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordedRestorePoint) DeepCopyInto(out *RecordedRestorePoint) {
+	*out = *in
+	if in.Time != nil {
+		in, out := &in.Time, &out.Time
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordedRestorePoint.
+func (in *RecordedRestorePoint) DeepCopy() *RecordedRestorePoint {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordedRestorePoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryStorage) DeepCopyInto(out *RepositoryStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryStorage.
+func (in *RepositoryStorage) DeepCopy() *RepositoryStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreJobSidecarConfiguration) DeepCopyInto(out *RestoreJobSidecarConfiguration) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExtraVolumes != nil {
+		in, out := &in.ExtraVolumes, &out.ExtraVolumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumeMounts != nil {
+		in, out := &in.ExtraVolumeMounts, &out.ExtraVolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreJobSidecarConfiguration.
+func (in *RestoreJobSidecarConfiguration) DeepCopy() *RestoreJobSidecarConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreJobSidecarConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestorePoint) DeepCopyInto(out *RestorePoint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestorePoint.
+func (in *RestorePoint) DeepCopy() *RestorePoint {
+	if in == nil {
+		return nil
+	}
+	out := new(RestorePoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestorePoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestorePointList) DeepCopyInto(out *RestorePointList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestorePoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestorePointList.
+func (in *RestorePointList) DeepCopy() *RestorePointList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestorePointList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestorePointList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestorePointSpec) DeepCopyInto(out *RestorePointSpec) {
+	*out = *in
+	in.Cluster.DeepCopyInto(&out.Cluster)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestorePointSpec.
+func (in *RestorePointSpec) DeepCopy() *RestorePointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestorePointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestorePointStatus) DeepCopyInto(out *RestorePointStatus) {
+	*out = *in
+	in.RecordedRestorePoint.DeepCopyInto(&out.RecordedRestorePoint)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestorePointStatus.
+func (in *RestorePointStatus) DeepCopy() *RestorePointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestorePointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTest) DeepCopyInto(out *RestoreTest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTest.
+func (in *RestoreTest) DeepCopy() *RestoreTest {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestoreTest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestAssertion) DeepCopyInto(out *RestoreTestAssertion) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestAssertion.
+func (in *RestoreTestAssertion) DeepCopy() *RestoreTestAssertion {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestAssertion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestAssertionResult) DeepCopyInto(out *RestoreTestAssertionResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestAssertionResult.
+func (in *RestoreTestAssertionResult) DeepCopy() *RestoreTestAssertionResult {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestAssertionResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestList) DeepCopyInto(out *RestoreTestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RestoreTest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestList.
+func (in *RestoreTestList) DeepCopy() *RestoreTestList {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RestoreTestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestSpec) DeepCopyInto(out *RestoreTestSpec) {
+	*out = *in
+	in.Archive.DeepCopyInto(&out.Archive)
+	if in.RecoveryTarget != nil {
+		in, out := &in.RecoveryTarget, &out.RecoveryTarget
+		*out = new(apiv1.RecoveryTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]RestoreTestAssertion, len(*in))
+		copy(*out, *in)
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.Storage.DeepCopyInto(&out.Storage)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestSpec.
+func (in *RestoreTestSpec) DeepCopy() *RestoreTestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestStatus) DeepCopyInto(out *RestoreTestStatus) {
+	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RestoredAt != nil {
+		in, out := &in.RestoredAt, &out.RestoredAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RecoveredAt != nil {
+		in, out := &in.RecoveredAt, &out.RecoveredAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RestoreDuration != nil {
+		in, out := &in.RestoreDuration, &out.RestoreDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RecoveryDuration != nil {
+		in, out := &in.RecoveryDuration, &out.RecoveryDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]RestoreTestAssertionResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestStatus.
+func (in *RestoreTestStatus) DeepCopy() *RestoreTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreTestStorage) DeepCopyInto(out *RestoreTestStorage) {
+	*out = *in
+	if in.SizeLimit != nil {
+		in, out := &in.SizeLimit, &out.SizeLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.VolumeClaimTemplate != nil {
+		in, out := &in.VolumeClaimTemplate, &out.VolumeClaimTemplate
+		*out = new(corev1.PersistentVolumeClaimTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreTestStorage.
+func (in *RestoreTestStorage) DeepCopy() *RestoreTestStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreTestStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaBackupSets) DeepCopyInto(out *StanzaBackupSets) {
+	*out = *in
+	if in.IDs != nil {
+		in, out := &in.IDs, &out.IDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.ListedAt.DeepCopyInto(&out.ListedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaBackupSets.
+func (in *StanzaBackupSets) DeepCopy() *StanzaBackupSets {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaBackupSets)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaStorage) DeepCopyInto(out *StanzaStorage) {
+	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]RepositoryStorage, len(*in))
+		copy(*out, *in)
+	}
+	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaStorage.
+func (in *StanzaStorage) DeepCopy() *StanzaStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StanzaVerification) DeepCopyInto(out *StanzaVerification) {
+	*out = *in
+	if in.InvalidBackups != nil {
+		in, out := &in.InvalidBackups, &out.InvalidBackups
+		*out = make([]InvalidBackup, len(*in))
+		copy(*out, *in)
+	}
+	if in.InvalidWALArchives != nil {
+		in, out := &in.InvalidWALArchives, &out.InvalidWALArchives
+		*out = make([]InvalidWALArchive, len(*in))
+		copy(*out, *in)
+	}
+	in.VerifiedAt.DeepCopyInto(&out.VerifiedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StanzaVerification.
+func (in *StanzaVerification) DeepCopy() *StanzaVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(StanzaVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerificationConfiguration) DeepCopyInto(out *VerificationConfiguration) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerificationConfiguration.
+func (in *VerificationConfiguration) DeepCopy() *VerificationConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(VerificationConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerifiedFiles) DeepCopyInto(out *VerifiedFiles) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerifiedFiles.
+func (in *VerifiedFiles) DeepCopy() *VerifiedFiles {
+	if in == nil {
+		return nil
+	}
+	out := new(VerifiedFiles)
+	in.DeepCopyInto(out)
+	return out
+}
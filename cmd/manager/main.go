@@ -10,10 +10,13 @@ import (
 	"github.com/spf13/cobra"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/config"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/healthcheck"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/instance"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/operator"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/restore"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/restoretest"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cmd/verify"
 )
 
 func main() {
@@ -34,6 +37,9 @@ func main() {
 	rootCmd.AddCommand(operator.NewCmd())
 	rootCmd.AddCommand(restore.NewCmd())
 	rootCmd.AddCommand(healthcheck.NewCmd())
+	rootCmd.AddCommand(config.NewCmd())
+	rootCmd.AddCommand(verify.NewCmd())
+	rootCmd.AddCommand(restoretest.NewCmd())
 
 	if err := rootCmd.ExecuteContext(ctrl.SetupSignalHandler()); err != nil {
 		if !errors.Is(err, context.Canceled) {
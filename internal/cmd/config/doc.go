@@ -0,0 +1,2 @@
+// Package config contains the commands to inspect the pgBackRest configuration used by the plugin
+package config
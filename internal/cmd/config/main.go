@@ -0,0 +1,130 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
+)
+
+// NewCmd returns the config command
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "pgBackRest configuration commands",
+	}
+
+	cmd.AddCommand(renderCmd())
+	cmd.AddCommand(execCmd())
+
+	_ = viper.BindEnv("namespace", "NAMESPACE")
+	_ = viper.BindEnv("cluster-name", "CLUSTER_NAME")
+	_ = viper.BindEnv("pgdata", "PGDATA")
+
+	return cmd
+}
+
+func renderCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "render",
+		Short: "prints the pgbackrest.conf matching the Archive of the cluster, without the secure options",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			rendered, err := renderConfiguration(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprint(cmd.OutOrStdout(), rendered)
+			return err
+		},
+	}
+}
+
+func execCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "exec [pgbackrest arguments]",
+		Short: "runs pgbackrest with the configuration matching the Archive of the cluster, " +
+			"including the secure options, which are removed once it exits",
+		// every argument is passed to pgbackrest
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			rendered, err := renderConfiguration(ctx, true)
+			if err != nil {
+				return err
+			}
+
+			path, cleanup, err := credentials.WriteConfigurationFile(ctx, rendered)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			pgbackrest := exec.CommandContext(ctx, pgbackrestCommand.PgbackrestExecutable, args...) // #nosec G204
+			pgbackrest.Env = append(utils.SanitizedEnviron(), utils.FormatEnv("CONFIG", path))
+			pgbackrest.Stdin = os.Stdin
+			pgbackrest.Stdout = cmd.OutOrStdout()
+			pgbackrest.Stderr = cmd.ErrOrStderr()
+			return pgbackrest.Run()
+		},
+	}
+}
+
+// renderConfiguration renders the pgbackrest.conf of the cluster the sidecar
+// belongs to
+func renderConfiguration(ctx context.Context, withSecrets bool) (string, error) {
+	requiredSettings := []string{
+		"namespace",
+		"cluster-name",
+	}
+
+	for _, k := range requiredSettings {
+		if len(viper.GetString(k)) == 0 {
+			return "", fmt.Errorf("missing required %s setting", k)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := pgbackrestv1.AddToScheme(scheme); err != nil {
+		return "", err
+	}
+	if err := cnpgv1.AddToScheme(scheme); err != nil {
+		return "", err
+	}
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return "", err
+	}
+
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return "", err
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: viper.GetString("namespace"),
+		Name:      viper.GetString("cluster-name"),
+	}, &cluster); err != nil {
+		return "", fmt.Errorf("while getting cluster: %w", err)
+	}
+
+	return common.RenderPgbackrestConfiguration(ctx, c, &cluster, viper.GetString("pgdata"), withSecrets)
+}
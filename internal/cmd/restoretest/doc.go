@@ -0,0 +1,2 @@
+// Package restoretest contains the commands run by the Jobs of the restore tests
+package restoretest
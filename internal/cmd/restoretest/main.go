@@ -0,0 +1,154 @@
+package restoretest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/restoretest"
+)
+
+// NewCmd creates the "restore-test" subcommand
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore-test",
+		Short: "commands run by the Jobs of the restore tests",
+	}
+
+	cmd.AddCommand(restoreCmd())
+	cmd.AddCommand(walServerCmd())
+	cmd.AddCommand(walGetCmd())
+	cmd.AddCommand(runCmd())
+
+	_ = viper.BindEnv("namespace", "NAMESPACE")
+	_ = viper.BindEnv("restore-test-name", "RESTORE_TEST_NAME")
+	_ = viper.BindEnv("pgdata", "PGDATA")
+	_ = viper.BindEnv("spool-directory", "SPOOL_DIRECTORY")
+
+	return cmd
+}
+
+func restoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore",
+		Short: "restores the backup of the restore test and prepares its recovery",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, restoreTest, err := getRestoreTest(cmd.Context(), "spool-directory")
+			if err != nil {
+				return err
+			}
+
+			return restoretest.Restore(
+				cmd.Context(), c, restoreTest, viper.GetString("pgdata"), viper.GetString("spool-directory"))
+		},
+	}
+}
+
+func walServerCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "wal-server",
+		Short: "serves the WAL files to the PostgreSQL of the restore test",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, restoreTest, err := getRestoreTest(cmd.Context(), "spool-directory")
+			if err != nil {
+				return err
+			}
+
+			server := &restoretest.WALServer{
+				Client:         c,
+				RestoreTest:    restoreTest,
+				PgDataPath:     viper.GetString("pgdata"),
+				SpoolDirectory: viper.GetString("spool-directory"),
+				SocketPath:     restoretest.WALServerSocketPath,
+			}
+			return server.Start(cmd.Context())
+		},
+	}
+}
+
+func walGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "wal-get [name] [path]",
+		Short: "restores a WAL file through the WAL server, used as restore_command",
+		Args:  cobra.ExactArgs(2),
+		// PostgreSQL runs the restore_command with the data directory as
+		// working directory, while the WAL server needs an absolute path
+		RunE: func(cmd *cobra.Command, args []string) error {
+			destinationPath, err := filepath.Abs(args[1])
+			if err != nil {
+				return err
+			}
+
+			return restoretest.RestoreWAL(cmd.Context(), restoretest.WALServerSocketPath, args[0], destinationPath)
+		},
+	}
+}
+
+func runCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "starts PostgreSQL on the restored backup and checks the assertions of the restore test",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, restoreTest, err := getRestoreTest(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return restoretest.Run(cmd.Context(), c, restoreTest, viper.GetString("pgdata"))
+		},
+	}
+}
+
+// getRestoreTest checks the required settings, and gets the RestoreTest
+// together with the client used to get it
+func getRestoreTest(
+	ctx context.Context,
+	additionalSettings ...string,
+) (client.Client, *pgbackrestv1.RestoreTest, error) {
+	requiredSettings := append([]string{
+		"namespace",
+		"restore-test-name",
+		"pgdata",
+	}, additionalSettings...)
+
+	for _, k := range requiredSettings {
+		if len(viper.GetString(k)) == 0 {
+			return nil, nil, fmt.Errorf("missing required %s setting", k)
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := pgbackrestv1.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, nil, err
+	}
+
+	restConfig, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var restoreTest pgbackrestv1.RestoreTest
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: viper.GetString("namespace"),
+		Name:      viper.GetString("restore-test-name"),
+	}, &restoreTest); err != nil {
+		return nil, nil, fmt.Errorf("while getting restore test: %w", err)
+	}
+
+	return c, &restoreTest, nil
+}
@@ -0,0 +1,2 @@
+// Package verify contains the command verifying the repositories of an Archive
+package verify
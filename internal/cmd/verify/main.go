@@ -0,0 +1,123 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
+)
+
+// NewCmd creates the "verify" subcommand
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "verifies the stanzas in the repositories of an Archive and records the result in its status",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			requiredSettings := []string{
+				"namespace",
+				"archive-name",
+				"stanzas",
+			}
+
+			for _, k := range requiredSettings {
+				if len(viper.GetString(k)) == 0 {
+					return fmt.Errorf("missing required %s setting", k)
+				}
+			}
+
+			scheme := runtime.NewScheme()
+			if err := pgbackrestv1.AddToScheme(scheme); err != nil {
+				return err
+			}
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				return err
+			}
+
+			restConfig, err := ctrl.GetConfig()
+			if err != nil {
+				return err
+			}
+			c, err := client.New(restConfig, client.Options{Scheme: scheme})
+			if err != nil {
+				return err
+			}
+
+			var archive pgbackrestv1.Archive
+			if err := c.Get(cmd.Context(), client.ObjectKey{
+				Namespace: viper.GetString("namespace"),
+				Name:      viper.GetString("archive-name"),
+			}, &archive); err != nil {
+				return fmt.Errorf("while getting archive: %w", err)
+			}
+
+			return verifyArchive(cmd.Context(), c, &archive, strings.Split(viper.GetString("stanzas"), ","))
+		},
+	}
+
+	_ = viper.BindEnv("namespace", "NAMESPACE")
+	_ = viper.BindEnv("archive-name", "ARCHIVE_NAME")
+	_ = viper.BindEnv("stanzas", "STANZAS")
+
+	return cmd
+}
+
+// verifyArchive verifies the stanzas in the repositories of the Archive, and
+// records the result in its status. The invalid backup sets and WAL archives
+// are reported in the status only, an error meaning that some stanzas
+// couldn't be verified.
+func verifyArchive(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	stanzas []string,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	env, removeConfig, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
+		ctx,
+		c,
+		archive.Namespace,
+		&archive.Spec.Configuration,
+		utils.SanitizedEnviron())
+	if err != nil {
+		return fmt.Errorf("while getting the repository credentials: %w", err)
+	}
+	defer removeConfig()
+
+	var errs []error
+	verification := make(map[string]pgbackrestv1.StanzaVerification, len(stanzas))
+	for _, stanza := range stanzas {
+		contextLogger.Info("Verifying stanza", "stanza", stanza)
+		result, err := pgbackrestCommand.Verify(ctx, &archive.Spec.Configuration, stanza, env)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("while verifying stanza %s: %w", stanza, err))
+		} else {
+			contextLogger.Info("Stanza verified",
+				"stanza", stanza,
+				"status", result.Status,
+				"invalidBackups", len(result.InvalidBackups()),
+				"invalidWALArchives", len(result.InvalidArchives()))
+		}
+		verification[stanza] = common.NewStanzaVerification(result, err)
+	}
+
+	if err := common.RecordVerification(ctx, c, archive, verification); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,421 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+)
+
+const (
+	// ReasonWALDropped is the reason of the Event emitted when pgBackRest
+	// drops WAL files because the archive queue is full
+	ReasonWALDropped = "WALDropped"
+
+	// ReasonChecksumErrors is the reason of the Event emitted when pgBackRest
+	// detects page checksum errors while taking a backup
+	ReasonChecksumErrors = "ChecksumErrors"
+
+	// ReasonVerified is the reason of the Verified condition when all the
+	// backup sets and WAL archives are valid
+	ReasonVerified = "Verified"
+
+	// ReasonInvalidFiles is the reason of the Verified condition when some
+	// backup sets or WAL archives are not valid
+	ReasonInvalidFiles = "InvalidFiles"
+
+	// ReasonVerificationFailed is the reason of the Verified condition when
+	// some stanzas couldn't be verified
+	ReasonVerificationFailed = "VerificationFailed"
+
+	// actionArchiveWAL is the action of the Events emitted while archiving WAL files
+	actionArchiveWAL = "ArchiveWAL"
+
+	// actionBackup is the action of the Events emitted while taking backups
+	actionBackup = "Backup"
+)
+
+// patchArchiveStatus applies a merge patch to the status of the Archive, so
+// that the fields and the map entries missing from status are left untouched,
+// and those set to nil are removed. isClusterArchive tells that the
+// configuration comes from a ClusterArchive, whose status has no room for the
+// information about its stanzas: the update is skipped with a warning.
+func patchArchiveStatus(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	status map[string]any,
+) error {
+	if isClusterArchive {
+		fields := slices.Sorted(maps.Keys(status))
+		log.FromContext(ctx).Warning(
+			"The status of ClusterArchives is not updated, skipping it",
+			"clusterArchive", archive.Name,
+			"fields", fields)
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"status": status,
+	})
+	if err != nil {
+		return err
+	}
+
+	target := &pgbackrestv1.Archive{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: archive.Namespace,
+			Name:      archive.Name,
+		},
+	}
+	return c.Status().Patch(ctx, target, client.RawPatch(types.MergePatchType, patch))
+}
+
+// MarkWALArchiveBroken records in the status of the Archive that pgBackRest
+// dropped WAL files of the stanza
+func MarkWALArchiveBroken(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	stanza string,
+	clusterName string,
+	droppedWALs []string,
+) error {
+	if len(droppedWALs) == 0 {
+		return nil
+	}
+
+	entry := pgbackrestv1.BrokenWALArchive{
+		Cluster:         clusterName,
+		FirstDroppedWAL: droppedWALs[0],
+		LastDroppedWAL:  droppedWALs[len(droppedWALs)-1],
+		Since:           metav1.Now(),
+	}
+	if previous, ok := archive.Status.BrokenWALArchives[stanza]; ok {
+		entry.FirstDroppedWAL = previous.FirstDroppedWAL
+		entry.Since = previous.Since
+	}
+
+	if err := patchArchiveStatus(ctx, c, archive, isClusterArchive, map[string]any{
+		"brokenWALArchives": map[string]any{
+			stanza: entry,
+		},
+	}); err != nil {
+		return fmt.Errorf("while marking the WAL archive of stanza %s as broken: %w", stanza, err)
+	}
+	return nil
+}
+
+// ClearBrokenWALArchive removes the broken WAL archive entry of the stanza
+// from the status of the Archive, once a full backup made it recoverable again
+func ClearBrokenWALArchive(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	stanza string,
+) error {
+	if _, ok := archive.Status.BrokenWALArchives[stanza]; !ok {
+		return nil
+	}
+
+	if err := patchArchiveStatus(ctx, c, archive, isClusterArchive, map[string]any{
+		"brokenWALArchives": map[string]any{
+			stanza: nil,
+		},
+	}); err != nil {
+		return fmt.Errorf("while clearing the broken WAL archive of stanza %s: %w", stanza, err)
+	}
+	log.FromContext(ctx).Info("WAL archive is recoverable again after a full backup", "stanza", stanza)
+	return nil
+}
+
+// RecordWALDropped emits a Warning Event on the cluster for the WAL files
+// pgBackRest dropped. A nil recorder doesn't emit anything.
+func RecordWALDropped(recorder events.EventRecorder, cluster *cnpgv1.Cluster, stanza string, droppedWALs []string) {
+	if recorder == nil || cluster == nil || len(droppedWALs) == 0 {
+		return
+	}
+
+	recorder.Eventf(cluster, nil, corev1.EventTypeWarning, ReasonWALDropped, actionArchiveWAL,
+		"pgBackRest dropped WAL files %s of stanza %s as the archive queue is full: "+
+			"point in time recovery is not possible until a full backup is taken",
+		strings.Join(droppedWALs, ", "), stanza)
+}
+
+// ChecksumErrorsWarning describes the page checksum errors pgBackRest
+// detected in the files of the backup set, being empty when there are none
+func ChecksumErrorsWarning(backupSet *catalog.PgbackrestBackup) string {
+	switch {
+	case len(backupSet.ErrorList) > 0:
+		return fmt.Sprintf("page checksum errors in %d files: %s",
+			len(backupSet.ErrorList), strings.Join(backupSet.ErrorList, ", "))
+	case backupSet.Error:
+		return "page checksum errors in some files"
+	default:
+		return ""
+	}
+}
+
+// RecordChecksumErrors emits a Warning Event on the cluster for the page
+// checksum errors pgBackRest detected in the backup set. A nil recorder
+// doesn't emit anything.
+func RecordChecksumErrors(
+	recorder events.EventRecorder,
+	cluster *cnpgv1.Cluster,
+	stanza string,
+	backupSet *catalog.PgbackrestBackup,
+) {
+	if recorder == nil || cluster == nil || !backupSet.HasChecksumErrors() {
+		return
+	}
+
+	recorder.Eventf(cluster, nil, corev1.EventTypeWarning, ReasonChecksumErrors, actionBackup,
+		"pgBackRest detected %s in backup %s of stanza %s: the data files of the cluster may be corrupted",
+		ChecksumErrorsWarning(backupSet), backupSet.ID, stanza)
+}
+
+// RecordRestorePoint records a named restore point of the stanza in the status
+// of the Archive, so that it can be used as recovery target. A restore point
+// already recorded with the same name is kept, as PostgreSQL stops the recovery
+// at the first one.
+func RecordRestorePoint(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	stanza string,
+	name string,
+	restorePoint pgbackrestv1.RecordedRestorePoint,
+) error {
+	if _, ok := archive.Status.RestorePoints[stanza][name]; ok {
+		return nil
+	}
+
+	if err := patchArchiveStatus(ctx, c, archive, isClusterArchive, map[string]any{
+		"restorePoints": map[string]any{
+			stanza: map[string]any{
+				name: restorePoint,
+			},
+		},
+	}); err != nil {
+		return fmt.Errorf("while recording restore point %s of stanza %s: %w", name, stanza, err)
+	}
+	return nil
+}
+
+// RecordStanzaStorage records the storage used by the backups of the stanza
+// in the status of the Archive
+func RecordStanzaStorage(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	stanza string,
+	clusterName string,
+	backupCatalog *catalog.Catalog,
+) error {
+	storage := pgbackrestv1.StanzaStorage{
+		Cluster:              clusterName,
+		DatabaseGrowthPerDay: backupCatalog.DatabaseGrowthPerDay(),
+		UpdatedAt:            metav1.Now(),
+	}
+	if latest := backupCatalog.LatestBackupInfo(); latest != nil {
+		storage.DatabaseSize = latest.Info.Size
+		storage.LastBackupDelta = latest.Info.Repository.Delta
+	}
+	for _, usage := range backupCatalog.RepositoryUsage() {
+		storage.Repositories = append(storage.Repositories, pgbackrestv1.RepositoryStorage{
+			Repository: int64(usage.Repository),
+			Backups:    int64(usage.Backups),
+			Size:       usage.Size,
+		})
+	}
+
+	if err := patchArchiveStatus(ctx, c, archive, isClusterArchive, map[string]any{
+		"storage": map[string]any{
+			stanza: storage,
+		},
+	}); err != nil {
+		return fmt.Errorf("while recording the storage of stanza %s: %w", stanza, err)
+	}
+	return nil
+}
+
+// RecordBackupSets records the completed backup sets of the stanza in the
+// status of the Archive, for the operator to synchronize them into Backup
+// objects
+func RecordBackupSets(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	isClusterArchive bool,
+	stanza string,
+	clusterName string,
+	backupCatalog *catalog.Catalog,
+) error {
+	backupSets := pgbackrestv1.StanzaBackupSets{
+		Cluster:  clusterName,
+		ListedAt: metav1.Now(),
+	}
+	for i := range backupCatalog.Backups {
+		if backupCatalog.Backups[i].IsDone() {
+			backupSets.IDs = append(backupSets.IDs, backupCatalog.Backups[i].ID)
+		}
+	}
+
+	if err := patchArchiveStatus(ctx, c, archive, isClusterArchive, map[string]any{
+		"backupSets": map[string]any{
+			stanza: backupSets,
+		},
+	}); err != nil {
+		return fmt.Errorf("while recording the backup sets of stanza %s: %w", stanza, err)
+	}
+	return nil
+}
+
+// NewStanzaVerification builds the verification status of a stanza from the
+// result of pgbackrest verify, or from the error which prevented it from running
+func NewStanzaVerification(result *pgbackrestCommand.VerifyResult, err error) pgbackrestv1.StanzaVerification {
+	verification := pgbackrestv1.StanzaVerification{
+		VerifiedAt: metav1.Now(),
+	}
+	if err != nil {
+		verification.Error = err.Error()
+		return verification
+	}
+
+	verification.Status = result.Status
+	for _, backup := range result.InvalidBackups() {
+		verification.InvalidBackups = append(verification.InvalidBackups, pgbackrestv1.InvalidBackup{
+			Label:         backup.Label,
+			Status:        backup.Status,
+			VerifiedFiles: pgbackrestv1.VerifiedFiles(backup.VerifyFiles),
+		})
+	}
+	for _, archive := range result.InvalidArchives() {
+		verification.InvalidWALArchives = append(verification.InvalidWALArchives, pgbackrestv1.InvalidWALArchive{
+			ArchiveID:     archive.ArchiveID,
+			VerifiedFiles: pgbackrestv1.VerifiedFiles(archive.VerifyFiles),
+		})
+	}
+	return verification
+}
+
+// verifiedCondition builds the Verified condition, listing the stanzas which
+// couldn't be verified and the backup sets and WAL archives which are not valid
+func verifiedCondition(
+	verification map[string]pgbackrestv1.StanzaVerification,
+	generation int64,
+) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               pgbackrestv1.ConditionVerified,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonVerified,
+		Message:            "The backup sets and WAL archives of all the stanzas are valid",
+		ObservedGeneration: generation,
+	}
+
+	stanzas := make([]string, 0, len(verification))
+	for stanza := range verification {
+		stanzas = append(stanzas, stanza)
+	}
+	slices.Sort(stanzas)
+
+	var problems []string
+	for _, stanza := range stanzas {
+		entry := verification[stanza]
+		if len(entry.Error) > 0 {
+			condition.Reason = ReasonVerificationFailed
+			problems = append(problems, fmt.Sprintf("stanza %s: verification failed", stanza))
+			continue
+		}
+
+		var invalid []string
+		for _, backup := range entry.InvalidBackups {
+			invalid = append(invalid, fmt.Sprintf("backup %s (%s)", backup.Label, backup.Status))
+		}
+		for _, archive := range entry.InvalidWALArchives {
+			invalid = append(invalid, fmt.Sprintf("WAL archive %s", archive.ArchiveID))
+		}
+		if len(invalid) == 0 && entry.Status == pgbackrestCommand.VerifyStatusError {
+			invalid = append(invalid, "invalid files")
+		}
+		if len(invalid) > 0 {
+			if condition.Reason == ReasonVerified {
+				condition.Reason = ReasonInvalidFiles
+			}
+			problems = append(problems, fmt.Sprintf("stanza %s: %s", stanza, strings.Join(invalid, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Message = strings.Join(problems, "; ")
+	}
+	return condition
+}
+
+// RecordVerification records the result of the verification of the stanzas in
+// the status of the Archive, replacing the one of the previous verification,
+// and updates its Verified condition
+func RecordVerification(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	verification map[string]pgbackrestv1.StanzaVerification,
+) error {
+	entries := make(map[string]any, len(verification))
+	for stanza := range archive.Status.Verification {
+		// the stanzas which are not verified anymore are removed
+		entries[stanza] = nil
+	}
+	for stanza, entry := range verification {
+		entries[stanza] = entry
+	}
+
+	conditions := slices.Clone(archive.Status.Conditions)
+	meta.SetStatusCondition(&conditions, verifiedCondition(verification, archive.Generation))
+
+	// ClusterArchives are not verified
+	if err := patchArchiveStatus(ctx, c, archive, false, map[string]any{
+		"verification": entries,
+		"conditions":   conditions,
+	}); err != nil {
+		return fmt.Errorf("while recording the verification of the repository: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"errors"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("broken WAL archive status", func() {
+	var (
+		c       client.Client
+		archive *pgbackrestv1.Archive
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+
+		archive = &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+			Status: pgbackrestv1.ArchiveStatus{
+				BrokenWALArchives: map[string]pgbackrestv1.BrokenWALArchive{
+					"other": {Cluster: "other", FirstDroppedWAL: "a", LastDroppedWAL: "b"},
+				},
+			},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(archive).
+			WithStatusSubresource(&pgbackrestv1.Archive{}).
+			Build()
+	})
+
+	getStatus := func(ctx SpecContext) pgbackrestv1.ArchiveStatus {
+		var current pgbackrestv1.Archive
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(archive), &current)).To(Succeed())
+		return current.Status
+	}
+
+	It("marks the stanza as broken, keeping the first dropped WAL", func(ctx SpecContext) {
+		Expect(MarkWALArchiveBroken(ctx, c, archive, false, "stanza", "cluster-example",
+			[]string{"000000010000000000000001", "000000010000000000000002"})).To(Succeed())
+		archive.Status = getStatus(ctx)
+		Expect(archive.Status.BrokenWALArchives).To(HaveKey("other"))
+		Expect(archive.Status.BrokenWALArchives["stanza"].FirstDroppedWAL).To(Equal("000000010000000000000001"))
+
+		Expect(MarkWALArchiveBroken(ctx, c, archive, false, "stanza", "cluster-example",
+			[]string{"000000010000000000000003"})).To(Succeed())
+		broken := getStatus(ctx).BrokenWALArchives["stanza"]
+		Expect(broken.Cluster).To(Equal("cluster-example"))
+		Expect(broken.FirstDroppedWAL).To(Equal("000000010000000000000001"))
+		Expect(broken.LastDroppedWAL).To(Equal("000000010000000000000003"))
+	})
+
+	It("clears only the given stanza", func(ctx SpecContext) {
+		Expect(MarkWALArchiveBroken(ctx, c, archive, false, "stanza", "cluster-example",
+			[]string{"000000010000000000000001"})).To(Succeed())
+		archive.Status = getStatus(ctx)
+
+		Expect(ClearBrokenWALArchive(ctx, c, archive, false, "stanza")).To(Succeed())
+		status := getStatus(ctx)
+		Expect(status.BrokenWALArchives).ToNot(HaveKey("stanza"))
+		Expect(status.BrokenWALArchives).To(HaveKey("other"))
+	})
+
+	It("doesn't update the status of ClusterArchives", func(ctx SpecContext) {
+		Expect(MarkWALArchiveBroken(ctx, c, archive, true, "stanza", "cluster-example",
+			[]string{"000000010000000000000001"})).To(Succeed())
+		Expect(getStatus(ctx).BrokenWALArchives).ToNot(HaveKey("stanza"))
+	})
+})
+
+var _ = Describe("RecordRestorePoint", func() {
+	var (
+		c       client.Client
+		archive *pgbackrestv1.Archive
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+
+		archive = &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+			Status: pgbackrestv1.ArchiveStatus{
+				RestorePoints: map[string]map[string]pgbackrestv1.RecordedRestorePoint{
+					"stanza": {"before-upgrade": {LSN: "0/3000028", Timeline: 1}},
+				},
+			},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(archive).
+			WithStatusSubresource(&pgbackrestv1.Archive{}).
+			Build()
+	})
+
+	getRestorePoints := func(ctx SpecContext) map[string]map[string]pgbackrestv1.RecordedRestorePoint {
+		var current pgbackrestv1.Archive
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(archive), &current)).To(Succeed())
+		return current.Status.RestorePoints
+	}
+
+	It("adds the restore point to the stanza", func(ctx SpecContext) {
+		Expect(RecordRestorePoint(ctx, c, archive, false, "stanza", "after-upgrade",
+			pgbackrestv1.RecordedRestorePoint{LSN: "0/5000060", Timeline: 2})).To(Succeed())
+		restorePoints := getRestorePoints(ctx)
+		Expect(restorePoints["stanza"]).To(HaveKey("before-upgrade"))
+		Expect(restorePoints["stanza"]["after-upgrade"].LSN).To(Equal("0/5000060"))
+		Expect(restorePoints["stanza"]["after-upgrade"].Timeline).To(BeEquivalentTo(2))
+	})
+
+	It("keeps the restore point already recorded with the same name", func(ctx SpecContext) {
+		Expect(RecordRestorePoint(ctx, c, archive, false, "stanza", "before-upgrade",
+			pgbackrestv1.RecordedRestorePoint{LSN: "0/5000060", Timeline: 2})).To(Succeed())
+		Expect(getRestorePoints(ctx)["stanza"]["before-upgrade"].LSN).To(Equal("0/3000028"))
+	})
+
+	It("doesn't update the status of ClusterArchives", func(ctx SpecContext) {
+		Expect(RecordRestorePoint(ctx, c, archive, true, "other", "after-upgrade",
+			pgbackrestv1.RecordedRestorePoint{LSN: "0/5000060", Timeline: 2})).To(Succeed())
+		Expect(getRestorePoints(ctx)).ToNot(HaveKey("other"))
+	})
+})
+
+var _ = Describe("RecordStanzaStorage", func() {
+	It("doesn't update the status of ClusterArchives", func(ctx SpecContext) {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		// the patch of a missing Archive would fail
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		archive := &pgbackrestv1.Archive{ObjectMeta: metav1.ObjectMeta{Name: "shared"}}
+
+		Expect(RecordStanzaStorage(ctx, c, archive, true, "stanza", "cluster-example", &catalog.Catalog{})).
+			To(Succeed())
+	})
+
+	It("records the storage of the stanza", func(ctx SpecContext) {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		archive := &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+		}
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(archive).
+			WithStatusSubresource(&pgbackrestv1.Archive{}).
+			Build()
+
+		backupCatalog := &catalog.Catalog{
+			Backups: []catalog.PgbackrestBackup{
+				{
+					Time:     catalog.PgbackrestBackupTime{Start: 1, Stop: 86400},
+					Database: catalog.PgbackrestBackupDatabase{RepoKey: 1},
+					Info: catalog.PgbackrestBackupInfo{
+						Size:       1000,
+						Repository: catalog.PgbackrestBackupRepositoryInfo{Size: 300, Delta: 300},
+					},
+				},
+				{
+					Time:     catalog.PgbackrestBackupTime{Start: 86400, Stop: 86400 * 2},
+					Database: catalog.PgbackrestBackupDatabase{RepoKey: 1},
+					Info: catalog.PgbackrestBackupInfo{
+						Size:       1200,
+						Repository: catalog.PgbackrestBackupRepositoryInfo{Size: 320, Delta: 20},
+					},
+				},
+			},
+		}
+		Expect(RecordStanzaStorage(ctx, c, archive, false, "stanza", "cluster-example", backupCatalog)).
+			To(Succeed())
+
+		var current pgbackrestv1.Archive
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(archive), &current)).To(Succeed())
+		storage := current.Status.Storage["stanza"]
+		Expect(storage.Cluster).To(Equal("cluster-example"))
+		Expect(storage.DatabaseSize).To(BeEquivalentTo(1200))
+		Expect(storage.DatabaseGrowthPerDay).To(BeEquivalentTo(200))
+		Expect(storage.LastBackupDelta).To(BeEquivalentTo(20))
+		Expect(storage.Repositories).To(Equal([]pgbackrestv1.RepositoryStorage{
+			{Repository: 1, Backups: 2, Size: 320},
+		}))
+	})
+})
+
+var _ = Describe("RecordVerification", func() {
+	var (
+		c       client.Client
+		archive *pgbackrestv1.Archive
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+
+		archive = &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+			Status: pgbackrestv1.ArchiveStatus{
+				Verification: map[string]pgbackrestv1.StanzaVerification{
+					"removed": {Status: pgbackrestCommand.VerifyStatusOk},
+				},
+			},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(archive).
+			WithStatusSubresource(&pgbackrestv1.Archive{}).
+			Build()
+	})
+
+	getStatus := func(ctx SpecContext) pgbackrestv1.ArchiveStatus {
+		var current pgbackrestv1.Archive
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(archive), &current)).To(Succeed())
+		return current.Status
+	}
+
+	It("marks the archive as verified when all the stanzas are valid", func(ctx SpecContext) {
+		Expect(RecordVerification(ctx, c, archive, map[string]pgbackrestv1.StanzaVerification{
+			"main": NewStanzaVerification(&pgbackrestCommand.VerifyResult{
+				Stanza: "main",
+				Status: pgbackrestCommand.VerifyStatusOk,
+			}, nil),
+		})).To(Succeed())
+
+		status := getStatus(ctx)
+		Expect(status.Verification).To(HaveKey("main"))
+		Expect(status.Verification).ToNot(HaveKey("removed"))
+		Expect(status.Conditions).To(HaveLen(1))
+		Expect(status.Conditions[0].Type).To(Equal(pgbackrestv1.ConditionVerified))
+		Expect(status.Conditions[0].Status).To(Equal(metav1.ConditionTrue))
+		Expect(status.Conditions[0].Reason).To(Equal(ReasonVerified))
+	})
+
+	It("lists the invalid sets and the stanzas which couldn't be verified", func(ctx SpecContext) {
+		invalid := &pgbackrestCommand.VerifyResult{
+			Stanza: "main",
+			Status: pgbackrestCommand.VerifyStatusError,
+			Archives: []pgbackrestCommand.VerifyArchive{
+				{ArchiveID: "17-1", VerifyFiles: pgbackrestCommand.VerifyFiles{Checked: 2, Valid: 1, Missing: 1}},
+			},
+			Backups: []pgbackrestCommand.VerifyBackup{
+				{Label: "20250101-010000F", Status: "valid"},
+				{Label: "20250102-010000F", Status: "invalid"},
+			},
+		}
+		Expect(RecordVerification(ctx, c, archive, map[string]pgbackrestv1.StanzaVerification{
+			"main":  NewStanzaVerification(invalid, nil),
+			"other": NewStanzaVerification(nil, errors.New("unable to find the stanza")),
+		})).To(Succeed())
+
+		status := getStatus(ctx)
+		Expect(status.Verification["main"].InvalidBackups).To(HaveLen(1))
+		Expect(status.Verification["main"].InvalidWALArchives).To(Equal([]pgbackrestv1.InvalidWALArchive{
+			{ArchiveID: "17-1", VerifiedFiles: pgbackrestv1.VerifiedFiles{Checked: 2, Valid: 1, Missing: 1}},
+		}))
+		Expect(status.Verification["other"].Error).To(Equal("unable to find the stanza"))
+		Expect(status.Conditions).To(HaveLen(1))
+		Expect(status.Conditions[0].Status).To(Equal(metav1.ConditionFalse))
+		Expect(status.Conditions[0].Reason).To(Equal(ReasonVerificationFailed))
+		Expect(status.Conditions[0].Message).To(Equal(
+			"stanza main: backup 20250102-010000F (invalid), WAL archive 17-1; stanza other: verification failed"))
+	})
+})
+
+var _ = Describe("RecordWALDropped", func() {
+	It("emits a Warning Event on the cluster", func() {
+		recorder := events.NewFakeRecorder(1)
+		RecordWALDropped(recorder, &cnpgv1.Cluster{}, "stanza", []string{"000000010000000000000001"})
+		Expect(recorder.Events).To(Receive(And(
+			HavePrefix("Warning "+ReasonWALDropped),
+			ContainSubstring("000000010000000000000001"),
+		)))
+	})
+
+	It("ignores a nil recorder", func() {
+		RecordWALDropped(nil, &cnpgv1.Cluster{}, "stanza", []string{"000000010000000000000001"})
+	})
+})
+
+var _ = Describe("RecordChecksumErrors", func() {
+	It("emits a Warning Event on the cluster listing the files", func() {
+		recorder := events.NewFakeRecorder(1)
+		RecordChecksumErrors(recorder, &cnpgv1.Cluster{}, "stanza", &catalog.PgbackrestBackup{
+			ID:        "20250331-142029F",
+			Error:     true,
+			ErrorList: []string{"base/5/16384"},
+		})
+		Expect(recorder.Events).To(Receive(And(
+			HavePrefix("Warning "+ReasonChecksumErrors),
+			ContainSubstring("page checksum errors in 1 files: base/5/16384"),
+			ContainSubstring("20250331-142029F"),
+		)))
+	})
+
+	It("doesn't emit anything for a valid backup set", func() {
+		recorder := events.NewFakeRecorder(1)
+		RecordChecksumErrors(recorder, &cnpgv1.Cluster{}, "stanza", &catalog.PgbackrestBackup{ID: "20250331-142029F"})
+		Expect(recorder.Events).ToNot(Receive())
+	})
+})
+
+var _ = Describe("ChecksumErrorsWarning", func() {
+	It("is empty without page checksum errors", func() {
+		Expect(ChecksumErrorsWarning(&catalog.PgbackrestBackup{})).To(BeEmpty())
+	})
+
+	It("describes the errors when pgBackRest doesn't list the files", func() {
+		Expect(ChecksumErrorsWarning(&catalog.PgbackrestBackup{Error: true})).To(
+			Equal("page checksum errors in some files"))
+	})
+})
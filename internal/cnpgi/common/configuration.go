@@ -0,0 +1,90 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/configfile"
+	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+)
+
+// PgbackrestConfigurationPath is where the sidecar keeps the rendered pgbackrest.conf.
+// It doesn't contain the secure options, which are only written to the
+// configuration files of each operation.
+const PgbackrestConfigurationPath = pgbackrestCredentials.SecretsConfigDirectory + "/pgbackrest.conf"
+
+// ErrNoArchive is returned when rendering the configuration of a cluster that
+// doesn't archive to any Archive
+var ErrNoArchive = errors.New("the cluster doesn't archive to any Archive")
+
+// RenderPgbackrestConfiguration renders the pgbackrest.conf matching the options the
+// plugin passes to its commands when archiving and backing up the given cluster.
+// The secure options, e.g. the S3 keys, are only included when withSecrets is set.
+// The stanzas of the recovery and replica source archives are not rendered: the
+// options of their repositories would clash with those of the global section,
+// which are numbered the same way.
+func RenderPgbackrestConfiguration(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	pgDataPath string,
+	withSecrets bool,
+) (string, error) {
+	configuration := config.NewFromCluster(cluster)
+	if len(configuration.PgbackrestObjectName) == 0 {
+		return "", ErrNoArchive
+	}
+
+	archive, err := config.GetArchive(ctx, c, configuration.GetArchiveObjectKey(), cluster.Namespace)
+	if err != nil {
+		return "", err
+	}
+	pgbackrestConfiguration := &archive.Spec.Configuration
+
+	globalOptions, stanzaOptions, err := pgbackrestCommand.ConfigurationOptions(
+		ctx, pgbackrestConfiguration, pgDataPath)
+	if err != nil {
+		return "", fmt.Errorf("while building pgbackrest options: %w", err)
+	}
+
+	env, secureOptions, err := pgbackrestCredentials.GetBackupCloudCredentials(
+		ctx, c, archive.Namespace, pgbackrestConfiguration)
+	if err != nil {
+		return "", fmt.Errorf("while getting backup credentials: %w", err)
+	}
+
+	global := configfile.FromOptions(globalOptions)
+	global = append(global, configfile.FromEnv(env)...)
+	if withSecrets {
+		global = append(global, secureOptions...)
+	}
+
+	file := configfile.File{
+		Stanza:        pgbackrestConfiguration.GetStanza(configuration.Stanza),
+		Global:        global,
+		StanzaOptions: configfile.FromOptions(stanzaOptions),
+	}
+	return file.Render(), nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package common
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RenderPgbackrestConfiguration", func() {
+	var (
+		c       client.Client
+		cluster *cnpgv1.Cluster
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+
+		secretKey := func(key string) *machineryapi.SecretKeySelector {
+			return &machineryapi.SecretKeySelector{
+				LocalObjectReference: machineryapi.LocalObjectReference{Name: "minio"},
+				Key:                  key,
+			}
+		}
+		archive := &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+			Spec: pgbackrestv1.ArchiveSpec{
+				Configuration: pgbackrestApi.PgbackrestConfiguration{
+					Repositories: []pgbackrestApi.PgbackrestRepository{{
+						PgbackrestCredentials: pgbackrestApi.PgbackrestCredentials{
+							AWS: &pgbackrestApi.S3Credentials{
+								KeyType:                  pgbackrestApi.KeyTypeShared,
+								AccessKeyIDReference:     secretKey("ACCESS_KEY_ID"),
+								SecretAccessKeyReference: secretKey("ACCESS_SECRET_KEY"),
+								Region:                   "eu-west-1",
+							},
+						},
+						Bucket:          "backups",
+						DestinationPath: "/repo",
+					}},
+				},
+			},
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "minio"},
+			Data: map[string][]byte{
+				"ACCESS_KEY_ID":     []byte("key-id"),
+				"ACCESS_SECRET_KEY": []byte("key-secret"),
+			},
+		}
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(archive, secret).Build()
+
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster-example"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{{
+					Name:       metadata.PluginName,
+					Parameters: map[string]string{"pgbackrestObjectName": "archive"},
+				}},
+			},
+		}
+	})
+
+	It("leaves the secure options out of the shared file", func(ctx SpecContext) {
+		rendered, err := RenderPgbackrestConfiguration(ctx, c, cluster, "/var/lib/postgresql/data/pgdata", false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(ContainSubstring("[cluster-example]\n"))
+		Expect(rendered).To(ContainSubstring("repo1-s3-bucket=backups\n"))
+		Expect(rendered).ToNot(ContainSubstring("key-id"))
+		Expect(rendered).ToNot(ContainSubstring("key-secret"))
+	})
+
+	It("adds the secure options when asked to", func(ctx SpecContext) {
+		rendered, err := RenderPgbackrestConfiguration(ctx, c, cluster, "/var/lib/postgresql/data/pgdata", true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(ContainSubstring("repo1-s3-key=key-id\n"))
+		Expect(rendered).To(ContainSubstring("repo1-s3-key-secret=key-secret\n"))
+	})
+
+	It("fails for clusters not archiving to any Archive", func(ctx SpecContext) {
+		cluster.Spec.Plugins = nil
+		_, err := RenderPgbackrestConfiguration(ctx, c, cluster, "/var/lib/postgresql/data/pgdata", false)
+		Expect(err).To(MatchError(ErrNoArchive))
+	})
+})
@@ -29,6 +29,7 @@ import (
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
@@ -38,7 +39,10 @@ import (
 	pgbackrestBackup "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/backup"
 	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
 	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 	pgbackrestRestorer "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/restorer"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
 )
 
@@ -50,6 +54,24 @@ type WALServiceImplementation struct {
 	SpoolDirectory string
 	PGDataPath     string
 	PGWALPath      string
+
+	// Limiter limits the number of pgBackRest processes the sidecar runs at once
+	Limiter *limiter.Limiter
+
+	// InFlight lets overlapping batches wait for the WAL files already being
+	// archived or restored instead of running pgBackRest again
+	InFlight *inflight.Registry
+
+	// SpoolJanitor evicts the stale entries of the spool and keeps it within
+	// its quota, nil meaning that the spool isn't cleaned
+	SpoolJanitor *spool.Janitor
+
+	// ArchiveParallelism chooses the number of WAL files archived in parallel,
+	// nil meaning the configured maxParallel
+	ArchiveParallelism *archiver.Parallelism
+
+	// Recorder emits the Events about the WAL archive, nil meaning no Event
+	Recorder events.EventRecorder
 }
 
 // GetCapabilities implements the WALService interface
@@ -97,12 +119,14 @@ func (w WALServiceImplementation) Archive(
 		return nil, err
 	}
 
-	var archive pgbackrestv1.Archive
-	if err := w.Client.Get(ctx, configuration.GetArchiveObjectKey(), &archive); err != nil {
+	archive, err := config.GetArchive(
+		ctx, w.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
 		return nil, err
 	}
+	stanza := archive.Spec.Configuration.GetStanza(configuration.Stanza)
 
-	envArchive, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
+	envArchive, removeConfig, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
 		ctx,
 		w.Client,
 		archive.Namespace,
@@ -114,6 +138,7 @@ func (w WALServiceImplementation) Archive(
 		}
 		return nil, err
 	}
+	defer removeConfig()
 
 	arch, err := archiver.New(
 		ctx,
@@ -121,6 +146,9 @@ func (w WALServiceImplementation) Archive(
 		w.SpoolDirectory,
 		w.PGDataPath,
 		path.Join(w.PGDataPath, metadata.CheckEmptyWalArchiveFile),
+		archive.Spec.Configuration.GetCommandTimeout(pgbackrestCommand.CommandArchivePush),
+		w.Limiter,
+		w.InFlight,
 	)
 	if err != nil {
 		return nil, err
@@ -138,7 +166,7 @@ func (w WALServiceImplementation) Archive(
 	}
 
 	// Check that the destination repository is reachable and its stanza exists.
-	err = arch.CheckWalArchiveDestination(ctx, &archive.Spec.Configuration, configuration.Stanza, envArchive)
+	err = arch.CheckWalArchiveDestination(ctx, &archive.Spec.Configuration, stanza, envArchive)
 	switch {
 	case errors.Is(err, archiver.ErrStanzaMissing):
 		// On a fresh cluster, or after a major upgrade changes the repository path, the
@@ -150,14 +178,14 @@ func (w WALServiceImplementation) Archive(
 		// does not contend with a running backup for the stanza lock.
 		if archive.Spec.Configuration.ShouldCreateStanzaOnArchive() {
 			backupCmd := pgbackrestBackup.NewBackupCommand(&archive.Spec.Configuration, nil, w.PGDataPath)
-			if stanzaErr := backupCmd.CreatePgbackrestStanza(ctx, configuration.Stanza, envArchive); stanzaErr != nil {
+			if stanzaErr := backupCmd.CreatePgbackrestStanza(ctx, stanza, envArchive); stanzaErr != nil {
 				// Best-effort: log and continue. archive-push below reports the real
 				// outcome, and PostgreSQL retries the WAL if the stanza is still missing.
 				contextLogger.Warning("could not auto-create pgbackrest stanza; WAL archiving will retry",
-					"stanza", configuration.Stanza, "err", stanzaErr.Error())
+					"stanza", stanza, "err", stanzaErr.Error())
 			} else {
 				contextLogger.Info("created pgbackrest stanza so WAL archiving can start",
-					"stanza", configuration.Stanza)
+					"stanza", stanza)
 			}
 		}
 	case err != nil:
@@ -165,15 +193,16 @@ func (w WALServiceImplementation) Archive(
 		return nil, err
 	}
 
-	options, err := arch.PgbackrestWalArchiveOptions(ctx, &archive.Spec.Configuration, configuration.Stanza)
+	options, err := arch.PgbackrestWalArchiveOptions(ctx, &archive.Spec.Configuration, stanza)
 	if err != nil {
 		return nil, err
 	}
 
-	maxParallel := 1
-	if archive.Spec.Configuration.Wal != nil && archive.Spec.Configuration.Wal.MaxParallel > 1 {
-		maxParallel = archive.Spec.Configuration.Wal.MaxParallel
+	backlog, err := arch.CountWALFilesToArchive()
+	if err != nil {
+		contextLogger.Error(err, "while counting the WAL files to archive")
 	}
+	maxParallel := w.ArchiveParallelism.Next(archive.Spec.Configuration.Wal, backlog)
 
 	walList := arch.GatherWALFilesToArchive(ctx, request.GetSourceFileName(), maxParallel)
 
@@ -181,17 +210,43 @@ func (w WALServiceImplementation) Archive(
 	contextLogger.Info("WAL archive batch prepared",
 		"requestedWalFile", request.GetSourceFileName(),
 		"maxParallel", maxParallel,
+		"backlog", backlog,
 		"walFiles", walList)
 
 	result := arch.ArchiveList(ctx, walList, options)
+	w.ArchiveParallelism.Observe(result)
+	if result[0].Err == nil && result[0].Shared {
+		// The requested WAL was archived by a batch which marked it in the spool
+		if _, err := arch.DeleteFromSpool(request.GetSourceFileName()); err != nil {
+			return nil, err
+		}
+	}
 	successfulArchives := 0
 	var lastErr error
+	var droppedWALs []string
 	for _, archiverResult := range result {
 		if archiverResult.Err == nil {
 			successfulArchives++
 		} else {
 			lastErr = archiverResult.Err
 		}
+		if archiverResult.Dropped {
+			droppedWALs = append(droppedWALs, archiverResult.WalName)
+		}
+	}
+
+	if len(droppedWALs) > 0 {
+		// PostgreSQL considers the dropped WAL files archived, so the failure to
+		// record them doesn't fail the request, which would only drop them again
+		contextLogger.Warning("pgBackRest dropped WAL files as the archive queue is full",
+			"stanza", stanza,
+			"droppedWALs", droppedWALs)
+		RecordWALDropped(w.Recorder, configuration.Cluster, stanza, droppedWALs)
+		isClusterArchive := configuration.GetArchiveObjectKey().Namespace == ""
+		if err := MarkWALArchiveBroken(ctx, w.Client, archive, isClusterArchive,
+			stanza, configuration.Cluster.Name, droppedWALs); err != nil {
+			contextLogger.Error(err, "while reporting the dropped WAL files")
+		}
 	}
 
 	contextLogger.Info("WAL archive batch completed",
@@ -256,10 +311,11 @@ func (w WALServiceImplementation) Restore(
 		archiveKey = configuration.GetArchiveObjectKey()
 	}
 
-	var archive pgbackrestv1.Archive
-	if err := w.Client.Get(ctx, archiveKey, &archive); err != nil {
+	archive, err := config.GetArchive(ctx, w.Client, archiveKey, configuration.Cluster.Namespace)
+	if err != nil {
 		return nil, err
 	}
+	stanza = archive.Spec.Configuration.GetStanza(stanza)
 
 	contextLogger.Info(
 		"Restoring WAL file",
@@ -267,7 +323,7 @@ func (w WALServiceImplementation) Restore(
 		"stanza", stanza,
 		"walName", walName)
 	return &wal.WALRestoreResult{}, w.restoreFromPgbackrestArchive(
-		ctx, configuration.Cluster, &archive, stanza, walName, destinationPath, controlledPromotion)
+		ctx, configuration.Cluster, archive, stanza, walName, destinationPath, controlledPromotion)
 }
 
 func (w WALServiceImplementation) restoreFromPgbackrestArchive(
@@ -284,7 +340,7 @@ func (w WALServiceImplementation) restoreFromPgbackrestArchive(
 
 	pgbackrestConfiguration := &archive.Spec.Configuration
 
-	env, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(
+	env, removeConfig, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(
 		ctx,
 		w.Client,
 		archive.Namespace,
@@ -294,6 +350,7 @@ func (w WALServiceImplementation) restoreFromPgbackrestArchive(
 	if err != nil {
 		return fmt.Errorf("while getting recover credentials: %w", err)
 	}
+	defer removeConfig()
 
 	options, err := pgbackrestCommand.CloudWalRestoreOptions(ctx, pgbackrestConfiguration, stanza, w.PGDataPath)
 	if err != nil {
@@ -302,10 +359,23 @@ func (w WALServiceImplementation) restoreFromPgbackrestArchive(
 
 	// Create the restorer
 	var walRestorer *pgbackrestRestorer.WALRestorer
-	if walRestorer, err = pgbackrestRestorer.NewWALRestorer(ctx, env, w.SpoolDirectory); err != nil {
+	walRestorer, err = pgbackrestRestorer.NewWALRestorer(
+		ctx,
+		env,
+		w.SpoolDirectory,
+		pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchiveGet),
+		w.Limiter,
+		w.InFlight,
+		w.SpoolJanitor,
+	)
+	if err != nil {
 		return fmt.Errorf("while creating the restorer: %w", err)
 	}
 
+	// PostgreSQL doesn't need the WAL files before the requested one anymore
+	w.SpoolJanitor.Replayed(walName)
+	w.SpoolJanitor.SetCommandTimeout(pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchiveGet))
+
 	// Step 1: check if this WAL file is not already in the spool
 	var wasInSpool bool
 	if wasInSpool, err = walRestorer.RestoreFromSpool(walName, destinationPath); err != nil {
@@ -333,7 +403,9 @@ func (w WALServiceImplementation) restoreFromPgbackrestArchive(
 	}
 	if IsWALFile(walName) {
 		// If this is a regular WAL file, we try to prefetch
-		if walFilesList, err = gatherWALFilesToRestore(walName, maxParallel, controlledPromotion); err != nil {
+		walSegmentSize := GetWALSegmentSize(ctx, cluster, w.PGDataPath)
+		walFilesList, err = gatherWALFilesToRestore(walName, maxParallel, walSegmentSize, controlledPromotion)
+		if err != nil {
 			return fmt.Errorf("while generating the list of WAL files to restore: %w", err)
 		}
 	} else {
@@ -379,7 +451,7 @@ func (w WALServiceImplementation) restoreFromPgbackrestArchive(
 		"walName", walName,
 		"maxParallel", maxParallel,
 		"successfulWalRestore", successfulWalRestore,
-		"failedWalRestore", maxParallel-successfulWalRestore,
+		"failedWalRestore", len(walStatus)-successfulWalRestore,
 		"startTime", startTime,
 		"downloadStartTime", downloadStartTime,
 		"downloadTotalTime", time.Since(downloadStartTime),
@@ -401,12 +473,13 @@ func (w WALServiceImplementation) Status(
 		return nil, err
 	}
 
-	var archive pgbackrestv1.Archive
-	if err := w.Client.Get(ctx, configuration.GetArchiveObjectKey(), &archive); err != nil {
+	archive, err := config.GetArchive(
+		ctx, w.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
 		return nil, err
 	}
 
-	env, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
+	env, removeConfig, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
 		ctx,
 		w.Client,
 		archive.Namespace,
@@ -418,8 +491,10 @@ func (w WALServiceImplementation) Status(
 		}
 		return nil, err
 	}
+	defer removeConfig()
 
-	backupCatalog, err := pgbackrestCommand.GetBackupList(ctx, &archive.Spec.Configuration, configuration.Stanza, env)
+	backupCatalog, err := pgbackrestCommand.GetBackupList(
+		ctx, &archive.Spec.Configuration, archive.Spec.Configuration.GetStanza(configuration.Stanza), env)
 	if err != nil {
 		return nil, err
 	}
@@ -479,7 +554,12 @@ func isStreamingAvailable(cluster *cnpgv1.Cluster, podName string) bool {
 
 // gatherWALFilesToRestore files a list of possible WAL files to restore, always
 // including as the first one the requested WAL file.
-func gatherWALFilesToRestore(walName string, parallel int, controlledPromotion bool) (walList []string, err error) {
+func gatherWALFilesToRestore(
+	walName string,
+	parallel int,
+	walSegmentSize int64,
+	controlledPromotion bool,
+) (walList []string, err error) {
 	var segment Segment
 
 	segment, err = SegmentFromName(walName)
@@ -490,9 +570,9 @@ func gatherWALFilesToRestore(walName string, parallel int, controlledPromotion b
 		// Let's just avoid prefetching in this case
 		return []string{walName}, nil
 	}
-	// NextSegments would accept postgresVersion and segmentSize,
-	// but we do not have this info here, so we pass nil.
-	segmentList := segment.NextSegments(parallel, nil, nil)
+	// The supported PostgreSQL versions all use the last segment of each
+	// log file, so only the segment size matters
+	segmentList := segment.NextSegments(parallel, nil, &walSegmentSize)
 	walList = make([]string, len(segmentList))
 	for idx := range segmentList {
 		walList[idx] = segmentList[idx].Name()
@@ -0,0 +1,100 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+const (
+	// pgControlFloatFormat is the value PostgreSQL stores in pg_control to
+	// check the float format. It is followed by the block size, the relation
+	// segment size, the WAL block size and the WAL segment size.
+	pgControlFloatFormat = 1234567.0
+
+	// pgControlWALSegmentSizeOffset is the offset of the WAL segment size from
+	// the float format
+	pgControlWALSegmentSizeOffset = 8 + 3*4
+
+	// minWALSegmentSize and maxWALSegmentSize are the bounds of --wal-segsize
+	minWALSegmentSize = int64(1 << 20)
+	maxWALSegmentSize = int64(1 << 30)
+)
+
+// ErrWALSegmentSizeNotFound is returned when pg_control doesn't contain a
+// valid WAL segment size
+var ErrWALSegmentSizeNotFound = errors.New("WAL segment size not found in pg_control")
+
+// isValidWALSegmentSize checks that the size is a power of 2 accepted by initdb
+func isValidWALSegmentSize(size int64) bool {
+	return size >= minWALSegmentSize && size <= maxWALSegmentSize && size&(size-1) == 0
+}
+
+// ReadWALSegmentSize reads the WAL segment size from the pg_control file of
+// the given data directory, as pg_controldata would do
+func ReadWALSegmentSize(pgDataPath string) (int64, error) {
+	// G304: the path is built from the data directory of the instance
+	//nolint:gosec
+	content, err := os.ReadFile(path.Join(pgDataPath, "global", "pg_control"))
+	if err != nil {
+		return 0, err
+	}
+
+	floatFormat := math.Float64bits(pgControlFloatFormat)
+	for offset := 0; offset+pgControlWALSegmentSizeOffset+4 <= len(content); offset += 8 {
+		if binary.NativeEndian.Uint64(content[offset:]) != floatFormat {
+			continue
+		}
+
+		size := int64(binary.NativeEndian.Uint32(content[offset+pgControlWALSegmentSizeOffset:]))
+		if !isValidWALSegmentSize(size) {
+			return 0, fmt.Errorf("%w: invalid size %d", ErrWALSegmentSizeNotFound, size)
+		}
+		return size, nil
+	}
+
+	return 0, ErrWALSegmentSizeNotFound
+}
+
+// GetWALSegmentSize returns the WAL segment size of the instance, from the
+// initdb configuration of the cluster or, when the cluster was not created by
+// initdb, from pg_control. It falls back to the default size.
+func GetWALSegmentSize(ctx context.Context, cluster *cnpgv1.Cluster, pgDataPath string) int64 {
+	if cluster != nil && cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.InitDB != nil &&
+		cluster.Spec.Bootstrap.InitDB.WalSegmentSize != 0 {
+		return int64(cluster.Spec.Bootstrap.InitDB.WalSegmentSize) << 20
+	}
+
+	size, err := ReadWALSegmentSize(pgDataPath)
+	if err != nil {
+		log.FromContext(ctx).Warning("Cannot read the WAL segment size, assuming the default one",
+			"pgDataPath", pgDataPath,
+			"defaultWALSegmentSize", DefaultWALSegmentSize,
+			"error", err.Error())
+		return DefaultWALSegmentSize
+	}
+	return size
+}
@@ -0,0 +1,99 @@
+/*
+Copyright The CloudNativePG Contributors
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL segment size", func() {
+	// writePgControl writes a pg_control file with the given WAL segment size
+	writePgControl := func(pgData string, walSegmentSize uint32) {
+		content := make([]byte, 8192)
+		// the settings follow the checkpoint, whose size depends on the version
+		offset := 232
+		binary.NativeEndian.PutUint64(content[offset:], math.Float64bits(pgControlFloatFormat))
+		binary.NativeEndian.PutUint32(content[offset+8:], 8192)
+		binary.NativeEndian.PutUint32(content[offset+12:], 131072)
+		binary.NativeEndian.PutUint32(content[offset+16:], 8192)
+		binary.NativeEndian.PutUint32(content[offset+20:], walSegmentSize)
+
+		Expect(os.MkdirAll(filepath.Join(pgData, "global"), 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(pgData, "global", "pg_control"), content, 0o600)).To(Succeed())
+	}
+
+	It("reads the WAL segment size from pg_control", func() {
+		pgData := GinkgoT().TempDir()
+		writePgControl(pgData, 64<<20)
+		Expect(ReadWALSegmentSize(pgData)).To(Equal(int64(64 << 20)))
+	})
+
+	It("rejects invalid WAL segment sizes", func() {
+		pgData := GinkgoT().TempDir()
+		writePgControl(pgData, 12345)
+		_, err := ReadWALSegmentSize(pgData)
+		Expect(err).To(MatchError(ErrWALSegmentSizeNotFound))
+	})
+
+	It("prefers the initdb configuration of the cluster", func(ctx SpecContext) {
+		pgData := GinkgoT().TempDir()
+		writePgControl(pgData, 64<<20)
+		cluster := &cnpgv1.Cluster{
+			Spec: cnpgv1.ClusterSpec{
+				Bootstrap: &cnpgv1.BootstrapConfiguration{
+					InitDB: &cnpgv1.BootstrapInitDB{WalSegmentSize: 32},
+				},
+			},
+		}
+		Expect(GetWALSegmentSize(ctx, cluster, pgData)).To(Equal(int64(32 << 20)))
+		Expect(GetWALSegmentSize(ctx, &cnpgv1.Cluster{}, pgData)).To(Equal(int64(64 << 20)))
+	})
+
+	It("falls back to the default WAL segment size", func(ctx SpecContext) {
+		Expect(GetWALSegmentSize(ctx, nil, GinkgoT().TempDir())).To(Equal(DefaultWALSegmentSize))
+	})
+})
+
+var _ = Describe("gatherWALFilesToRestore", func() {
+	It("moves to the next log file according to the WAL segment size", func() {
+		walList, err := gatherWALFilesToRestore("00000001000000010000003E", 4, 64<<20, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(walList).To(Equal([]string{
+			"00000001000000010000003E",
+			"00000001000000010000003F",
+			"000000010000000200000000",
+			"000000010000000200000001",
+		}))
+
+		walList, err = gatherWALFilesToRestore("0000000100000001000000FE", 3, DefaultWALSegmentSize, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(walList).To(Equal([]string{
+			"0000000100000001000000FE",
+			"0000000100000001000000FF",
+			"000000010000000200000000",
+		}))
+	})
+})
@@ -20,31 +20,54 @@ package instance
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/postgres"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
 	"github.com/cloudnative-pg/cnpg-i/pkg/backup"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	pgTime "github.com/cloudnative-pg/machinery/pkg/postgres/time"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
 	pgbackrestBackup "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/backup"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
 	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
 )
 
+const (
+	// fullBackupType is the type pgBackRest reports for full backups
+	fullBackupType = "full"
+
+	// checksumErrorFilesUnknown is the number of files with page checksum
+	// errors of a backup set whose files pgBackRest didn't list
+	checksumErrorFilesUnknown = -1
+)
+
 // BackupServiceImplementation is the implementation
 // of the Backup CNPG capability
 type BackupServiceImplementation struct {
 	Client       client.Client
 	InstanceName string
 	PGDataPath   string
+	// Limiter limits the number of pgBackRest processes the sidecar runs at once
+	Limiter *limiter.Limiter
+	// Recorder emits the Events about the backups
+	Recorder events.EventRecorder
+	// ChecksumErrors is the number of files with page checksum errors in the
+	// last backup taken by the instance, checksumErrorFilesUnknown when
+	// pgBackRest didn't list them
+	ChecksumErrors *atomic.Int64
 	backup.UnimplementedBackupServer
 }
 
@@ -85,11 +108,13 @@ func (b BackupServiceImplementation) Backup(
 		return nil, err
 	}
 
-	var archive pgbackrestv1.Archive
-	if err := b.Client.Get(ctx, configuration.GetArchiveObjectKey(), &archive); err != nil {
-		contextLogger.Error(err, "while getting archive", "key", configuration.GetRecoveryArchiveObjectKey())
+	archive, err := config.GetArchive(
+		ctx, b.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
+		contextLogger.Error(err, "while getting archive", "key", configuration.GetArchiveObjectKey())
 		return nil, err
 	}
+	stanza := archive.Spec.Configuration.GetStanza(configuration.Stanza)
 
 	if err := fileutils.EnsureDirectoryExists(postgres.BackupTemporaryDirectory); err != nil {
 		contextLogger.Error(err, "Cannot create backup temporary directory", "err", err)
@@ -105,7 +130,7 @@ func (b BackupServiceImplementation) Backup(
 	// We need to connect to PostgreSQL and to do that we need
 	// PGHOST (and the like) to be available
 	osEnvironment := utils.SanitizedEnviron()
-	env, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
+	env, removeConfig, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
 		ctx,
 		b.Client,
 		archive.Namespace,
@@ -115,11 +140,40 @@ func (b BackupServiceImplementation) Backup(
 		contextLogger.Error(err, "while setting backup cloud credentials")
 		return nil, err
 	}
+	defer removeConfig()
+
+	// The Backup objects created for the sets found in the repository adopt
+	// their set instead of taking a new backup
+	if backupID := backupConfig.Annotations[metadata.BackupIDAnnotationName]; backupID != "" {
+		contextLogger.Info("Adopting backup set", "backupID", backupID)
+		backupCatalog, err := pgbackrestCommand.GetBackupList(ctx, &archive.Spec.Configuration, stanza, env)
+		if err != nil {
+			return nil, err
+		}
+		backupSet, err := backupCatalog.FindBackupFromID(backupID)
+		if err != nil {
+			return nil, err
+		}
+		b.warnChecksumErrors(ctx, configuration.Cluster, stanza, backupSet)
+		return b.backupResult(backupSet, stanza), nil
+	}
+
+	// A backup runs as many processes as its jobs, and gives way to the WAL
+	// files archived and restored for PostgreSQL
+	processes := 1
+	if data := archive.Spec.Configuration.Data; data != nil && data.Jobs != nil {
+		processes = int(*data.Jobs)
+	}
+	release, err := b.Limiter.Acquire(ctx, limiter.PriorityBackup, processes)
+	if err != nil {
+		return nil, fmt.Errorf("while waiting to start the backup: %w", err)
+	}
+	defer release()
 
 	// Create the stanza unless the Archive disables it (createStanza=Disabled), in which
 	// case it is expected to be managed out of band.
 	if archive.Spec.Configuration.ShouldCreateStanzaOnBackup() {
-		if err = backupCmd.CreatePgbackrestStanza(ctx, configuration.Stanza, env); err != nil {
+		if err = backupCmd.CreatePgbackrestStanza(ctx, stanza, env); err != nil {
 			contextLogger.Error(err, "while initializing pgbackrest stanza")
 			return nil, err
 		}
@@ -130,7 +184,7 @@ func (b BackupServiceImplementation) Backup(
 	if err = backupCmd.Take(
 		ctx,
 		backupName,
-		configuration.Stanza,
+		stanza,
 		env,
 		postgres.BackupTemporaryDirectory,
 	); err != nil {
@@ -141,7 +195,7 @@ func (b BackupServiceImplementation) Backup(
 	executedBackupInfo, err := backupCmd.GetExecutedBackupInfo(
 		ctx,
 		backupName,
-		configuration.Stanza,
+		stanza,
 		env)
 	if err != nil {
 		contextLogger.Error(err, "while getting executed backup info")
@@ -149,21 +203,102 @@ func (b BackupServiceImplementation) Backup(
 	}
 
 	contextLogger.Info("Backup completed", "backup", executedBackupInfo.Backups[0].ID)
-	return &backup.BackupResult{
-		BackupId:   executedBackupInfo.Backups[0].ID,
-		BackupName: executedBackupInfo.Backups[0].Annotations[catalog.BackupNameAnnotation],
-		StartedAt:  executedBackupInfo.Backups[0].Time.Start,
-		StoppedAt:  executedBackupInfo.Backups[0].Time.Stop,
-		BeginWal:   executedBackupInfo.Backups[0].WAL.Start,
-		EndWal:     executedBackupInfo.Backups[0].WAL.Stop,
-		BeginLsn:   executedBackupInfo.Backups[0].LSN.Start,
-		EndLsn:     executedBackupInfo.Backups[0].LSN.Stop,
+
+	b.warnChecksumErrors(ctx, configuration.Cluster, stanza, &executedBackupInfo.Backups[0])
+	if b.ChecksumErrors != nil {
+		b.ChecksumErrors.Store(checksumErrorFiles(&executedBackupInfo.Backups[0]))
+	}
+
+	// Only a full backup is recoverable without the WAL files pgBackRest dropped
+	if _, broken := archive.Status.BrokenWALArchives[stanza]; broken {
+		if executedBackupInfo.Backups[0].Type != fullBackupType {
+			contextLogger.Warning("The WAL archive is broken, a full backup is needed to make it recoverable again",
+				"stanza", stanza,
+				"backupType", executedBackupInfo.Backups[0].Type)
+		} else if err := common.ClearBrokenWALArchive(ctx, b.Client, archive,
+			configuration.GetArchiveObjectKey().Namespace == "", stanza); err != nil {
+			contextLogger.Error(err, "while clearing the broken WAL archive")
+		}
+	}
+
+	// The storage used by the stanza changes with each backup and the expiration following it
+	backupCatalog, err := pgbackrestCommand.GetBackupList(ctx, &archive.Spec.Configuration, stanza, env)
+	if err != nil {
+		contextLogger.Error(err, "while getting the backup list to measure the storage")
+	} else if err := common.RecordStanzaStorage(ctx, b.Client, archive,
+		configuration.GetArchiveObjectKey().Namespace == "", stanza,
+		configuration.Cluster.Name, backupCatalog); err != nil {
+		contextLogger.Error(err, "while recording the storage of the stanza")
+	}
+
+	return b.backupResult(&executedBackupInfo.Backups[0], stanza), nil
+}
+
+// warnChecksumErrors logs the page checksum errors pgBackRest detected in the
+// backup set and emits a Warning Event on the cluster. pgBackRest completes
+// the backup even when it copies corrupted pages.
+func (b BackupServiceImplementation) warnChecksumErrors(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	stanza string,
+	backupSet *catalog.PgbackrestBackup,
+) {
+	if !backupSet.HasChecksumErrors() {
+		return
+	}
+
+	log.FromContext(ctx).Warning("Page checksum errors detected in the backup",
+		"backup", backupSet.ID,
+		"stanza", stanza,
+		"files", backupSet.ErrorList)
+	common.RecordChecksumErrors(b.Recorder, cluster, stanza, backupSet)
+}
+
+// backupResult describes a backup set of the stanza to CloudNativePG
+func (b BackupServiceImplementation) backupResult(
+	backupSet *catalog.PgbackrestBackup,
+	stanza string,
+) *backup.BackupResult {
+	result := &backup.BackupResult{
+		BackupId:   backupSet.ID,
+		BackupName: backupSet.Annotations[catalog.BackupNameAnnotation],
+		StartedAt:  backupSet.Time.Start,
+		StoppedAt:  backupSet.Time.Stop,
+		BeginWal:   backupSet.WAL.Start,
+		EndWal:     backupSet.WAL.Stop,
+		BeginLsn:   backupSet.LSN.Start,
+		EndLsn:     backupSet.LSN.Stop,
 		InstanceId: b.InstanceName,
 		Online:     true,
 		Metadata: map[string]string{
 			"version":     metadata.Data.Version,
 			"name":        metadata.Data.Name,
 			"displayName": metadata.Data.DisplayName,
+			"stanza":      stanza,
+			// The sizes in bytes of the database, of the files copied by the
+			// backup, and of the backup in the repository
+			"databaseSize":    strconv.FormatInt(backupSet.Info.Size, 10),
+			"backupSize":      strconv.FormatInt(backupSet.Info.Delta, 10),
+			"repositorySize":  strconv.FormatInt(backupSet.Info.Repository.Size, 10),
+			"repositoryDelta": strconv.FormatInt(backupSet.Info.Repository.Delta, 10),
 		},
-	}, nil
+	}
+
+	// The backup is usable, but the corrupted pages are restored as they are
+	if warning := common.ChecksumErrorsWarning(backupSet); warning != "" {
+		result.Metadata["warning"] = warning
+	}
+
+	return result
+}
+
+// checksumErrorFiles counts the files with page checksum errors in the
+// backup set, which are only listed when querying a single set. It returns
+// checksumErrorFilesUnknown when pgBackRest reports errors without listing
+// the files.
+func checksumErrorFiles(backupSet *catalog.PgbackrestBackup) int64 {
+	if len(backupSet.ErrorList) == 0 && backupSet.Error {
+		return checksumErrorFilesUnknown
+	}
+	return int64(len(backupSet.ErrorList))
 }
@@ -0,0 +1,59 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backupResult", func() {
+	It("doesn't warn about a valid backup set", func() {
+		result := BackupServiceImplementation{}.backupResult(&catalog.PgbackrestBackup{ID: "20250331-142029F"}, "main")
+		Expect(result.BackupId).To(Equal("20250331-142029F"))
+		Expect(result.Metadata).ToNot(HaveKey("warning"))
+	})
+
+	It("warns about the page checksum errors of the backup set", func() {
+		result := BackupServiceImplementation{}.backupResult(&catalog.PgbackrestBackup{
+			ID:        "20250331-142029F",
+			Error:     true,
+			ErrorList: []string{"base/5/16384", "base/5/16391"},
+		}, "main")
+		Expect(result.Metadata).To(HaveKeyWithValue("warning",
+			"page checksum errors in 2 files: base/5/16384, base/5/16391"))
+	})
+})
+
+var _ = Describe("checksumErrorFiles", func() {
+	It("counts the listed files", func() {
+		Expect(checksumErrorFiles(&catalog.PgbackrestBackup{
+			Error:     true,
+			ErrorList: []string{"base/5/16384", "base/5/16391"},
+		})).To(Equal(int64(2)))
+	})
+
+	It("doesn't know the number of files when pgBackRest doesn't list them", func() {
+		Expect(checksumErrorFiles(&catalog.PgbackrestBackup{Error: true})).To(Equal(int64(checksumErrorFilesUnknown)))
+	})
+
+	It("is zero for a valid backup set", func() {
+		Expect(checksumErrorFiles(&catalog.PgbackrestBackup{})).To(BeZero())
+	})
+})
@@ -0,0 +1,118 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package instance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
+)
+
+// backupSyncCheckInterval is how often the backup sync checks whether the
+// backup sets are due to be listed
+const backupSyncCheckInterval = time.Minute
+
+// BackupSync lists the backup sets of the stanza of the cluster in the status
+// of its Archive, when the instance is the primary of the cluster and the
+// Archive enables the backup sync. The operator synchronizes them into Backup
+// objects, as the instances aren't allowed to create or delete them.
+type BackupSync struct {
+	Client       client.Client
+	Namespace    string
+	ClusterName  string
+	InstanceName string
+
+	lastSync time.Time
+}
+
+// Start lists the backup sets until the context is cancelled
+func (s *BackupSync) Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("backup-sync")
+
+	ticker := time.NewTicker(backupSyncCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			contextLogger.Error(err, "while listing the backup sets")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync lists the backup sets when this instance is the primary and they are due
+func (s *BackupSync) sync(ctx context.Context) error {
+	var cluster cnpgv1.Cluster
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.ClusterName}, &cluster); err != nil {
+		return fmt.Errorf("while getting cluster: %w", err)
+	}
+	if cluster.Status.CurrentPrimary != s.InstanceName || cluster.Status.TargetPrimary != s.InstanceName {
+		return nil
+	}
+
+	configuration := config.NewFromCluster(&cluster)
+	if configuration.PgbackrestObjectName == "" {
+		return nil
+	}
+	archive, err := config.GetArchive(
+		ctx, s.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
+		return fmt.Errorf("while getting archive: %w", err)
+	}
+	if !archive.Spec.BackupSync.IsEnabled() || time.Since(s.lastSync) < archive.Spec.BackupSync.GetInterval() {
+		return nil
+	}
+
+	env, removeConfig, err := pgbackrestCredentials.EnvSetBackupCloudCredentials(
+		ctx,
+		s.Client,
+		archive.Namespace,
+		&archive.Spec.Configuration,
+		utils.SanitizedEnviron())
+	if err != nil {
+		return err
+	}
+	defer removeConfig()
+
+	stanza := archive.Spec.Configuration.GetStanza(configuration.Stanza)
+	backupCatalog, err := pgbackrestCommand.GetBackupList(ctx, &archive.Spec.Configuration, stanza, env)
+	if err != nil {
+		return err
+	}
+
+	if err := common.RecordBackupSets(ctx, s.Client, archive,
+		configuration.GetArchiveObjectKey().Namespace == "", stanza, s.ClusterName, backupCatalog); err != nil {
+		return err
+	}
+
+	s.lastSync = time.Now()
+	return nil
+}
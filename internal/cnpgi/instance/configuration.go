@@ -0,0 +1,125 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+)
+
+// configurationSyncInterval is how often the rendered pgbackrest.conf is refreshed
+const configurationSyncInterval = time.Minute
+
+// ConfigurationSync keeps the pgbackrest.conf of the sidecar in sync with the
+// Archive of the cluster, so that pgBackRest can be run interactively with the
+// same configuration the plugin uses. The file doesn't hold the secure options,
+// which "manager config exec" adds for the duration of a single command.
+type ConfigurationSync struct {
+	Client      client.Client
+	Namespace   string
+	ClusterName string
+	PGDataPath  string
+
+	rendered string
+}
+
+// Start refreshes the configuration file until the context is cancelled
+func (s *ConfigurationSync) Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("configuration-sync")
+
+	ticker := time.NewTicker(configurationSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			contextLogger.Error(err, "while rendering pgbackrest.conf")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync renders the configuration and writes it when it changed
+func (s *ConfigurationSync) sync(ctx context.Context) error {
+	var cluster cnpgv1.Cluster
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.ClusterName}, &cluster); err != nil {
+		return fmt.Errorf("while getting cluster: %w", err)
+	}
+
+	rendered, err := common.RenderPgbackrestConfiguration(ctx, s.Client, &cluster, s.PGDataPath, false)
+	if errors.Is(err, common.ErrNoArchive) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rendered == s.rendered {
+		return nil
+	}
+
+	if err := writeConfigurationFile(common.PgbackrestConfigurationPath, rendered); err != nil {
+		return err
+	}
+	s.rendered = rendered
+
+	log.FromContext(ctx).Info("Updated pgbackrest.conf", "path", common.PgbackrestConfigurationPath)
+	return nil
+}
+
+// writeConfigurationFile atomically replaces the configuration file with a new one
+// readable by the current user only
+func writeConfigurationFile(path string, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("while creating directory %s: %w", filepath.Dir(path), err)
+	}
+
+	// CreateTemp opens the file with mode 0600
+	file, err := os.CreateTemp(filepath.Dir(path), ".pgbackrest-*.conf")
+	if err != nil {
+		return fmt.Errorf("while creating pgbackrest configuration file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("while writing pgbackrest configuration file: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("while replacing %s: %w", path, err)
+	}
+	return nil
+}
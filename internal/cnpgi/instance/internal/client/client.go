@@ -54,6 +54,10 @@ func (e *ExtendedClient) isObjectCached(obj client.Object) bool {
 		return true
 	}
 
+	if _, isClusterArchive := obj.(*pgbackrestv1.ClusterArchive); isClusterArchive {
+		return true
+	}
+
 	return false
 }
 
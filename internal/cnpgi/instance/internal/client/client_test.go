@@ -102,6 +102,21 @@ var _ = Describe("ExtendedClient Get", func() {
 		Expect(extendedClient.cachedObjects[0].entry.GetName()).To(Equal("test-object-store"))
 	})
 
+	It("caches ClusterArchive objects", func(ctx SpecContext) {
+		clusterArchive := &v1.ClusterArchive{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-cluster-object-store",
+			},
+		}
+		Expect(extendedClient.Client.Create(ctx, clusterArchive)).To(Succeed())
+
+		clusterArchiveResult := &v1.ClusterArchive{}
+		err := extendedClient.Get(ctx, client.ObjectKeyFromObject(clusterArchive), clusterArchiveResult)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(extendedClient.cachedObjects).To(HaveLen(1))
+		Expect(extendedClient.cachedObjects[0].entry.GetName()).To(Equal("test-cluster-object-store"))
+	})
+
 	It("distinguishes objects with same key but different types", func(ctx SpecContext) {
 		// Add a Secret with the same name as the archive to the cache
 		secretSameName := &corev1.Secret{
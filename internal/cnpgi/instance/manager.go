@@ -24,6 +24,7 @@ import (
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/spf13/viper"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -33,6 +34,8 @@ import (
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
 	extendedclient "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/instance/internal/client"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
 )
 
 var scheme = runtime.NewScheme()
@@ -56,7 +59,10 @@ func Start(ctx context.Context) error {
 				DisableFor: []client.Object{
 					&corev1.Secret{},
 					&pgbackrestv1.Archive{},
+					&pgbackrestv1.ClusterArchive{},
 					&cnpgv1.Cluster{},
+					&pgbackrestv1.RestorePoint{},
+					&rbacv1.Role{},
 				},
 			},
 		},
@@ -68,19 +74,68 @@ func Start(ctx context.Context) error {
 		return err
 	}
 
+	customCacheClient := extendedclient.NewExtendedClient(mgr.GetClient())
+
+	spoolJanitor, err := spool.NewJanitor(
+		viper.GetString("spool-directory"),
+		viper.GetDuration("spool-max-age"),
+		viper.GetInt64("spool-quota"),
+	)
+	if err != nil {
+		setupLog.Error(err, "unable to create the spool janitor")
+		return err
+	}
+	if err := mgr.Add(spoolJanitor); err != nil {
+		setupLog.Error(err, "unable to create spool janitor runnable")
+		return err
+	}
+
 	if err := mgr.Add(&CNPGI{
-		Client:       extendedclient.NewExtendedClient(mgr.GetClient()),
+		Client:       customCacheClient,
 		InstanceName: podName,
 		// TODO: improve
 		PGDataPath:     viper.GetString("pgdata"),
 		PGWALPath:      path.Join(viper.GetString("pgdata"), "pg_wal"),
 		SpoolDirectory: viper.GetString("spool-directory"),
 		PluginPath:     viper.GetString("plugin-path"),
+		Limiter:        limiter.New(viper.GetInt("max-processes")),
+		Recorder:       mgr.GetEventRecorder("plugin-pgbackrest"),
+		SpoolJanitor:   spoolJanitor,
 	}); err != nil {
 		setupLog.Error(err, "unable to create CNPGI runnable")
 		return err
 	}
 
+	if err := mgr.Add(&ConfigurationSync{
+		Client:      customCacheClient,
+		Namespace:   viper.GetString("namespace"),
+		ClusterName: viper.GetString("cluster-name"),
+		PGDataPath:  viper.GetString("pgdata"),
+	}); err != nil {
+		setupLog.Error(err, "unable to create configuration sync runnable")
+		return err
+	}
+
+	if err := mgr.Add(&RestorePointSync{
+		Client:       customCacheClient,
+		Namespace:    viper.GetString("namespace"),
+		ClusterName:  viper.GetString("cluster-name"),
+		InstanceName: podName,
+	}); err != nil {
+		setupLog.Error(err, "unable to create restore point sync runnable")
+		return err
+	}
+
+	if err := mgr.Add(&BackupSync{
+		Client:       customCacheClient,
+		Namespace:    viper.GetString("namespace"),
+		ClusterName:  viper.GetString("cluster-name"),
+		InstanceName: podName,
+	}); err != nil {
+		setupLog.Error(err, "unable to create backup sync runnable")
+		return err
+	}
+
 	if err := mgr.Start(ctx); err != nil {
 		return err
 	}
@@ -0,0 +1,283 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cloudnative-pg/cnpg-i/pkg/metrics"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/archiver"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
+)
+
+const (
+	walArchiveParallelMetricName = "pgbackrest_wal_archive_parallel"
+	walArchiveBacklogMetricName  = "pgbackrest_wal_archive_backlog"
+	spoolBytesMetricName         = "pgbackrest_spool_bytes"
+	spoolFilesMetricName         = "pgbackrest_spool_files"
+	spoolQuotaMetricName         = "pgbackrest_spool_quota_bytes"
+
+	databaseSizeMetricName      = "pgbackrest_database_size_bytes"
+	databaseGrowthMetricName    = "pgbackrest_database_growth_bytes_per_day"
+	lastBackupDeltaMetricName   = "pgbackrest_last_backup_delta_bytes"
+	repositorySizeMetricName    = "pgbackrest_repository_size_bytes"
+	repositoryBackupsMetricName = "pgbackrest_repository_backups"
+	repositoryLabel             = "repo"
+
+	lastBackupChecksumErrorsMetricName     = "pgbackrest_last_backup_checksum_errors"
+	lastBackupChecksumErrorFilesMetricName = "pgbackrest_last_backup_checksum_error_files"
+)
+
+// MetricsServiceImplementation is the implementation of the metrics service,
+// exposing the state of the sidecar through the metrics of the instance
+type MetricsServiceImplementation struct {
+	metrics.UnimplementedMetricsServer
+
+	Client             client.Client
+	InstanceName       string
+	ArchiveParallelism *archiver.Parallelism
+	SpoolJanitor       *spool.Janitor
+	ChecksumErrors     *atomic.Int64
+}
+
+// GetCapabilities implements the MetricsServer interface
+func (m MetricsServiceImplementation) GetCapabilities(
+	_ context.Context,
+	_ *metrics.MetricsCapabilitiesRequest,
+) (*metrics.MetricsCapabilitiesResult, error) {
+	return &metrics.MetricsCapabilitiesResult{
+		Capabilities: []*metrics.MetricsCapability{
+			{
+				Type: &metrics.MetricsCapability_Rpc{
+					Rpc: &metrics.MetricsCapability_RPC{
+						Type: metrics.MetricsCapability_RPC_TYPE_METRICS,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Define implements the MetricsServer interface
+func (m MetricsServiceImplementation) Define(
+	_ context.Context,
+	_ *metrics.DefineMetricsRequest,
+) (*metrics.DefineMetricsResult, error) {
+	gauge := &metrics.MetricType{Type: metrics.MetricType_TYPE_GAUGE}
+	return &metrics.DefineMetricsResult{
+		Metrics: []*metrics.Metric{
+			{
+				FqName:    walArchiveParallelMetricName,
+				Help:      "Number of WAL files archived in parallel by the last batch",
+				ValueType: gauge,
+			},
+			{
+				FqName:    walArchiveBacklogMetricName,
+				Help:      "Number of WAL files waiting to be archived when the last batch started",
+				ValueType: gauge,
+			},
+			{
+				FqName:    spoolBytesMetricName,
+				Help:      "Size of the files in the spool after the last cleaning",
+				ValueType: gauge,
+			},
+			{
+				FqName:    spoolFilesMetricName,
+				Help:      "Number of files in the spool after the last cleaning",
+				ValueType: gauge,
+			},
+			{
+				FqName:    spoolQuotaMetricName,
+				Help:      "Maximum size of the spool, zero meaning no quota",
+				ValueType: gauge,
+			},
+			{
+				FqName:    lastBackupChecksumErrorsMetricName,
+				Help:      "Whether pgBackRest detected page checksum errors in the last backup taken by the instance",
+				ValueType: gauge,
+			},
+			{
+				FqName: lastBackupChecksumErrorFilesMetricName,
+				Help: "Number of files with page checksum errors in the last backup taken by the instance, " +
+					"not reported when pgBackRest doesn't list them",
+				ValueType: gauge,
+			},
+			{
+				FqName:    databaseSizeMetricName,
+				Help:      "Size of the database when the last backup of the stanza was taken",
+				ValueType: gauge,
+			},
+			{
+				FqName:    databaseGrowthMetricName,
+				Help:      "Growth of the database size per day, from the first to the last backup of the stanza",
+				ValueType: gauge,
+			},
+			{
+				FqName:    lastBackupDeltaMetricName,
+				Help:      "Size of the files stored in the repository by the last backup of the stanza",
+				ValueType: gauge,
+			},
+			{
+				FqName:         repositorySizeMetricName,
+				Help:           "Size of the files stored by the backups of the stanza in the repository",
+				ValueType:      gauge,
+				VariableLabels: []string{repositoryLabel},
+			},
+			{
+				FqName:         repositoryBackupsMetricName,
+				Help:           "Number of backups of the stanza in the repository",
+				ValueType:      gauge,
+				VariableLabels: []string{repositoryLabel},
+			},
+		},
+	}, nil
+}
+
+// Collect implements the MetricsServer interface
+func (m MetricsServiceImplementation) Collect(
+	ctx context.Context,
+	request *metrics.CollectMetricsRequest,
+) (*metrics.CollectMetricsResult, error) {
+	parallel, backlog := m.ArchiveParallelism.Current()
+	spoolUsage := m.SpoolJanitor.Usage()
+	result := &metrics.CollectMetricsResult{
+		Metrics: []*metrics.CollectMetric{
+			{
+				FqName: walArchiveParallelMetricName,
+				Value:  float64(parallel),
+			},
+			{
+				FqName: walArchiveBacklogMetricName,
+				Value:  float64(backlog),
+			},
+			{
+				FqName: spoolBytesMetricName,
+				Value:  float64(spoolUsage.Bytes),
+			},
+			{
+				FqName: spoolFilesMetricName,
+				Value:  float64(spoolUsage.Files),
+			},
+			{
+				FqName: spoolQuotaMetricName,
+				Value:  float64(m.SpoolJanitor.Quota()),
+			},
+		},
+	}
+	result.Metrics = append(result.Metrics, checksumErrorsMetrics(m.ChecksumErrors.Load())...)
+
+	storage, err := m.stanzaStorage(ctx, request.ClusterDefinition)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "while getting the storage of the stanza")
+	}
+	if storage != nil {
+		result.Metrics = append(result.Metrics, storageMetrics(storage)...)
+	}
+
+	return result, nil
+}
+
+// checksumErrorsMetrics reports whether the last backup has page checksum
+// errors and, when pgBackRest listed them, the number of affected files
+func checksumErrorsMetrics(files int64) []*metrics.CollectMetric {
+	result := []*metrics.CollectMetric{
+		{
+			FqName: lastBackupChecksumErrorsMetricName,
+			Value:  0,
+		},
+	}
+	if files != 0 {
+		result[0].Value = 1
+	}
+	if files != checksumErrorFilesUnknown {
+		result = append(result, &metrics.CollectMetric{
+			FqName: lastBackupChecksumErrorFilesMetricName,
+			Value:  float64(files),
+		})
+	}
+	return result
+}
+
+// stanzaStorage gets the storage of the stanza of the cluster recorded in the
+// status of its Archive. It is only reported by the primary, so that it is
+// counted once per cluster.
+func (m MetricsServiceImplementation) stanzaStorage(
+	ctx context.Context,
+	clusterDefinition []byte,
+) (*pgbackrestv1.StanzaStorage, error) {
+	configuration, err := config.NewFromClusterJSON(clusterDefinition)
+	if err != nil {
+		return nil, err
+	}
+	if configuration.PgbackrestObjectName == "" ||
+		configuration.Cluster.Status.CurrentPrimary != m.InstanceName {
+		return nil, nil
+	}
+
+	archive, err := config.GetArchive(
+		ctx, m.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, ok := archive.Status.Storage[archive.Spec.Configuration.GetStanza(configuration.Stanza)]
+	if !ok {
+		return nil, nil
+	}
+	return &storage, nil
+}
+
+// storageMetrics builds the metrics of the storage of a stanza
+func storageMetrics(storage *pgbackrestv1.StanzaStorage) []*metrics.CollectMetric {
+	result := []*metrics.CollectMetric{
+		{
+			FqName: databaseSizeMetricName,
+			Value:  float64(storage.DatabaseSize),
+		},
+		{
+			FqName: databaseGrowthMetricName,
+			Value:  float64(storage.DatabaseGrowthPerDay),
+		},
+		{
+			FqName: lastBackupDeltaMetricName,
+			Value:  float64(storage.LastBackupDelta),
+		},
+	}
+	for _, repository := range storage.Repositories {
+		labels := []string{strconv.FormatInt(repository.Repository, 10)}
+		result = append(result,
+			&metrics.CollectMetric{
+				FqName:         repositorySizeMetricName,
+				Value:          float64(repository.Size),
+				VariableLabels: labels,
+			},
+			&metrics.CollectMetric{
+				FqName:         repositoryBackupsMetricName,
+				Value:          float64(repository.Backups),
+				VariableLabels: labels,
+			},
+		)
+	}
+	return result
+}
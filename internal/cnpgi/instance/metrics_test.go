@@ -0,0 +1,86 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("storageMetrics", func() {
+	It("reports the storage of each repository", func() {
+		result := storageMetrics(&pgbackrestv1.StanzaStorage{
+			DatabaseSize:         1200,
+			DatabaseGrowthPerDay: 200,
+			LastBackupDelta:      20,
+			Repositories: []pgbackrestv1.RepositoryStorage{
+				{Repository: 1, Backups: 2, Size: 320},
+				{Repository: 2, Backups: 1, Size: 310},
+			},
+		})
+
+		values := map[string]float64{}
+		for _, metric := range result {
+			key := metric.FqName
+			if len(metric.VariableLabels) > 0 {
+				key += "/" + metric.VariableLabels[0]
+			}
+			values[key] = metric.Value
+		}
+		Expect(values).To(Equal(map[string]float64{
+			databaseSizeMetricName:             1200,
+			databaseGrowthMetricName:           200,
+			lastBackupDeltaMetricName:          20,
+			repositorySizeMetricName + "/1":    320,
+			repositoryBackupsMetricName + "/1": 2,
+			repositorySizeMetricName + "/2":    310,
+			repositoryBackupsMetricName + "/2": 1,
+		}))
+	})
+})
+
+var _ = Describe("checksumErrorsMetrics", func() {
+	values := func(files int64) map[string]float64 {
+		result := map[string]float64{}
+		for _, metric := range checksumErrorsMetrics(files) {
+			result[metric.FqName] = metric.Value
+		}
+		return result
+	}
+
+	It("reports a valid backup", func() {
+		Expect(values(0)).To(Equal(map[string]float64{
+			lastBackupChecksumErrorsMetricName:     0,
+			lastBackupChecksumErrorFilesMetricName: 0,
+		}))
+	})
+
+	It("reports the number of files with page checksum errors", func() {
+		Expect(values(2)).To(Equal(map[string]float64{
+			lastBackupChecksumErrorsMetricName:     1,
+			lastBackupChecksumErrorFilesMetricName: 2,
+		}))
+	})
+
+	It("doesn't report the number of files when pgBackRest didn't list them", func() {
+		Expect(values(checksumErrorFilesUnknown)).To(Equal(map[string]float64{
+			lastBackupChecksumErrorsMetricName: 1,
+		}))
+	})
+})
@@ -0,0 +1,278 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	// The PostgreSQL driver used to create the restore points
+	"github.com/lib/pq"
+	"github.com/lib/pq/pqerror"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+)
+
+const (
+	// restorePointSyncInterval is how often the pending restore points are looked for
+	restorePointSyncInterval = 10 * time.Second
+
+	// restorePointConnectionString is used to connect to the local PostgreSQL
+	// through the socket set in PGHOST, as pgBackRest does
+	restorePointConnectionString = "user=postgres dbname=postgres sslmode=disable"
+
+	// maxRestorePointNameLength is the longest name PostgreSQL accepts for a
+	// restore point
+	maxRestorePointNameLength = 63
+)
+
+// restorePointCreator creates a named restore point in PostgreSQL
+type restorePointCreator func(ctx context.Context, name string) (pgbackrestv1.RecordedRestorePoint, error)
+
+// RestorePointSync creates the restore points requested with RestorePoint objects
+// when the instance is the primary of the cluster, and records them in the
+// status of the Archive of the cluster
+type RestorePointSync struct {
+	Client       client.Client
+	Namespace    string
+	ClusterName  string
+	InstanceName string
+
+	// createRestorePoint defaults to createPostgresRestorePoint
+	createRestorePoint restorePointCreator
+}
+
+// Start creates the pending restore points until the context is cancelled
+func (s *RestorePointSync) Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("restore-point-sync")
+	if s.createRestorePoint == nil {
+		s.createRestorePoint = createPostgresRestorePoint
+	}
+
+	ticker := time.NewTicker(restorePointSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil {
+			contextLogger.Error(err, "while creating restore points")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync creates the pending restore points of the cluster when this instance is its primary
+func (s *RestorePointSync) sync(ctx context.Context) error {
+	var cluster cnpgv1.Cluster
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.ClusterName}, &cluster); err != nil {
+		return fmt.Errorf("while getting cluster: %w", err)
+	}
+	if cluster.Status.CurrentPrimary != s.InstanceName || cluster.Status.TargetPrimary != s.InstanceName {
+		return nil
+	}
+
+	// The sidecar is only allowed to access the pending RestorePoints of the
+	// cluster, which the operator lists in the Role granting this access
+	var role rbacv1.Role
+	if err := s.Client.Get(ctx, client.ObjectKey{
+		Namespace: s.Namespace,
+		Name:      specs.GetRestorePointRBACName(s.ClusterName),
+	}, &role); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	// A restore point failing doesn't hold back the other ones
+	var errs []error
+	for _, name := range specs.GetRestorePointNames(&role) {
+		var restorePoint pgbackrestv1.RestorePoint
+		if err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: name}, &restorePoint); err != nil {
+			// The restore point was deleted, or completed and the access to it revoked
+			if !apierrs.IsNotFound(err) && !apierrs.IsForbidden(err) {
+				errs = append(errs, fmt.Errorf("while getting restore point %s: %w", name, err))
+			}
+			continue
+		}
+		if restorePoint.Spec.Cluster.Name != s.ClusterName || restorePoint.Status.Phase != "" {
+			continue
+		}
+		if err := s.reconcileRestorePoint(ctx, &cluster, &restorePoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcileRestorePoint creates and records a pending restore point. The
+// restore points which can't be created, whatever the number of attempts, are
+// marked as failed.
+func (s *RestorePointSync) reconcileRestorePoint(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	restorePoint *pgbackrestv1.RestorePoint,
+) error {
+	contextLogger := log.FromContext(ctx).WithValues("restorePoint", restorePoint.Name)
+
+	configuration := config.NewFromCluster(cluster)
+	if configuration.PgbackrestObjectName == "" {
+		return s.patchStatus(ctx, restorePoint, pgbackrestv1.RestorePointStatus{
+			Phase: pgbackrestv1.RestorePointPhaseFailed,
+			Error: "the cluster doesn't archive its WAL with the plugin",
+		})
+	}
+
+	// The name of the RestorePoint may be too long for PostgreSQL, which
+	// would reject it. It's not truncated, as that could make it collide
+	// with another restore point.
+	name := restorePoint.GetRestorePointName()
+	if len(name) > maxRestorePointNameLength {
+		return s.patchStatus(ctx, restorePoint, pgbackrestv1.RestorePointStatus{
+			Phase: pgbackrestv1.RestorePointPhaseFailed,
+			Error: fmt.Sprintf("the name of the restore point %s is longer than %d characters, "+
+				"set a shorter one in spec.name", name, maxRestorePointNameLength),
+		})
+	}
+
+	archive, err := config.GetArchive(
+		ctx, s.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+	if errors.Is(err, config.ErrClusterArchiveNotAllowed) {
+		return s.patchStatus(ctx, restorePoint, pgbackrestv1.RestorePointStatus{
+			Phase: pgbackrestv1.RestorePointPhaseFailed,
+			Error: err.Error(),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("while getting archive: %w", err)
+	}
+	isClusterArchive := configuration.GetArchiveObjectKey().Namespace == ""
+	stanza := archive.Spec.Configuration.GetStanza(configuration.Stanza)
+
+	// A restore point recorded with the same name was created by a previous
+	// attempt or by another RestorePoint, and is the one recovery stops at
+	recorded, ok := archive.Status.RestorePoints[stanza][name]
+	if !ok {
+		recorded, err = s.createRestorePoint(ctx, name)
+		if isPermanentRestorePointError(err) {
+			return s.patchStatus(ctx, restorePoint, pgbackrestv1.RestorePointStatus{
+				Phase: pgbackrestv1.RestorePointPhaseFailed,
+				Error: err.Error(),
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("while creating restore point %s: %w", name, err)
+		}
+		contextLogger.Info("Created restore point", "name", name, "lsn", recorded.LSN)
+
+		if err := common.RecordRestorePoint(
+			ctx, s.Client, archive, isClusterArchive, stanza, name, recorded,
+		); err != nil {
+			return err
+		}
+	}
+
+	return s.patchStatus(ctx, restorePoint, pgbackrestv1.RestorePointStatus{
+		Phase:                pgbackrestv1.RestorePointPhaseCompleted,
+		Stanza:               stanza,
+		RecordedRestorePoint: recorded,
+	})
+}
+
+// patchStatus replaces the status of the restore point
+func (s *RestorePointSync) patchStatus(
+	ctx context.Context,
+	restorePoint *pgbackrestv1.RestorePoint,
+	status pgbackrestv1.RestorePointStatus,
+) error {
+	origRestorePoint := restorePoint.DeepCopy()
+	restorePoint.Status = status
+	if err := s.Client.Status().Patch(ctx, restorePoint, client.MergeFrom(origRestorePoint)); err != nil {
+		return fmt.Errorf("while updating the status of restore point %s: %w", restorePoint.Name, err)
+	}
+	return nil
+}
+
+// isPermanentRestorePointError tells whether PostgreSQL rejected the restore
+// point because of its name, or because of a setting like wal_level, so that
+// creating it again would fail the same way. The other errors, like the ones
+// connecting to PostgreSQL, are retried.
+func isPermanentRestorePointError(err error) bool {
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) {
+		return false
+	}
+
+	switch {
+	case pqError.Code.Class() == pqerror.ClassDataException,
+		pqError.Code == pqerror.InsufficientPrivilege:
+		return true
+	case pqError.Code == pqerror.ObjectNotInPrerequisiteState:
+		// raised both when wal_level is minimal and while the instance is
+		// still in recovery, the latter being transient
+		return !strings.Contains(pqError.Message, "recovery is in progress")
+	}
+	return false
+}
+
+// createPostgresRestorePoint creates a named restore point in the local PostgreSQL.
+// The timeline is read from the name of the WAL file containing it.
+func createPostgresRestorePoint(ctx context.Context, name string) (pgbackrestv1.RecordedRestorePoint, error) {
+	db, err := sql.Open("postgres", restorePointConnectionString)
+	if err != nil {
+		return pgbackrestv1.RecordedRestorePoint{}, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var lsn, walName string
+	row := db.QueryRowContext(ctx,
+		"SELECT lsn::text, pg_walfile_name(lsn) FROM pg_create_restore_point($1) AS lsn", name)
+	if err := row.Scan(&lsn, &walName); err != nil {
+		return pgbackrestv1.RecordedRestorePoint{}, err
+	}
+
+	if len(walName) < 8 {
+		return pgbackrestv1.RecordedRestorePoint{}, fmt.Errorf("unexpected WAL file name %s", walName)
+	}
+	timeline, err := strconv.ParseInt(walName[:8], 16, 64)
+	if err != nil {
+		return pgbackrestv1.RecordedRestorePoint{}, fmt.Errorf("while parsing WAL file name %s: %w", walName, err)
+	}
+
+	return pgbackrestv1.RecordedRestorePoint{
+		LSN:      lsn,
+		Timeline: timeline,
+		Time:     &metav1.Time{Time: time.Now()},
+	}, nil
+}
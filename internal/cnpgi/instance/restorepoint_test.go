@@ -0,0 +1,183 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package instance
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/lib/pq"
+	"github.com/lib/pq/pqerror"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestorePointSync", func() {
+	const (
+		namespace    = "default"
+		clusterName  = "cluster-example"
+		instanceName = "cluster-example-1"
+	)
+
+	var (
+		c                client.Client
+		created          []string
+		failing          map[string]error
+		restorePointSync *RestorePointSync
+	)
+
+	newRestorePoint := func(name, restorePointName string) *pgbackrestv1.RestorePoint {
+		return &pgbackrestv1.RestorePoint{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: pgbackrestv1.RestorePointSpec{
+				Cluster: cnpgv1.LocalObjectReference{Name: clusterName},
+				Name:    restorePointName,
+			},
+		}
+	}
+
+	getStatus := func(ctx context.Context, name string) pgbackrestv1.RestorePointStatus {
+		var restorePoint pgbackrestv1.RestorePoint
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &restorePoint)).To(Succeed())
+		return restorePoint.Status
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		Expect(cnpgv1.AddToScheme(scheme)).To(Succeed())
+		Expect(rbacv1.AddToScheme(scheme)).To(Succeed())
+
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: clusterName},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{{
+					Name:       metadata.PluginName,
+					Parameters: map[string]string{"pgbackrestObjectName": "archive"},
+				}},
+			},
+			Status: cnpgv1.ClusterStatus{
+				CurrentPrimary: instanceName,
+				TargetPrimary:  instanceName,
+			},
+		}
+		archive := &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "archive"},
+		}
+		restorePoints := []pgbackrestv1.RestorePoint{
+			*newRestorePoint("before-upgrade", ""),
+			*newRestorePoint(strings.Repeat("a", 64), ""),
+			*newRestorePoint("rejected", "rejected"),
+		}
+		// Only granted once the Role listing the pending restore points is updated
+		notGranted := newRestorePoint("not-granted", "")
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				cluster,
+				archive,
+				&restorePoints[0],
+				&restorePoints[1],
+				&restorePoints[2],
+				notGranted,
+				specs.BuildRestorePointRole(cluster, restorePoints),
+			).
+			WithStatusSubresource(&pgbackrestv1.Archive{}, &pgbackrestv1.RestorePoint{}).
+			Build()
+
+		created = nil
+		failing = map[string]error{}
+		restorePointSync = &RestorePointSync{
+			Client:       c,
+			Namespace:    namespace,
+			ClusterName:  clusterName,
+			InstanceName: instanceName,
+			createRestorePoint: func(_ context.Context, name string) (pgbackrestv1.RecordedRestorePoint, error) {
+				if err := failing[name]; err != nil {
+					return pgbackrestv1.RecordedRestorePoint{}, err
+				}
+				created = append(created, name)
+				return pgbackrestv1.RecordedRestorePoint{LSN: "0/5000060", Timeline: 1}, nil
+			},
+		}
+	})
+
+	It("marks the restore points which can't be created as failed, creating the other ones", func(ctx SpecContext) {
+		failing["rejected"] = &pq.Error{
+			Code:    pqerror.ObjectNotInPrerequisiteState,
+			Message: "WAL level not sufficient for creating a restore point",
+		}
+
+		Expect(restorePointSync.sync(ctx)).To(Succeed())
+		Expect(created).To(ConsistOf("before-upgrade"))
+
+		Expect(getStatus(ctx, "before-upgrade").Phase).To(Equal(pgbackrestv1.RestorePointPhaseCompleted))
+
+		tooLong := getStatus(ctx, strings.Repeat("a", 64))
+		Expect(tooLong.Phase).To(Equal(pgbackrestv1.RestorePointPhaseFailed))
+		Expect(tooLong.Error).To(ContainSubstring("longer than 63 characters"))
+
+		rejected := getStatus(ctx, "rejected")
+		Expect(rejected.Phase).To(Equal(pgbackrestv1.RestorePointPhaseFailed))
+		Expect(rejected.Error).To(ContainSubstring("WAL level not sufficient"))
+
+		Expect(getStatus(ctx, "not-granted").Phase).To(BeEmpty())
+	})
+
+	It("retries the restore points failing for a transient reason", func(ctx SpecContext) {
+		failing["rejected"] = errors.New("connection refused")
+
+		Expect(restorePointSync.sync(ctx)).To(MatchError(ContainSubstring("connection refused")))
+		Expect(created).To(ConsistOf("before-upgrade"))
+		Expect(getStatus(ctx, "before-upgrade").Phase).To(Equal(pgbackrestv1.RestorePointPhaseCompleted))
+		Expect(getStatus(ctx, "rejected").Phase).To(BeEmpty())
+
+		delete(failing, "rejected")
+		Expect(restorePointSync.sync(ctx)).To(Succeed())
+		Expect(created).To(ConsistOf("before-upgrade", "rejected"))
+		Expect(getStatus(ctx, "rejected").Phase).To(Equal(pgbackrestv1.RestorePointPhaseCompleted))
+	})
+})
+
+var _ = DescribeTable("isPermanentRestorePointError",
+	func(err error, permanent bool) {
+		Expect(isPermanentRestorePointError(err)).To(Equal(permanent))
+	},
+	Entry("no error", nil, false),
+	Entry("a connection error", errors.New("dial unix: connection refused"), false),
+	Entry("a name too long", &pq.Error{Code: pqerror.StringDataRightTruncation}, true),
+	Entry("a missing privilege", &pq.Error{Code: pqerror.InsufficientPrivilege}, true),
+	Entry("a minimal wal_level", &pq.Error{
+		Code:    pqerror.ObjectNotInPrerequisiteState,
+		Message: "WAL level not sufficient for creating a restore point",
+	}, true),
+	Entry("an instance in recovery", &pq.Error{
+		Code:    pqerror.ObjectNotInPrerequisiteState,
+		Message: "recovery is in progress",
+	}, false),
+)
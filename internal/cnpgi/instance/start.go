@@ -19,14 +19,21 @@ package instance
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/http"
 	"github.com/cloudnative-pg/cnpg-i/pkg/backup"
+	"github.com/cloudnative-pg/cnpg-i/pkg/metrics"
 	"github.com/cloudnative-pg/cnpg-i/pkg/wal"
 	"google.golang.org/grpc"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/archiver"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
 )
 
 // CNPGI is the implementation of the PostgreSQL sidecar
@@ -38,22 +45,45 @@ type CNPGI struct {
 	// mutually exclusive with serverAddress
 	PluginPath   string
 	InstanceName string
+	// Limits the number of pgBackRest processes run at once, nil meaning no limit
+	Limiter *limiter.Limiter
+	// Emits the Events about the WAL archive and the backups
+	Recorder events.EventRecorder
+	// Cleans the spool and keeps it within its quota
+	SpoolJanitor *spool.Janitor
 }
 
 // Start starts the GRPC service
 func (c *CNPGI) Start(ctx context.Context) error {
+	archiveParallelism := archiver.NewParallelism()
+	checksumErrors := &atomic.Int64{}
 	enrich := func(server *grpc.Server) error {
 		wal.RegisterWALServer(server, common.WALServiceImplementation{
-			InstanceName:   c.InstanceName,
+			InstanceName:       c.InstanceName,
+			Client:             c.Client,
+			SpoolDirectory:     c.SpoolDirectory,
+			PGDataPath:         c.PGDataPath,
+			PGWALPath:          c.PGWALPath,
+			Limiter:            c.Limiter,
+			InFlight:           inflight.NewRegistry(),
+			ArchiveParallelism: archiveParallelism,
+			Recorder:           c.Recorder,
+			SpoolJanitor:       c.SpoolJanitor,
+		})
+		backup.RegisterBackupServer(server, BackupServiceImplementation{
 			Client:         c.Client,
-			SpoolDirectory: c.SpoolDirectory,
+			InstanceName:   c.InstanceName,
 			PGDataPath:     c.PGDataPath,
-			PGWALPath:      c.PGWALPath,
+			Limiter:        c.Limiter,
+			Recorder:       c.Recorder,
+			ChecksumErrors: checksumErrors,
 		})
-		backup.RegisterBackupServer(server, BackupServiceImplementation{
-			Client:       c.Client,
-			InstanceName: c.InstanceName,
-			PGDataPath:   c.PGDataPath,
+		metrics.RegisterMetricsServer(server, MetricsServiceImplementation{
+			Client:             c.Client,
+			InstanceName:       c.InstanceName,
+			ArchiveParallelism: archiveParallelism,
+			SpoolJanitor:       c.SpoolJanitor,
+			ChecksumErrors:     checksumErrors,
 		})
 		common.AddHealthCheck(server)
 		return nil
@@ -67,5 +97,6 @@ func (c *CNPGI) Start(ctx context.Context) error {
 		PluginPath: c.PluginPath,
 	}
 
-	return srv.Start(ctx)
+	releaseStaleLocks(ctx)
+	return srv.Start(drainingContext(ctx))
 }
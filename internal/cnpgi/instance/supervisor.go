@@ -0,0 +1,56 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instance
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+)
+
+// drainGracePeriod is how long the running pgBackRest commands have to complete
+// when the sidecar stops. It is shorter than the graceful shutdown timeout of
+// the manager, so that the commands still running can be terminated in time.
+const drainGracePeriod = 20 * time.Second
+
+// releaseStaleLocks removes the locks left by the pgBackRest processes of a
+// previous run of the sidecar. It must run before the sidecar starts any
+// pgBackRest process.
+func releaseStaleLocks(ctx context.Context) {
+	contextLogger := log.FromContext(ctx).WithName("process-supervisor")
+
+	if err := pgbackrestCommand.ReleaseStaleLocks(ctx, pgbackrestCommand.LockPath); err != nil {
+		contextLogger.Error(err, "while releasing the stale pgbackrest locks")
+	}
+}
+
+// drainingContext returns a context which is cancelled only once the running
+// pgBackRest commands, like archive-push, are drained after the given context
+// is cancelled. The gRPC server stops on it, so that the requests waiting for
+// these commands are not cancelled before the drain.
+func drainingContext(ctx context.Context) context.Context {
+	drainingCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		defer cancel()
+		<-ctx.Done()
+		pgbackrestCommand.DefaultSupervisor.Drain(drainingCtx, drainGracePeriod)
+	}()
+	return drainingCtx
+}
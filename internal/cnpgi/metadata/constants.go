@@ -11,6 +11,38 @@ const (
 	// if present, requires the WAL archiver to check that the backup object
 	// store is empty.
 	CheckEmptyWalArchiveFile = ".check-empty-wal-archive"
+
+	// ArchiveFinalizerName is the finalizer preventing an Archive
+	// from being deleted while clusters are still using it
+	ArchiveFinalizerName = PluginName + "/archive-protection"
+
+	// SidecarConfigurationHashAnnotationName is the annotation stamped on the
+	// instance pods with the hash of the sidecar configuration they were
+	// created with
+	SidecarConfigurationHashAnnotationName = PluginName + "/sidecar-configuration-hash"
+
+	// BackupIDAnnotationName is the annotation of the Backup objects created
+	// for the backup sets found in the repository. It holds the ID of the set,
+	// which the Backup adopts instead of taking a new backup.
+	BackupIDAnnotationName = PluginName + "/backup-id"
+
+	// BackupSyncLabelName is the label of the Backup objects created for the
+	// backup sets found in the repository
+	BackupSyncLabelName = PluginName + "/synced"
+
+	// ClusterNameLabelName and ClusterNamespaceLabelName are the labels of the
+	// RBAC objects granting a cluster access to ClusterArchives, which live
+	// outside of its namespace. They hold the name and namespace of the cluster.
+	ClusterNameLabelName      = PluginName + "/cluster"
+	ClusterNamespaceLabelName = PluginName + "/cluster-namespace"
+
+	// ArchiveLabelName is the label of the objects created for an Archive,
+	// like the CronJob verifying its repositories. It holds the Archive name.
+	ArchiveLabelName = PluginName + "/archive"
+
+	// RestoreTestLabelName is the label of the objects created for a
+	// RestoreTest, like the CronJob running it. It holds the RestoreTest name.
+	RestoreTestLabelName = PluginName + "/restore-test"
 )
 
 // Data is the metadata of this plugin.
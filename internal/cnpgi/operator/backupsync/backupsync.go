@@ -0,0 +1,219 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+)
+
+// defaultStaleBackupTimeout is how long a Backup which isn't done holds back
+// the synchronization, when the Archive doesn't limit the duration of the
+// backups. Past it, the Backup is considered abandoned.
+const defaultStaleBackupTimeout = 24 * time.Hour
+
+// backupSyncPlan is what is needed to make the Backup objects of a cluster
+// match the backup sets of its stanza
+type backupSyncPlan struct {
+	// The IDs of the backup sets without a Backup object
+	missing []string
+
+	// The Backup objects whose backup set expired
+	expired []cnpgv1.Backup
+}
+
+// Reconcile synchronizes the backup sets of each stanza listed in the status
+// of the Archive into Backup objects of the cluster which listed them. A
+// Backup object is created for each backup set without one, and the Backup
+// objects whose set expired are deleted.
+func Reconcile(ctx context.Context, c client.Client, archive *pgbackrestv1.Archive) error {
+	if !archive.Spec.BackupSync.IsEnabled() {
+		return nil
+	}
+
+	var errs []error
+	for _, stanza := range slices.Sorted(maps.Keys(archive.Status.BackupSets)) {
+		backupSets := archive.Status.BackupSets[stanza]
+		if err := reconcileStanza(ctx, c, archive, stanza, &backupSets); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reconcileStanza synchronizes the backup sets of a stanza
+func reconcileStanza(
+	ctx context.Context,
+	c client.Client,
+	archive *pgbackrestv1.Archive,
+	stanza string,
+	backupSets *pgbackrestv1.StanzaBackupSets,
+) error {
+	contextLogger := log.FromContext(ctx).WithValues("stanza", stanza, "cluster", backupSets.Cluster)
+
+	// The cluster may have been deleted, or moved to another Archive or
+	// stanza, since it listed the backup sets
+	var cluster cnpgv1.Cluster
+	if err := c.Get(ctx, client.ObjectKey{Namespace: archive.Namespace, Name: backupSets.Cluster}, &cluster); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	configuration := config.NewFromCluster(&cluster)
+	if configuration.PgbackrestObjectName == "" ||
+		configuration.GetArchiveObjectKey() != client.ObjectKeyFromObject(archive) ||
+		archive.Spec.Configuration.GetStanza(configuration.Stanza) != stanza {
+		return nil
+	}
+
+	var backupList cnpgv1.BackupList
+	if err := c.List(ctx, &backupList, client.InNamespace(archive.Namespace)); err != nil {
+		return fmt.Errorf("while listing backups: %w", err)
+	}
+	backups := make([]cnpgv1.Backup, 0, len(backupList.Items))
+	for _, backup := range backupList.Items {
+		if backup.Spec.Cluster.Name == cluster.Name &&
+			backup.Spec.Method == cnpgv1.BackupMethodPlugin &&
+			backup.Spec.PluginConfiguration != nil &&
+			backup.Spec.PluginConfiguration.Name == metadata.PluginName {
+			backups = append(backups, backup)
+		}
+	}
+
+	staleBackupTimeout := archive.Spec.Configuration.GetCommandTimeout(pgbackrestCommand.CommandBackup)
+	if staleBackupTimeout == 0 {
+		staleBackupTimeout = defaultStaleBackupTimeout
+	}
+	plan, running := planBackupSync(stanza, backups, backupSets, time.Now().Add(-staleBackupTimeout))
+	if running != "" {
+		contextLogger.Info("Waiting for a running backup to synchronize the backup sets", "backup", running)
+		return nil
+	}
+
+	for _, backupID := range plan.missing {
+		backup := newSyncedBackup(archive.Namespace, cluster.Name, backupID)
+		if err := c.Create(ctx, backup); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("while creating backup %s for backup set %s: %w", backup.Name, backupID, err)
+		}
+		contextLogger.Info("Created backup for backup set", "backup", backup.Name, "backupID", backupID)
+	}
+
+	for i := range plan.expired {
+		backup := &plan.expired[i]
+		if err := c.Delete(ctx, backup); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("while deleting backup %s of expired backup set %s: %w",
+				backup.Name, backup.Status.BackupID, err)
+		}
+		contextLogger.Info("Deleted backup of expired backup set",
+			"backup", backup.Name, "backupID", backup.Status.BackupID)
+	}
+
+	return nil
+}
+
+// planBackupSync compares the Backup objects of a cluster with the backup sets
+// listed for its stanza. A backup which isn't done yet may be about to report
+// a set of the list, so nothing is planned until it is done and its name is
+// returned, unless it was created before staleBefore and is considered
+// abandoned. The Backup objects completed after the backup sets were listed
+// are kept, as their set may not be listed yet.
+func planBackupSync(
+	stanza string,
+	backups []cnpgv1.Backup,
+	backupSets *pgbackrestv1.StanzaBackupSets,
+	staleBefore time.Time,
+) (*backupSyncPlan, string) {
+	known := make(map[string]struct{}, len(backups))
+	for i := range backups {
+		if !backups[i].Status.IsDone() && !backups[i].CreationTimestamp.Time.Before(staleBefore) {
+			return nil, backups[i].Name
+		}
+		if backupID := backups[i].Status.BackupID; backupID != "" {
+			known[backupID] = struct{}{}
+		}
+		if backupID := backups[i].Annotations[metadata.BackupIDAnnotationName]; backupID != "" {
+			known[backupID] = struct{}{}
+		}
+	}
+
+	plan := &backupSyncPlan{}
+	listed := make(map[string]struct{}, len(backupSets.IDs))
+	for _, backupID := range backupSets.IDs {
+		listed[backupID] = struct{}{}
+		if _, ok := known[backupID]; !ok {
+			plan.missing = append(plan.missing, backupID)
+		}
+	}
+
+	// Only the Backup objects known to belong to the stanza are deleted, as
+	// the cluster may have archived into other stanzas before
+	for _, backup := range backups {
+		if backup.Status.Phase != cnpgv1.BackupPhaseCompleted ||
+			backup.Status.BackupID == "" ||
+			backup.Status.PluginMetadata["stanza"] != stanza ||
+			backup.Status.StoppedAt == nil ||
+			backup.Status.StoppedAt.After(backupSets.ListedAt.Time) {
+			continue
+		}
+		if _, ok := listed[backup.Status.BackupID]; !ok {
+			plan.expired = append(plan.expired, backup)
+		}
+	}
+
+	return plan, ""
+}
+
+// newSyncedBackup builds the Backup object adopting a backup set
+func newSyncedBackup(namespace, clusterName, backupID string) *cnpgv1.Backup {
+	// Backup set IDs are like 20250101-120000F_20250102-120000D
+	name := fmt.Sprintf("%s-%s", clusterName, strings.ToLower(strings.ReplaceAll(backupID, "_", "-")))
+
+	return &cnpgv1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels: map[string]string{
+				metadata.BackupSyncLabelName: "true",
+			},
+			Annotations: map[string]string{
+				metadata.BackupIDAnnotationName: backupID,
+			},
+		},
+		Spec: cnpgv1.BackupSpec{
+			Cluster: cnpgv1.LocalObjectReference{Name: clusterName},
+			Method:  cnpgv1.BackupMethodPlugin,
+			Target:  cnpgv1.BackupTargetPrimary,
+			PluginConfiguration: &cnpgv1.BackupPluginConfiguration{
+				Name: metadata.PluginName,
+			},
+		},
+	}
+}
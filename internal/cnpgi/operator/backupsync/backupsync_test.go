@@ -0,0 +1,210 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backupsync
+
+import (
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("planBackupSync", func() {
+	const stanza = "cluster-example"
+
+	listedAt := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
+	staleBefore := listedAt.Add(-defaultStaleBackupTimeout)
+
+	completedBackup := func(name, backupID, backupStanza string) cnpgv1.Backup {
+		return cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(listedAt.Add(-time.Hour))},
+			Status: cnpgv1.BackupStatus{
+				Phase:          cnpgv1.BackupPhaseCompleted,
+				BackupID:       backupID,
+				StoppedAt:      &metav1.Time{Time: listedAt.Add(-time.Hour)},
+				PluginMetadata: map[string]string{"stanza": backupStanza},
+			},
+		}
+	}
+
+	backupSets := func(backupIDs ...string) *pgbackrestv1.StanzaBackupSets {
+		return &pgbackrestv1.StanzaBackupSets{
+			Cluster:  "cluster-example",
+			IDs:      backupIDs,
+			ListedAt: metav1.NewTime(listedAt),
+		}
+	}
+
+	It("finds the backup sets without a Backup object", func() {
+		adopted := cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "adopted",
+				Annotations: map[string]string{
+					metadata.BackupIDAnnotationName: "20250103-120000F",
+				},
+			},
+			Status: cnpgv1.BackupStatus{Phase: cnpgv1.BackupPhaseFailed},
+		}
+
+		plan, running := planBackupSync(stanza, []cnpgv1.Backup{
+			completedBackup("scheduled", "20250101-120000F", stanza),
+			adopted,
+		}, backupSets("20250101-120000F", "20250101-120000F_20250102-120000D", "20250103-120000F"), staleBefore)
+		Expect(running).To(BeEmpty())
+		Expect(plan.missing).To(ConsistOf("20250101-120000F_20250102-120000D"))
+		Expect(plan.expired).To(BeEmpty())
+	})
+
+	It("finds the Backup objects of the stanza whose backup set expired", func() {
+		plan, running := planBackupSync(stanza, []cnpgv1.Backup{
+			completedBackup("expired", "20250101-120000F", stanza),
+			completedBackup("other-stanza", "20250102-120000F", "other"),
+			completedBackup("current", "20250103-120000F", stanza),
+		}, backupSets("20250103-120000F"), staleBefore)
+		Expect(running).To(BeEmpty())
+		Expect(plan.missing).To(BeEmpty())
+		Expect(plan.expired).To(HaveLen(1))
+		Expect(plan.expired[0].Name).To(Equal("expired"))
+	})
+
+	It("keeps the Backup objects completed after the backup sets were listed", func() {
+		completed := completedBackup("completed", "20250104-120000F", stanza)
+		completed.Status.StoppedAt = &metav1.Time{Time: listedAt.Add(time.Minute)}
+
+		plan, running := planBackupSync(stanza, []cnpgv1.Backup{completed}, backupSets(), staleBefore)
+		Expect(running).To(BeEmpty())
+		Expect(plan.expired).To(BeEmpty())
+	})
+
+	It("waits for the running backups", func() {
+		running := cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{Name: "running", CreationTimestamp: metav1.NewTime(listedAt)},
+			Status:     cnpgv1.BackupStatus{Phase: cnpgv1.BackupPhaseRunning},
+		}
+
+		plan, runningName := planBackupSync(stanza, []cnpgv1.Backup{running}, backupSets("20250101-120000F"), staleBefore)
+		Expect(plan).To(BeNil())
+		Expect(runningName).To(Equal("running"))
+	})
+
+	It("doesn't wait for the backups which aren't done for too long", func() {
+		abandoned := cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "abandoned",
+				CreationTimestamp: metav1.NewTime(staleBefore.Add(-time.Minute)),
+			},
+			Status: cnpgv1.BackupStatus{Phase: cnpgv1.BackupPhaseRunning},
+		}
+
+		plan, running := planBackupSync(stanza, []cnpgv1.Backup{abandoned}, backupSets("20250101-120000F"), staleBefore)
+		Expect(running).To(BeEmpty())
+		Expect(plan.missing).To(ConsistOf("20250101-120000F"))
+	})
+})
+
+var _ = Describe("newSyncedBackup", func() {
+	It("adopts the backup set", func() {
+		backup := newSyncedBackup("default", "cluster-example", "20250101-120000F_20250102-120000D")
+		Expect(backup.Name).To(Equal("cluster-example-20250101-120000f-20250102-120000d"))
+		Expect(backup.Annotations).To(HaveKeyWithValue(
+			metadata.BackupIDAnnotationName, "20250101-120000F_20250102-120000D"))
+		Expect(backup.Spec.Method).To(Equal(cnpgv1.BackupMethodPlugin))
+		Expect(backup.Spec.PluginConfiguration.Name).To(Equal(metadata.PluginName))
+	})
+})
+
+var _ = Describe("Reconcile", func() {
+	var (
+		c       client.Client
+		archive *pgbackrestv1.Archive
+	)
+
+	newCluster := func(name string, parameters map[string]string) *cnpgv1.Cluster {
+		return &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{{
+					Name:       metadata.PluginName,
+					Parameters: parameters,
+				}},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		Expect(cnpgv1.AddToScheme(scheme)).To(Succeed())
+
+		archive = &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+			Spec: pgbackrestv1.ArchiveSpec{
+				BackupSync: &pgbackrestv1.BackupSyncConfiguration{Enabled: true},
+			},
+			Status: pgbackrestv1.ArchiveStatus{
+				BackupSets: map[string]pgbackrestv1.StanzaBackupSets{
+					"cluster-example": {
+						Cluster:  "cluster-example",
+						IDs:      []string{"20250101-120000F"},
+						ListedAt: metav1.Now(),
+					},
+					"moved": {
+						Cluster:  "moved",
+						IDs:      []string{"20250101-120000F"},
+						ListedAt: metav1.Now(),
+					},
+				},
+			},
+		}
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				archive,
+				newCluster("cluster-example", map[string]string{"pgbackrestObjectName": "archive"}),
+				newCluster("moved", map[string]string{"pgbackrestObjectName": "other"}),
+			).
+			Build()
+	})
+
+	It("creates the Backup objects of the clusters still archiving into the stanza", func(ctx SpecContext) {
+		Expect(Reconcile(ctx, c, archive)).To(Succeed())
+
+		var backups cnpgv1.BackupList
+		Expect(c.List(ctx, &backups)).To(Succeed())
+		Expect(backups.Items).To(HaveLen(1))
+		Expect(backups.Items[0].Name).To(Equal("cluster-example-20250101-120000f"))
+		Expect(backups.Items[0].Spec.Cluster.Name).To(Equal("cluster-example"))
+	})
+
+	It("does nothing when the backup sync is disabled", func(ctx SpecContext) {
+		archive.Spec.BackupSync.Enabled = false
+		Expect(Reconcile(ctx, c, archive)).To(Succeed())
+
+		var backups cnpgv1.BackupList
+		Expect(c.List(ctx, &backups)).To(Succeed())
+		Expect(backups.Items).To(BeEmpty())
+	})
+})
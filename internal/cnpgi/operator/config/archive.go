@@ -0,0 +1,72 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+)
+
+const (
+	// ArchiveKind is the value of the pgbackrestObjectKind parameter referring
+	// to a namespaced Archive. It is the default.
+	ArchiveKind = "Archive"
+
+	// ClusterArchiveKind is the value of the pgbackrestObjectKind parameter
+	// referring to a cluster-scoped ClusterArchive
+	ClusterArchiveKind = "ClusterArchive"
+)
+
+// ErrClusterArchiveNotAllowed is returned when a cluster refers to a ClusterArchive
+// whose allow-list doesn't include the namespace of the cluster
+var ErrClusterArchiveNotAllowed = errors.New("namespace not allowed to use the ClusterArchive")
+
+// GetArchive retrieves the archive object identified by key on behalf of a cluster
+// living in clusterNamespace. Keys without a namespace refer to a ClusterArchive,
+// which is returned as an equivalent Archive living in its credentials namespace.
+func GetArchive(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	clusterNamespace string,
+) (*pgbackrestv1.Archive, error) {
+	if key.Namespace != "" {
+		var archive pgbackrestv1.Archive
+		if err := c.Get(ctx, key, &archive); err != nil {
+			return nil, err
+		}
+		return &archive, nil
+	}
+
+	var clusterArchive pgbackrestv1.ClusterArchive
+	if err := c.Get(ctx, key, &clusterArchive); err != nil {
+		return nil, err
+	}
+
+	if !clusterArchive.IsNamespaceAllowed(clusterNamespace) {
+		return nil, fmt.Errorf("%w: ClusterArchive %s, namespace %s",
+			ErrClusterArchiveNotAllowed, key.Name, clusterNamespace)
+	}
+
+	return clusterArchive.ToArchive(), nil
+}
@@ -18,6 +18,7 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
 	"strings"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
@@ -66,38 +67,44 @@ type PluginConfiguration struct {
 	Cluster *cnpgv1.Cluster
 
 	PgbackrestObjectName string
+	PgbackrestObjectKind string
 	Stanza               string
 
 	RecoveryPgbackrestObjectName string
+	RecoveryPgbackrestObjectKind string
 	RecoveryStanza               string
 
 	ReplicaSourcePgbackrestObjectName string
+	ReplicaSourcePgbackrestObjectKind string
 	ReplicaSourceStanza               string
 }
 
-// GetArchiveObjectKey gets the namespaced name of the pgbackrest archive object
+// GetArchiveObjectKey gets the namespaced name of the pgbackrest archive object.
+// The namespace is empty when the object is a ClusterArchive.
 func (config *PluginConfiguration) GetArchiveObjectKey() types.NamespacedName {
-	return types.NamespacedName{
-		Namespace: config.Cluster.Namespace,
-		Name:      config.PgbackrestObjectName,
-	}
+	return config.archiveObjectKey(config.PgbackrestObjectKind, config.PgbackrestObjectName)
 }
 
 // GetRecoveryArchiveObjectKey gets the namespaced name of the recovery pgbackrest
-// archive object
+// archive object. The namespace is empty when the object is a ClusterArchive.
 func (config *PluginConfiguration) GetRecoveryArchiveObjectKey() types.NamespacedName {
-	return types.NamespacedName{
-		Namespace: config.Cluster.Namespace,
-		Name:      config.RecoveryPgbackrestObjectName,
-	}
+	return config.archiveObjectKey(config.RecoveryPgbackrestObjectKind, config.RecoveryPgbackrestObjectName)
 }
 
 // GetReplicaSourceArchiveObjectKey gets the namespaced name of the replica source
-// pgbackrest archive object
+// pgbackrest archive object. The namespace is empty when the object is a ClusterArchive.
 func (config *PluginConfiguration) GetReplicaSourceArchiveObjectKey() types.NamespacedName {
+	return config.archiveObjectKey(config.ReplicaSourcePgbackrestObjectKind, config.ReplicaSourcePgbackrestObjectName)
+}
+
+func (config *PluginConfiguration) archiveObjectKey(kind, name string) types.NamespacedName {
+	if kind == ClusterArchiveKind {
+		return types.NamespacedName{Name: name}
+	}
+
 	return types.NamespacedName{
 		Namespace: config.Cluster.Namespace,
-		Name:      config.ReplicaSourcePgbackrestObjectName,
+		Name:      name,
 	}
 }
 
@@ -156,8 +163,10 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 
 	recoveryStanza := ""
 	recoveryPgbackrestObjectName := ""
+	recoveryPgbackrestObjectKind := ""
 	if recoveryParameters := getRecoveryParameters(cluster); recoveryParameters != nil {
 		recoveryPgbackrestObjectName = recoveryParameters["pgbackrestObjectName"]
+		recoveryPgbackrestObjectKind = recoveryParameters["pgbackrestObjectKind"]
 		recoveryStanza = recoveryParameters["stanza"]
 		if len(recoveryStanza) == 0 {
 			recoveryStanza = cluster.Name
@@ -166,8 +175,10 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 
 	replicaSourceStanza := ""
 	replicaSourcePgbackrestObjectName := ""
+	replicaSourcePgbackrestObjectKind := ""
 	if replicaSourceParameters := getReplicaSourceParameters(cluster); replicaSourceParameters != nil {
 		replicaSourcePgbackrestObjectName = replicaSourceParameters["pgbackrestObjectName"]
+		replicaSourcePgbackrestObjectKind = replicaSourceParameters["pgbackrestObjectKind"]
 		replicaSourceStanza = replicaSourceParameters["stanza"]
 		if len(replicaSourceStanza) == 0 {
 			replicaSourceStanza = cluster.Name
@@ -178,13 +189,16 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 		Cluster: cluster,
 		// used for the backup/archive
 		PgbackrestObjectName: helper.Parameters["pgbackrestObjectName"],
+		PgbackrestObjectKind: helper.Parameters["pgbackrestObjectKind"],
 		Stanza:               stanza,
 		// used for restore and wal_restore during backup recovery
 		RecoveryStanza:               recoveryStanza,
 		RecoveryPgbackrestObjectName: recoveryPgbackrestObjectName,
+		RecoveryPgbackrestObjectKind: recoveryPgbackrestObjectKind,
 		// used for wal_restore in the designed primary of a replica cluster
 		ReplicaSourceStanza:               replicaSourceStanza,
 		ReplicaSourcePgbackrestObjectName: replicaSourcePgbackrestObjectName,
+		ReplicaSourcePgbackrestObjectKind: replicaSourcePgbackrestObjectKind,
 	}
 
 	return result
@@ -267,6 +281,19 @@ func (config *PluginConfiguration) Validate() error {
 		return err.WithMessage("no reference to pgbackrestObjectName have been included")
 	}
 
+	for _, kind := range []string{
+		config.PgbackrestObjectKind,
+		config.RecoveryPgbackrestObjectKind,
+		config.ReplicaSourcePgbackrestObjectKind,
+	} {
+		if kind != "" && kind != ArchiveKind && kind != ClusterArchiveKind {
+			err = err.WithMessage(fmt.Sprintf("unknown pgbackrestObjectKind %q", kind))
+		}
+	}
+	if !err.IsEmpty() {
+		return err
+	}
+
 	return nil
 }
 
@@ -21,31 +21,69 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/object"
 	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
+	"github.com/cloudnative-pg/machinery/pkg/hash"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"github.com/spf13/viper"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
 )
 
 const (
 	kindPod             = "Pod"
 	kindJob             = "Job"
 	jobRoleFullRecovery = "full-recovery"
+
+	sidecarConfigurationHashEnvName = "SIDECAR_CONFIGURATION_HASH"
+	maxProcessesEnvName             = "MAX_PROCESSES"
+	spoolQuotaEnvName               = "SPOOL_QUOTA"
+	spoolMaxAgeEnvName              = "SPOOL_MAX_AGE"
+
+	secretsVolumeName = "pgbackrest-secrets"
 )
 
+// mainContainerEnvNames lists the variables of the PostgreSQL container that are
+// merged into the sidecar. The rest of its environment, including the variables
+// set in the Cluster spec, isn't needed by pgBackRest and is kept out of the sidecar.
+var mainContainerEnvNames = []string{
+	"CLUSTER_NAME",
+	"NAMESPACE",
+	"PGDATA",
+	"PGHOST",
+	"PGPORT",
+	"POD_NAME",
+	"TMPDIR",
+}
+
+// sidecarConfiguration is the configuration of the plugin sidecar
+// coming from the Archive objects. Its hash is stamped on the instance pods.
+type sidecarConfiguration struct {
+	Env             []corev1.EnvVar
+	Resources       *corev1.ResourceRequirements
+	SecurityContext *corev1.SecurityContext
+	Volumes         []corev1.Volume
+	VolumeMounts    []corev1.VolumeMount
+	Image           string
+	ImagePullPolicy corev1.PullPolicy
+}
+
 // LifecycleImplementation is the implementation of the lifecycle handler
 type LifecycleImplementation struct {
 	lifecycle.UnimplementedOperatorLifecycleServer
@@ -163,28 +201,27 @@ func (impl LifecycleImplementation) getArchives(
 	namespace string,
 	pluginConfiguration *config.PluginConfiguration,
 ) (*pgbackrestv1.Archive, *pgbackrestv1.Archive, error) {
-	var archive pgbackrestv1.Archive
-	var recoveryArchive pgbackrestv1.Archive
+	archive := &pgbackrestv1.Archive{}
+	recoveryArchive := &pgbackrestv1.Archive{}
 	contextLogger := log.FromContext(ctx).WithName("lifecycle")
 	if len(pluginConfiguration.PgbackrestObjectName) > 0 {
-		if err := impl.Client.Get(ctx, types.NamespacedName{
-			Name:      pluginConfiguration.PgbackrestObjectName,
-			Namespace: namespace,
-		}, &archive); err != nil {
+		var err error
+		archive, err = config.GetArchive(ctx, impl.Client, pluginConfiguration.GetArchiveObjectKey(), namespace)
+		if err != nil {
 			contextLogger.Error(err, "failed to retrieve archive", "error", err)
 			return nil, nil, err
 		}
 	}
 	if len(pluginConfiguration.RecoveryPgbackrestObjectName) > 0 {
-		if err := impl.Client.Get(ctx, types.NamespacedName{
-			Name:      pluginConfiguration.RecoveryPgbackrestObjectName,
-			Namespace: namespace,
-		}, &recoveryArchive); err != nil {
+		var err error
+		recoveryArchive, err = config.GetArchive(
+			ctx, impl.Client, pluginConfiguration.GetRecoveryArchiveObjectKey(), namespace)
+		if err != nil {
 			contextLogger.Error(err, "failed to retrieve recovery archive", "error", err)
 			return nil, nil, err
 		}
 	}
-	return &archive, &recoveryArchive, nil
+	return archive, recoveryArchive, nil
 }
 
 func (impl LifecycleImplementation) collectAdditionalEnvs(
@@ -228,6 +265,33 @@ func (impl LifecycleImplementation) collectArchiveEnvs(
 	return archive.Spec.InstanceSidecarConfiguration.Env, nil
 }
 
+// buildLimitEnvs builds the variables passing the process and spool limits of
+// the archive to the sidecar
+func buildLimitEnvs(archive *pgbackrestv1.Archive) []corev1.EnvVar {
+	sidecarConfiguration := archive.Spec.InstanceSidecarConfiguration
+
+	var env []corev1.EnvVar
+	if sidecarConfiguration.MaxProcesses != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  maxProcessesEnvName,
+			Value: strconv.Itoa(int(*sidecarConfiguration.MaxProcesses)),
+		})
+	}
+	if sidecarConfiguration.SpoolQuota != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  spoolQuotaEnvName,
+			Value: strconv.FormatInt(sidecarConfiguration.SpoolQuota.Value(), 10),
+		})
+	}
+	if sidecarConfiguration.SpoolMaxAge != nil {
+		env = append(env, corev1.EnvVar{
+			Name:  spoolMaxAgeEnvName,
+			Value: sidecarConfiguration.SpoolMaxAge.Duration.String(),
+		})
+	}
+	return env
+}
+
 func (impl LifecycleImplementation) reconcileJob(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
@@ -238,23 +302,51 @@ func (impl LifecycleImplementation) reconcileJob(
 	if err != nil {
 		return nil, err
 	}
-	env, err := impl.collectAdditionalEnvs(ctx, archive, recoveryArchive)
+
+	// The restore job may check the destination archive too
+	webIdentityVolumes, webIdentityVolumeMounts := specs.BuildWebIdentityVolume(archive, recoveryArchive)
+
+	// The restore job uses the pgBackRest build the recovery archive was written with
+	sidecar := sidecarConfiguration{
+		Image:           recoveryArchive.Spec.InstanceSidecarConfiguration.Image,
+		ImagePullPolicy: recoveryArchive.Spec.InstanceSidecarConfiguration.ImagePullPolicy,
+	}
+
+	if restoreJobConfiguration := recoveryArchive.Spec.RestoreJobSidecarConfiguration; restoreJobConfiguration != nil {
+		log.FromContext(ctx).WithName("lifecycle").Info(
+			"Loading restore job sidecar configuration from the recovery archive object.")
+		env, err := impl.collectAdditionalEnvs(ctx, archive, nil)
+		if err != nil {
+			return nil, err
+		}
+		sidecar.Env = append(env, restoreJobConfiguration.Env...)
+		sidecar.Resources = &restoreJobConfiguration.Resources
+		sidecar.SecurityContext = restoreJobConfiguration.SecurityContext
+		sidecar.Volumes = slices.Concat(restoreJobConfiguration.ExtraVolumes, webIdentityVolumes)
+		sidecar.VolumeMounts = slices.Concat(restoreJobConfiguration.ExtraVolumeMounts, webIdentityVolumeMounts)
+
+		return reconcileJob(ctx, cluster, request, sidecar)
+	}
+
+	sidecar.Env, err = impl.collectAdditionalEnvs(ctx, archive, recoveryArchive)
 	if err != nil {
 		return nil, err
 	}
-	resources := impl.calculateSidecarResources(ctx, recoveryArchive)
-	securityContext := impl.calculateSidecarSecurityContext(ctx, recoveryArchive)
+	sidecar.Resources = impl.calculateSidecarResources(ctx, recoveryArchive)
+	sidecar.SecurityContext = impl.calculateSidecarSecurityContext(ctx, recoveryArchive)
+	sidecar.Volumes = slices.Concat(
+		recoveryArchive.Spec.InstanceSidecarConfiguration.ExtraVolumes, webIdentityVolumes)
+	sidecar.VolumeMounts = slices.Concat(
+		recoveryArchive.Spec.InstanceSidecarConfiguration.ExtraVolumeMounts, webIdentityVolumeMounts)
 
-	return reconcileJob(ctx, cluster, request, env, resources, securityContext)
+	return reconcileJob(ctx, cluster, request, sidecar)
 }
 
 func reconcileJob(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
 	request *lifecycle.OperatorLifecycleRequest,
-	env []corev1.EnvVar,
-	resources *corev1.ResourceRequirements,
-	securityContext *corev1.SecurityContext,
+	sidecar sidecarConfiguration,
 ) (*lifecycle.OperatorLifecycleResponse, error) {
 	contextLogger := log.FromContext(ctx).WithName("lifecycle")
 	if pluginConfig := cluster.GetRecoverySourcePlugin(); pluginConfig == nil || pluginConfig.Name != metadata.PluginName {
@@ -290,7 +382,7 @@ func reconcileJob(
 		corev1.Container{
 			Args: []string{"restore"},
 		},
-		env, resources, securityContext,
+		sidecar,
 	); err != nil {
 		return nil, fmt.Errorf("while reconciling pod spec for job: %w", err)
 	}
@@ -320,10 +412,19 @@ func (impl LifecycleImplementation) reconcilePod(
 	if err != nil {
 		return nil, err
 	}
-	resources := impl.calculateSidecarResources(ctx, archive)
-	securityContext := impl.calculateSidecarSecurityContext(ctx, archive)
-
-	return reconcilePod(ctx, cluster, request, pluginConfiguration, env, resources, securityContext)
+	env = append(buildLimitEnvs(archive), env...)
+	webIdentityVolumes, webIdentityVolumeMounts := specs.BuildWebIdentityVolume(archive, recoveryArchive)
+
+	return reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
+		Env:             env,
+		Resources:       impl.calculateSidecarResources(ctx, archive),
+		SecurityContext: impl.calculateSidecarSecurityContext(ctx, archive),
+		Volumes:         slices.Concat(archive.Spec.InstanceSidecarConfiguration.ExtraVolumes, webIdentityVolumes),
+		VolumeMounts: slices.Concat(
+			archive.Spec.InstanceSidecarConfiguration.ExtraVolumeMounts, webIdentityVolumeMounts),
+		Image:           archive.Spec.InstanceSidecarConfiguration.Image,
+		ImagePullPolicy: archive.Spec.InstanceSidecarConfiguration.ImagePullPolicy,
+	})
 }
 
 func reconcilePod(
@@ -331,9 +432,7 @@ func reconcilePod(
 	cluster *cnpgv1.Cluster,
 	request *lifecycle.OperatorLifecycleRequest,
 	pluginConfiguration *config.PluginConfiguration,
-	env []corev1.EnvVar,
-	resources *corev1.ResourceRequirements,
-	securityContext *corev1.SecurityContext,
+	sidecar sidecarConfiguration,
 ) (*lifecycle.OperatorLifecycleResponse, error) {
 	pod, err := decoder.DecodePodJSON(request.GetObjectDefinition())
 	if err != nil {
@@ -346,14 +445,33 @@ func reconcilePod(
 	mutatedPod := pod.DeepCopy()
 
 	if len(pluginConfiguration.PgbackrestObjectName) != 0 {
+		// The hash of the sidecar configuration is stamped both on the pod, for
+		// inspection, and in the sidecar environment. As CNPG compares the pod
+		// spec of running instances with the one returned by the TYPE_EVALUATE
+		// hook, any change in the Archive makes the pods out of date and rolls them.
+		configurationHash, err := hash.ComputeHash(sidecar)
+		if err != nil {
+			return nil, fmt.Errorf("while computing the sidecar configuration hash: %w", err)
+		}
+		if mutatedPod.Annotations == nil {
+			mutatedPod.Annotations = make(map[string]string)
+		}
+		mutatedPod.Annotations[metadata.SidecarConfigurationHashAnnotationName] = configurationHash
+
 		if err := reconcilePodSpec(
 			cluster,
 			&mutatedPod.Spec,
 			"postgres",
 			corev1.Container{
 				Args: []string{"instance"},
+				Env: []corev1.EnvVar{
+					{
+						Name:  sidecarConfigurationHashEnvName,
+						Value: configurationHash,
+					},
+				},
 			},
-			env, resources, securityContext,
+			sidecar,
 		); err != nil {
 			return nil, fmt.Errorf("while reconciling pod spec for pod: %w", err)
 		}
@@ -377,9 +495,7 @@ func reconcilePodSpec(
 	spec *corev1.PodSpec,
 	mainContainerName string,
 	sidecarConfig corev1.Container,
-	additionalEnvs []corev1.EnvVar,
-	resources *corev1.ResourceRequirements,
-	securityContext *corev1.SecurityContext,
+	sidecar sidecarConfiguration,
 ) error {
 	//nolint:prealloc
 	envs := []corev1.EnvVar{
@@ -397,9 +513,15 @@ func reconcilePodSpec(
 			Name:  "SPOOL_DIRECTORY",
 			Value: "/controller/wal-restore-spool",
 		},
+		{
+			// lets pgbackrest run interactively in the sidecar with the
+			// configuration the plugin keeps in sync with the Archive
+			Name:  "PGBACKREST_CONFIG",
+			Value: common.PgbackrestConfigurationPath,
+		},
 	}
 
-	envs = append(envs, additionalEnvs...)
+	envs = append(envs, sidecar.Env...)
 
 	baseProbe := &corev1.Probe{
 		FailureThreshold: 10,
@@ -414,13 +536,22 @@ func reconcilePodSpec(
 	// fixed values
 	sidecarConfig.Name = "plugin-pgbackrest"
 	sidecarConfig.Image = viper.GetString("sidecar-image")
+	if len(sidecar.Image) > 0 {
+		sidecarConfig.Image = sidecar.Image
+	}
 	sidecarConfig.ImagePullPolicy = cluster.Spec.ImagePullPolicy
+	if len(sidecar.ImagePullPolicy) > 0 {
+		sidecarConfig.ImagePullPolicy = sidecar.ImagePullPolicy
+	}
 	sidecarConfig.StartupProbe = baseProbe.DeepCopy()
 
-	// merge the main container envs if they aren't already set
+	// merge the allowed main container envs if they aren't already set
 	for _, container := range spec.Containers {
 		if container.Name == mainContainerName {
 			for _, env := range container.Env {
+				if !slices.Contains(mainContainerEnvNames, env.Name) {
+					continue
+				}
 				found := false
 				for _, existingEnv := range sidecarConfig.Env {
 					if existingEnv.Name == env.Name {
@@ -450,13 +581,43 @@ func reconcilePodSpec(
 		}
 	}
 
-	if resources != nil {
-		sidecarConfig.Resources = *resources
+	if sidecar.Resources != nil {
+		sidecarConfig.Resources = *sidecar.Resources
 	}
 
-	if securityContext != nil {
-		sidecarConfig.SecurityContext = securityContext
+	if sidecar.SecurityContext != nil {
+		sidecarConfig.SecurityContext = sidecar.SecurityContext
+	}
+
+	// the pgBackRest secure options are written to memory-backed configuration files
+	volumes := slices.Concat(sidecar.Volumes, []corev1.Volume{{
+		Name: secretsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+		},
+	}})
+	sidecarConfig.VolumeMounts = append(sidecarConfig.VolumeMounts, corev1.VolumeMount{
+		Name:      secretsVolumeName,
+		MountPath: credentials.SecretsConfigDirectory,
+	})
+
+	// A volume of the pod with the same name is only accepted when it is the
+	// one already injected by the plugin, otherwise its mount in the sidecar
+	// would point to the wrong volume
+	for _, volume := range volumes {
+		index := slices.IndexFunc(spec.Volumes, func(existing corev1.Volume) bool {
+			return existing.Name == volume.Name
+		})
+		if index < 0 {
+			spec.Volumes = append(spec.Volumes, volume)
+			continue
+		}
+		if !equality.Semantic.DeepDerivative(volume, spec.Volumes[index]) {
+			return fmt.Errorf("volume %s of the sidecar conflicts with a volume of the pod with the same name",
+				volume.Name)
+		}
 	}
+	sidecarConfig.VolumeMounts = append(sidecarConfig.VolumeMounts, sidecar.VolumeMounts...)
 
 	if err := InjectPluginSidecarPodSpec(spec, &sidecarConfig, mainContainerName, true); err != nil {
 		return err
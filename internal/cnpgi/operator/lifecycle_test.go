@@ -20,17 +20,23 @@ package operator
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
 	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -127,7 +133,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: jobJSON,
 			}
 
-			response, err := reconcileJob(ctx, cluster, request, nil, nil, nil)
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -148,7 +154,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: jobJSON,
 			}
 
-			response, err := reconcileJob(ctx, cluster, request, nil, nil, nil)
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).To(BeNil())
 		})
@@ -158,7 +164,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: []byte("invalid-json"),
 			}
 
-			response, err := reconcileJob(ctx, cluster, request, nil, nil, nil)
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{})
 			Expect(err).To(HaveOccurred())
 			Expect(response).To(BeNil())
 		})
@@ -185,7 +191,7 @@ var _ = Describe("LifecycleImplementation", func() {
 					ObjectDefinition: jobJSON,
 				}
 
-				response, err := reconcileJob(ctx, cluster, request, nil, nil, nil)
+				response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{})
 				Expect(err).NotTo(HaveOccurred())
 				Expect(response).To(BeNil())
 			})
@@ -205,7 +211,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: podJSON,
 			}
 
-			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, nil, nil, nil)
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -218,12 +224,164 @@ var _ = Describe("LifecycleImplementation", func() {
 				HaveKey("value")))
 		})
 
+		It("stamps the sidecar configuration hash on the pod", func(ctx SpecContext) {
+			pod := &corev1.Pod{
+				TypeMeta: podTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-pod",
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}},
+			}
+			podJSON, _ := json.Marshal(pod)
+			request := &lifecycle.OperatorLifecycleRequest{
+				ObjectDefinition: podJSON,
+			}
+
+			getHashes := func(resources *corev1.ResourceRequirements) (string, string) {
+				response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
+					Resources: resources,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				var patch []struct {
+					Path  string          `json:"path"`
+					Value json.RawMessage `json:"value"`
+				}
+				Expect(json.Unmarshal(response.JsonPatch, &patch)).To(Succeed())
+
+				var annotation, envValue string
+				for _, operation := range patch {
+					switch operation.Path {
+					case "/metadata/annotations":
+						var annotations map[string]string
+						Expect(json.Unmarshal(operation.Value, &annotations)).To(Succeed())
+						annotation = annotations[metadata.SidecarConfigurationHashAnnotationName]
+					case "/spec/initContainers":
+						var initContainers []corev1.Container
+						Expect(json.Unmarshal(operation.Value, &initContainers)).To(Succeed())
+						Expect(initContainers).To(HaveLen(1))
+						for _, env := range initContainers[0].Env {
+							if env.Name == sidecarConfigurationHashEnvName {
+								envValue = env.Value
+							}
+						}
+					}
+				}
+				return annotation, envValue
+			}
+
+			annotation, envValue := getHashes(nil)
+			Expect(annotation).NotTo(BeEmpty())
+			Expect(envValue).To(Equal(annotation))
+
+			changedAnnotation, _ := getHashes(&corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			})
+			Expect(changedAnnotation).NotTo(Equal(annotation))
+		})
+
+		It("overrides the sidecar image and pull policy", func(ctx SpecContext) {
+			pod := &corev1.Pod{
+				TypeMeta: podTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-pod",
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}},
+			}
+			podJSON, _ := json.Marshal(pod)
+			request := &lifecycle.OperatorLifecycleRequest{
+				ObjectDefinition: podJSON,
+			}
+
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
+				Image:           "sidecar-pgbackrest:pinned",
+				ImagePullPolicy: corev1.PullAlways,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			var patch []struct {
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			Expect(json.Unmarshal(response.JsonPatch, &patch)).To(Succeed())
+
+			var initContainers []corev1.Container
+			for _, operation := range patch {
+				if operation.Path == "/spec/initContainers" {
+					Expect(json.Unmarshal(operation.Value, &initContainers)).To(Succeed())
+				}
+			}
+			Expect(initContainers).To(HaveLen(1))
+			Expect(initContainers[0].Image).To(Equal("sidecar-pgbackrest:pinned"))
+			Expect(initContainers[0].ImagePullPolicy).To(Equal(corev1.PullAlways))
+		})
+
+		It("keeps unrelated variables out of the sidecar and mounts the secrets volume", func(ctx SpecContext) {
+			pod := &corev1.Pod{
+				TypeMeta: podTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-pod",
+				},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name: "postgres",
+					Env: []corev1.EnvVar{
+						{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"},
+						{Name: "APPLICATION_PASSWORD", Value: "secret"},
+					},
+				}}},
+			}
+			podJSON, _ := json.Marshal(pod)
+			request := &lifecycle.OperatorLifecycleRequest{
+				ObjectDefinition: podJSON,
+			}
+
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
+			Expect(err).NotTo(HaveOccurred())
+
+			var patch []struct {
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			Expect(json.Unmarshal(response.JsonPatch, &patch)).To(Succeed())
+
+			var initContainers []corev1.Container
+			var volumes []corev1.Volume
+			for _, operation := range patch {
+				switch operation.Path {
+				case "/spec/initContainers":
+					Expect(json.Unmarshal(operation.Value, &initContainers)).To(Succeed())
+				case "/spec/volumes":
+					Expect(json.Unmarshal(operation.Value, &volumes)).To(Succeed())
+				}
+			}
+			Expect(initContainers).To(HaveLen(1))
+
+			var envNames []string
+			for _, env := range initContainers[0].Env {
+				envNames = append(envNames, env.Name)
+			}
+			Expect(envNames).To(ContainElement("PGDATA"))
+			Expect(envNames).NotTo(ContainElement("APPLICATION_PASSWORD"))
+
+			Expect(volumes).To(ContainElement(corev1.Volume{
+				Name: secretsVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+				},
+			}))
+			Expect(initContainers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      secretsVolumeName,
+				MountPath: credentials.SecretsConfigDirectory,
+			}))
+		})
+
 		It("returns an error for invalid pod definition", func(ctx SpecContext) {
 			request := &lifecycle.OperatorLifecycleRequest{
 				ObjectDefinition: []byte("invalid-json"),
 			}
 
-			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, nil, nil, nil)
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
 			Expect(err).To(HaveOccurred())
 			Expect(response).To(BeNil())
 		})
@@ -311,7 +469,9 @@ var _ = Describe("LifecycleImplementation", func() {
 				},
 			}
 
-			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, nil, nil, customSecurityContext)
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
+				SecurityContext: customSecurityContext,
+			})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -353,7 +513,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: podJSON,
 			}
 
-			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, nil, nil, nil)
+			response, err := reconcilePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -383,6 +543,143 @@ var _ = Describe("LifecycleImplementation", func() {
 		})
 	})
 
+	Describe("reconcileJob with extra volumes", func() {
+		It("adds the volumes to the job and mounts them in the sidecar", func(ctx SpecContext) {
+			job := &batchv1.Job{
+				TypeMeta: jobTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-job",
+					Labels: map[string]string{},
+				},
+				Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							utils.JobRoleLabelName: "full-recovery",
+						},
+					},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "full-recovery"}}},
+				}},
+			}
+			jobJSON, _ := json.Marshal(job)
+			request := &lifecycle.OperatorLifecycleRequest{
+				ObjectDefinition: jobJSON,
+			}
+
+			volumes := []corev1.Volume{
+				{
+					Name: "restore-spool",
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
+			}
+			volumeMounts := []corev1.VolumeMount{
+				{
+					Name:      "restore-spool",
+					MountPath: "/restore-spool",
+				},
+			}
+
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{
+				Volumes:      volumes,
+				VolumeMounts: volumeMounts,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response).NotTo(BeNil())
+
+			var patch []struct {
+				Op    string          `json:"op"`
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			Expect(json.Unmarshal(response.JsonPatch, &patch)).To(Succeed())
+
+			var jobVolumes []corev1.Volume
+			var initContainers []corev1.Container
+			for _, operation := range patch {
+				switch operation.Path {
+				case "/spec/template/spec/volumes":
+					Expect(json.Unmarshal(operation.Value, &jobVolumes)).To(Succeed())
+				case "/spec/template/spec/initContainers":
+					Expect(json.Unmarshal(operation.Value, &initContainers)).To(Succeed())
+				}
+			}
+
+			Expect(jobVolumes).To(ContainElement(HaveField("Name", "restore-spool")))
+			Expect(initContainers).To(HaveLen(1))
+			Expect(initContainers[0].VolumeMounts).To(ContainElement(volumeMounts[0]))
+		})
+
+		It("rejects a volume whose name is already used by the pod", func(ctx SpecContext) {
+			job := &batchv1.Job{
+				TypeMeta:   jobTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							utils.JobRoleLabelName: "full-recovery",
+						},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "full-recovery"}},
+						Volumes: []corev1.Volume{{
+							Name: "pgdata",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pgdata"},
+							},
+						}},
+					},
+				}},
+			}
+			jobJSON, _ := json.Marshal(job)
+			request := &lifecycle.OperatorLifecycleRequest{
+				ObjectDefinition: jobJSON,
+			}
+
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{
+				Volumes: []corev1.Volume{{
+					Name:         "pgdata",
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				}},
+				VolumeMounts: []corev1.VolumeMount{{Name: "pgdata", MountPath: "/restore-spool"}},
+			})
+			Expect(err).To(MatchError(ContainSubstring("volume pgdata")))
+			Expect(response).To(BeNil())
+		})
+	})
+
+	Describe("buildLimitEnvs", func() {
+		It("returns nothing when the number of processes is not limited", func() {
+			Expect(buildLimitEnvs(&pgbackrestv1.Archive{})).To(BeEmpty())
+		})
+
+		It("passes the maximum number of processes to the sidecar", func() {
+			archive := &pgbackrestv1.Archive{
+				Spec: pgbackrestv1.ArchiveSpec{
+					InstanceSidecarConfiguration: pgbackrestv1.InstanceSidecarConfiguration{
+						MaxProcesses: ptr.To(int32(4)),
+					},
+				},
+			}
+			Expect(buildLimitEnvs(archive)).To(ConsistOf(corev1.EnvVar{Name: "MAX_PROCESSES", Value: "4"}))
+		})
+
+		It("passes the spool limits to the sidecar", func() {
+			archive := &pgbackrestv1.Archive{
+				Spec: pgbackrestv1.ArchiveSpec{
+					InstanceSidecarConfiguration: pgbackrestv1.InstanceSidecarConfiguration{
+						SpoolQuota:  ptr.To(resource.MustParse("1Gi")),
+						SpoolMaxAge: &metav1.Duration{Duration: 30 * time.Minute},
+					},
+				},
+			}
+			Expect(buildLimitEnvs(archive)).To(ConsistOf(
+				corev1.EnvVar{Name: "SPOOL_QUOTA", Value: "1073741824"},
+				corev1.EnvVar{Name: "SPOOL_MAX_AGE", Value: "30m0s"},
+			))
+		})
+	})
+
 	Describe("reconcileJob with security context", func() {
 		It("applies custom security context to job sidecar when configured", func(ctx SpecContext) {
 			job := &batchv1.Job{
@@ -413,7 +710,9 @@ var _ = Describe("LifecycleImplementation", func() {
 				RunAsGroup:               ptr.To(int64(1000)),
 			}
 
-			response, err := reconcileJob(ctx, cluster, request, nil, nil, customSecurityContext)
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{
+				SecurityContext: customSecurityContext,
+			})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -464,7 +763,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: jobJSON,
 			}
 
-			response, err := reconcileJob(ctx, cluster, request, nil, nil, nil)
+			response, err := reconcileJob(ctx, cluster, request, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -494,4 +793,122 @@ var _ = Describe("LifecycleImplementation", func() {
 			Expect(container).NotTo(HaveKey("securityContext"))
 		})
 	})
+
+	Describe("impl.reconcileJob", func() {
+		var (
+			sourceArchive *pgbackrestv1.Archive
+			request       *lifecycle.OperatorLifecycleRequest
+		)
+
+		BeforeEach(func() {
+			cluster.Namespace = "default"
+			sourceArchive = &pgbackrestv1.Archive{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "minio-store-source"},
+				Spec: pgbackrestv1.ArchiveSpec{
+					InstanceSidecarConfiguration: pgbackrestv1.InstanceSidecarConfiguration{
+						Env: []corev1.EnvVar{{Name: "INSTANCE", Value: "true"}},
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+						},
+						ExtraVolumes: []corev1.Volume{{Name: "instance-spool"}},
+						ExtraVolumeMounts: []corev1.VolumeMount{
+							{Name: "instance-spool", MountPath: "/instance-spool"},
+						},
+					},
+				},
+			}
+
+			job := &batchv1.Job{
+				TypeMeta:   jobTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "test-job"},
+				Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{
+							utils.JobRoleLabelName: "full-recovery",
+						},
+					},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "full-recovery"}}},
+				}},
+			}
+			jobJSON, err := json.Marshal(job)
+			Expect(err).NotTo(HaveOccurred())
+			request = &lifecycle.OperatorLifecycleRequest{ObjectDefinition: jobJSON}
+		})
+
+		reconcile := func(ctx SpecContext) (corev1.Container, []corev1.Volume) {
+			scheme := runtime.NewScheme()
+			Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+			destinationArchive := &pgbackrestv1.Archive{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "minio-store-dest"},
+				Spec: pgbackrestv1.ArchiveSpec{
+					InstanceSidecarConfiguration: pgbackrestv1.InstanceSidecarConfiguration{
+						Env: []corev1.EnvVar{{Name: "DESTINATION", Value: "true"}},
+					},
+				},
+			}
+			impl := LifecycleImplementation{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(sourceArchive, destinationArchive).Build(),
+			}
+
+			response, err := impl.reconcileJob(ctx, cluster, request, config.NewFromCluster(cluster))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response).NotTo(BeNil())
+
+			var patch []struct {
+				Path  string          `json:"path"`
+				Value json.RawMessage `json:"value"`
+			}
+			Expect(json.Unmarshal(response.JsonPatch, &patch)).To(Succeed())
+
+			var jobVolumes []corev1.Volume
+			var initContainers []corev1.Container
+			for _, operation := range patch {
+				switch operation.Path {
+				case "/spec/template/spec/volumes":
+					Expect(json.Unmarshal(operation.Value, &jobVolumes)).To(Succeed())
+				case "/spec/template/spec/initContainers":
+					Expect(json.Unmarshal(operation.Value, &initContainers)).To(Succeed())
+				}
+			}
+			Expect(initContainers).To(HaveLen(1))
+			return initContainers[0], jobVolumes
+		}
+
+		It("uses the instance sidecar configuration of the recovery archive by default", func(ctx SpecContext) {
+			sidecar, volumes := reconcile(ctx)
+			Expect(sidecar.Env).To(ContainElements(
+				corev1.EnvVar{Name: "INSTANCE", Value: "true"},
+				corev1.EnvVar{Name: "DESTINATION", Value: "true"},
+			))
+			Expect(sidecar.Resources.Limits.Memory().String()).To(Equal("1Gi"))
+			Expect(volumes).To(ContainElement(HaveField("Name", "instance-spool")))
+		})
+
+		It("selects the restore job sidecar configuration when set", func(ctx SpecContext) {
+			sourceArchive.Spec.RestoreJobSidecarConfiguration = &pgbackrestv1.RestoreJobSidecarConfiguration{
+				Env: []corev1.EnvVar{{Name: "RESTORE_JOB", Value: "true"}},
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+				},
+				SecurityContext: &corev1.SecurityContext{RunAsNonRoot: ptr.To(true)},
+				ExtraVolumes:    []corev1.Volume{{Name: "restore-spool"}},
+				ExtraVolumeMounts: []corev1.VolumeMount{
+					{Name: "restore-spool", MountPath: "/restore-spool"},
+				},
+			}
+
+			sidecar, volumes := reconcile(ctx)
+			Expect(sidecar.Env).To(ContainElements(
+				corev1.EnvVar{Name: "RESTORE_JOB", Value: "true"},
+				corev1.EnvVar{Name: "DESTINATION", Value: "true"},
+			))
+			Expect(sidecar.Env).NotTo(ContainElement(corev1.EnvVar{Name: "INSTANCE", Value: "true"}))
+			Expect(sidecar.Resources.Limits.Memory().String()).To(Equal("4Gi"))
+			Expect(sidecar.SecurityContext).To(Equal(&corev1.SecurityContext{RunAsNonRoot: ptr.To(true)}))
+			Expect(sidecar.VolumeMounts).To(ContainElement(
+				corev1.VolumeMount{Name: "restore-spool", MountPath: "/restore-spool"}))
+			Expect(volumes).To(ContainElement(HaveField("Name", "restore-spool")))
+			Expect(volumes).NotTo(ContainElement(HaveField("Name", "instance-spool")))
+		})
+	})
 })
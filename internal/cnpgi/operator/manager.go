@@ -125,10 +125,54 @@ func Start(ctx context.Context) error {
 	}
 
 	if err = (&controller.ArchiveReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		SidecarImage: viper.GetString("sidecar-image"),
+		Recorder:     mgr.GetEventRecorder("plugin-pgbackrest"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Archive")
+		return err
+	}
+
+	if err = (&controller.RestoreTestReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		SidecarImage: viper.GetString("sidecar-image"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RestoreTest")
+		return err
+	}
+
+	if err = (&controller.ClusterArchiveReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorder("plugin-pgbackrest"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterArchive")
+		return err
+	}
+
+	if err = (&controller.ClusterArchiveRBACReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Archive")
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterArchiveRBAC")
+		return err
+	}
+
+	if err = (&controller.BackupSyncReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BackupSync")
+		return err
+	}
+
+	if err = (&controller.RestorePointRBACReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RestorePointRBAC")
 		return err
 	}
 	// +kubebuilder:scaffold:builder
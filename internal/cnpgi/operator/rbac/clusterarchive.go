@@ -0,0 +1,226 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+)
+
+// ReconcileClusterArchives grants the cluster access to the passed
+// ClusterArchives and to the Secrets they refer to, which live in their
+// credentials namespaces, and revokes the access to any other ClusterArchive.
+// As these objects can't be owned by the cluster, they are owned by the
+// ClusterArchives instead, and removed by RevokeClusterArchives once the
+// cluster is deleted.
+func ReconcileClusterArchives(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	clusterArchives []pgbackrestv1.ClusterArchive,
+) error {
+	clusterKey := client.ObjectKeyFromObject(cluster)
+	if len(clusterArchives) == 0 {
+		return RevokeClusterArchives(ctx, c, clusterKey)
+	}
+
+	owners := make([]client.Object, 0, len(clusterArchives))
+	clusterArchivesByNamespace := make(map[string][]pgbackrestv1.ClusterArchive)
+	for i := range clusterArchives {
+		owners = append(owners, &clusterArchives[i])
+		credentialsNamespace := clusterArchives[i].Spec.CredentialsNamespace
+		clusterArchivesByNamespace[credentialsNamespace] = append(
+			clusterArchivesByNamespace[credentialsNamespace], clusterArchives[i])
+	}
+
+	desired := []client.Object{
+		specs.BuildClusterRole(cluster, clusterArchives),
+		specs.BuildClusterRoleBinding(cluster),
+	}
+	if err := ensureObject(ctx, c, desired[0], &rbacv1.ClusterRole{}, owners); err != nil {
+		return err
+	}
+	if err := ensureObject(ctx, c, desired[1], &rbacv1.ClusterRoleBinding{}, owners); err != nil {
+		return err
+	}
+
+	for credentialsNamespace, namespaceArchives := range clusterArchivesByNamespace {
+		namespaceOwners := make([]client.Object, 0, len(namespaceArchives))
+		for i := range namespaceArchives {
+			namespaceOwners = append(namespaceOwners, &namespaceArchives[i])
+		}
+
+		role := specs.BuildCredentialsRole(cluster, credentialsNamespace, namespaceArchives)
+		if err := ensureObject(ctx, c, role, &rbacv1.Role{}, namespaceOwners); err != nil {
+			return err
+		}
+		roleBinding := specs.BuildCredentialsRoleBinding(cluster, credentialsNamespace)
+		if err := ensureObject(ctx, c, roleBinding, &rbacv1.RoleBinding{}, namespaceOwners); err != nil {
+			return err
+		}
+		desired = append(desired, role, roleBinding)
+	}
+
+	return deleteObjects(ctx, c, clusterKey, desired)
+}
+
+// RevokeClusterArchives removes every RBAC object granting the cluster
+// identified by clusterKey access to ClusterArchives
+func RevokeClusterArchives(ctx context.Context, c client.Client, clusterKey client.ObjectKey) error {
+	return deleteObjects(ctx, c, clusterKey, nil)
+}
+
+// deleteObjects removes the RBAC objects created for the cluster identified
+// by clusterKey, except for the kept ones. The bindings are removed first.
+func deleteObjects(
+	ctx context.Context,
+	c client.Client,
+	clusterKey client.ObjectKey,
+	kept []client.Object,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	lists := []client.ObjectList{
+		&rbacv1.ClusterRoleBindingList{},
+		&rbacv1.RoleBindingList{},
+		&rbacv1.ClusterRoleList{},
+		&rbacv1.RoleList{},
+	}
+	for _, list := range lists {
+		if err := c.List(ctx, list,
+			client.MatchingLabels(specs.GetClusterScopedRBACLabels(clusterKey.Namespace, clusterKey.Name)),
+		); err != nil {
+			return fmt.Errorf("while listing the RBAC objects of the cluster: %w", err)
+		}
+
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			object, ok := item.(client.Object)
+			if !ok || isKept(object, kept) {
+				continue
+			}
+
+			contextLogger.Info(
+				"Deleting RBAC object",
+				"kind", fmt.Sprintf("%T", object),
+				"name", object.GetName(),
+				"namespace", object.GetNamespace(),
+			)
+			if err := c.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("while deleting %T %s: %w", object, object.GetName(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isKept(object client.Object, kept []client.Object) bool {
+	for _, keptObject := range kept {
+		if reflect.TypeOf(keptObject) == reflect.TypeOf(object) &&
+			keptObject.GetNamespace() == object.GetNamespace() &&
+			keptObject.GetName() == object.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureObject creates the passed RBAC object when missing, or updates its
+// labels, rules, subjects and owners otherwise
+func ensureObject(
+	ctx context.Context,
+	c client.Client,
+	newObject client.Object,
+	currentObject client.Object,
+	owners []client.Object,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	for _, owner := range owners {
+		if err := controllerutil.SetOwnerReference(owner, newObject, c.Scheme()); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(newObject), currentObject); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return err
+		}
+
+		contextLogger.Info(
+			"Creating RBAC object",
+			"kind", fmt.Sprintf("%T", newObject),
+			"name", newObject.GetName(),
+			"namespace", newObject.GetNamespace(),
+		)
+		return c.Create(ctx, newObject)
+	}
+
+	patch := client.MergeFrom(currentObject.DeepCopyObject().(client.Object))
+	changed := !equality.Semantic.DeepEqual(newObject.GetOwnerReferences(), currentObject.GetOwnerReferences()) ||
+		!equality.Semantic.DeepEqual(newObject.GetLabels(), currentObject.GetLabels())
+	currentObject.SetOwnerReferences(newObject.GetOwnerReferences())
+	currentObject.SetLabels(newObject.GetLabels())
+
+	switch current := currentObject.(type) {
+	case *rbacv1.Role:
+		rules := newObject.(*rbacv1.Role).Rules
+		changed = changed || !equality.Semantic.DeepEqual(rules, current.Rules)
+		current.Rules = rules
+	case *rbacv1.ClusterRole:
+		rules := newObject.(*rbacv1.ClusterRole).Rules
+		changed = changed || !equality.Semantic.DeepEqual(rules, current.Rules)
+		current.Rules = rules
+	case *rbacv1.RoleBinding:
+		subjects := newObject.(*rbacv1.RoleBinding).Subjects
+		changed = changed || !equality.Semantic.DeepEqual(subjects, current.Subjects)
+		current.Subjects = subjects
+	case *rbacv1.ClusterRoleBinding:
+		subjects := newObject.(*rbacv1.ClusterRoleBinding).Subjects
+		changed = changed || !equality.Semantic.DeepEqual(subjects, current.Subjects)
+		current.Subjects = subjects
+	}
+
+	if !changed {
+		// There's no need to hit the API server again
+		return nil
+	}
+
+	contextLogger.Info(
+		"Patching RBAC object",
+		"kind", fmt.Sprintf("%T", newObject),
+		"name", newObject.GetName(),
+		"namespace", newObject.GetNamespace(),
+	)
+	return c.Patch(ctx, currentObject, patch)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package rbac
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClusterArchive RBAC", func() {
+	var (
+		c          client.Client
+		cluster    *cnpgv1.Cluster
+		archiveA   pgbackrestv1.ClusterArchive
+		archiveB   pgbackrestv1.ClusterArchive
+		objectName string
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example"},
+		}
+		archiveA = pgbackrestv1.ClusterArchive{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-a", UID: "a"},
+			Spec:       pgbackrestv1.ClusterArchiveSpec{CredentialsNamespace: "credentials-a"},
+		}
+		archiveB = pgbackrestv1.ClusterArchive{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-b", UID: "b"},
+			Spec:       pgbackrestv1.ClusterArchiveSpec{CredentialsNamespace: "credentials-b"},
+		}
+		objectName = specs.GetClusterScopedRBACName(cluster)
+	})
+
+	getRoleNamespaces := func(ctx SpecContext) []string {
+		var roles rbacv1.RoleList
+		Expect(c.List(ctx, &roles)).To(Succeed())
+		var namespaces []string
+		for _, role := range roles.Items {
+			namespaces = append(namespaces, role.Namespace)
+		}
+		var roleBindings rbacv1.RoleBindingList
+		Expect(c.List(ctx, &roleBindings)).To(Succeed())
+		Expect(roleBindings.Items).To(HaveLen(len(namespaces)))
+		return namespaces
+	}
+
+	It("grants access to the ClusterArchives and their credentials namespaces", func(ctx SpecContext) {
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA, archiveB})).To(Succeed())
+
+		var clusterRole rbacv1.ClusterRole
+		Expect(c.Get(ctx, client.ObjectKey{Name: objectName}, &clusterRole)).To(Succeed())
+		Expect(clusterRole.Rules[0].ResourceNames).To(Equal([]string{"shared-a", "shared-b"}))
+		Expect(clusterRole.OwnerReferences).To(HaveLen(2))
+
+		var clusterRoleBinding rbacv1.ClusterRoleBinding
+		Expect(c.Get(ctx, client.ObjectKey{Name: objectName}, &clusterRoleBinding)).To(Succeed())
+
+		Expect(getRoleNamespaces(ctx)).To(ConsistOf("credentials-a", "credentials-b"))
+	})
+
+	It("revokes the access to the ClusterArchives not used anymore", func(ctx SpecContext) {
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA, archiveB})).To(Succeed())
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveB})).To(Succeed())
+
+		var clusterRole rbacv1.ClusterRole
+		Expect(c.Get(ctx, client.ObjectKey{Name: objectName}, &clusterRole)).To(Succeed())
+		Expect(clusterRole.Rules[0].ResourceNames).To(Equal([]string{"shared-b"}))
+		Expect(clusterRole.OwnerReferences).To(HaveLen(1))
+
+		Expect(getRoleNamespaces(ctx)).To(ConsistOf("credentials-b"))
+	})
+
+	It("revokes every access when no ClusterArchive is used", func(ctx SpecContext) {
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA})).To(Succeed())
+		Expect(ReconcileClusterArchives(ctx, c, cluster, nil)).To(Succeed())
+
+		var clusterRoles rbacv1.ClusterRoleList
+		Expect(c.List(ctx, &clusterRoles)).To(Succeed())
+		Expect(clusterRoles.Items).To(BeEmpty())
+		var clusterRoleBindings rbacv1.ClusterRoleBindingList
+		Expect(c.List(ctx, &clusterRoleBindings)).To(Succeed())
+		Expect(clusterRoleBindings.Items).To(BeEmpty())
+		Expect(getRoleNamespaces(ctx)).To(BeEmpty())
+	})
+
+	It("only revokes the access of the passed cluster", func(ctx SpecContext) {
+		other := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "cluster-example"},
+		}
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA})).To(Succeed())
+		Expect(ReconcileClusterArchives(ctx, c, other,
+			[]pgbackrestv1.ClusterArchive{archiveA})).To(Succeed())
+
+		Expect(RevokeClusterArchives(ctx, c, client.ObjectKeyFromObject(cluster))).To(Succeed())
+
+		var clusterRoleBindings rbacv1.ClusterRoleBindingList
+		Expect(c.List(ctx, &clusterRoleBindings)).To(Succeed())
+		Expect(clusterRoleBindings.Items).To(HaveLen(1))
+		Expect(clusterRoleBindings.Items[0].Name).To(Equal(specs.GetClusterScopedRBACName(other)))
+		Expect(getRoleNamespaces(ctx)).To(ConsistOf("credentials-a"))
+	})
+
+	It("corrects the subjects of the bindings", func(ctx SpecContext) {
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA})).To(Succeed())
+
+		var clusterRoleBinding rbacv1.ClusterRoleBinding
+		Expect(c.Get(ctx, client.ObjectKey{Name: objectName}, &clusterRoleBinding)).To(Succeed())
+		clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects,
+			rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-b", Name: "intruder"})
+		Expect(c.Update(ctx, &clusterRoleBinding)).To(Succeed())
+
+		var roleBinding rbacv1.RoleBinding
+		roleBindingKey := client.ObjectKey{Namespace: "credentials-a", Name: objectName}
+		Expect(c.Get(ctx, roleBindingKey, &roleBinding)).To(Succeed())
+		roleBinding.Subjects = nil
+		Expect(c.Update(ctx, &roleBinding)).To(Succeed())
+
+		Expect(ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{archiveA})).To(Succeed())
+
+		expected := specs.BuildClusterRoleBinding(cluster).Subjects
+		Expect(c.Get(ctx, client.ObjectKey{Name: objectName}, &clusterRoleBinding)).To(Succeed())
+		Expect(clusterRoleBinding.Subjects).To(Equal(expected))
+		Expect(c.Get(ctx, roleBindingKey, &roleBinding)).To(Succeed())
+		Expect(roleBinding.Subjects).To(Equal(expected))
+	})
+})
@@ -0,0 +1,48 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+)
+
+// ReconcileRestorePoints grants the cluster access to its pending
+// RestorePoints among the passed ones. The Role and RoleBinding are owned by
+// the cluster, and removed together with it.
+func ReconcileRestorePoints(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	restorePoints []pgbackrestv1.RestorePoint,
+) error {
+	owners := []client.Object{cluster}
+
+	role := specs.BuildRestorePointRole(cluster, restorePoints)
+	if err := ensureObject(ctx, c, role, &rbacv1.Role{}, owners); err != nil {
+		return err
+	}
+
+	roleBinding := specs.BuildRestorePointRoleBinding(cluster)
+	return ensureObject(ctx, c, roleBinding, &rbacv1.RoleBinding{}, owners)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestorePoint RBAC", func() {
+	It("grants access to the pending restore points of the cluster", func(ctx SpecContext) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(cnpgv1.AddToScheme(scheme)).To(Succeed())
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example", UID: "cluster"},
+		}
+		restorePoints := []pgbackrestv1.RestorePoint{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "before-upgrade"},
+			Spec:       pgbackrestv1.RestorePointSpec{Cluster: cnpgv1.LocalObjectReference{Name: "cluster-example"}},
+		}}
+		key := client.ObjectKey{Namespace: "team-a", Name: specs.GetRestorePointRBACName(cluster.Name)}
+
+		Expect(ReconcileRestorePoints(ctx, c, cluster, restorePoints)).To(Succeed())
+		var role rbacv1.Role
+		Expect(c.Get(ctx, key, &role)).To(Succeed())
+		Expect(specs.GetRestorePointNames(&role)).To(Equal([]string{"before-upgrade"}))
+		Expect(role.OwnerReferences).To(ConsistOf(HaveField("UID", cluster.UID)))
+		var roleBinding rbacv1.RoleBinding
+		Expect(c.Get(ctx, key, &roleBinding)).To(Succeed())
+		Expect(roleBinding.RoleRef.Name).To(Equal(key.Name))
+
+		restorePoints[0].Status.Phase = pgbackrestv1.RestorePointPhaseCompleted
+		Expect(ReconcileRestorePoints(ctx, c, cluster, restorePoints)).To(Succeed())
+		Expect(c.Get(ctx, key, &role)).To(Succeed())
+		Expect(specs.GetRestorePointNames(&role)).To(BeEmpty())
+	})
+})
@@ -19,6 +19,7 @@ package operator
 
 import (
 	"context"
+	"fmt"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
@@ -92,14 +93,33 @@ func (r ReconcilerImplementation) Pre(
 	contextLogger.Debug("parsing pgbackrest archive object configuration")
 
 	archiveObjects := make([]pgbackrestv1.Archive, 0, len(pluginConfiguration.GetReferredArchiveObjectsKey()))
+	clusterArchiveObjects := make([]pgbackrestv1.ClusterArchive, 0)
+	var notAllowedErr error
 	for _, archiveObjectKey := range pluginConfiguration.GetReferredArchiveObjectsKey() {
-		var archiveObject pgbackrestv1.Archive
-		if err := r.Client.Get(ctx, archiveObjectKey, &archiveObject); err != nil {
+		var err error
+		if len(archiveObjectKey.Namespace) == 0 {
+			var clusterArchiveObject pgbackrestv1.ClusterArchive
+			if err = r.Client.Get(ctx, archiveObjectKey, &clusterArchiveObject); err == nil {
+				if !clusterArchiveObject.IsNamespaceAllowed(cluster.Namespace) {
+					notAllowedErr = fmt.Errorf("%w: ClusterArchive %s, namespace %s",
+						config.ErrClusterArchiveNotAllowed, archiveObjectKey.Name, cluster.Namespace)
+					continue
+				}
+				clusterArchiveObjects = append(clusterArchiveObjects, clusterArchiveObject)
+			}
+		} else {
+			var archiveObject pgbackrestv1.Archive
+			if err = r.Client.Get(ctx, archiveObjectKey, &archiveObject); err == nil {
+				archiveObjects = append(archiveObjects, archiveObject)
+			}
+		}
+
+		if err != nil {
 			if apierrs.IsNotFound(err) {
 				contextLogger.Info(
 					"pgbackrest archive object configuration not found, requeuing",
-					"name", pluginConfiguration.PgbackrestObjectName,
-					"namespace", cluster.Namespace)
+					"name", archiveObjectKey.Name,
+					"namespace", archiveObjectKey.Namespace)
 				return &reconciler.ReconcilerHooksResult{
 					Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
 				}, nil
@@ -107,8 +127,23 @@ func (r ReconcilerImplementation) Pre(
 
 			return nil, err
 		}
+	}
+
+	if notAllowedErr != nil {
+		return nil, notAllowedErr
+	}
 
-		archiveObjects = append(archiveObjects, archiveObject)
+	// The access to the ClusterArchives is granted by the ClusterArchive RBAC
+	// controller, which the cluster waits for
+	granted, err := r.isClusterArchivesAccessGranted(ctx, &cluster, clusterArchiveObjects)
+	if err != nil {
+		return nil, err
+	}
+	if !granted {
+		contextLogger.Info("Waiting for the access to the ClusterArchives to be granted, requeuing")
+		return &reconciler.ReconcilerHooksResult{
+			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
+		}, nil
 	}
 
 	if err := r.ensureRole(ctx, &cluster, archiveObjects); err != nil {
@@ -138,6 +173,32 @@ func (r ReconcilerImplementation) Post(
 	}, nil
 }
 
+// isClusterArchivesAccessGranted checks whether the cluster was granted the
+// access to the passed ClusterArchives
+func (r ReconcilerImplementation) isClusterArchivesAccessGranted(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	clusterArchives []pgbackrestv1.ClusterArchive,
+) (bool, error) {
+	if len(clusterArchives) == 0 {
+		return true, nil
+	}
+
+	key := client.ObjectKey{Name: specs.GetClusterScopedRBACName(cluster)}
+	var clusterRole rbacv1.ClusterRole
+	if err := r.Client.Get(ctx, key, &clusterRole); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !equality.Semantic.DeepEqual(specs.BuildClusterRole(cluster, clusterArchives).Rules, clusterRole.Rules) {
+		return false, nil
+	}
+
+	if err := r.Client.Get(ctx, key, &rbacv1.ClusterRoleBinding{}); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
 func (r ReconcilerImplementation) ensureRole(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
@@ -193,20 +254,34 @@ func (r ReconcilerImplementation) ensureRoleBinding(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
 ) error {
-	var role rbacv1.RoleBinding
+	var roleBinding rbacv1.RoleBinding
 	if err := r.Client.Get(ctx, client.ObjectKey{
 		Namespace: cluster.Namespace,
 		Name:      specs.GetRBACName(cluster.Name),
-	}, &role); err != nil {
+	}, &roleBinding); err != nil {
 		if apierrs.IsNotFound(err) {
 			return r.createRoleBinding(ctx, cluster)
 		}
 		return err
 	}
 
-	// TODO: this assumes role bindings never change.
-	// Is that true? Should we relax this assumption?
-	return nil
+	// The role reference can't change, while the service account of the
+	// cluster may
+	subjects := specs.BuildRoleBinding(cluster).Subjects
+	if equality.Semantic.DeepEqual(subjects, roleBinding.Subjects) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info(
+		"Patching role binding",
+		"name", roleBinding.Name,
+		"namespace", roleBinding.Namespace,
+		"subjects", subjects,
+	)
+
+	patch := client.MergeFrom(roleBinding.DeepCopy())
+	roleBinding.Subjects = subjects
+	return r.Client.Patch(ctx, &roleBinding, patch)
 }
 
 func (r ReconcilerImplementation) createRoleBinding(
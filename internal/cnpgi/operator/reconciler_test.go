@@ -0,0 +1,135 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operator
+
+import (
+	"encoding/json"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/rbac"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReconcilerImplementation Pre", func() {
+	var (
+		c              client.Client
+		impl           ReconcilerImplementation
+		cluster        *cnpgv1.Cluster
+		clusterArchive *pgbackrestv1.ClusterArchive
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(cnpgv1.AddToScheme(scheme)).To(Succeed())
+		Expect(pgbackrestv1.AddToScheme(scheme)).To(Succeed())
+
+		cluster = &cnpgv1.Cluster{
+			TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: "postgresql.cnpg.io/v1"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example", UID: "cluster"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{{
+					Name: metadata.PluginName,
+					Parameters: map[string]string{
+						"pgbackrestObjectName": "shared",
+						"pgbackrestObjectKind": config.ClusterArchiveKind,
+					},
+				}},
+			},
+		}
+		clusterArchive = &pgbackrestv1.ClusterArchive{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", UID: "shared"},
+			Spec: pgbackrestv1.ClusterArchiveSpec{
+				CredentialsNamespace: "credentials",
+				AllowedNamespaces:    []string{"team-a"},
+			},
+		}
+
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterArchive).Build()
+		impl = ReconcilerImplementation{Client: c}
+	})
+
+	pre := func(ctx SpecContext) (*reconciler.ReconcilerHooksResult, error) {
+		definition, err := json.Marshal(cluster)
+		Expect(err).ToNot(HaveOccurred())
+		return impl.Pre(ctx, &reconciler.ReconcilerHooksRequest{ResourceDefinition: definition})
+	}
+
+	clusterScopedKey := func() client.ObjectKey {
+		return client.ObjectKey{Name: specs.GetClusterScopedRBACName(cluster)}
+	}
+	credentialsKey := func() client.ObjectKey {
+		return client.ObjectKey{Namespace: "credentials", Name: specs.GetClusterScopedRBACName(cluster)}
+	}
+
+	It("waits for the access to the ClusterArchive to be granted", func(ctx SpecContext) {
+		result, err := pre(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Behavior).To(Equal(reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE))
+
+		By("granting the access")
+		Expect(rbac.ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{*clusterArchive})).To(Succeed())
+		result, err = pre(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Behavior).To(Equal(reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE))
+	})
+
+	It("doesn't touch the access to the ClusterArchives", func(ctx SpecContext) {
+		Expect(rbac.ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{*clusterArchive})).To(Succeed())
+
+		clusterArchive.Spec.AllowedNamespaces = []string{"team-b"}
+		Expect(c.Update(ctx, clusterArchive)).To(Succeed())
+
+		_, err := pre(ctx)
+		Expect(err).To(MatchError(config.ErrClusterArchiveNotAllowed))
+		Expect(c.Get(ctx, clusterScopedKey(), &rbacv1.ClusterRoleBinding{})).To(Succeed())
+		Expect(c.Get(ctx, credentialsKey(), &rbacv1.RoleBinding{})).To(Succeed())
+	})
+
+	It("corrects the subjects of the namespaced role binding", func(ctx SpecContext) {
+		Expect(rbac.ReconcileClusterArchives(ctx, c, cluster,
+			[]pgbackrestv1.ClusterArchive{*clusterArchive})).To(Succeed())
+		_, err := pre(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		var roleBinding rbacv1.RoleBinding
+		key := client.ObjectKey{Namespace: "team-a", Name: specs.GetRBACName(cluster.Name)}
+		Expect(c.Get(ctx, key, &roleBinding)).To(Succeed())
+		roleBinding.Subjects = nil
+		Expect(c.Update(ctx, &roleBinding)).To(Succeed())
+
+		_, err = pre(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Get(ctx, key, &roleBinding)).To(Succeed())
+		Expect(roleBinding.Subjects).To(Equal(specs.BuildRoleBinding(cluster).Subjects))
+	})
+})
@@ -0,0 +1,321 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/restore"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/restoretest"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+)
+
+const (
+	// restoreTestDataVolumeName is the volume the backup is restored into
+	restoreTestDataVolumeName = "pgdata"
+
+	// restoreTestDataMountPath is where the data volume is mounted, matching
+	// the PostgreSQL images of CloudNativePG
+	restoreTestDataMountPath = "/var/lib/postgresql/data"
+
+	// restoreTestScratchVolumeName is the volume shared by the containers of
+	// the restore test, holding the manager binary, the sockets and the locks
+	restoreTestScratchVolumeName = "scratch-data"
+
+	// restoreTestSecretsVolumeName is the memory-backed volume where the
+	// pgBackRest secure options are written
+	restoreTestSecretsVolumeName = "pgbackrest-secrets"
+
+	// restoreTestUserID is the ID of the postgres user and group in the
+	// PostgreSQL images of CloudNativePG
+	restoreTestUserID = 26
+)
+
+// GetRestoreTestName returns the name of the objects running the RestoreTest
+func GetRestoreTestName(restoreTest *pgbackrestv1.RestoreTest) string {
+	return fmt.Sprintf("%s-restore-test", restoreTest.Name)
+}
+
+func buildRestoreTestObjectMeta(restoreTest *pgbackrestv1.RestoreTest) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: restoreTest.Namespace,
+		Name:      GetRestoreTestName(restoreTest),
+		Labels: map[string]string{
+			metadata.RestoreTestLabelName: restoreTest.Name,
+		},
+	}
+}
+
+// BuildRestoreTestServiceAccount builds the service account of the Job
+// running the RestoreTest
+func BuildRestoreTestServiceAccount(restoreTest *pgbackrestv1.RestoreTest) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: buildRestoreTestObjectMeta(restoreTest),
+	}
+}
+
+// BuildRestoreTestRole builds the Role allowing the restore test Job to read
+// the RestoreTest, its Archive with the Secrets, and to record the result in
+// the RestoreTest status
+func BuildRestoreTestRole(
+	restoreTest *pgbackrestv1.RestoreTest,
+	archive *pgbackrestv1.Archive,
+) *rbacv1.Role {
+	secretsSet := stringset.New()
+	collectSecretNames(secretsSet, &archive.Spec)
+
+	return &rbacv1.Role{
+		ObjectMeta: buildRestoreTestObjectMeta(restoreTest),
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+				},
+				Resources: []string{
+					"restoretests",
+				},
+				ResourceNames: []string{restoreTest.Name},
+			},
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+					"patch",
+				},
+				Resources: []string{
+					"restoretests/status",
+				},
+				ResourceNames: []string{restoreTest.Name},
+			},
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+				},
+				Resources: []string{
+					"archives",
+				},
+				ResourceNames: []string{archive.Name},
+			},
+			buildSecretsPolicyRule(secretsSet),
+		},
+	}
+}
+
+// BuildRestoreTestRoleBinding builds the role binding giving the restore test
+// Job its Role
+func BuildRestoreTestRoleBinding(restoreTest *pgbackrestv1.RestoreTest) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: buildRestoreTestObjectMeta(restoreTest),
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				APIGroup:  "",
+				Name:      GetRestoreTestName(restoreTest),
+				Namespace: restoreTest.Namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     GetRestoreTestName(restoreTest),
+		},
+	}
+}
+
+// BuildRestoreTestCronJob builds the CronJob running the RestoreTest on its
+// schedule. The sidecar image, which ships pgBackRest, restores the backup
+// and serves the WAL files, while PostgreSQL runs from the image of the
+// RestoreTest with a copy of the manager restoring the WAL files through the
+// WAL server and checking the assertions. The containers running pgBackRest
+// get the environment and the volumes of the instance sidecar of the Archive.
+func BuildRestoreTestCronJob(
+	restoreTest *pgbackrestv1.RestoreTest,
+	archive *pgbackrestv1.Archive,
+	image string,
+) *batchv1.CronJob {
+	pgData := filepath.Join(restoreTestDataMountPath, "pgdata")
+	env := []corev1.EnvVar{
+		{
+			Name:  "NAMESPACE",
+			Value: restoreTest.Namespace,
+		},
+		{
+			Name:  "RESTORE_TEST_NAME",
+			Value: restoreTest.Name,
+		},
+		{
+			Name:  "PGDATA",
+			Value: pgData,
+		},
+		{
+			Name:  "SPOOL_DIRECTORY",
+			Value: filepath.Join(restore.ScratchDataDirectory, "wal-restore-spool"),
+		},
+	}
+	securityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+	dataVolumeMounts := []corev1.VolumeMount{
+		{
+			Name:      restoreTestDataVolumeName,
+			MountPath: restoreTestDataMountPath,
+		},
+		{
+			Name:      restoreTestScratchVolumeName,
+			MountPath: restore.ScratchDataDirectory,
+		},
+	}
+	archiveVolumes, archiveVolumeMounts := buildArchiveVolumes(archive)
+	pgbackrestEnv := slices.Concat(archive.Spec.InstanceSidecarConfiguration.Env, env)
+	pgbackrestVolumeMounts := slices.Concat([]corev1.VolumeMount{
+		{
+			Name:      restoreTestSecretsVolumeName,
+			MountPath: credentials.SecretsConfigDirectory,
+		},
+	}, dataVolumeMounts, archiveVolumeMounts)
+	labels := map[string]string{
+		metadata.RestoreTestLabelName: restoreTest.Name,
+	}
+
+	return &batchv1.CronJob{
+		ObjectMeta: buildRestoreTestObjectMeta(restoreTest),
+		Spec: batchv1.CronJobSpec{
+			Schedule:          restoreTest.Spec.Schedule,
+			Suspend:           ptr.To(restoreTest.Spec.Suspend),
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: ptr.To(int32(0)),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: GetRestoreTestName(restoreTest),
+							RestartPolicy:      corev1.RestartPolicyNever,
+							SecurityContext: &corev1.PodSecurityContext{
+								RunAsUser:    ptr.To(int64(restoreTestUserID)),
+								RunAsGroup:   ptr.To(int64(restoreTestUserID)),
+								FSGroup:      ptr.To(int64(restoreTestUserID)),
+								RunAsNonRoot: ptr.To(true),
+								SeccompProfile: &corev1.SeccompProfile{
+									Type: corev1.SeccompProfileTypeRuntimeDefault,
+								},
+							},
+							InitContainers: []corev1.Container{
+								{
+									Name:            "restore",
+									Image:           image,
+									Args:            []string{"restore-test", "restore"},
+									Env:             pgbackrestEnv,
+									SecurityContext: securityContext,
+									VolumeMounts:    pgbackrestVolumeMounts,
+								},
+								{
+									// native sidecar, running until PostgreSQL
+									// is done with the recovery
+									Name:            "wal-server",
+									Image:           image,
+									Args:            []string{"restore-test", "wal-server"},
+									Env:             pgbackrestEnv,
+									RestartPolicy:   ptr.To(corev1.ContainerRestartPolicyAlways),
+									SecurityContext: securityContext,
+									VolumeMounts:    pgbackrestVolumeMounts,
+								},
+							},
+							Containers: []corev1.Container{
+								{
+									Name:            "postgres",
+									Image:           restoreTest.Spec.ImageName,
+									Command:         []string{restoretest.ManagerPath},
+									Args:            []string{"restore-test", "run"},
+									Env:             env,
+									Resources:       restoreTest.Spec.Resources,
+									SecurityContext: securityContext,
+									VolumeMounts:    dataVolumeMounts,
+								},
+							},
+							Volumes: slices.Concat([]corev1.Volume{
+								{
+									Name:         restoreTestDataVolumeName,
+									VolumeSource: buildRestoreTestDataVolumeSource(restoreTest),
+								},
+								{
+									Name: restoreTestScratchVolumeName,
+									VolumeSource: corev1.VolumeSource{
+										EmptyDir: &corev1.EmptyDirVolumeSource{},
+									},
+								},
+								{
+									Name: restoreTestSecretsVolumeName,
+									VolumeSource: corev1.VolumeSource{
+										EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+									},
+								},
+							}, archiveVolumes),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildRestoreTestDataVolumeSource builds the volume the backup is restored
+// into: an ephemeral PersistentVolumeClaim when the RestoreTest has a template
+// for it, a size-limited emptyDir otherwise
+func buildRestoreTestDataVolumeSource(restoreTest *pgbackrestv1.RestoreTest) corev1.VolumeSource {
+	storage := restoreTest.Spec.Storage
+	if storage.VolumeClaimTemplate != nil {
+		return corev1.VolumeSource{
+			Ephemeral: &corev1.EphemeralVolumeSource{
+				VolumeClaimTemplate: storage.VolumeClaimTemplate.DeepCopy(),
+			},
+		}
+	}
+
+	return corev1.VolumeSource{
+		EmptyDir: &corev1.EmptyDirVolumeSource{
+			SizeLimit: ptr.To(storage.GetSizeLimit()),
+		},
+	}
+}
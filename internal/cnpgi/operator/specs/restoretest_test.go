@@ -0,0 +1,93 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BuildRestoreTestCronJob", func() {
+	var (
+		restoreTest *pgbackrestv1.RestoreTest
+		archive     *pgbackrestv1.Archive
+	)
+
+	BeforeEach(func() {
+		restoreTest = &pgbackrestv1.RestoreTest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly"},
+			Spec: pgbackrestv1.RestoreTestSpec{
+				Schedule:  "0 4 * * *",
+				Archive:   cnpgv1.LocalObjectReference{Name: "archive"},
+				Stanza:    "cluster-example",
+				ImageName: "ghcr.io/cloudnative-pg/postgresql:17",
+			},
+		}
+		archive = newWebIdentityArchive("")
+		archive.Spec.InstanceSidecarConfiguration.Env = []corev1.EnvVar{
+			{Name: "AWS_REGION", Value: "eu-north-1"},
+		}
+	})
+
+	It("gives the environment and the volumes of the instance sidecar to pgBackRest only", func() {
+		podSpec := BuildRestoreTestCronJob(restoreTest, archive, "sidecar:latest").Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ContainElement(HaveField("Name", WebIdentityVolumeName)))
+
+		Expect(podSpec.InitContainers).To(HaveLen(2))
+		for _, container := range podSpec.InitContainers {
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "AWS_REGION", Value: "eu-north-1"}))
+			Expect(container.VolumeMounts).To(ContainElement(HaveField("Name", WebIdentityVolumeName)))
+		}
+
+		Expect(podSpec.Containers).To(HaveLen(1))
+		Expect(podSpec.Containers[0].Env).ToNot(ContainElement(HaveField("Name", "AWS_REGION")))
+		Expect(podSpec.Containers[0].VolumeMounts).ToNot(ContainElement(HaveField("Name", WebIdentityVolumeName)))
+	})
+
+	It("limits the size of the emptyDir the backup is restored into", func() {
+		podSpec := BuildRestoreTestCronJob(restoreTest, archive, "sidecar:latest").Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ContainElement(And(
+			HaveField("Name", restoreTestDataVolumeName),
+			HaveField("EmptyDir.SizeLimit", HaveValue(Equal(resource.MustParse("10Gi")))),
+		)))
+	})
+
+	It("restores the backup into an ephemeral volume claim when a template is set", func() {
+		restoreTest.Spec.Storage.VolumeClaimTemplate = &corev1.PersistentVolumeClaimTemplate{
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")},
+				},
+			},
+		}
+
+		podSpec := BuildRestoreTestCronJob(restoreTest, archive, "sidecar:latest").Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ContainElement(And(
+			HaveField("Name", restoreTestDataVolumeName),
+			HaveField("EmptyDir", BeNil()),
+			HaveField("Ephemeral.VolumeClaimTemplate", Equal(restoreTest.Spec.Storage.VolumeClaimTemplate)),
+		)))
+	})
+})
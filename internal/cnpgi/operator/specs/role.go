@@ -18,7 +18,9 @@ limitations under the License.
 package specs
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"slices"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/stringset"
@@ -26,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
 )
 
@@ -48,14 +51,7 @@ func BuildRole(
 
 	for _, pgbackrestObject := range pgbackrestObjects {
 		pgbackrestObjectsSet.Put(pgbackrestObject.Name)
-		for _, repo := range pgbackrestObject.Spec.Configuration.Repositories {
-			for _, secret := range CollectSecretNamesFromCredentials(&repo.PgbackrestCredentials) {
-				secretsSet.Put(secret)
-			}
-			for _, secret := range CollectSecretNamesFromRepositories([]pgbackrestApi.PgbackrestRepository{repo}) {
-				secretsSet.Put(secret)
-			}
-		}
+		collectSecretNames(secretsSet, &pgbackrestObject.Spec)
 	}
 
 	role.Rules = append(role.Rules, rbacv1.PolicyRule{
@@ -73,24 +69,127 @@ func BuildRole(
 		ResourceNames: pgbackrestObjectsSet.ToSortedList(),
 	})
 
+	// The sidecar reports the WAL files dropped from the archive in its status
+	if pgbackrestObjectsSet.Len() > 0 {
+		role.Rules = append(role.Rules, rbacv1.PolicyRule{
+			APIGroups: []string{
+				"pgbackrest.cnpg.opera.com",
+			},
+			Verbs: []string{
+				"get",
+				"patch",
+				"update",
+			},
+			Resources: []string{
+				"archives/status",
+			},
+			ResourceNames: pgbackrestObjectsSet.ToSortedList(),
+		})
+	}
+
+	role.Rules = append(role.Rules, buildSecretsPolicyRule(secretsSet))
+
 	role.Rules = append(role.Rules, rbacv1.PolicyRule{
 		APIGroups: []string{
-			"",
-		},
-		Resources: []string{
-			"secrets",
+			"events.k8s.io",
 		},
 		Verbs: []string{
-			"get",
-			"watch",
-			"list",
+			"create",
+			"patch",
+		},
+		Resources: []string{
+			"events",
 		},
-		ResourceNames: secretsSet.ToSortedList(),
 	})
 
 	return role
 }
 
+// BuildCredentialsRole builds the Role granting this cluster access to the
+// Secrets used by the passed ClusterArchives, all sharing the same
+// credentials namespace
+func BuildCredentialsRole(
+	cluster *cnpgv1.Cluster,
+	credentialsNamespace string,
+	clusterArchives []pgbackrestv1.ClusterArchive,
+) *rbacv1.Role {
+	secretsSet := stringset.New()
+	for i := range clusterArchives {
+		collectSecretNames(secretsSet, &clusterArchives[i].Spec.ArchiveSpec)
+	}
+
+	return &rbacv1.Role{
+		ObjectMeta: buildClusterScopedObjectMeta(cluster, credentialsNamespace),
+		Rules: []rbacv1.PolicyRule{
+			buildSecretsPolicyRule(secretsSet),
+		},
+	}
+}
+
+// BuildCredentialsRoleBinding builds the role binding giving the service account
+// of this cluster the credentials Role in the passed namespace
+func BuildCredentialsRoleBinding(
+	cluster *cnpgv1.Cluster,
+	credentialsNamespace string,
+) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: buildClusterScopedObjectMeta(cluster, credentialsNamespace),
+		Subjects:   buildClusterSubjects(cluster),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     GetClusterScopedRBACName(cluster),
+		},
+	}
+}
+
+// BuildClusterRole builds the ClusterRole allowing this cluster to read the
+// ClusterArchives it refers to
+func BuildClusterRole(
+	cluster *cnpgv1.Cluster,
+	clusterArchives []pgbackrestv1.ClusterArchive,
+) *rbacv1.ClusterRole {
+	clusterArchivesSet := stringset.New()
+	for i := range clusterArchives {
+		clusterArchivesSet.Put(clusterArchives[i].Name)
+	}
+
+	return &rbacv1.ClusterRole{
+		ObjectMeta: buildClusterScopedObjectMeta(cluster, ""),
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+					"watch",
+					"list",
+				},
+				Resources: []string{
+					"clusterarchives",
+				},
+				ResourceNames: clusterArchivesSet.ToSortedList(),
+			},
+		},
+	}
+}
+
+// BuildClusterRoleBinding builds the cluster role binding object for this cluster
+func BuildClusterRoleBinding(
+	cluster *cnpgv1.Cluster,
+) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: buildClusterScopedObjectMeta(cluster, ""),
+		Subjects:   buildClusterSubjects(cluster),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     GetClusterScopedRBACName(cluster),
+		},
+	}
+}
+
 // BuildRoleBinding builds the role binding object for this cluster
 func BuildRoleBinding(
 	cluster *cnpgv1.Cluster,
@@ -100,24 +199,195 @@ func BuildRoleBinding(
 			Namespace: cluster.Namespace,
 			Name:      GetRBACName(cluster.Name),
 		},
-		Subjects: []rbacv1.Subject{
+		Subjects: buildClusterSubjects(cluster),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     GetRBACName(cluster.Name),
+		},
+	}
+}
+
+// BuildRestorePointRole builds the Role granting this cluster access to its
+// pending RestorePoints, which the sidecar of the primary creates in
+// PostgreSQL before updating their status. They are listed by name, so that
+// the sidecar can't read or update the RestorePoints of the other clusters,
+// and the sidecar reads this Role to find them.
+func BuildRestorePointRole(
+	cluster *cnpgv1.Cluster,
+	restorePoints []pgbackrestv1.RestorePoint,
+) *rbacv1.Role {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetRestorePointRBACName(cluster.Name),
+		},
+
+		Rules: []rbacv1.PolicyRule{
 			{
-				Kind:      "ServiceAccount",
-				APIGroup:  "",
-				Name:      cluster.Name,
-				Namespace: cluster.Namespace,
+				APIGroups: []string{
+					"rbac.authorization.k8s.io",
+				},
+				Verbs: []string{
+					"get",
+				},
+				Resources: []string{
+					"roles",
+				},
+				ResourceNames: []string{
+					GetRestorePointRBACName(cluster.Name),
+				},
 			},
 		},
+	}
+
+	restorePointsSet := stringset.New()
+	for i := range restorePoints {
+		if restorePoints[i].Spec.Cluster.Name == cluster.Name && restorePoints[i].Status.Phase == "" {
+			restorePointsSet.Put(restorePoints[i].Name)
+		}
+	}
+
+	// A rule without resource names would grant access to every RestorePoint
+	if restorePointsSet.Len() == 0 {
+		return role
+	}
+
+	role.Rules = append(role.Rules,
+		rbacv1.PolicyRule{
+			APIGroups: []string{
+				"pgbackrest.cnpg.opera.com",
+			},
+			Verbs: []string{
+				"get",
+			},
+			Resources: []string{
+				"restorepoints",
+			},
+			ResourceNames: restorePointsSet.ToSortedList(),
+		},
+		rbacv1.PolicyRule{
+			APIGroups: []string{
+				"pgbackrest.cnpg.opera.com",
+			},
+			Verbs: []string{
+				"get",
+				"patch",
+				"update",
+			},
+			Resources: []string{
+				"restorepoints/status",
+			},
+			ResourceNames: restorePointsSet.ToSortedList(),
+		},
+	)
+
+	return role
+}
+
+// BuildRestorePointRoleBinding builds the RoleBinding granting this cluster
+// the access to its pending RestorePoints
+func BuildRestorePointRoleBinding(
+	cluster *cnpgv1.Cluster,
+) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetRestorePointRBACName(cluster.Name),
+		},
+		Subjects: buildClusterSubjects(cluster),
 		RoleRef: rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "Role",
-			Name:     GetRBACName(cluster.Name),
+			Name:     GetRestorePointRBACName(cluster.Name),
 		},
 	}
 }
 
+// GetRestorePointNames returns the names of the RestorePoints the passed Role
+// built by BuildRestorePointRole grants access to
+func GetRestorePointNames(role *rbacv1.Role) []string {
+	for _, rule := range role.Rules {
+		if slices.Contains(rule.Resources, "restorepoints") {
+			return rule.ResourceNames
+		}
+	}
+	return nil
+}
+
+// GetRestorePointRBACName returns the name of the RBAC entities granting
+// access to the RestorePoints of the cluster
+func GetRestorePointRBACName(clusterName string) string {
+	return fmt.Sprintf("%s-pgbackrest-restorepoints", clusterName)
+}
+
 // GetRBACName returns the name of the RBAC entities for the
 // pgbackrest plugin
 func GetRBACName(clusterName string) string {
 	return fmt.Sprintf("%s-pgbackrest", clusterName)
 }
+
+// GetClusterScopedRBACName returns the name of the RBAC entities created
+// outside of the cluster namespace, which need to be unique across namespaces.
+// As both the namespace and the name of the cluster can contain dashes, they
+// are hashed together instead of being joined.
+func GetClusterScopedRBACName(cluster *cnpgv1.Cluster) string {
+	sum := sha256.Sum256([]byte(cluster.Namespace + "/" + cluster.Name))
+	return fmt.Sprintf("%s-pgbackrest-%x", cluster.Name, sum[:8])
+}
+
+// GetClusterScopedRBACLabels returns the labels of the RBAC entities created
+// outside of the cluster namespace, used to find them when revoking the access
+func GetClusterScopedRBACLabels(clusterNamespace, clusterName string) map[string]string {
+	return map[string]string{
+		metadata.ClusterNamespaceLabelName: clusterNamespace,
+		metadata.ClusterNameLabelName:      clusterName,
+	}
+}
+
+func buildClusterScopedObjectMeta(cluster *cnpgv1.Cluster, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: namespace,
+		Name:      GetClusterScopedRBACName(cluster),
+		Labels:    GetClusterScopedRBACLabels(cluster.Namespace, cluster.Name),
+	}
+}
+
+func buildClusterSubjects(cluster *cnpgv1.Cluster) []rbacv1.Subject {
+	return []rbacv1.Subject{
+		{
+			Kind:      "ServiceAccount",
+			APIGroup:  "",
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+	}
+}
+
+func buildSecretsPolicyRule(secretsSet *stringset.Data) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{
+		APIGroups: []string{
+			"",
+		},
+		Resources: []string{
+			"secrets",
+		},
+		Verbs: []string{
+			"get",
+			"watch",
+			"list",
+		},
+		ResourceNames: secretsSet.ToSortedList(),
+	}
+}
+
+func collectSecretNames(secretsSet *stringset.Data, spec *pgbackrestv1.ArchiveSpec) {
+	for _, repo := range spec.Configuration.Repositories {
+		for _, secret := range CollectSecretNamesFromCredentials(&repo.PgbackrestCredentials) {
+			secretsSet.Put(secret)
+		}
+		for _, secret := range CollectSecretNamesFromRepositories([]pgbackrestApi.PgbackrestRepository{repo}) {
+			secretsSet.Put(secret)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package specs
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func newClusterArchive(name, credentialsNamespace, secretName string) pgbackrestv1.ClusterArchive {
+	return pgbackrestv1.ClusterArchive{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: pgbackrestv1.ClusterArchiveSpec{
+			CredentialsNamespace: credentialsNamespace,
+			ArchiveSpec: pgbackrestv1.ArchiveSpec{
+				Configuration: pgbackrestApi.PgbackrestConfiguration{
+					Repositories: []pgbackrestApi.PgbackrestRepository{{
+						PgbackrestCredentials: pgbackrestApi.PgbackrestCredentials{
+							AWS: &pgbackrestApi.S3Credentials{
+								AccessKeyIDReference: &machineryapi.SecretKeySelector{
+									LocalObjectReference: machineryapi.LocalObjectReference{Name: secretName},
+									Key:                  "ACCESS_KEY_ID",
+								},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+var _ = Describe("cluster-scoped RBAC", func() {
+	var cluster *cnpgv1.Cluster
+
+	BeforeEach(func() {
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example"},
+		}
+	})
+
+	It("uses names which don't collide across namespaces", func() {
+		other := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "a-cluster-example"}}
+		swapped := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a-cluster", Name: "example"}}
+
+		name := GetClusterScopedRBACName(cluster)
+		Expect(name).To(HavePrefix("cluster-example-pgbackrest-"))
+		Expect(name).To(Equal(GetClusterScopedRBACName(cluster.DeepCopy())))
+		Expect(name).ToNot(Equal(GetClusterScopedRBACName(other)))
+		Expect(name).ToNot(Equal(GetClusterScopedRBACName(swapped)))
+	})
+
+	It("labels every object with the cluster", func() {
+		clusterArchives := []pgbackrestv1.ClusterArchive{newClusterArchive("shared", "credentials", "s3")}
+		objects := []metav1.Object{
+			BuildClusterRole(cluster, clusterArchives),
+			BuildClusterRoleBinding(cluster),
+			BuildCredentialsRole(cluster, "credentials", clusterArchives),
+			BuildCredentialsRoleBinding(cluster, "credentials"),
+		}
+		for _, object := range objects {
+			Expect(object.GetName()).To(Equal(GetClusterScopedRBACName(cluster)))
+			Expect(object.GetLabels()).To(Equal(map[string]string{
+				metadata.ClusterNamespaceLabelName: "team-a",
+				metadata.ClusterNameLabelName:      "cluster-example",
+			}))
+		}
+	})
+
+	It("grants access to the referred ClusterArchives only", func() {
+		role := BuildClusterRole(cluster, []pgbackrestv1.ClusterArchive{
+			newClusterArchive("shared-b", "credentials", "s3"),
+			newClusterArchive("shared-a", "credentials", "s3"),
+		})
+		Expect(role.Namespace).To(BeEmpty())
+		Expect(role.Rules).To(HaveLen(1))
+		Expect(role.Rules[0].Resources).To(Equal([]string{"clusterarchives"}))
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{"shared-a", "shared-b"}))
+	})
+
+	It("grants access to the Secrets in the credentials namespace", func() {
+		role := BuildCredentialsRole(cluster, "credentials", []pgbackrestv1.ClusterArchive{
+			newClusterArchive("shared-a", "credentials", "s3-b"),
+			newClusterArchive("shared-b", "credentials", "s3-a"),
+		})
+		Expect(role.Namespace).To(Equal("credentials"))
+		Expect(role.Rules).To(HaveLen(1))
+		Expect(role.Rules[0].Resources).To(Equal([]string{"secrets"}))
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{"s3-a", "s3-b"}))
+	})
+
+	It("binds the roles to the service account of the cluster", func() {
+		subject := rbacv1.Subject{Kind: "ServiceAccount", Namespace: "team-a", Name: "cluster-example"}
+
+		clusterRoleBinding := BuildClusterRoleBinding(cluster)
+		Expect(clusterRoleBinding.Subjects).To(ConsistOf(subject))
+		Expect(clusterRoleBinding.RoleRef.Kind).To(Equal("ClusterRole"))
+		Expect(clusterRoleBinding.RoleRef.Name).To(Equal(GetClusterScopedRBACName(cluster)))
+
+		roleBinding := BuildCredentialsRoleBinding(cluster, "credentials")
+		Expect(roleBinding.Namespace).To(Equal("credentials"))
+		Expect(roleBinding.Subjects).To(ConsistOf(subject))
+		Expect(roleBinding.RoleRef.Kind).To(Equal("Role"))
+		Expect(roleBinding.RoleRef.Name).To(Equal(GetClusterScopedRBACName(cluster)))
+	})
+})
+
+var _ = Describe("BuildRole", func() {
+	It("doesn't allow the instances to create or delete the objects of CloudNativePG", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example"},
+		}
+
+		role := BuildRole(cluster, nil)
+		for _, rule := range role.Rules {
+			if rule.APIGroups[0] != "postgresql.cnpg.io" {
+				continue
+			}
+			Expect(rule.Verbs).ToNot(ContainElement(BeElementOf("create", "delete")), "resources %v", rule.Resources)
+		}
+	})
+})
+
+var _ = Describe("BuildRestorePointRole", func() {
+	var cluster *cnpgv1.Cluster
+
+	newRestorePoint := func(name, clusterName string, phase pgbackrestv1.RestorePointPhase) pgbackrestv1.RestorePoint {
+		return pgbackrestv1.RestorePoint{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec:       pgbackrestv1.RestorePointSpec{Cluster: cnpgv1.LocalObjectReference{Name: clusterName}},
+			Status:     pgbackrestv1.RestorePointStatus{Phase: phase},
+		}
+	}
+
+	BeforeEach(func() {
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "cluster-example"},
+		}
+	})
+
+	It("grants access to the pending restore points of the cluster only", func() {
+		role := BuildRestorePointRole(cluster, []pgbackrestv1.RestorePoint{
+			newRestorePoint("before-upgrade", "cluster-example", ""),
+			newRestorePoint("before-migration", "cluster-example", ""),
+			newRestorePoint("completed", "cluster-example", pgbackrestv1.RestorePointPhaseCompleted),
+			newRestorePoint("other", "other-cluster", ""),
+		})
+
+		Expect(role.Name).To(Equal(GetRestorePointRBACName(cluster.Name)))
+		Expect(GetRestorePointNames(role)).To(Equal([]string{"before-migration", "before-upgrade"}))
+		for _, rule := range role.Rules {
+			Expect(rule.ResourceNames).ToNot(BeEmpty(), "resources %v", rule.Resources)
+			Expect(rule.Verbs).ToNot(ContainElement(BeElementOf("create", "delete", "list", "watch")))
+		}
+	})
+
+	It("grants access to no restore point when none is pending", func() {
+		role := BuildRestorePointRole(cluster, []pgbackrestv1.RestorePoint{
+			newRestorePoint("completed", "cluster-example", pgbackrestv1.RestorePointPhaseCompleted),
+		})
+
+		Expect(GetRestorePointNames(role)).To(BeEmpty())
+		Expect(role.Rules).To(HaveLen(1))
+		Expect(role.Rules[0].Resources).To(Equal([]string{"roles"}))
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{role.Name}))
+	})
+})
@@ -32,6 +32,7 @@ func CollectSecretNamesFromCredentials(pgbackrestCredentials *pgbackrestApi.Pgba
 			references,
 			pgbackrestCredentials.AWS.AccessKeyIDReference,
 			pgbackrestCredentials.AWS.SecretAccessKeyReference,
+			pgbackrestCredentials.AWS.SSECustomerKeyReference,
 		)
 	}
 
@@ -0,0 +1,93 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+)
+
+// WebIdentityVolumeName is the name of the volume holding the projected
+// service account tokens of the repositories using web identity credentials
+const WebIdentityVolumeName = "pgbackrest-web-identity"
+
+// BuildWebIdentityVolume builds the projected volume containing the service account
+// tokens needed by the repositories using web identity credentials, and its mount.
+// Tokens are projected once per audience: the repositories of an archive share
+// the same one, while the archives a restore Job uses may not.
+func BuildWebIdentityVolume(archives ...*pgbackrestv1.Archive) ([]corev1.Volume, []corev1.VolumeMount) {
+	var sources []corev1.VolumeProjection
+	for _, archive := range archives {
+		if archive == nil {
+			continue
+		}
+		for _, repo := range archive.Spec.Configuration.Repositories {
+			if repo.AWS == nil || repo.AWS.WebIdentity == nil {
+				continue
+			}
+
+			webIdentity := repo.AWS.WebIdentity
+			if slices.ContainsFunc(sources, func(source corev1.VolumeProjection) bool {
+				return source.ServiceAccountToken.Path == webIdentity.GetTokenFileName()
+			}) {
+				continue
+			}
+			sources = append(sources, corev1.VolumeProjection{
+				ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+					Audience:          webIdentity.GetAudience(),
+					ExpirationSeconds: webIdentity.ExpirationSeconds,
+					Path:              webIdentity.GetTokenFileName(),
+				},
+			})
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	volume := corev1.Volume{
+		Name: WebIdentityVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: sources,
+			},
+		},
+	}
+	volumeMount := corev1.VolumeMount{
+		Name:      WebIdentityVolumeName,
+		MountPath: pgbackrestApi.WebIdentityTokenDirectory,
+		ReadOnly:  true,
+	}
+
+	return []corev1.Volume{volume}, []corev1.VolumeMount{volumeMount}
+}
+
+// buildArchiveVolumes builds the volumes, and their mounts, the Jobs using the
+// repositories of the Archive need on top of their own. They are the ones of
+// the instance sidecar: its extra volumes and the web identity tokens.
+func buildArchiveVolumes(archive *pgbackrestv1.Archive) ([]corev1.Volume, []corev1.VolumeMount) {
+	webIdentityVolumes, webIdentityVolumeMounts := BuildWebIdentityVolume(archive)
+	sidecarConfiguration := archive.Spec.InstanceSidecarConfiguration
+
+	return slices.Concat(sidecarConfiguration.ExtraVolumes, webIdentityVolumes),
+		slices.Concat(sidecarConfiguration.ExtraVolumeMounts, webIdentityVolumeMounts)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func newWebIdentityArchive(audience string) *pgbackrestv1.Archive {
+	return &pgbackrestv1.Archive{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "archive"},
+		Spec: pgbackrestv1.ArchiveSpec{
+			Configuration: pgbackrestApi.PgbackrestConfiguration{
+				Repositories: []pgbackrestApi.PgbackrestRepository{
+					{
+						PgbackrestCredentials: pgbackrestApi.PgbackrestCredentials{
+							AWS: &pgbackrestApi.S3Credentials{
+								WebIdentity: &pgbackrestApi.WebIdentity{
+									RoleARN:  "arn:aws:iam::123456789012:role/backup",
+									Audience: audience,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var _ = Describe("BuildWebIdentityVolume", func() {
+	It("returns nothing when no repository uses web identity", func() {
+		volumes, volumeMounts := BuildWebIdentityVolume(&pgbackrestv1.Archive{}, nil)
+		Expect(volumes).To(BeEmpty())
+		Expect(volumeMounts).To(BeEmpty())
+	})
+
+	It("projects a token per audience", func() {
+		volumes, volumeMounts := BuildWebIdentityVolume(
+			newWebIdentityArchive(""), newWebIdentityArchive(""), newWebIdentityArchive("other"))
+		Expect(volumes).To(HaveLen(1))
+		Expect(volumes[0].Projected.Sources).To(HaveLen(2))
+		Expect(volumes[0].Projected.Sources[0].ServiceAccountToken.Audience).To(
+			Equal(pgbackrestApi.DefaultWebIdentityAudience))
+		Expect(volumes[0].Projected.Sources[1].ServiceAccountToken.Path).To(Equal("other"))
+		Expect(volumeMounts).To(ConsistOf(corev1.VolumeMount{
+			Name:      WebIdentityVolumeName,
+			MountPath: pgbackrestApi.WebIdentityTokenDirectory,
+			ReadOnly:  true,
+		}))
+	})
+})
@@ -0,0 +1,229 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+)
+
+const (
+	// verificationScratchVolumeName is the volume holding the pgBackRest locks
+	verificationScratchVolumeName = "scratch-data"
+
+	// verificationSecretsVolumeName is the memory-backed volume where the
+	// pgBackRest secure options are written
+	verificationSecretsVolumeName = "pgbackrest-secrets"
+)
+
+// GetVerificationName returns the name of the objects verifying the
+// repositories of the Archive
+func GetVerificationName(archive *pgbackrestv1.Archive) string {
+	return fmt.Sprintf("%s-verify", archive.Name)
+}
+
+func buildVerificationObjectMeta(archive *pgbackrestv1.Archive) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace: archive.Namespace,
+		Name:      GetVerificationName(archive),
+		Labels: map[string]string{
+			metadata.ArchiveLabelName: archive.Name,
+		},
+	}
+}
+
+// BuildVerificationServiceAccount builds the service account of the Job
+// verifying the repositories of the Archive
+func BuildVerificationServiceAccount(archive *pgbackrestv1.Archive) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: buildVerificationObjectMeta(archive),
+	}
+}
+
+// BuildVerificationRole builds the Role allowing the verification Job to read
+// the Archive with its Secrets and to record the result in its status
+func BuildVerificationRole(archive *pgbackrestv1.Archive) *rbacv1.Role {
+	secretsSet := stringset.New()
+	collectSecretNames(secretsSet, &archive.Spec)
+
+	return &rbacv1.Role{
+		ObjectMeta: buildVerificationObjectMeta(archive),
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+				},
+				Resources: []string{
+					"archives",
+				},
+				ResourceNames: []string{archive.Name},
+			},
+			{
+				APIGroups: []string{
+					"pgbackrest.cnpg.opera.com",
+				},
+				Verbs: []string{
+					"get",
+					"patch",
+				},
+				Resources: []string{
+					"archives/status",
+				},
+				ResourceNames: []string{archive.Name},
+			},
+			buildSecretsPolicyRule(secretsSet),
+		},
+	}
+}
+
+// BuildVerificationRoleBinding builds the role binding giving the verification
+// Job its Role
+func BuildVerificationRoleBinding(archive *pgbackrestv1.Archive) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: buildVerificationObjectMeta(archive),
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				APIGroup:  "",
+				Name:      GetVerificationName(archive),
+				Namespace: archive.Namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     GetVerificationName(archive),
+		},
+	}
+}
+
+// BuildVerificationCronJob builds the CronJob verifying the passed stanzas in
+// the repositories of the Archive, on the schedule of its verification
+// configuration. The Job runs the verify command of the sidecar image, which
+// ships pgBackRest, with the environment and the volumes of the instance
+// sidecar, so that it reaches the repositories as the instances do.
+func BuildVerificationCronJob(
+	archive *pgbackrestv1.Archive,
+	stanzas []string,
+	image string,
+) *batchv1.CronJob {
+	verification := archive.Spec.Verification
+	archiveVolumes, archiveVolumeMounts := buildArchiveVolumes(archive)
+
+	return &batchv1.CronJob{
+		ObjectMeta: buildVerificationObjectMeta(archive),
+		Spec: batchv1.CronJobSpec{
+			Schedule:          verification.Schedule,
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						metadata.ArchiveLabelName: archive.Name,
+					},
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: ptr.To(int32(0)),
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								metadata.ArchiveLabelName: archive.Name,
+							},
+						},
+						Spec: corev1.PodSpec{
+							ServiceAccountName: GetVerificationName(archive),
+							RestartPolicy:      corev1.RestartPolicyNever,
+							SecurityContext: &corev1.PodSecurityContext{
+								RunAsNonRoot: ptr.To(true),
+								SeccompProfile: &corev1.SeccompProfile{
+									Type: corev1.SeccompProfileTypeRuntimeDefault,
+								},
+							},
+							Containers: []corev1.Container{
+								{
+									Name:  "verify",
+									Image: image,
+									Args:  []string{"verify"},
+									Env: slices.Concat(archive.Spec.InstanceSidecarConfiguration.Env, []corev1.EnvVar{
+										{
+											Name:  "NAMESPACE",
+											Value: archive.Namespace,
+										},
+										{
+											Name:  "ARCHIVE_NAME",
+											Value: archive.Name,
+										},
+										{
+											Name:  "STANZAS",
+											Value: strings.Join(stanzas, ","),
+										},
+									}),
+									Resources: verification.Resources,
+									SecurityContext: &corev1.SecurityContext{
+										AllowPrivilegeEscalation: ptr.To(false),
+										Capabilities: &corev1.Capabilities{
+											Drop: []corev1.Capability{"ALL"},
+										},
+									},
+									VolumeMounts: slices.Concat([]corev1.VolumeMount{
+										{
+											Name:      verificationScratchVolumeName,
+											MountPath: pgbackrestCommand.LockPath,
+										},
+										{
+											Name:      verificationSecretsVolumeName,
+											MountPath: credentials.SecretsConfigDirectory,
+										},
+									}, archiveVolumeMounts),
+								},
+							},
+							Volumes: slices.Concat([]corev1.Volume{
+								{
+									Name: verificationScratchVolumeName,
+									VolumeSource: corev1.VolumeSource{
+										EmptyDir: &corev1.EmptyDirVolumeSource{},
+									},
+								},
+								{
+									Name: verificationSecretsVolumeName,
+									VolumeSource: corev1.VolumeSource{
+										EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+									},
+								},
+							}, archiveVolumes),
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package specs
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BuildVerificationCronJob", func() {
+	It("runs with the environment and the volumes of the instance sidecar", func() {
+		archive := newWebIdentityArchive("")
+		archive.Spec.Verification = &pgbackrestv1.VerificationConfiguration{Schedule: "0 3 * * 0"}
+		archive.Spec.InstanceSidecarConfiguration.Env = []corev1.EnvVar{
+			{Name: "AWS_REGION", Value: "eu-north-1"},
+		}
+
+		cronJob := BuildVerificationCronJob(archive, []string{"cluster-example"}, "sidecar:latest")
+		podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ContainElement(HaveField("Name", WebIdentityVolumeName)))
+		Expect(podSpec.Containers).To(HaveLen(1))
+		Expect(podSpec.Containers[0].Env).To(ContainElements(
+			corev1.EnvVar{Name: "AWS_REGION", Value: "eu-north-1"},
+			corev1.EnvVar{Name: "STANZAS", Value: "cluster-example"},
+		))
+		Expect(podSpec.Containers[0].VolumeMounts).To(ContainElement(HaveField("Name", WebIdentityVolumeName)))
+	})
+})
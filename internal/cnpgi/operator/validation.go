@@ -0,0 +1,128 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudnative-pg/cnpg-i/pkg/operator"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+)
+
+// OperatorImplementation implements the validation of the clusters using
+// the plugin, called by the CloudNativePG admission webhooks
+type OperatorImplementation struct {
+	operator.UnimplementedOperatorServer
+	Client client.Client
+}
+
+// GetCapabilities gets the capabilities of the operator service
+func (o OperatorImplementation) GetCapabilities(
+	_ context.Context,
+	_ *operator.OperatorCapabilitiesRequest,
+) (*operator.OperatorCapabilitiesResult, error) {
+	return &operator.OperatorCapabilitiesResult{
+		Capabilities: []*operator.OperatorCapability{
+			{
+				Type: &operator.OperatorCapability_Rpc{
+					Rpc: &operator.OperatorCapability_RPC{
+						Type: operator.OperatorCapability_RPC_TYPE_VALIDATE_CLUSTER_CREATE,
+					},
+				},
+			},
+			{
+				Type: &operator.OperatorCapability_Rpc{
+					Rpc: &operator.OperatorCapability_RPC{
+						Type: operator.OperatorCapability_RPC_TYPE_VALIDATE_CLUSTER_CHANGE,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// ValidateClusterCreate validates a cluster that is being created
+func (o OperatorImplementation) ValidateClusterCreate(
+	ctx context.Context,
+	request *operator.OperatorValidateClusterCreateRequest,
+) (*operator.OperatorValidateClusterCreateResult, error) {
+	validationErrors, err := o.validateCluster(ctx, request.GetDefinition())
+	if err != nil {
+		return nil, err
+	}
+	return &operator.OperatorValidateClusterCreateResult{ValidationErrors: validationErrors}, nil
+}
+
+// ValidateClusterChange validates a cluster that is being changed
+func (o OperatorImplementation) ValidateClusterChange(
+	ctx context.Context,
+	request *operator.OperatorValidateClusterChangeRequest,
+) (*operator.OperatorValidateClusterChangeResult, error) {
+	validationErrors, err := o.validateCluster(ctx, request.GetNewCluster())
+	if err != nil {
+		return nil, err
+	}
+	return &operator.OperatorValidateClusterChangeResult{ValidationErrors: validationErrors}, nil
+}
+
+// validateCluster checks the additional command line arguments of the Archives
+// the cluster refers to. Archives that don't exist yet are skipped, the options
+// are checked again before running each command.
+func (o OperatorImplementation) validateCluster(
+	ctx context.Context,
+	clusterDefinition []byte,
+) ([]*operator.ValidationError, error) {
+	pluginConfiguration, err := config.NewFromClusterJSON(clusterDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	var validationErrors []*operator.ValidationError
+	for _, key := range pluginConfiguration.GetReferredArchiveObjectsKey() {
+		archive, err := config.GetArchive(ctx, o.Client, key, pluginConfiguration.Cluster.Namespace)
+		if apierrs.IsNotFound(err) {
+			continue
+		}
+		if errors.Is(err, config.ErrClusterArchiveNotAllowed) {
+			validationErrors = append(validationErrors, &operator.ValidationError{
+				PathComponents: []string{"spec", "plugins"},
+				Value:          key.Name,
+				Message:        err.Error(),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pgbackrestCommand.ValidateConfiguration(&archive.Spec.Configuration); err != nil {
+			validationErrors = append(validationErrors, &operator.ValidationError{
+				PathComponents: []string{"spec", "plugins"},
+				Value:          key.Name,
+				Message:        fmt.Sprintf("the configuration of %s is invalid: %s", key.Name, err.Error()),
+			})
+		}
+	}
+
+	return validationErrors, nil
+}
@@ -52,6 +52,7 @@ func Start(ctx context.Context) error {
 				DisableFor: []client.Object{
 					&corev1.Secret{},
 					&pgbackrestv1.Archive{},
+					&pgbackrestv1.ClusterArchive{},
 				},
 			},
 		},
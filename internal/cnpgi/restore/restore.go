@@ -92,29 +92,86 @@ func (impl JobHookImpl) Restore(
 		return nil, err
 	}
 
-	var recoveryArchive pgbackrestv1.Archive
-	if err := impl.Client.Get(ctx, configuration.GetRecoveryArchiveObjectKey(), &recoveryArchive); err != nil {
+	recoveryArchive, err := config.GetArchive(
+		ctx, impl.Client, configuration.GetRecoveryArchiveObjectKey(), configuration.Cluster.Namespace)
+	if err != nil {
 		return nil, err
 	}
 
 	if configuration.PgbackrestObjectName != "" {
-		var targeArchive pgbackrestv1.Archive
-		if err := impl.Client.Get(ctx, configuration.GetArchiveObjectKey(), &targeArchive); err != nil {
+		targeArchive, err := config.GetArchive(
+			ctx, impl.Client, configuration.GetArchiveObjectKey(), configuration.Cluster.Namespace)
+		if err != nil {
 			return nil, err
 		}
 
-		if err := impl.checkBackupDestination(ctx, configuration.Cluster, &targeArchive.Spec.Configuration); err != nil {
+		if err := impl.checkBackupDestination(ctx, configuration.Cluster, targeArchive); err != nil {
 			return nil, err
 		}
 	}
 
-	// Detect the backup to recover
-	backup, env, err := loadBackupObjectFromExternalCluster(
+	// Without a recovery target, PostgreSQL recovers to the latest timeline,
+	// so the latest backup which is part of its history is used
+	recoveryTarget := &cnpgv1.RecoveryTarget{}
+	if recovery := configuration.Cluster.Spec.Bootstrap.Recovery; recovery != nil && recovery.RecoveryTarget != nil {
+		recoveryTarget = recovery.RecoveryTarget
+	}
+
+	if _, err := impl.RestoreBackup(ctx, recoveryArchive, configuration.RecoveryStanza, recoveryTarget); err != nil {
+		return nil, err
+	}
+
+	if configuration.Cluster.Spec.WalStorage != nil {
+		if _, err := impl.restoreCustomWalDir(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	config := getRestoreWalConfig()
+
+	contextLogger.Info("sending restore response", "config", config)
+	return &restore.RestoreResponse{
+		RestoreConfig: config,
+		Envs:          nil,
+	}, nil
+}
+
+// RestoreBackup restores PGDATA from the backup of the stanza chosen for the
+// recovery target, and returns it
+func (impl JobHookImpl) RestoreBackup(
+	ctx context.Context,
+	recoveryArchive *pgbackrestv1.Archive,
+	stanza string,
+	recoveryTarget *cnpgv1.RecoveryTarget,
+) (*cnpgv1.Backup, error) {
+	stanza = recoveryArchive.Spec.Configuration.GetStanza(stanza)
+
+	env, removeConfig, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(
 		ctx,
 		impl.Client,
-		configuration.Cluster,
+		recoveryArchive.Namespace,
 		&recoveryArchive.Spec.Configuration,
-		configuration.RecoveryStanza,
+		pgbackrestUtils.SanitizedEnviron())
+	if err != nil {
+		return nil, err
+	}
+	defer removeConfig()
+
+	getHistory, err := impl.timelineHistoryGetter(ctx, env, &recoveryArchive.Spec.Configuration, stanza)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryTarget = resolveRestorePoint(ctx, recoveryTarget, recoveryArchive, stanza)
+
+	// Detect the backup to recover
+	backup, err := loadBackupObjectFromExternalCluster(
+		ctx,
+		recoveryTarget,
+		recoveryArchive,
+		stanza,
+		env,
+		getHistory,
 	)
 	if err != nil {
 		return nil, err
@@ -129,19 +186,40 @@ func (impl JobHookImpl) Restore(
 		return nil, err
 	}
 
-	if configuration.Cluster.Spec.WalStorage != nil {
-		if _, err := impl.restoreCustomWalDir(ctx); err != nil {
-			return nil, err
-		}
+	return backup, nil
+}
+
+// timelineHistoryGetter builds the function getting the history files of the
+// timelines from the recovery archive
+func (impl JobHookImpl) timelineHistoryGetter(
+	ctx context.Context,
+	env []string,
+	pgbackrestConfiguration *pgbackrestApi.PgbackrestConfiguration,
+	stanza string,
+) (pgbackrestCatalog.TimelineHistoryGetter, error) {
+	if err := fileutils.EnsureDirectoryExists(RecoveryTemporaryDirectory); err != nil {
+		return nil, err
 	}
 
-	config := getRestoreWalConfig()
+	rest, err := pgbackrestRestorer.NewWALRestorer(
+		ctx,
+		env,
+		impl.SpoolDirectory,
+		pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchiveGet),
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	contextLogger.Info("sending restore response", "config", config)
-	return &restore.RestoreResponse{
-		RestoreConfig: config,
-		Envs:          nil,
-	}, nil
+	opts, err := pgbackrestCommand.CloudWalRestoreOptions(ctx, pgbackrestConfiguration, stanza, impl.PgDataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return rest.TimelineHistoryGetter(ctx, RecoveryTemporaryDirectory, opts), nil
 }
 
 // restoreDataDir restores PGDATA from an existing backup
@@ -185,6 +263,10 @@ func (impl JobHookImpl) ensureArchiveContainsLastCheckpointRedoWAL(
 		ctx,
 		env,
 		impl.SpoolDirectory,
+		pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchiveGet),
+		nil,
+		nil,
+		nil,
 	)
 	if err != nil {
 		return err
@@ -210,17 +292,20 @@ func (impl JobHookImpl) ensureArchiveContainsLastCheckpointRedoWAL(
 func (impl *JobHookImpl) checkBackupDestination(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
-	pgbackrestConfiguration *pgbackrestApi.PgbackrestConfiguration,
+	archive *pgbackrestv1.Archive,
 ) error {
+	pgbackrestConfiguration := &archive.Spec.Configuration
+
 	// Get environment from cache
-	env, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(ctx,
+	env, removeConfig, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(ctx,
 		impl.Client,
-		cluster.Namespace,
+		archive.Namespace,
 		pgbackrestConfiguration,
 		pgbackrestUtils.SanitizedEnviron())
 	if err != nil {
 		return fmt.Errorf("can't get credentials for cluster %v: %w", cluster.Name, err)
 	}
+	defer removeConfig()
 	if len(env) == 0 {
 		return nil
 	}
@@ -232,7 +317,10 @@ func (impl *JobHookImpl) checkBackupDestination(
 		env,
 		impl.SpoolDirectory,
 		impl.PgDataPath,
-		path.Join(impl.PgDataPath, metadata.CheckEmptyWalArchiveFile))
+		path.Join(impl.PgDataPath, metadata.CheckEmptyWalArchiveFile),
+		pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchivePush),
+		nil,
+		nil)
 	if err != nil {
 		return fmt.Errorf("while creating the archiver: %w", err)
 	}
@@ -314,47 +402,31 @@ func getRestoreWalConfig() string {
 // an external cluster, loading the required information from the object store
 func loadBackupObjectFromExternalCluster(
 	ctx context.Context,
-	typedClient client.Client,
-	cluster *cnpgv1.Cluster,
-	recoveryArchive *pgbackrestApi.PgbackrestConfiguration,
+	recoveryTarget *cnpgv1.RecoveryTarget,
+	archive *pgbackrestv1.Archive,
 	stanza string,
-) (*cnpgv1.Backup, []string, error) {
+	env []string,
+	getHistory pgbackrestCatalog.TimelineHistoryGetter,
+) (*cnpgv1.Backup, error) {
 	contextLogger := log.FromContext(ctx)
+	recoveryArchive := &archive.Spec.Configuration
 
 	contextLogger.Info("Recovering from external cluster",
 		"stanza", stanza,
 		"archive", recoveryArchive)
 
-	env, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(
-		ctx,
-		typedClient,
-		cluster.Namespace,
-		recoveryArchive,
-		pgbackrestUtils.SanitizedEnviron())
-	if err != nil {
-		return nil, nil, err
-	}
-
 	backupCatalog, err := pgbackrestCommand.GetBackupList(ctx, recoveryArchive, stanza, env)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// We are now choosing the right backup to restore
-	var targetBackup *pgbackrestCatalog.PgbackrestBackup
-	if cluster.Spec.Bootstrap.Recovery != nil &&
-		cluster.Spec.Bootstrap.Recovery.RecoveryTarget != nil {
-		targetBackup, err = backupCatalog.FindBackupInfo(
-			cluster.Spec.Bootstrap.Recovery.RecoveryTarget,
-		)
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		targetBackup = backupCatalog.LatestBackupInfo()
+	targetBackup, err := backupCatalog.FindBackupInfo(recoveryTarget, getHistory)
+	if err != nil {
+		return nil, err
 	}
 	if targetBackup == nil {
-		return nil, nil, fmt.Errorf("no target backup found")
+		return nil, fmt.Errorf("no target backup found")
 	}
 
 	contextLogger.Info("Target backup found", "backup", targetBackup)
@@ -391,5 +463,41 @@ func loadBackupObjectFromExternalCluster(
 				"stanza": stanza,
 			},
 		},
-	}, env, nil
+	}, nil
+}
+
+// resolveRestorePoint looks up the restore point used as targetName in the
+// status of the Archive. When it is recorded, it returns a copy of the recovery
+// target with its LSN, so that the newest backup ending before it is chosen.
+// The LSN is only used to choose the backup: PostgreSQL stops at the restore
+// point itself, as CloudNativePG sets recovery_target_name. Otherwise, the
+// recovery target is returned as it is.
+func resolveRestorePoint(
+	ctx context.Context,
+	recoveryTarget *cnpgv1.RecoveryTarget,
+	archive *pgbackrestv1.Archive,
+	stanza string,
+) *cnpgv1.RecoveryTarget {
+	if recoveryTarget.TargetName == "" || recoveryTarget.BackupID != "" {
+		return recoveryTarget
+	}
+
+	restorePoint, ok := archive.Status.RestorePoints[stanza][recoveryTarget.TargetName]
+	if !ok || restorePoint.LSN == "" {
+		log.FromContext(ctx).Info(
+			"Restore point not recorded in the archive, using the latest backup",
+			"stanza", stanza,
+			"targetName", recoveryTarget.TargetName)
+		return recoveryTarget
+	}
+
+	log.FromContext(ctx).Info("Using recorded restore point",
+		"stanza", stanza,
+		"targetName", recoveryTarget.TargetName,
+		"lsn", restorePoint.LSN,
+		"timeline", restorePoint.Timeline)
+
+	resolved := recoveryTarget.DeepCopy()
+	resolved.TargetLSN = restorePoint.LSN
+	return resolved
 }
@@ -0,0 +1,108 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package restore
+
+import (
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+	pgbackrestCatalog "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+	pgbackrestRestorer "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/restorer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recovery to a named restore point", func() {
+	const stanza = "cluster-example"
+
+	newBackup := func(id, wal, lsn string, stop time.Time) pgbackrestCatalog.PgbackrestBackup {
+		return pgbackrestCatalog.PgbackrestBackup{
+			ID:   id,
+			WAL:  pgbackrestCatalog.PgbackrestBackupWALArchive{Start: wal, Stop: wal},
+			LSN:  pgbackrestCatalog.PgbackrestBackupLSN{Start: lsn, Stop: lsn},
+			Time: pgbackrestCatalog.PgbackrestBackupTime{Start: stop.Unix() - 10, Stop: stop.Unix()},
+		}
+	}
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	catalog := &pgbackrestCatalog.Catalog{
+		Backups: []pgbackrestCatalog.PgbackrestBackup{
+			newBackup("20250101-000000F", "000000010000000000000002", "0/2000100", day),
+			newBackup("20250102-000000F", "000000010000000000000005", "0/5000100", day.Add(24*time.Hour)),
+			newBackup("20250103-000000F", "000000010000000000000007", "0/7000100", day.Add(48*time.Hour)),
+		},
+	}
+	getHistory := func(int64) (*pgbackrestCatalog.TimelineHistory, error) {
+		return nil, pgbackrestCatalog.ErrTimelineHistoryNotFound
+	}
+
+	archive := &pgbackrestv1.Archive{
+		Spec: pgbackrestv1.ArchiveSpec{
+			Configuration: pgbackrestApi.PgbackrestConfiguration{
+				Repositories: []pgbackrestApi.PgbackrestRepository{
+					{Bucket: "bucket-name", DestinationPath: "/"},
+				},
+			},
+		},
+		Status: pgbackrestv1.ArchiveStatus{
+			RestorePoints: map[string]map[string]pgbackrestv1.RecordedRestorePoint{
+				stanza: {
+					"before_upgrade": {LSN: "0/6000000", Timeline: 1},
+				},
+			},
+		},
+	}
+
+	It("restores the newest backup ending before the restore point, without a pgBackRest target",
+		func(ctx SpecContext) {
+			recoveryTarget := &cnpgv1.RecoveryTarget{TargetName: "before_upgrade"}
+
+			resolved := resolveRestorePoint(ctx, recoveryTarget, archive, stanza)
+			Expect(resolved.TargetName).To(Equal("before_upgrade"))
+			Expect(resolved.TargetLSN).To(Equal("0/6000000"))
+			Expect(recoveryTarget.TargetLSN).To(BeEmpty())
+
+			backup, err := catalog.FindBackupInfo(resolved, getHistory)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backup.ID).To(Equal("20250102-000000F"))
+
+			options, err := pgbackrestRestorer.NewRestoreCommand(&archive.Spec.Configuration, "/pg/data").
+				GetPgbackrestRestoreOptions(ctx, backup.ID, stanza)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(options).To(ContainElements("restore", "--set", backup.ID))
+			Expect(options).ToNot(ContainElement(Or(HavePrefix("--type"), HavePrefix("--target"))))
+		})
+
+	It("restores the latest backup when the restore point isn't recorded", func(ctx SpecContext) {
+		recoveryTarget := &cnpgv1.RecoveryTarget{TargetName: "unknown"}
+
+		resolved := resolveRestorePoint(ctx, recoveryTarget, archive, stanza)
+		Expect(resolved).To(BeIdenticalTo(recoveryTarget))
+
+		backup, err := catalog.FindBackupInfo(resolved, getHistory)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backup.ID).To(Equal("20250103-000000F"))
+	})
+
+	It("uses the backup chosen by the user over the restore point", func(ctx SpecContext) {
+		recoveryTarget := &cnpgv1.RecoveryTarget{TargetName: "before_upgrade", BackupID: "20250101-000000F"}
+
+		Expect(resolveRestorePoint(ctx, recoveryTarget, archive, stanza)).To(BeIdenticalTo(recoveryTarget))
+	})
+})
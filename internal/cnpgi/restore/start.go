@@ -11,6 +11,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/common"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
 )
 
 // CNPGI is the implementation of the PostgreSQL sidecar
@@ -35,6 +36,7 @@ func (c *CNPGI) Start(ctx context.Context) error {
 			SpoolDirectory: c.SpoolDirectory,
 			PGDataPath:     c.PGDataPath,
 			PGWALPath:      path.Join(c.PGDataPath, "pg_wal"),
+			InFlight:       inflight.NewRegistry(),
 		})
 
 		restore.RegisterRestoreJobHooksServer(server, &JobHookImpl{
@@ -0,0 +1,4 @@
+// Package restoretest runs the restore tests: it restores a backup into the
+// throwaway Job of a RestoreTest, starts PostgreSQL on it and checks the
+// restored database
+package restoretest
@@ -0,0 +1,138 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/fileutils"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/restore"
+)
+
+const (
+	// ManagerPath is where the manager is copied for the PostgreSQL
+	// container, which runs it to restore the WAL files and the assertions
+	ManagerPath = restore.ScratchDataDirectory + "/manager"
+
+	// WALServerSocketPath is the unix socket where the WAL server listens
+	WALServerSocketPath = restore.ScratchDataDirectory + "/restore-test.sock"
+
+	// PostgresSocketDirectory is the directory of the PostgreSQL unix socket
+	PostgresSocketDirectory = restore.ScratchDataDirectory + "/run"
+
+	// PostgresPort is the port of the PostgreSQL unix socket
+	PostgresPort = 5432
+
+	// configurationDirectory holds the authentication files of PostgreSQL,
+	// which replace the ones of the restored cluster
+	configurationDirectory = restore.ScratchDataDirectory + "/restore-test"
+)
+
+// quoteParameter quotes a value of the PostgreSQL configuration
+func quoteParameter(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// recoveryConfiguration builds the configuration overriding the one of the
+// restored cluster, so that PostgreSQL starts without its certificates and
+// replicas, restores the WAL files from the WAL server and is promoted once
+// the recovery target is reached
+func recoveryConfiguration(target *cnpgv1.RecoveryTarget) string {
+	parameters := [][2]string{
+		{"listen_addresses", quoteParameter("")},
+		{"port", fmt.Sprint(PostgresPort)},
+		{"unix_socket_directories", quoteParameter(PostgresSocketDirectory)},
+		{"ssl", "off"},
+		{"archive_mode", "off"},
+		{"logging_collector", "off"},
+		{"log_destination", quoteParameter("stderr")},
+		{"synchronous_standby_names", quoteParameter("")},
+		{"hba_file", quoteParameter(filepath.Join(configurationDirectory, "pg_hba.conf"))},
+		{"ident_file", quoteParameter(filepath.Join(configurationDirectory, "pg_ident.conf"))},
+		{"restore_command", quoteParameter(ManagerPath + " restore-test wal-get %f %p")},
+		{"recovery_target_action", quoteParameter("promote")},
+	}
+
+	if target != nil {
+		switch {
+		case target.TargetImmediate != nil && *target.TargetImmediate:
+			parameters = append(parameters, [2]string{"recovery_target", quoteParameter("immediate")})
+		case target.TargetName != "":
+			parameters = append(parameters, [2]string{"recovery_target_name", quoteParameter(target.TargetName)})
+		case target.TargetLSN != "":
+			parameters = append(parameters, [2]string{"recovery_target_lsn", quoteParameter(target.TargetLSN)})
+		case target.TargetXID != "":
+			parameters = append(parameters, [2]string{"recovery_target_xid", quoteParameter(target.TargetXID)})
+		case target.TargetTime != "":
+			parameters = append(parameters, [2]string{"recovery_target_time", quoteParameter(target.TargetTime)})
+		}
+		if target.TargetTLI != "" {
+			parameters = append(parameters, [2]string{"recovery_target_timeline", quoteParameter(target.TargetTLI)})
+		}
+		if target.Exclusive != nil && *target.Exclusive {
+			parameters = append(parameters, [2]string{"recovery_target_inclusive", "off"})
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString("# Restore test\n")
+	for _, parameter := range parameters {
+		fmt.Fprintf(&builder, "%s = %s\n", parameter[0], parameter[1])
+	}
+	return builder.String()
+}
+
+// writeRecoveryConfiguration prepares the restored data directory to be
+// recovered by PostgreSQL as a new primary
+func writeRecoveryConfiguration(pgData string, target *cnpgv1.RecoveryTarget) error {
+	for _, directory := range []string{configurationDirectory, PostgresSocketDirectory} {
+		if err := fileutils.EnsureDirectoryExists(directory); err != nil {
+			return err
+		}
+	}
+
+	// only the local connections of the restore test are allowed
+	if err := os.WriteFile(
+		filepath.Join(configurationDirectory, "pg_hba.conf"), []byte("local all all trust\n"), 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(configurationDirectory, "pg_ident.conf"), nil, 0o600); err != nil {
+		return err
+	}
+
+	// postgresql.auto.conf is read last, overriding the configuration of
+	// the restored cluster
+	autoConf, err := os.OpenFile( // #nosec G304
+		filepath.Join(pgData, "postgresql.auto.conf"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := autoConf.WriteString(recoveryConfiguration(target)); err != nil {
+		_ = autoConf.Close()
+		return err
+	}
+	if err := autoConf.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(pgData, "recovery.signal"), nil, 0o600)
+}
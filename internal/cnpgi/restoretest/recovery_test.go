@@ -0,0 +1,52 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"k8s.io/utils/ptr"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("recoveryConfiguration", func() {
+	It("restores the WAL files from the WAL server and promotes PostgreSQL", func() {
+		configuration := recoveryConfiguration(nil)
+		Expect(configuration).To(ContainSubstring(
+			"restore_command = '/controller/manager restore-test wal-get %f %p'\n"))
+		Expect(configuration).To(ContainSubstring("recovery_target_action = 'promote'\n"))
+		Expect(configuration).To(ContainSubstring("ssl = off\n"))
+		Expect(configuration).ToNot(ContainSubstring("recovery_target_time"))
+	})
+
+	It("sets the recovery target", func() {
+		configuration := recoveryConfiguration(&cnpgv1.RecoveryTarget{
+			TargetTime: "2025-04-01 13:20:32+00",
+			TargetTLI:  "2",
+			Exclusive:  ptr.To(true),
+		})
+		Expect(configuration).To(ContainSubstring("recovery_target_time = '2025-04-01 13:20:32+00'\n"))
+		Expect(configuration).To(ContainSubstring("recovery_target_timeline = '2'\n"))
+		Expect(configuration).To(ContainSubstring("recovery_target_inclusive = off\n"))
+	})
+
+	It("quotes the values", func() {
+		configuration := recoveryConfiguration(&cnpgv1.RecoveryTarget{TargetName: "before 'migration'"})
+		Expect(configuration).To(ContainSubstring("recovery_target_name = 'before ''migration'''\n"))
+	})
+})
@@ -0,0 +1,167 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/restore"
+)
+
+// getArchive gets the Archive holding the backups restored by the RestoreTest
+func getArchive(
+	ctx context.Context,
+	c client.Client,
+	restoreTest *pgbackrestv1.RestoreTest,
+) (*pgbackrestv1.Archive, error) {
+	var archive pgbackrestv1.Archive
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: restoreTest.Namespace,
+		Name:      restoreTest.Spec.Archive.Name,
+	}, &archive); err != nil {
+		return nil, fmt.Errorf("while getting archive %s: %w", restoreTest.Spec.Archive.Name, err)
+	}
+	return &archive, nil
+}
+
+// patchStatus updates the status of the RestoreTest with mutate
+func patchStatus(
+	ctx context.Context,
+	c client.Client,
+	restoreTest *pgbackrestv1.RestoreTest,
+	mutate func(status *pgbackrestv1.RestoreTestStatus),
+) error {
+	origRestoreTest := restoreTest.DeepCopy()
+	mutate(&restoreTest.Status)
+	if err := c.Status().Patch(ctx, restoreTest, client.MergeFrom(origRestoreTest)); err != nil {
+		return fmt.Errorf("while updating the restore test status: %w", err)
+	}
+	return nil
+}
+
+// recordFailure marks the run of the RestoreTest as failed because of err,
+// which is returned
+func recordFailure(ctx context.Context, c client.Client, restoreTest *pgbackrestv1.RestoreTest, err error) error {
+	now := metav1.Now()
+	if patchErr := patchStatus(ctx, c, restoreTest, func(status *pgbackrestv1.RestoreTestStatus) {
+		status.Phase = pgbackrestv1.RestoreTestPhaseFailed
+		status.Error = err.Error()
+		status.CompletedAt = &now
+	}); patchErr != nil {
+		log.FromContext(ctx).Error(patchErr, "while recording the restore test failure")
+	}
+	return err
+}
+
+// Restore restores the backup chosen for the recovery target of the
+// RestoreTest into pgData, through the same path as the full-recovery Job,
+// and prepares PostgreSQL to recover it as a new primary
+func Restore(
+	ctx context.Context,
+	c client.Client,
+	restoreTest *pgbackrestv1.RestoreTest,
+	pgData string,
+	spoolDirectory string,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	startedAt := metav1.Now()
+	if err := patchStatus(ctx, c, restoreTest, func(status *pgbackrestv1.RestoreTestStatus) {
+		*status = pgbackrestv1.RestoreTestStatus{
+			Phase:              pgbackrestv1.RestoreTestPhaseRestoring,
+			StartedAt:          &startedAt,
+			LastSuccessfulTime: status.LastSuccessfulTime,
+		}
+	}); err != nil {
+		return err
+	}
+
+	archive, err := getArchive(ctx, c, restoreTest)
+	if err != nil {
+		return recordFailure(ctx, c, restoreTest, err)
+	}
+
+	recoveryTarget := &cnpgv1.RecoveryTarget{}
+	if restoreTest.Spec.RecoveryTarget != nil {
+		recoveryTarget = restoreTest.Spec.RecoveryTarget
+	}
+
+	jobHook := restore.JobHookImpl{
+		Client:         c,
+		SpoolDirectory: spoolDirectory,
+		PgDataPath:     pgData,
+	}
+	backup, err := jobHook.RestoreBackup(ctx, archive, restoreTest.Spec.Stanza, recoveryTarget)
+	if err != nil {
+		return recordFailure(ctx, c, restoreTest, fmt.Errorf("while restoring the backup: %w", err))
+	}
+
+	if err := writeRecoveryConfiguration(pgData, restoreTest.Spec.RecoveryTarget); err != nil {
+		return recordFailure(ctx, c, restoreTest,
+			fmt.Errorf("while writing the recovery configuration: %w", err))
+	}
+
+	if err := copyManager(); err != nil {
+		return recordFailure(ctx, c, restoreTest, fmt.Errorf("while copying the manager: %w", err))
+	}
+
+	restoredAt := metav1.Now()
+	contextLogger.Info("Backup restored", "backupID", backup.Status.BackupID,
+		"duration", restoredAt.Sub(startedAt.Time))
+	return patchStatus(ctx, c, restoreTest, func(status *pgbackrestv1.RestoreTestStatus) {
+		status.BackupID = backup.Status.BackupID
+		status.RestoredAt = &restoredAt
+		status.RestoreDuration = &metav1.Duration{Duration: restoredAt.Sub(startedAt.Time)}
+	})
+}
+
+// copyManager copies the running manager where the PostgreSQL container can
+// run it. The manager is statically linked, so it runs in any image.
+func copyManager() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(executable) // #nosec G304
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+
+	// #nosec G302 G304
+	destination, err := os.OpenFile(ManagerPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destination, source); err != nil {
+		_ = destination.Close()
+		return err
+	}
+	return destination.Close()
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	// Register the PostgreSQL driver used to run the assertions
+	_ "github.com/lib/pq"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+)
+
+const (
+	// promotionPollInterval is how often PostgreSQL is checked for promotion
+	promotionPollInterval = time.Second
+
+	// shutdownTimeout is how long PostgreSQL has to stop after the assertions
+	shutdownTimeout = time.Minute
+)
+
+// errPostgresExited is returned when PostgreSQL stops before being promoted,
+// e.g. because the recovery target can't be reached
+var errPostgresExited = errors.New("PostgreSQL exited before being promoted")
+
+// Run starts PostgreSQL on the restored data directory, waits for it to be
+// promoted once the WAL files are replayed, checks the assertions of the
+// RestoreTest and records the result in its status
+func Run(ctx context.Context, c client.Client, restoreTest *pgbackrestv1.RestoreTest, pgData string) error {
+	contextLogger := log.FromContext(ctx)
+
+	if err := patchStatus(ctx, c, restoreTest, func(status *pgbackrestv1.RestoreTestStatus) {
+		status.Phase = pgbackrestv1.RestoreTestPhaseRecovering
+	}); err != nil {
+		return err
+	}
+
+	postgres := exec.Command("postgres", "-D", pgData) // #nosec G204
+	postgres.Stdout = os.Stdout
+	postgres.Stderr = os.Stderr
+	if err := postgres.Start(); err != nil {
+		return recordFailure(ctx, c, restoreTest, fmt.Errorf("while starting PostgreSQL: %w", err))
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- postgres.Wait()
+	}()
+
+	if err := waitForPromotion(ctx, exited); err != nil {
+		stopPostgres(ctx, postgres, exited)
+		return recordFailure(ctx, c, restoreTest, err)
+	}
+	recoveredAt := metav1.Now()
+	contextLogger.Info("PostgreSQL promoted")
+
+	results := runAssertions(ctx, restoreTest.Spec.Assertions)
+	stopPostgres(ctx, postgres, exited)
+
+	var failed []string
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	completedAt := metav1.Now()
+	if err := patchStatus(ctx, c, restoreTest, func(status *pgbackrestv1.RestoreTestStatus) {
+		status.RecoveredAt = &recoveredAt
+		if status.StartedAt != nil {
+			status.RecoveryDuration = &metav1.Duration{Duration: recoveredAt.Sub(status.StartedAt.Time)}
+		}
+		status.Assertions = results
+		status.CompletedAt = &completedAt
+		if len(failed) > 0 {
+			status.Phase = pgbackrestv1.RestoreTestPhaseFailed
+			status.Error = "failed assertions: " + strings.Join(failed, ", ")
+		} else {
+			status.Phase = pgbackrestv1.RestoreTestPhaseSucceeded
+			status.LastSuccessfulTime = &completedAt
+		}
+	}); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed assertions: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// connectionString builds the connection string to a database of the
+// restored PostgreSQL
+func connectionString(database string) string {
+	quote := func(value string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=postgres dbname=%s sslmode=disable",
+		quote(PostgresSocketDirectory), PostgresPort, quote(database))
+}
+
+// waitForPromotion waits for PostgreSQL to accept connections and to leave
+// the recovery
+func waitForPromotion(ctx context.Context, exited <-chan error) error {
+	db, err := sql.Open("postgres", connectionString("postgres"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	ticker := time.NewTicker(promotionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var inRecovery bool
+		err := db.QueryRowContext(ctx, "SELECT pg_is_in_recovery()").Scan(&inRecovery)
+		if err == nil && !inRecovery {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case exitErr := <-exited:
+			return errors.Join(errPostgresExited, exitErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// runAssertions runs the queries of the assertions, each of them returning
+// true when it holds
+func runAssertions(
+	ctx context.Context,
+	assertions []pgbackrestv1.RestoreTestAssertion,
+) []pgbackrestv1.RestoreTestAssertionResult {
+	contextLogger := log.FromContext(ctx)
+
+	results := make([]pgbackrestv1.RestoreTestAssertionResult, 0, len(assertions))
+	for i := range assertions {
+		passed, err := runAssertion(ctx, &assertions[i])
+		result := pgbackrestv1.RestoreTestAssertionResult{
+			Name:   assertions[i].Name,
+			Passed: passed,
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		contextLogger.Info("Assertion checked", "name", result.Name, "passed", result.Passed, "error", result.Error)
+		results = append(results, result)
+	}
+	return results
+}
+
+// runAssertion runs the query of an assertion
+func runAssertion(ctx context.Context, assertion *pgbackrestv1.RestoreTestAssertion) (bool, error) {
+	db, err := sql.Open("postgres", connectionString(assertion.GetDatabase()))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var holds bool
+	if err := db.QueryRowContext(ctx, assertion.Query).Scan(&holds); err != nil {
+		return false, err
+	}
+	return holds, nil
+}
+
+// stopPostgres stops PostgreSQL with a fast shutdown, killing it when it
+// doesn't stop in time
+func stopPostgres(ctx context.Context, postgres *exec.Cmd, exited <-chan error) {
+	if err := postgres.Process.Signal(syscall.SIGINT); err != nil {
+		// PostgreSQL already exited
+		return
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(shutdownTimeout):
+		log.FromContext(ctx).Info("PostgreSQL didn't stop in time, killing it")
+		_ = postgres.Process.Kill()
+		<-exited
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	pgbackrestCredentials "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/credentials"
+	pgbackrestRestorer "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/restorer"
+	pgbackrestUtils "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
+)
+
+const (
+	// walServerDialTimeout is how long the client waits for the WAL server
+	// to listen, as it starts together with PostgreSQL
+	walServerDialTimeout = 30 * time.Second
+
+	// walPath is the path of the WAL server requests
+	walPath = "/wal"
+)
+
+// restoreFunc restores a WAL file to the destination path
+type restoreFunc func(ctx context.Context, walName string, destinationPath string) error
+
+// WALServer restores the WAL files requested by PostgreSQL, as the
+// PostgreSQL image doesn't ship pgBackRest. It listens on a unix socket shared
+// with the PostgreSQL container, whose restore_command asks for the WAL files
+// through RestoreWAL.
+type WALServer struct {
+	Client         client.Client
+	RestoreTest    *pgbackrestv1.RestoreTest
+	PgDataPath     string
+	SpoolDirectory string
+	SocketPath     string
+}
+
+// Start serves the WAL files until the context is cancelled
+func (server *WALServer) Start(ctx context.Context) error {
+	// listening before getting the credentials lets PostgreSQL connect
+	// while they are fetched
+	if err := os.Remove(server.SocketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", server.SocketPath)
+	if err != nil {
+		return fmt.Errorf("while listening on %s: %w", server.SocketPath, err)
+	}
+
+	archive, err := getArchive(ctx, server.Client, server.RestoreTest)
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	pgbackrestConfiguration := &archive.Spec.Configuration
+
+	env, removeConfig, err := pgbackrestCredentials.EnvSetRestoreCloudCredentials(
+		ctx,
+		server.Client,
+		archive.Namespace,
+		pgbackrestConfiguration,
+		pgbackrestUtils.SanitizedEnviron())
+	if err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("while getting the repository credentials: %w", err)
+	}
+	defer removeConfig()
+
+	walRestorer, err := pgbackrestRestorer.NewWALRestorer(
+		ctx,
+		env,
+		server.SpoolDirectory,
+		pgbackrestConfiguration.GetCommandTimeout(pgbackrestCommand.CommandArchiveGet),
+		nil,
+		nil,
+		nil,
+	)
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	options, err := pgbackrestCommand.CloudWalRestoreOptions(
+		ctx, pgbackrestConfiguration, server.RestoreTest.Spec.Stanza, server.PgDataPath)
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	return serveWAL(ctx, listener, server.PgDataPath,
+		func(ctx context.Context, walName string, destinationPath string) error {
+			return walRestorer.Restore(ctx, walName, destinationPath, options)
+		})
+}
+
+// serveWAL serves the WAL files restored by restore on the listener, until
+// the context is cancelled. The WAL files can only be restored into pgData.
+func serveWAL(ctx context.Context, listener net.Listener, pgData string, restore restoreFunc) error {
+	contextLogger := log.FromContext(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(walPath, func(writer http.ResponseWriter, request *http.Request) {
+		walName := request.URL.Query().Get("name")
+		destinationPath := filepath.Clean(request.URL.Query().Get("path"))
+		if walName == "" || !strings.HasPrefix(destinationPath, filepath.Clean(pgData)+string(filepath.Separator)) {
+			http.Error(writer, "invalid WAL restore request", http.StatusBadRequest)
+			return
+		}
+
+		err := restore(request.Context(), walName, destinationPath)
+		switch {
+		case errors.Is(err, pgbackrestRestorer.ErrWALNotFound):
+			contextLogger.Info("WAL file not found", "walName", walName)
+			http.Error(writer, err.Error(), http.StatusNotFound)
+		case err != nil:
+			contextLogger.Error(err, "while restoring WAL file", "walName", walName)
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+		default:
+			contextLogger.Info("Restored WAL file", "walName", walName)
+			writer.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	httpServer := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Close()
+	}()
+
+	if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RestoreWAL asks the WAL server listening on the socket to restore the WAL
+// file to the destination path. It returns restorer.ErrWALNotFound when the
+// WAL file isn't archived.
+func RestoreWAL(ctx context.Context, socketPath string, walName string, destinationPath string) error {
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialWALServer(ctx, socketPath)
+			},
+		},
+	}
+
+	query := url.Values{}
+	query.Set("name", walName)
+	query.Set("path", destinationPath)
+	request, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, "http://wal-server"+walPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("while requesting WAL file %s: %w", walName, err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	switch response.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return pgbackrestRestorer.ErrWALNotFound
+	default:
+		message, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("while restoring WAL file %s: %s", walName, strings.TrimSpace(string(message)))
+	}
+}
+
+// dialWALServer connects to the WAL server, waiting for it to listen
+func dialWALServer(ctx context.Context, socketPath string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, walServerDialTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "unix", socketPath)
+		if err == nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(time.Second):
+		}
+	}
+}
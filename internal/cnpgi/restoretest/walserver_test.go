@@ -0,0 +1,94 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restoretest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+
+	pgbackrestRestorer "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/restorer"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL server", func() {
+	var (
+		socketPath string
+		pgData     string
+		restored   map[string]string
+	)
+
+	BeforeEach(func(ctx SpecContext) {
+		tempDir, err := os.MkdirTemp("", "restore-test-")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(func() {
+			_ = os.RemoveAll(tempDir)
+		})
+		socketPath = filepath.Join(tempDir, "wal.sock")
+		pgData = filepath.Join(tempDir, "pgdata")
+		restored = make(map[string]string)
+
+		listener, err := net.Listen("unix", socketPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		serverCtx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- serveWAL(serverCtx, listener, pgData,
+				func(_ context.Context, walName string, destinationPath string) error {
+					switch walName {
+					case "000000010000000000000002":
+						return pgbackrestRestorer.ErrWALNotFound
+					case "000000010000000000000003":
+						return errors.New("repository unreachable")
+					}
+					restored[walName] = destinationPath
+					return nil
+				})
+		}()
+		DeferCleanup(func() {
+			cancel()
+			Expect(<-done).ToNot(HaveOccurred())
+		})
+	})
+
+	It("restores the requested WAL file", func(ctx SpecContext) {
+		destination := filepath.Join(pgData, "pg_wal", "RECOVERYXLOG")
+		Expect(RestoreWAL(ctx, socketPath, "000000010000000000000001", destination)).To(Succeed())
+		Expect(restored).To(HaveKeyWithValue("000000010000000000000001", destination))
+	})
+
+	It("reports the WAL files which are not archived", func(ctx SpecContext) {
+		err := RestoreWAL(ctx, socketPath, "000000010000000000000002", filepath.Join(pgData, "pg_wal", "RECOVERYXLOG"))
+		Expect(err).To(MatchError(pgbackrestRestorer.ErrWALNotFound))
+	})
+
+	It("reports the restore errors", func(ctx SpecContext) {
+		err := RestoreWAL(ctx, socketPath, "000000010000000000000003", filepath.Join(pgData, "pg_wal", "RECOVERYXLOG"))
+		Expect(err).To(MatchError(ContainSubstring("repository unreachable")))
+	})
+
+	It("refuses to restore WAL files outside of the data directory", func(ctx SpecContext) {
+		err := RestoreWAL(ctx, socketPath, "000000010000000000000001", "/etc/passwd")
+		Expect(err).To(HaveOccurred())
+		Expect(restored).To(BeEmpty())
+	})
+})
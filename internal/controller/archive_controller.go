@@ -20,51 +20,387 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
 )
 
 // ArchiveReconciler reconciles an Archive object.
 type ArchiveReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// SidecarImage is the image of the Jobs verifying the repositories
+	SidecarImage string
+
+	// Recorder emits the Events about the Archives, nil meaning no Event
+	Recorder events.EventRecorder
 }
 
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch
+const (
+	// reasonConfigurationValid is the reason of the ConfigurationValid
+	// condition when the configuration is accepted
+	reasonConfigurationValid = "ConfigurationValid"
+
+	// reasonInvalidConfiguration is the reason of the ConfigurationValid
+	// condition, and of the Event, when the configuration is rejected
+	reasonInvalidConfiguration = "InvalidConfiguration"
+
+	// actionValidate is the action of the Events emitted while validating
+	// the configuration
+	actionValidate = "Validate"
+)
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=create;patch;update;get;list;watch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;list;get;watch;delete
-// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=archives,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=archives/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=archives/finalizers,verbs=update
+// +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=restorepoints,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=restorepoints/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=clusterarchives,verbs=get;list;watch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch
 // +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters/finalizers,verbs=update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Archive object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
-func (r *ArchiveReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+// Reconcile keeps the list of clusters using an Archive in its status,
+// prevents the Archive from being deleted while it is still in use, validates
+// its configuration and schedules the verification of its repositories.
+func (r *ArchiveReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx)
+
+	var archive pgbackrestv1.Archive
+	if err := r.Get(ctx, req.NamespacedName, &archive); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	consumers, err := r.getConsumers(ctx, &archive)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("while listing the clusters using the archive: %w", err)
+	}
+
+	if !equality.Semantic.DeepEqual(consumers, archive.Status.Consumers) {
+		origArchive := archive.DeepCopy()
+		archive.Status.Consumers = consumers
+		if err := r.Status().Patch(ctx, &archive, client.MergeFrom(origArchive)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("while updating the archive status: %w", err)
+		}
+	}
+
+	if !archive.DeletionTimestamp.IsZero() {
+		if len(consumers) > 0 {
+			contextLogger.Info("Archive deletion blocked, clusters are still using it",
+				"consumers", consumers)
+			return ctrl.Result{}, nil
+		}
+
+		if controllerutil.RemoveFinalizer(&archive, metadata.ArchiveFinalizerName) {
+			contextLogger.Info("Archive not used anymore, releasing it for deletion")
+			if err := r.Update(ctx, &archive); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
 
-	// TODO(user): your logic here
+	if controllerutil.AddFinalizer(&archive, metadata.ArchiveFinalizerName) {
+		if err := r.Update(ctx, &archive); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.reconcileConfigurationValidity(ctx, &archive); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while validating the configuration of the archive: %w", err)
+	}
+
+	if err := r.reconcileVerification(ctx, &archive); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while scheduling the verification of the archive: %w", err)
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// reconcileConfigurationValidity checks the additional command line arguments
+// of the Archive and records the result in its ConfigurationValid condition,
+// emitting a Warning Event when they become invalid. The admission of the
+// clusters only checks them when a cluster is created or changed.
+func (r *ArchiveReconciler) reconcileConfigurationValidity(ctx context.Context, archive *pgbackrestv1.Archive) error {
+	condition := metav1.Condition{
+		Type:               pgbackrestv1.ConditionConfigurationValid,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonConfigurationValid,
+		Message:            "The configuration is valid",
+		ObservedGeneration: archive.Generation,
+	}
+	if err := pgbackrestCommand.ValidateConfiguration(&archive.Spec.Configuration); err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonInvalidConfiguration
+		condition.Message = err.Error()
+	}
+
+	previous := meta.FindStatusCondition(archive.Status.Conditions, condition.Type)
+	if previous != nil && previous.Status == condition.Status && previous.Message == condition.Message &&
+		previous.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+
+	if condition.Status == metav1.ConditionFalse && r.Recorder != nil {
+		r.Recorder.Eventf(archive, nil, corev1.EventTypeWarning, reasonInvalidConfiguration, actionValidate,
+			"The configuration is invalid, the commands using it will fail: %s", condition.Message)
+	}
+
+	origArchive := archive.DeepCopy()
+	meta.SetStatusCondition(&archive.Status.Conditions, condition)
+	return r.Status().Patch(ctx, archive,
+		client.MergeFromWithOptions(origArchive, client.MergeFromWithOptimisticLock{}))
+}
+
+// reconcileVerification creates or updates the CronJob verifying the stanzas
+// archived into the Archive, together with its RBAC, and removes them once the
+// verification is disabled or no stanza is left
+func (r *ArchiveReconciler) reconcileVerification(ctx context.Context, archive *pgbackrestv1.Archive) error {
+	stanzas, err := r.getArchivedStanzas(ctx, archive)
+	if err != nil {
+		return fmt.Errorf("while listing the stanzas of the archive: %w", err)
+	}
+
+	if archive.Spec.Verification == nil || len(stanzas) == 0 {
+		return r.deleteVerification(ctx, archive)
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, archive,
+		specs.BuildVerificationServiceAccount(archive),
+		&corev1.ServiceAccount{},
+		func(_, _ *corev1.ServiceAccount) {},
+	); err != nil {
+		return err
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, archive,
+		specs.BuildVerificationRole(archive),
+		&rbacv1.Role{},
+		func(current, desired *rbacv1.Role) {
+			current.Rules = desired.Rules
+		},
+	); err != nil {
+		return err
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, archive,
+		specs.BuildVerificationRoleBinding(archive),
+		&rbacv1.RoleBinding{},
+		func(current, desired *rbacv1.RoleBinding) {
+			current.Subjects = desired.Subjects
+			current.RoleRef = desired.RoleRef
+		},
+	); err != nil {
+		return err
+	}
+
+	return ensureOwnedObject(ctx, r.Client, r.Scheme, archive,
+		specs.BuildVerificationCronJob(archive, stanzas, r.SidecarImage),
+		&batchv1.CronJob{},
+		func(current, desired *batchv1.CronJob) {
+			// the fields defaulted by the API server are not reset
+			if !equality.Semantic.DeepDerivative(desired.Spec, current.Spec) {
+				current.Spec = desired.Spec
+			}
+		},
+	)
+}
+
+// deleteVerification removes the CronJob verifying the Archive and its RBAC.
+// Only the existing objects controlled by the Archive are deleted, so that an
+// Archive without verification doesn't call the API server at every
+// reconciliation.
+func (r *ArchiveReconciler) deleteVerification(ctx context.Context, archive *pgbackrestv1.Archive) error {
+	name := specs.GetVerificationName(archive)
+	objects := []client.Object{
+		&batchv1.CronJob{},
+		&rbacv1.RoleBinding{},
+		&rbacv1.Role{},
+		&corev1.ServiceAccount{},
+	}
+	for _, object := range objects {
+		if err := r.Get(ctx, client.ObjectKey{Namespace: archive.Namespace, Name: name}, object); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("while getting %T %s: %w", object, name, err)
+		}
+		if !metav1.IsControlledBy(object, archive) {
+			continue
+		}
+
+		err := r.Delete(ctx, object, client.Preconditions{UID: ptr.To(object.GetUID())})
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("while deleting %T %s: %w", object, name, err)
+		}
+	}
+	return nil
+}
+
+// ensureOwnedObject creates the desired object, controlled by owner, when
+// missing, or patches the existing one with update otherwise
+func ensureOwnedObject[T client.Object](
+	ctx context.Context,
+	c client.Client,
+	scheme *runtime.Scheme,
+	owner client.Object,
+	desired T,
+	current T,
+	update func(current T, desired T),
+) error {
+	current.SetNamespace(desired.GetNamespace())
+	current.SetName(desired.GetName())
+
+	result, err := controllerutil.CreateOrPatch(ctx, c, current, func() error {
+		current.SetLabels(desired.GetLabels())
+		update(current, desired)
+		return controllerutil.SetControllerReference(owner, current, scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("while reconciling %T %s: %w", desired, desired.GetName(), err)
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("Reconciled owned object",
+			"kind", fmt.Sprintf("%T", desired),
+			"name", desired.GetName(),
+			"operation", result)
+	}
+	return nil
+}
+
+// getArchivedStanzas gets the sorted list of the stanzas archived into the
+// Archive by the clusters of its namespace
+func (r *ArchiveReconciler) getArchivedStanzas(
+	ctx context.Context,
+	archive *pgbackrestv1.Archive,
+) ([]string, error) {
+	var clusters cnpgv1.ClusterList
+	if err := r.List(ctx, &clusters, client.InNamespace(archive.Namespace)); err != nil {
+		return nil, err
+	}
+
+	archiveKey := client.ObjectKeyFromObject(archive)
+	stanzas := stringset.New()
+	for i := range clusters.Items {
+		pluginConfiguration := config.NewFromCluster(&clusters.Items[i])
+		if len(pluginConfiguration.PgbackrestObjectName) == 0 ||
+			pluginConfiguration.GetArchiveObjectKey() != archiveKey {
+			continue
+		}
+
+		stanzas.Put(archive.Spec.Configuration.GetStanza(pluginConfiguration.Stanza))
+	}
+
+	return stanzas.ToSortedList(), nil
+}
+
+// getConsumers gets the sorted list of clusters referring to the archive
+func (r *ArchiveReconciler) getConsumers(
+	ctx context.Context,
+	archive *pgbackrestv1.Archive,
+) ([]pgbackrestv1.ArchiveConsumer, error) {
+	var clusters cnpgv1.ClusterList
+	if err := r.List(ctx, &clusters, client.InNamespace(archive.Namespace)); err != nil {
+		return nil, err
+	}
+
+	archiveKey := client.ObjectKeyFromObject(archive)
+	var consumers []pgbackrestv1.ArchiveConsumer
+	for i := range clusters.Items {
+		pluginConfiguration := config.NewFromCluster(&clusters.Items[i])
+
+		var usages []pgbackrestv1.ArchiveUsage
+		if len(pluginConfiguration.PgbackrestObjectName) > 0 &&
+			pluginConfiguration.GetArchiveObjectKey() == archiveKey {
+			usages = append(usages, pgbackrestv1.ArchiveUsageArchive)
+		}
+		if len(pluginConfiguration.RecoveryPgbackrestObjectName) > 0 &&
+			pluginConfiguration.GetRecoveryArchiveObjectKey() == archiveKey {
+			usages = append(usages, pgbackrestv1.ArchiveUsageRecovery)
+		}
+		if len(pluginConfiguration.ReplicaSourcePgbackrestObjectName) > 0 &&
+			pluginConfiguration.GetReplicaSourceArchiveObjectKey() == archiveKey {
+			usages = append(usages, pgbackrestv1.ArchiveUsageReplicaSource)
+		}
+
+		if len(usages) > 0 {
+			consumers = append(consumers, pgbackrestv1.ArchiveConsumer{
+				Name:   clusters.Items[i].Name,
+				Usages: usages,
+			})
+		}
+	}
+
+	slices.SortFunc(consumers, func(a, b pgbackrestv1.ArchiveConsumer) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return consumers, nil
+}
+
+// mapClusterToArchives maps a cluster to the archives it refers to
+func (r *ArchiveReconciler) mapClusterToArchives(_ context.Context, obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*cnpgv1.Cluster)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, key := range config.NewFromCluster(cluster).GetReferredArchiveObjectsKey() {
+		// ClusterArchives are cluster-scoped and not reconciled here
+		if len(key.Namespace) == 0 {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ArchiveReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// On updates, the archives referred by both the old and the new
+	// cluster definition are reconciled
 	err := ctrl.NewControllerManagedBy(mgr).
 		For(&pgbackrestv1.Archive{}).
+		Owns(&batchv1.CronJob{}).
+		Watches(
+			&cnpgv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(r.mapClusterToArchives),
+		).
 		Complete(r)
 	if err != nil {
 		return fmt.Errorf("unable to create controller: %w", err)
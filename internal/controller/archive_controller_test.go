@@ -20,12 +20,20 @@ package controller
 import (
 	"context"
 
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -72,6 +80,18 @@ var _ = Describe("Archive Controller", func() {
 
 			By("Cleanup the specific resource instance Archive")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			By("Releasing the Archive finalizer")
+			controllerReconciler := &ArchiveReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, typeNamespacedName, resource)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
@@ -84,8 +104,201 @@ var _ = Describe("Archive Controller", func() {
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			Expect(archive.Finalizers).To(ContainElement(metadata.ArchiveFinalizerName))
+			Expect(archive.Status.Consumers).To(BeEmpty())
+		})
+
+		It("should block the deletion while clusters are using the archive", func() {
+			controllerReconciler := &ArchiveReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("creating a cluster using the archive for both archiving and recovery")
+			cluster := &cnpgv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-cluster",
+					Namespace: "default",
+				},
+				Spec: cnpgv1.ClusterSpec{
+					Instances: 1,
+					StorageConfiguration: cnpgv1.StorageConfiguration{
+						Size: "1Gi",
+					},
+					Bootstrap: &cnpgv1.BootstrapConfiguration{
+						Recovery: &cnpgv1.BootstrapRecovery{
+							Source: "origin",
+						},
+					},
+					ExternalClusters: []cnpgv1.ExternalCluster{
+						{
+							Name: "origin",
+							PluginConfiguration: &cnpgv1.PluginConfiguration{
+								Name: metadata.PluginName,
+								Parameters: map[string]string{
+									"pgbackrestObjectName": resourceName,
+								},
+							},
+						},
+					},
+					Plugins: []cnpgv1.PluginConfiguration{
+						{
+							Name: metadata.PluginName,
+							Parameters: map[string]string{
+								"pgbackrestObjectName": resourceName,
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			Expect(archive.Status.Consumers).To(Equal([]pgbackrestv1.ArchiveConsumer{
+				{
+					Name: "test-cluster",
+					Usages: []pgbackrestv1.ArchiveUsage{
+						pgbackrestv1.ArchiveUsageArchive,
+						pgbackrestv1.ArchiveUsageRecovery,
+					},
+				},
+			}))
+
+			By("deleting the archive while in use")
+			Expect(k8sClient.Delete(ctx, archive)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			Expect(archive.DeletionTimestamp).NotTo(BeNil())
+			Expect(archive.Finalizers).To(ContainElement(metadata.ArchiveFinalizerName))
+
+			By("deleting the cluster")
+			Expect(k8sClient.Delete(ctx, cluster)).To(Succeed())
+		})
+
+		It("should report an invalid configuration in the status and with an Event", func() {
+			recorder := events.NewFakeRecorder(10)
+			controllerReconciler := &ArchiveReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+			}
+
+			By("reconciling the valid archive")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(archive.Status.Conditions,
+				pgbackrestv1.ConditionConfigurationValid)).To(BeTrue())
+			Expect(recorder.Events).To(BeEmpty())
+
+			By("changing the archive to an invalid configuration")
+			archive.Spec.Configuration.Restore = &pgbackrestApi.DataRestoreConfiguration{
+				AdditionalCommandArgs: []string{"--archive-push-queue-max=1GiB"},
+			}
+			Expect(k8sClient.Update(ctx, archive)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			condition := meta.FindStatusCondition(archive.Status.Conditions, pgbackrestv1.ConditionConfigurationValid)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(reasonInvalidConfiguration))
+			Expect(condition.Message).To(ContainSubstring("restore.additionalCommandArgs"))
+			Expect(condition.ObservedGeneration).To(Equal(archive.Generation))
+			Expect(recorder.Events).To(Receive(ContainSubstring(reasonInvalidConfiguration)))
+
+			By("reconciling again the unchanged archive")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should schedule the verification of the stanzas archived into the archive", func() {
+			controllerReconciler := &ArchiveReconciler{
+				Client:       k8sClient,
+				Scheme:       k8sClient.Scheme(),
+				SidecarImage: "sidecar:test",
+			}
+
+			By("enabling the verification")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			archive.Spec.Verification = &pgbackrestv1.VerificationConfiguration{Schedule: "0 3 * * 0"}
+			Expect(k8sClient.Update(ctx, archive)).To(Succeed())
+
+			By("creating a cluster archiving into the archive")
+			cluster := &cnpgv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "verified-cluster",
+					Namespace: "default",
+				},
+				Spec: cnpgv1.ClusterSpec{
+					Instances: 1,
+					StorageConfiguration: cnpgv1.StorageConfiguration{
+						Size: "1Gi",
+					},
+					Plugins: []cnpgv1.PluginConfiguration{
+						{
+							Name: metadata.PluginName,
+							Parameters: map[string]string{
+								"pgbackrestObjectName": resourceName,
+								"stanza":               "main",
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			verificationKey := types.NamespacedName{
+				Namespace: "default",
+				Name:      specs.GetVerificationName(archive),
+			}
+			var cronJob batchv1.CronJob
+			Expect(k8sClient.Get(ctx, verificationKey, &cronJob)).To(Succeed())
+			Expect(cronJob.Spec.Schedule).To(Equal("0 3 * * 0"))
+			Expect(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image).To(Equal("sidecar:test"))
+			Expect(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Env).To(
+				ContainElement(corev1.EnvVar{Name: "STANZAS", Value: "main"}))
+			Expect(k8sClient.Get(ctx, verificationKey, &corev1.ServiceAccount{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, verificationKey, &rbacv1.Role{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, verificationKey, &rbacv1.RoleBinding{})).To(Succeed())
+
+			By("disabling the verification")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, archive)).To(Succeed())
+			archive.Spec.Verification = nil
+			Expect(k8sClient.Update(ctx, archive)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, verificationKey, &batchv1.CronJob{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("deleting the cluster")
+			Expect(k8sClient.Delete(ctx, cluster)).To(Succeed())
 		})
 	})
 })
@@ -0,0 +1,61 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/backupsync"
+)
+
+// BackupSyncReconciler creates and deletes the Backup objects of the backup
+// sets the primary instances listed in the status of the Archives. The
+// instances aren't allowed to do it themselves.
+type BackupSyncReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch;create;delete
+
+// Reconcile synchronizes the Backup objects with the backup sets of an
+// Archive. The instances list them again at every interval, which updates
+// the Archive and triggers a new reconciliation.
+func (r *BackupSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var archive pgbackrestv1.Archive
+	if err := r.Get(ctx, req.NamespacedName, &archive); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !archive.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{}, backupsync.Reconcile(ctx, r.Client, &archive)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackupSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pgbackrestv1.Archive{}).
+		Named("archive-backupsync").
+		Complete(r)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+)
+
+// ClusterArchiveReconciler validates the configuration of the ClusterArchives.
+// A ClusterArchive has no status, so an invalid configuration is only
+// reported through a Warning Event.
+type ClusterArchiveReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Recorder emits the Events about the ClusterArchives, nil meaning no Event
+	Recorder events.EventRecorder
+}
+
+// Reconcile emits a Warning Event when the configuration of a ClusterArchive
+// is invalid. The admission of the clusters only checks it when a cluster is
+// created or changed.
+func (r *ClusterArchiveReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var clusterArchive pgbackrestv1.ClusterArchive
+	if err := r.Get(ctx, req.NamespacedName, &clusterArchive); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !clusterArchive.DeletionTimestamp.IsZero() || r.Recorder == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if err := pgbackrestCommand.ValidateConfiguration(&clusterArchive.Spec.Configuration); err != nil {
+		r.Recorder.Eventf(&clusterArchive, nil, corev1.EventTypeWarning, reasonInvalidConfiguration, actionValidate,
+			"The configuration is invalid, the commands using it will fail: %s", err.Error())
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager, validating the
+// ClusterArchives when their specification changes.
+func (r *ClusterArchiveReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&pgbackrestv1.ClusterArchive{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("clusterarchive").
+		Complete(r)
+}
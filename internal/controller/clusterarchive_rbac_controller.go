@@ -0,0 +1,145 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/metadata"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/rbac"
+)
+
+// ClusterArchiveRBACReconciler reconciles the access of a cluster to the
+// ClusterArchives, which is granted through RBAC objects living outside of
+// its namespace and thus not garbage collected with it. It is the only owner
+// of these objects: the reconciler hooks of the cluster wait for them.
+type ClusterArchiveRBACReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile grants a cluster access to the ClusterArchives it uses, and
+// revokes the access of deleted clusters, together with the access to the
+// ClusterArchives a cluster doesn't use, or isn't allowed to use anymore.
+func (r *ClusterArchiveRBACReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		if !apierrs.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, rbac.RevokeClusterArchives(ctx, r.Client, req.NamespacedName)
+	}
+
+	if !cluster.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, rbac.RevokeClusterArchives(ctx, r.Client, req.NamespacedName)
+	}
+
+	var clusterArchives []pgbackrestv1.ClusterArchive
+	for _, key := range config.NewFromCluster(&cluster).GetReferredArchiveObjectsKey() {
+		if len(key.Namespace) > 0 {
+			continue
+		}
+
+		var clusterArchive pgbackrestv1.ClusterArchive
+		if err := r.Get(ctx, key, &clusterArchive); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return ctrl.Result{}, fmt.Errorf("while getting the ClusterArchive %s: %w", key.Name, err)
+		}
+		if clusterArchive.IsNamespaceAllowed(cluster.Namespace) {
+			clusterArchives = append(clusterArchives, clusterArchive)
+		}
+	}
+
+	return ctrl.Result{}, rbac.ReconcileClusterArchives(ctx, r.Client, &cluster, clusterArchives)
+}
+
+// mapClusterArchiveToClusters maps a ClusterArchive to the clusters referring
+// to it, whose access depends on its allowed namespaces
+func (r *ClusterArchiveRBACReconciler) mapClusterArchiveToClusters(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	var clusters cnpgv1.ClusterList
+	if err := r.List(ctx, &clusters); err != nil {
+		log.FromContext(ctx).Error(err, "while listing the clusters")
+		return nil
+	}
+
+	clusterArchiveKey := types.NamespacedName{Name: obj.GetName()}
+	var requests []reconcile.Request
+	for i := range clusters.Items {
+		for _, key := range config.NewFromCluster(&clusters.Items[i]).GetReferredArchiveObjectsKey() {
+			if key == clusterArchiveKey {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i]),
+				})
+				break
+			}
+		}
+	}
+
+	return requests
+}
+
+// mapRBACObjectToCluster maps the RBAC objects granting access to the
+// ClusterArchives to their cluster, so that the ones left behind by a cluster
+// deleted while the operator wasn't running are removed too
+func (r *ClusterArchiveRBACReconciler) mapRBACObjectToCluster(_ context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	name, namespace := labels[metadata.ClusterNameLabelName], labels[metadata.ClusterNamespaceLabelName]
+	if len(name) == 0 || len(namespace) == 0 {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterArchiveRBACReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("clusterarchive-rbac").
+		For(&cnpgv1.Cluster{}).
+		Watches(
+			&pgbackrestv1.ClusterArchive{},
+			handler.EnqueueRequestsFromMapFunc(r.mapClusterArchiveToClusters),
+		).
+		Watches(
+			&rbacv1.ClusterRoleBinding{},
+			handler.EnqueueRequestsFromMapFunc(r.mapRBACObjectToCluster),
+		).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/config"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/rbac"
+)
+
+// RestorePointRBACReconciler grants the sidecars of a cluster access to the
+// RestorePoints of the cluster which are still pending, naming them one by one,
+// as the RestorePoints of all the clusters of a namespace live together. It
+// is the only owner of the Role and RoleBinding granting this access.
+type RestorePointRBACReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile grants a cluster using the plugin access to its pending
+// RestorePoints, revoking the access to the other ones
+func (r *RestorePointRBACReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !cluster.DeletionTimestamp.IsZero() || len(config.NewFromCluster(&cluster).GetReferredArchiveObjectsKey()) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	var restorePoints pgbackrestv1.RestorePointList
+	if err := r.List(ctx, &restorePoints, client.InNamespace(cluster.Namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while listing the restore points: %w", err)
+	}
+
+	return ctrl.Result{}, rbac.ReconcileRestorePoints(ctx, r.Client, &cluster, restorePoints.Items)
+}
+
+// mapRestorePointToCluster maps a RestorePoint to the cluster it is created in
+func (r *RestorePointRBACReconciler) mapRestorePointToCluster(
+	_ context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	restorePoint, ok := obj.(*pgbackrestv1.RestorePoint)
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Namespace: restorePoint.Namespace,
+		Name:      restorePoint.Spec.Cluster.Name,
+	}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RestorePointRBACReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("restorepoint-rbac").
+		For(&cnpgv1.Cluster{}).
+		Watches(
+			&pgbackrestv1.RestorePoint{},
+			handler.EnqueueRequestsFromMapFunc(r.mapRestorePointToCluster),
+		).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+)
+
+// RestoreTestReconciler reconciles a RestoreTest object.
+type RestoreTestReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// SidecarImage is the image restoring the backups in the restore tests
+	SidecarImage string
+}
+
+// +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=restoretests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pgbackrest.cnpg.opera.com,resources=restoretests/status,verbs=get;update;patch
+
+// Reconcile schedules the RestoreTest through a CronJob, together with the
+// RBAC allowing its Jobs to read the Archive and to record the results. The
+// owned objects are garbage collected with the RestoreTest.
+func (r *RestoreTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx)
+
+	var restoreTest pgbackrestv1.RestoreTest
+	if err := r.Get(ctx, req.NamespacedName, &restoreTest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !restoreTest.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	var archive pgbackrestv1.Archive
+	if err := r.Get(ctx, client.ObjectKey{
+		Namespace: restoreTest.Namespace,
+		Name:      restoreTest.Spec.Archive.Name,
+	}, &archive); err != nil {
+		if apierrs.IsNotFound(err) {
+			// the RestoreTest is reconciled again once the Archive is created
+			contextLogger.Info("Archive of the restore test not found, waiting for it",
+				"archive", restoreTest.Spec.Archive.Name)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("while getting the archive of the restore test: %w", err)
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, &restoreTest,
+		specs.BuildRestoreTestServiceAccount(&restoreTest),
+		&corev1.ServiceAccount{},
+		func(_, _ *corev1.ServiceAccount) {},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, &restoreTest,
+		specs.BuildRestoreTestRole(&restoreTest, &archive),
+		&rbacv1.Role{},
+		func(current, desired *rbacv1.Role) {
+			current.Rules = desired.Rules
+		},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, &restoreTest,
+		specs.BuildRestoreTestRoleBinding(&restoreTest),
+		&rbacv1.RoleBinding{},
+		func(current, desired *rbacv1.RoleBinding) {
+			current.Subjects = desired.Subjects
+			current.RoleRef = desired.RoleRef
+		},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := ensureOwnedObject(ctx, r.Client, r.Scheme, &restoreTest,
+		specs.BuildRestoreTestCronJob(&restoreTest, &archive, r.SidecarImage),
+		&batchv1.CronJob{},
+		func(current, desired *batchv1.CronJob) {
+			// the fields defaulted by the API server are not reset
+			if !equality.Semantic.DeepDerivative(desired.Spec, current.Spec) {
+				current.Spec = desired.Spec
+			}
+		},
+	); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// mapArchiveToRestoreTests maps an Archive to the restore tests using it, whose
+// Role and CronJob depend on the Secrets and the sidecar configuration of the
+// Archive
+func (r *RestoreTestReconciler) mapArchiveToRestoreTests(ctx context.Context, obj client.Object) []reconcile.Request {
+	var restoreTests pgbackrestv1.RestoreTestList
+	if err := r.List(ctx, &restoreTests, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "while listing the restore tests")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range restoreTests.Items {
+		if restoreTests.Items[i].Spec.Archive.Name != obj.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(&restoreTests.Items[i]),
+		})
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RestoreTestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&pgbackrestv1.RestoreTest{}).
+		Owns(&batchv1.CronJob{}).
+		Watches(
+			&pgbackrestv1.Archive{},
+			handler.EnqueueRequestsFromMapFunc(r.mapArchiveToRestoreTests),
+		).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
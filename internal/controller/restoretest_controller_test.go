@@ -0,0 +1,148 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	pgbackrestv1 "github.com/operasoftware/cnpg-plugin-pgbackrest/api/v1"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/cnpgi/operator/specs"
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestoreTest Controller", func() {
+	const (
+		archiveName     = "restore-test-archive"
+		restoreTestName = "nightly"
+	)
+
+	ctx := context.Background()
+
+	restoreTestKey := types.NamespacedName{
+		Name:      restoreTestName,
+		Namespace: "default",
+	}
+
+	var controllerReconciler *RestoreTestReconciler
+
+	BeforeEach(func() {
+		controllerReconciler = &RestoreTestReconciler{
+			Client:       k8sClient,
+			Scheme:       k8sClient.Scheme(),
+			SidecarImage: "sidecar:test",
+		}
+
+		By("creating the restore test")
+		Expect(k8sClient.Create(ctx, &pgbackrestv1.RestoreTest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      restoreTestName,
+				Namespace: "default",
+			},
+			Spec: pgbackrestv1.RestoreTestSpec{
+				Schedule:  "0 4 * * *",
+				Archive:   cnpgv1.LocalObjectReference{Name: archiveName},
+				Stanza:    "main",
+				ImageName: "ghcr.io/cloudnative-pg/postgresql:17",
+			},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		restoreTest := &pgbackrestv1.RestoreTest{}
+		Expect(k8sClient.Get(ctx, restoreTestKey, restoreTest)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, restoreTest)).To(Succeed())
+
+		archive := &pgbackrestv1.Archive{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: archiveName, Namespace: "default"}, archive)
+		if err == nil {
+			Expect(k8sClient.Delete(ctx, archive)).To(Succeed())
+		}
+	})
+
+	It("should wait for the archive to exist", func() {
+		_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreTestKey})
+		Expect(err).NotTo(HaveOccurred())
+
+		restoreTest := &pgbackrestv1.RestoreTest{}
+		Expect(k8sClient.Get(ctx, restoreTestKey, restoreTest)).To(Succeed())
+		err = k8sClient.Get(ctx, types.NamespacedName{
+			Namespace: "default",
+			Name:      specs.GetRestoreTestName(restoreTest),
+		}, &batchv1.CronJob{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should schedule the restore test", func() {
+		By("creating the archive")
+		Expect(k8sClient.Create(ctx, &pgbackrestv1.Archive{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      archiveName,
+				Namespace: "default",
+			},
+			Spec: pgbackrestv1.ArchiveSpec{
+				Configuration: pgbackrestApi.PgbackrestConfiguration{
+					Repositories: []pgbackrestApi.PgbackrestRepository{},
+				},
+			},
+		})).To(Succeed())
+
+		_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreTestKey})
+		Expect(err).NotTo(HaveOccurred())
+
+		restoreTest := &pgbackrestv1.RestoreTest{}
+		Expect(k8sClient.Get(ctx, restoreTestKey, restoreTest)).To(Succeed())
+		objectKey := types.NamespacedName{
+			Namespace: "default",
+			Name:      specs.GetRestoreTestName(restoreTest),
+		}
+
+		var cronJob batchv1.CronJob
+		Expect(k8sClient.Get(ctx, objectKey, &cronJob)).To(Succeed())
+		Expect(cronJob.Spec.Schedule).To(Equal("0 4 * * *"))
+		Expect(cronJob.OwnerReferences).To(HaveLen(1))
+		Expect(cronJob.OwnerReferences[0].Name).To(Equal(restoreTestName))
+
+		podSpec := cronJob.Spec.JobTemplate.Spec.Template.Spec
+		Expect(podSpec.InitContainers).To(HaveLen(2))
+		Expect(podSpec.InitContainers[0].Image).To(Equal("sidecar:test"))
+		Expect(podSpec.Containers[0].Image).To(Equal("ghcr.io/cloudnative-pg/postgresql:17"))
+		Expect(k8sClient.Get(ctx, objectKey, &corev1.ServiceAccount{})).To(Succeed())
+		Expect(k8sClient.Get(ctx, objectKey, &rbacv1.Role{})).To(Succeed())
+		Expect(k8sClient.Get(ctx, objectKey, &rbacv1.RoleBinding{})).To(Succeed())
+
+		By("suspending the restore test")
+		restoreTest.Spec.Suspend = true
+		Expect(k8sClient.Update(ctx, restoreTest)).To(Succeed())
+
+		_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: restoreTestKey})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, objectKey, &cronJob)).To(Succeed())
+		Expect(cronJob.Spec.Suspend).To(HaveValue(BeTrue()))
+	})
+})
@@ -22,10 +22,15 @@ limitations under the License.
 package api
 
 import (
+	"path"
 	"slices"
 	"strings"
+	"time"
+	"unicode"
 
 	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EncryptionType encapsulated the available types of encryption
@@ -72,11 +77,16 @@ const (
 )
 
 // S3Credentials is the type for the credentials to be used to upload
-// files to S3. It can be provided in two alternative ways:
+// files to S3. It can be provided in the following alternative ways:
 //
 // - explicitly passing accessKeyId and secretAccessKey
 //
 // - inheriting the role from the pod environment by setting inheritFromIAMRole to true
+//
+// - assuming a role with a projected service account token via webIdentity
+//
+// It also holds the server-side encryption settings of the bucket.
+// +kubebuilder:validation:XValidation:rule="!(has(self.kmsKeyId) && has(self.sseCustomerKey))",message="kmsKeyId and sseCustomerKey are mutually exclusive"
 type S3Credentials struct {
 	// KeyType specifies the type of key used for S3 credentials
 	// +optional
@@ -100,6 +110,82 @@ type S3Credentials struct {
 	// TODO: Enforce values via Enum like iin compression.
 	// +optional
 	URIStyle string `json:"uriStyle,omitempty"`
+
+	// Assume an IAM role using a projected service account token (IRSA).
+	// When set, the key type is always web-id.
+	// +optional
+	WebIdentity *WebIdentity `json:"webIdentity,omitempty"`
+
+	// The ID of the KMS key used to encrypt the repository files on the server side
+	// (SSE-KMS). Can't be used together with sseCustomerKey.
+	// +optional
+	KMSKeyID string `json:"kmsKeyId,omitempty"`
+
+	// The reference to the secret containing the base64-encoded customer-provided
+	// key used to encrypt the repository files on the server side (SSE-C).
+	// Can't be used together with kmsKeyId.
+	// +optional
+	SSECustomerKeyReference *machineryapi.SecretKeySelector `json:"sseCustomerKey,omitempty"`
+}
+
+// WebIdentityTokenDirectory is the directory where the projected service account
+// tokens used for web identity credentials are mounted in the sidecar
+const WebIdentityTokenDirectory = "/var/run/secrets/pgbackrest.cnpg.opera.com/web-identity"
+
+// DefaultWebIdentityAudience is the audience of the projected service account
+// token when none is specified
+const DefaultWebIdentityAudience = "sts.amazonaws.com"
+
+// WebIdentity is the configuration needed to assume an IAM role using
+// a projected service account token
+type WebIdentity struct {
+	// The ARN of the IAM role to assume
+	// +kubebuilder:validation:MinLength=1
+	RoleARN string `json:"roleARN"`
+
+	// The audience of the projected service account token
+	// +optional
+	// +kubebuilder:default:=sts.amazonaws.com
+	Audience string `json:"audience,omitempty"`
+
+	// The requested validity of the projected service account token, in seconds.
+	// The kubelet refreshes the token before it expires.
+	// +optional
+	// +kubebuilder:validation:Minimum=600
+	// +kubebuilder:default:=3600
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+// GetAudience returns the audience of the projected service account token
+func (webIdentity *WebIdentity) GetAudience() string {
+	if len(webIdentity.Audience) == 0 {
+		return DefaultWebIdentityAudience
+	}
+	return webIdentity.Audience
+}
+
+// GetTokenFileName returns the name of the token file, relative to
+// WebIdentityTokenDirectory. Tokens are distinguished by their audience.
+func (webIdentity *WebIdentity) GetTokenFileName() string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, webIdentity.GetAudience())
+}
+
+// GetTokenPath returns the path of the projected service account token
+func (webIdentity *WebIdentity) GetTokenPath() string {
+	return path.Join(WebIdentityTokenDirectory, webIdentity.GetTokenFileName())
+}
+
+// GetKeyType returns the type of key used for S3 credentials
+func (s3credentials *S3Credentials) GetKeyType() KeyType {
+	if s3credentials.WebIdentity != nil {
+		return KeyTypeWebID
+	}
+	return s3credentials.KeyType
 }
 
 // PgbackrestCredentials an object containing the potential credentials for each cloud provider
@@ -170,16 +256,40 @@ type PgbackrestRetention struct {
 
 // WalBackupConfiguration is the configuration of the backup of the
 // WAL stream
+// +kubebuilder:validation:XValidation:rule="!has(self.minParallel) || self.minParallel <= (has(self.maxParallel) ? self.maxParallel : 1)",message="minParallel can't be greater than maxParallel"
 type WalBackupConfiguration struct {
 	// Number of WAL files to be either archived in parallel (when the
 	// PostgreSQL instance is archiving to a backup object store) or
 	// restored in parallel (when a PostgreSQL standby is fetching WAL
 	// files from a recovery object store). If not specified, WAL files
 	// will be processed one at a time. It accepts a positive integer as a
-	// value - with 1 being the minimum accepted value.
+	// value - with 1 being the minimum accepted value. With adaptiveParallel,
+	// it is the upper bound of the number of WAL files archived in parallel.
 	// +kubebuilder:validation:Minimum=1
 	// +optional
 	MaxParallel int `json:"maxParallel,omitempty"`
+
+	// When enabled, the number of WAL files archived in parallel is adapted,
+	// between minParallel and maxParallel, to the number of WAL files waiting
+	// to be archived and to the time taken to archive them.
+	// +optional
+	AdaptiveParallel bool `json:"adaptiveParallel,omitempty"`
+
+	// The minimum number of WAL files archived in parallel when
+	// adaptiveParallel is enabled. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinParallel int `json:"minParallel,omitempty"`
+
+	// The maximum size of the WAL files waiting to be archived, passed to
+	// pgBackRest as archive-push-queue-max. Once it is exceeded, pgBackRest
+	// drops the WAL files instead of archiving them, so that pg_wal doesn't
+	// fill its volume when the repository is unavailable. As point in time
+	// recovery isn't possible across the dropped WAL files, the archive is
+	// then reported as broken until a full backup is taken.
+	// +optional
+	ArchivePushQueueMax *resource.Quantity `json:"archivePushQueueMax,omitempty"`
+
 	// Additional arguments that can be appended to the 'pgbackrest archive-push'
 	// command-line invocation. These arguments provide flexibility to customize
 	// the WAL archive process further, according to specific requirements or configurations.
@@ -190,13 +300,13 @@ type WalBackupConfiguration struct {
 	// to specify additional command arguments.
 	//
 	// Note:
-	// It's essential to ensure that the provided arguments are valid and supported
-	// by the 'pgbackrest archive-push' command, to avoid potential errors or unintended
-	// behavior during execution.
+	// The arguments must be options in the --name or --name=value form supported
+	// by the 'pgbackrest archive-push' command. Unknown options and options managed by
+	// the plugin are rejected.
 	// +optional
 	ArchiveAdditionalCommandArgs []string `json:"archiveAdditionalCommandArgs,omitempty"`
 
-	// Additional arguments that can be appended to the 'pgbackrest restore'
+	// Additional arguments that can be appended to the 'pgbackrest archive-get'
 	// command-line invocation. These arguments provide flexibility to customize
 	// the WAL restore process further, according to specific requirements or configurations.
 	//
@@ -206,9 +316,9 @@ type WalBackupConfiguration struct {
 	// to specify additional command arguments.
 	//
 	// Note:
-	// It's essential to ensure that the provided arguments are valid and supported
-	// by the 'pgbackrest restore' command, to avoid potential errors or unintended
-	// behavior during execution.
+	// The arguments must be options in the --name or --name=value form supported
+	// by the 'pgbackrest archive-get' command. Unknown options and options managed by
+	// the plugin are rejected.
 	// +optional
 	RestoreAdditionalCommandArgs []string `json:"restoreAdditionalCommandArgs,omitempty"`
 }
@@ -230,6 +340,13 @@ type DataBackupConfiguration struct {
 	// +optional
 	ImmediateCheckpoint bool `json:"immediateCheckpoint,omitempty"`
 
+	// Control whether pgBackRest validates the checksums of the data pages
+	// it copies, reporting the files with invalid pages in the backup. When
+	// not set, pgBackRest validates them if the data checksums are enabled
+	// in the cluster.
+	// +optional
+	ChecksumPage *bool `json:"checksumPage,omitempty"`
+
 	// Annotations is a list of key value pairs that will be passed to the
 	// pgbackrest --annotation option.
 	// +optional
@@ -246,9 +363,9 @@ type DataBackupConfiguration struct {
 	// to specify additional command arguments.
 	//
 	// Note:
-	// It's essential to ensure that the provided arguments are valid and supported
-	// by the 'pgbackrest backup' command, to avoid potential errors or unintended
-	// behavior during execution.
+	// The arguments must be options in the --name or --name=value form supported
+	// by the 'pgbackrest backup' command. Unknown options and options managed by
+	// the plugin are rejected.
 	// +optional
 	AdditionalCommandArgs []string `json:"additionalCommandArgs,omitempty"`
 }
@@ -267,6 +384,39 @@ type LogConfiguration struct {
 	LevelStderr string `json:"levelStderr,omitempty"`
 }
 
+// CommandTimeouts limits how long the pgBackRest commands run by the plugin can take.
+// A command still running when its timeout expires is terminated, together with
+// the processes it started. Commands without a timeout are not limited.
+type CommandTimeouts struct {
+	// Timeout of the backup command
+	// +optional
+	Backup *metav1.Duration `json:"backup,omitempty"`
+
+	// Timeout of the restore command
+	// +optional
+	Restore *metav1.Duration `json:"restore,omitempty"`
+
+	// Timeout of the archive-push command, archiving a single WAL file
+	// +optional
+	ArchivePush *metav1.Duration `json:"archivePush,omitempty"`
+
+	// Timeout of the archive-get command, restoring a single WAL file
+	// +optional
+	ArchiveGet *metav1.Duration `json:"archiveGet,omitempty"`
+
+	// Timeout of the stanza-create command
+	// +optional
+	StanzaCreate *metav1.Duration `json:"stanzaCreate,omitempty"`
+
+	// Timeout of the info command, used to read the backup catalog
+	// +optional
+	Info *metav1.Duration `json:"info,omitempty"`
+
+	// Timeout of the verify command, checking the files of the repository
+	// +optional
+	Verify *metav1.Duration `json:"verify,omitempty"`
+}
+
 // DataRestoreConfiguration is the configuration of the main backup restore process
 // (pgbackrest restore call) which is then followed by a series of WAL restore
 // (pgbackrest archive-get) calls using the WalBackupConfiguration
@@ -282,9 +432,9 @@ type DataRestoreConfiguration struct {
 	// specific requirements or configurations.
 	//
 	// Note:
-	// It's essential to ensure that the provided arguments are valid and supported
-	// by the 'pgbackrest restore' command, to avoid potential errors or unintended
-	// behavior during execution.
+	// The arguments must be options in the --name or --name=value form supported
+	// by the 'pgbackrest restore' command. Unknown options and options managed by
+	// the plugin are rejected.
 	// +optional
 	AdditionalCommandArgs []string `json:"additionalCommandArgs,omitempty"`
 }
@@ -398,6 +548,51 @@ type PgbackrestConfiguration struct {
 	// +kubebuilder:default=OnFirstArchive
 	// +optional
 	CreateStanza StanzaCreatePolicy `json:"createStanza,omitempty"`
+
+	// Timeouts of the pgBackRest commands run by the plugin.
+	// When not defined, the commands are not limited in time.
+	// +optional
+	Timeouts *CommandTimeouts `json:"timeouts,omitempty"`
+}
+
+// GetCommandTimeout returns the timeout of the given pgBackRest command, zero
+// meaning the command is not limited in time
+func (c *PgbackrestConfiguration) GetCommandTimeout(command string) time.Duration {
+	if c == nil || c.Timeouts == nil {
+		return 0
+	}
+
+	var timeout *metav1.Duration
+	switch command {
+	case "backup":
+		timeout = c.Timeouts.Backup
+	case "restore":
+		timeout = c.Timeouts.Restore
+	case "archive-push":
+		timeout = c.Timeouts.ArchivePush
+	case "archive-get":
+		timeout = c.Timeouts.ArchiveGet
+	case "stanza-create":
+		timeout = c.Timeouts.StanzaCreate
+	case "info":
+		timeout = c.Timeouts.Info
+	case "verify":
+		timeout = c.Timeouts.Verify
+	}
+	if timeout == nil {
+		return 0
+	}
+	return timeout.Duration
+}
+
+// GetStanza returns the stanza set in the configuration, falling back to the
+// given one, usually the name of the cluster or the stanza set in the plugin
+// parameters, when it is not set
+func (c *PgbackrestConfiguration) GetStanza(defaultStanza string) string {
+	if len(c.Stanza) != 0 {
+		return c.Stanza
+	}
+	return defaultStanza
 }
 
 // GetCreateStanzaPolicy returns the configured stanza creation policy, defaulting to
@@ -176,3 +176,36 @@ var _ = Describe("Pgbackrest retention", func() {
 		Expect(*policy2.History).To(Equal(history))
 	})
 })
+
+var _ = Describe("PgbackrestConfiguration.GetStanza", func() {
+	It("uses the stanza of the configuration when set", func() {
+		configuration := PgbackrestConfiguration{Stanza: "shared"}
+		Expect(configuration.GetStanza("cluster-example")).To(Equal("shared"))
+	})
+
+	It("falls back to the given stanza otherwise", func() {
+		configuration := PgbackrestConfiguration{}
+		Expect(configuration.GetStanza("cluster-example")).To(Equal("cluster-example"))
+	})
+})
+
+var _ = Describe("WebIdentity", func() {
+	It("defaults to the STS audience", func() {
+		webIdentity := &WebIdentity{RoleARN: "arn:aws:iam::123456789012:role/backup"}
+		Expect(webIdentity.GetAudience()).To(Equal(DefaultWebIdentityAudience))
+		Expect(webIdentity.GetTokenPath()).To(Equal(WebIdentityTokenDirectory + "/sts.amazonaws.com"))
+	})
+
+	It("derives a safe token file name from the audience", func() {
+		webIdentity := &WebIdentity{Audience: "https://oidc.example.com/audience"}
+		Expect(webIdentity.GetTokenFileName()).To(Equal("https___oidc.example.com_audience"))
+	})
+
+	It("forces the web-id key type", func() {
+		credentials := &S3Credentials{KeyType: KeyTypeShared}
+		Expect(credentials.GetKeyType()).To(Equal(KeyTypeShared))
+
+		credentials.WebIdentity = &WebIdentity{RoleARN: "arn:aws:iam::123456789012:role/backup"}
+		Expect(credentials.GetKeyType()).To(Equal(KeyTypeWebID))
+	})
+})
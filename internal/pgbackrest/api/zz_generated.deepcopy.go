@@ -22,8 +22,59 @@ package api
 
 import (
 	pkgapi "github.com/cloudnative-pg/machinery/pkg/api"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CommandTimeouts) DeepCopyInto(out *CommandTimeouts) {
+	*out = *in
+	if in.Backup != nil {
+		in, out := &in.Backup, &out.Backup
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Restore != nil {
+		in, out := &in.Restore, &out.Restore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ArchivePush != nil {
+		in, out := &in.ArchivePush, &out.ArchivePush
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ArchiveGet != nil {
+		in, out := &in.ArchiveGet, &out.ArchiveGet
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.StanzaCreate != nil {
+		in, out := &in.StanzaCreate, &out.StanzaCreate
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Info != nil {
+		in, out := &in.Info, &out.Info
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommandTimeouts.
+func (in *CommandTimeouts) DeepCopy() *CommandTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(CommandTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataBackupConfiguration) DeepCopyInto(out *DataBackupConfiguration) {
 	*out = *in
@@ -32,6 +83,11 @@ func (in *DataBackupConfiguration) DeepCopyInto(out *DataBackupConfiguration) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.ChecksumPage != nil {
+		in, out := &in.ChecksumPage, &out.ChecksumPage
+		*out = new(bool)
+		**out = **in
+	}
 	if in.Annotations != nil {
 		in, out := &in.Annotations, &out.Annotations
 		*out = make(map[string]string, len(*in))
@@ -126,6 +182,11 @@ func (in *PgbackrestConfiguration) DeepCopyInto(out *PgbackrestConfiguration) {
 		*out = new(LogConfiguration)
 		**out = **in
 	}
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(CommandTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgbackrestConfiguration.
@@ -222,6 +283,16 @@ func (in *S3Credentials) DeepCopyInto(out *S3Credentials) {
 		*out = new(pkgapi.SecretKeySelector)
 		**out = **in
 	}
+	if in.WebIdentity != nil {
+		in, out := &in.WebIdentity, &out.WebIdentity
+		*out = new(WebIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSECustomerKeyReference != nil {
+		in, out := &in.SSECustomerKeyReference, &out.SSECustomerKeyReference
+		*out = new(pkgapi.SecretKeySelector)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3Credentials.
@@ -237,6 +308,11 @@ func (in *S3Credentials) DeepCopy() *S3Credentials {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WalBackupConfiguration) DeepCopyInto(out *WalBackupConfiguration) {
 	*out = *in
+	if in.ArchivePushQueueMax != nil {
+		in, out := &in.ArchivePushQueueMax, &out.ArchivePushQueueMax
+		x := (*in).DeepCopy()
+		*out = &x
+	}
 	if in.ArchiveAdditionalCommandArgs != nil {
 		in, out := &in.ArchiveAdditionalCommandArgs, &out.ArchiveAdditionalCommandArgs
 		*out = make([]string, len(*in))
@@ -258,3 +334,23 @@ func (in *WalBackupConfiguration) DeepCopy() *WalBackupConfiguration {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebIdentity) DeepCopyInto(out *WebIdentity) {
+	*out = *in
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebIdentity.
+func (in *WebIdentity) DeepCopy() *WebIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(WebIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
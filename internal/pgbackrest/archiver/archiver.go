@@ -28,6 +28,8 @@ import (
 
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
 	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/walarchive"
 )
@@ -60,6 +62,12 @@ type WALArchiverResult struct {
 
 	// The time when pgbackrest archive-push ended
 	EndTime time.Time
+
+	// Whether the WAL was archived by another batch which was already pushing it
+	Shared bool
+
+	// Whether pgBackRest dropped the WAL because the archive queue was full
+	Dropped bool
 }
 
 // New creates a new WAL archiver
@@ -69,6 +77,9 @@ func New(
 	spoolDirectory string,
 	pgDataDirectory string,
 	emptyWalArchivePath string,
+	timeout time.Duration,
+	processLimiter *limiter.Limiter,
+	inFlight *inflight.Registry,
 ) (archiver *WALArchiver, err error) {
 	contextLog := log.FromContext(ctx)
 	var walArchiveSpool *spool.WALSpool
@@ -86,6 +97,9 @@ func New(
 			Env:                 env,
 			Touch:               walArchiveSpool.Touch,
 			EmptyWalArchivePath: emptyWalArchivePath,
+			Timeout:             timeout,
+			Limiter:             processLimiter,
+			InFlight:            inFlight,
 		},
 	}
 	return archiver, nil
@@ -97,8 +111,9 @@ func (archiver *WALArchiver) DeleteFromSpool(
 ) (hasBeenDeleted bool, err error) {
 	var isContained bool
 
-	// this code assumes the wal-archive command is run at most once at each instant,
-	// given that PostgreSQL will call it sequentially without overlapping
+	// PostgreSQL calls the wal-archive command sequentially, and the batches
+	// overlapping after a cancelled request share the archival of their WAL
+	// files through the in-flight registry
 	isContained, err = archiver.spool.Contains(walName)
 	if !isContained || err != nil {
 		return false, err
@@ -124,6 +139,8 @@ func (archiver *WALArchiver) ArchiveList(
 			Err:       re.Err,
 			StartTime: re.StartTime,
 			EndTime:   re.EndTime,
+			Shared:    re.Shared,
+			Dropped:   re.Dropped,
 		})
 	}
 	return result
@@ -190,16 +207,13 @@ func (archiver *WALArchiver) PgbackrestCheckWalArchiveOptions(
 		return nil, err
 	}
 
-	stanza := clusterName
-	if len(configuration.Stanza) != 0 {
-		stanza = configuration.Stanza
-	}
+	stanza := configuration.GetStanza(clusterName)
 	options = append(
 		options,
 		"--stanza",
 		stanza,
 		"--lock-path",
-		"/controller/tmp/pgbackrest",
+		pgbackrestCommand.LockPath,
 	)
 	return options, nil
 }
@@ -24,6 +24,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
@@ -138,7 +139,16 @@ func (archiver *WALArchiver) PgbackrestWalArchiveOptions(
 	// }
 
 	if configuration.Wal != nil {
+		err = pgbackrestCommand.ValidateCommandArgs(
+			pgbackrestCommand.CommandArchivePush, configuration.Wal.ArchiveAdditionalCommandArgs)
+		if err != nil {
+			return nil, err
+		}
 		options = configuration.Wal.AppendAdditionalArchivePushCommandArgs(options)
+		if configuration.Wal.ArchivePushQueueMax != nil {
+			options = append(options,
+				"--archive-push-queue-max="+strconv.FormatInt(configuration.Wal.ArchivePushQueueMax.Value(), 10))
+		}
 	}
 
 	options, err = pgbackrestCommand.AppendCloudProviderOptionsFromConfiguration(ctx, options, configuration)
@@ -151,10 +161,7 @@ func (archiver *WALArchiver) PgbackrestWalArchiveOptions(
 		return nil, err
 	}
 
-	serverName := clusterName
-	if len(configuration.Stanza) != 0 {
-		serverName = configuration.Stanza
-	}
+	serverName := configuration.GetStanza(clusterName)
 	options = append(
 		options,
 		"--stanza",
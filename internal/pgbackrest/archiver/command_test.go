@@ -22,6 +22,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -62,7 +64,7 @@ var _ = Describe("pgbackrestWalArchiveOptions", func() {
 	})
 
 	It("should generate correct arguments", func(ctx SpecContext) {
-		archiver, err := New(ctx, nil, "/tmp/pgbackrest-test-spool", "pgdata", tempEmptyWalArchivePath)
+		archiver, err := New(ctx, nil, "/tmp/pgbackrest-test-spool", "pgdata", tempEmptyWalArchivePath, 0, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 		extraOptions := []string{"--buffer-size=5MB", "--io-timeout=60"}
@@ -77,7 +79,7 @@ var _ = Describe("pgbackrestWalArchiveOptions", func() {
 	})
 
 	It("should honor configured stderr log level", func(ctx SpecContext) {
-		archiver, err := New(ctx, nil, "/tmp/pgbackrest-test-spool", "pgdata", tempEmptyWalArchivePath)
+		archiver, err := New(ctx, nil, "/tmp/pgbackrest-test-spool", "pgdata", tempEmptyWalArchivePath, 0, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
 
 		config.Log = &pgbackrestApi.LogConfiguration{
@@ -88,6 +90,17 @@ var _ = Describe("pgbackrestWalArchiveOptions", func() {
 		Expect(strings.Join(options, " ")).
 			To(ContainSubstring("--log-level-stderr debug --log-level-console off"))
 	})
+
+	It("should pass the archive queue limit", func(ctx SpecContext) {
+		archiver, err := New(ctx, nil, "/tmp/pgbackrest-test-spool", "pgdata", tempEmptyWalArchivePath, 0, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		queueMax := resource.MustParse("1Gi")
+		config.Wal.ArchivePushQueueMax = &queueMax
+		options, err := archiver.PgbackrestWalArchiveOptions(ctx, config, "test-cluster")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(ContainElement("--archive-push-queue-max=1073741824"))
+	})
 })
 
 var _ = Describe("GatherWALFilesToArchive", func() {
@@ -112,7 +125,7 @@ var _ = Describe("GatherWALFilesToArchive", func() {
 		_, err = os.Create(tempEmptyWalArchivePath)
 		Expect(err).ToNot(HaveOccurred())
 
-		archiver, err = New(ctx, nil, filepath.Join(tempPgData, "spool"), tempPgData, tempEmptyWalArchivePath)
+		archiver, err = New(ctx, nil, filepath.Join(tempPgData, "spool"), tempPgData, tempEmptyWalArchivePath, 0, nil, nil)
 		Expect(err).ToNot(HaveOccurred())
 	})
 
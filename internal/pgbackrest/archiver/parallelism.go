@@ -0,0 +1,184 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archiver
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+)
+
+const (
+	// latencySmoothing is the weight of the last batch in the average latency
+	latencySmoothing = 0.2
+
+	// congestionFactor is how much slower than the average a batch must be
+	// to be considered a sign that the repository is saturated
+	congestionFactor = 2.0
+)
+
+// Parallelism chooses the number of WAL files archived in parallel. With the
+// adaptive mode, it grows with the number of WAL files waiting to be archived,
+// and shrinks when they are few, or when archiving fails or gets slower.
+//
+// A nil Parallelism always uses the configured maxParallel.
+type Parallelism struct {
+	mu sync.Mutex
+
+	// current is the number of WAL files archived in the last batch
+	current int
+
+	// backlog is the number of WAL files which were waiting to be archived
+	backlog int
+
+	// latency is the average time taken to archive a WAL file
+	latency time.Duration
+
+	// congested is set when the last batch failed or was unusually slow
+	congested bool
+}
+
+// NewParallelism creates a parallelism controller
+func NewParallelism() *Parallelism {
+	return &Parallelism{}
+}
+
+// bounds returns the minimum and maximum parallelism of the given configuration,
+// and whether it is adaptive
+func bounds(configuration *pgbackrestApi.WalBackupConfiguration) (minimum, maximum int, adaptive bool) {
+	if configuration == nil {
+		return 1, 1, false
+	}
+
+	maximum = max(configuration.MaxParallel, 1)
+	if !configuration.AdaptiveParallel {
+		return maximum, maximum, false
+	}
+	return min(max(configuration.MinParallel, 1), maximum), maximum, true
+}
+
+// Next returns the number of WAL files to be archived by the next batch,
+// given the number of WAL files waiting to be archived
+func (p *Parallelism) Next(configuration *pgbackrestApi.WalBackupConfiguration, backlog int) int {
+	minimum, maximum, adaptive := bounds(configuration)
+	if p == nil {
+		return maximum
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.backlog = backlog
+	switch {
+	case !adaptive:
+		p.current = maximum
+
+	case p.current == 0:
+		// First batch, start from the minimum and let the backlog drive it
+		p.current = minimum
+
+	case p.congested:
+		p.current /= 2
+		p.congested = false
+
+	case backlog > 2*p.current:
+		p.current *= 2
+
+	case backlog > p.current:
+		p.current++
+
+	case 2*backlog < p.current:
+		p.current--
+	}
+
+	p.current = min(max(p.current, minimum), maximum)
+	return p.current
+}
+
+// Observe records the outcome of a batch
+func (p *Parallelism) Observe(results []WALArchiverResult) {
+	if p == nil {
+		return
+	}
+
+	var total time.Duration
+	archived := 0
+	failed := false
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed = true
+		case !result.Shared:
+			// the WAL files archived by another batch don't say much about
+			// the time it takes to archive them
+			total += result.EndTime.Sub(result.StartTime)
+			archived++
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.congested = failed
+	if archived == 0 {
+		return
+	}
+
+	latency := total / time.Duration(archived)
+	if p.latency == 0 {
+		p.latency = latency
+		return
+	}
+
+	if float64(latency) > congestionFactor*float64(p.latency) {
+		p.congested = true
+	}
+	p.latency = time.Duration((1-latencySmoothing)*float64(p.latency) + latencySmoothing*float64(latency))
+}
+
+// Current returns the number of WAL files archived by the last batch and the
+// number of WAL files which were waiting to be archived
+func (p *Parallelism) Current() (parallel int, backlog int) {
+	if p == nil {
+		return 0, 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current, p.backlog
+}
+
+// CountWALFilesToArchive returns the number of WAL files PostgreSQL marked as
+// ready to be archived
+func (archiver *WALArchiver) CountWALFilesToArchive() (int, error) {
+	entries, err := os.ReadDir(path.Join(archiver.pgDataDirectory, "pg_wal", "archive_status"))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".ready") {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright The CloudNativePG Contributors
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archiver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parallelism", func() {
+	var (
+		parallelism *Parallelism
+		adaptive    *pgbackrestApi.WalBackupConfiguration
+	)
+
+	// batch builds the results of a batch archiving each WAL in the given time
+	batch := func(size int, elapsed time.Duration, err error) []WALArchiverResult {
+		start := time.Now()
+		results := make([]WALArchiverResult, size)
+		for i := range results {
+			results[i] = WALArchiverResult{StartTime: start, EndTime: start.Add(elapsed), Err: err}
+		}
+		return results
+	}
+
+	BeforeEach(func() {
+		parallelism = NewParallelism()
+		adaptive = &pgbackrestApi.WalBackupConfiguration{
+			AdaptiveParallel: true,
+			MinParallel:      2,
+			MaxParallel:      16,
+		}
+	})
+
+	It("uses maxParallel when not adaptive", func() {
+		Expect(parallelism.Next(nil, 1000)).To(Equal(1))
+		Expect(parallelism.Next(&pgbackrestApi.WalBackupConfiguration{MaxParallel: 8}, 0)).To(Equal(8))
+
+		var static *Parallelism
+		Expect(static.Next(&pgbackrestApi.WalBackupConfiguration{MaxParallel: 4, AdaptiveParallel: true}, 0)).
+			To(Equal(4))
+	})
+
+	It("grows with the backlog up to maxParallel", func() {
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(2))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(4))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(8))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(16))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(16))
+
+		parallel, backlog := parallelism.Current()
+		Expect(parallel).To(Equal(16))
+		Expect(backlog).To(Equal(1000))
+	})
+
+	It("shrinks down to minParallel when the backlog is small", func() {
+		parallelism.Next(adaptive, 1000)
+		parallelism.Next(adaptive, 1000)
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(8))
+
+		Expect(parallelism.Next(adaptive, 1)).To(Equal(7))
+		for range 10 {
+			parallelism.Next(adaptive, 1)
+		}
+		Expect(parallelism.Next(adaptive, 1)).To(Equal(2))
+	})
+
+	It("halves the parallelism when archiving fails", func() {
+		parallelism.Next(adaptive, 1000)
+		parallelism.Next(adaptive, 1000)
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(8))
+
+		parallelism.Observe(batch(8, time.Second, errors.New("failed")))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(4))
+	})
+
+	It("halves the parallelism when archiving gets slower", func() {
+		parallelism.Next(adaptive, 1000)
+		parallelism.Next(adaptive, 1000)
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(8))
+
+		parallelism.Observe(batch(8, time.Second, nil))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(16))
+
+		parallelism.Observe(batch(16, 5*time.Second, nil))
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(8))
+	})
+
+	It("keeps within the bounds when the configuration changes", func() {
+		parallelism.Next(adaptive, 1000)
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(4))
+
+		adaptive.MaxParallel = 3
+		Expect(parallelism.Next(adaptive, 1000)).To(Equal(3))
+	})
+})
+
+var _ = Describe("CountWALFilesToArchive", func() {
+	It("counts the WAL files ready to be archived", func(ctx SpecContext) {
+		pgData := GinkgoT().TempDir()
+		archiveStatus := filepath.Join(pgData, "pg_wal", "archive_status")
+		Expect(os.MkdirAll(archiveStatus, 0o700)).To(Succeed())
+		for _, name := range []string{
+			"000000010000000000000001.done",
+			"000000010000000000000002.ready",
+			"000000010000000000000003.ready",
+		} {
+			Expect(os.WriteFile(filepath.Join(archiveStatus, name), nil, 0o600)).To(Succeed())
+		}
+
+		archiver, err := New(ctx, nil, filepath.Join(pgData, "spool"), pgData, "", 0, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(archiver.CountWALFilesToArchive()).To(Equal(2))
+	})
+})
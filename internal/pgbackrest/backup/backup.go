@@ -20,14 +20,12 @@ package backup
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"os/exec"
+	"slices"
 	"strconv"
 
 	"github.com/blang/semver"
 	cnpgApiV1 "github.com/cloudnative-pg/api/pkg/api/v1"
-	"github.com/cloudnative-pg/machinery/pkg/execlog"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
@@ -81,6 +79,18 @@ func (b *Command) GetDataConfiguration(
 			"--start-fast")
 	}
 
+	if b.configuration.Data.ChecksumPage != nil {
+		if *b.configuration.Data.ChecksumPage {
+			options = append(
+				options,
+				"--checksum-page")
+		} else {
+			options = append(
+				options,
+				"--no-checksum-page")
+		}
+	}
+
 	if b.configuration.Data.Jobs != nil {
 		options = append(
 			options,
@@ -88,6 +98,12 @@ func (b *Command) GetDataConfiguration(
 			strconv.Itoa(int(*b.configuration.Data.Jobs)))
 	}
 
+	err := pgbackrestCommand.ValidateCommandArgs(
+		pgbackrestCommand.CommandBackup, b.configuration.Data.AdditionalCommandArgs)
+	if err != nil {
+		return nil, err
+	}
+
 	return b.configuration.Data.AppendAdditionalBackupCommandArgs(options), nil
 }
 
@@ -152,6 +168,10 @@ func (b *Command) GetPgbackrestBackupOptions(
 	}
 
 	if b.backupConfig.Parameters != nil {
+		err = pgbackrestCommand.ValidateCommandParameters(pgbackrestCommand.CommandBackup, b.backupConfig.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Backup parameters: %w", err)
+		}
 		for k, v := range b.backupConfig.Parameters {
 			options = append(
 				options,
@@ -165,7 +185,7 @@ func (b *Command) GetPgbackrestBackupOptions(
 		"--stanza",
 		stanza,
 		"--lock-path",
-		"/controller/tmp/pgbackrest",
+		pgbackrestCommand.LockPath,
 		"--no-archive-check",
 	)
 
@@ -180,7 +200,7 @@ func (b *Command) getStanzaCreateOptions(
 ) ([]string, error) {
 	//nolint:prealloc
 	options := []string{
-		"stanza-create",
+		pgbackrestCommand.CommandStanzaCreate,
 	}
 
 	options, err := pgbackrestCommand.AppendCloudProviderOptionsFromConfiguration(ctx, options, b.configuration)
@@ -213,7 +233,7 @@ func (b *Command) getStanzaCreateOptions(
 		"--stanza",
 		stanza,
 		"--lock-path",
-		"/controller/tmp/pgbackrest",
+		pgbackrestCommand.LockPath,
 	)
 
 	return options, nil
@@ -263,19 +283,16 @@ func (b *Command) Take(
 	// record the backup beginning
 	log.Info("Starting pgbackrest backup", "options", options)
 
-	cmd := exec.Command("pgbackrest", options...) // #nosec G204
-	cmd.Env = env
 	// TODO: Should tmpdir be handled differently in pgbackrest?
-	cmd.Env = append(cmd.Env, "TMPDIR="+backupTemporaryDirectory)
-	if err := execlog.RunStreaming(cmd, "pgbackrest backup"); err != nil {
-		const badArgumentsErrorCode = "3"
-		if err.Error() == badArgumentsErrorCode {
-			descriptiveError := errors.New("invalid arguments for pgbackrest backup. " +
-				"Ensure that the additionalCommandArgs field is correctly populated")
-			log.Error(descriptiveError, "error while executing pgbackrest backup",
-				"arguments", options)
-			return descriptiveError
-		}
+	if err := pgbackrestCommand.Run(ctx, pgbackrestCommand.Execution{
+		Command: pgbackrestCommand.CommandBackup,
+		Options: options,
+		Env:     slices.Concat(env, []string{"TMPDIR=" + backupTemporaryDirectory}),
+		Timeout: b.configuration.GetCommandTimeout(pgbackrestCommand.CommandBackup),
+	}); err != nil {
+		log.Error(err, "error while executing pgbackrest backup",
+			"arguments", options,
+			"retriable", pgbackrestCommand.IsRetriable(err))
 		return err
 	}
 
@@ -301,16 +318,18 @@ func (b *Command) CreatePgbackrestStanza(ctx context.Context, stanza string, env
 		"options", options,
 	)
 
-	stanzaCreateCmd := exec.Command("pgbackrest", options...) // #nosec G204
-	stanzaCreateCmd.Env = env
-
-	err = execlog.RunStreaming(stanzaCreateCmd, "pgbackrest stanza-create")
+	err = pgbackrestCommand.Run(ctx, pgbackrestCommand.Execution{
+		Command: pgbackrestCommand.CommandStanzaCreate,
+		Options: options,
+		Env:     env,
+		Timeout: b.configuration.GetCommandTimeout(pgbackrestCommand.CommandStanzaCreate),
+	})
 	if err != nil {
 		contextLogger.Error(err, "Error invoking pgbackrest stanza-create",
 			"options", options,
-			"exitCode", stanzaCreateCmd.ProcessState.ExitCode(),
+			"retriable", pgbackrestCommand.IsRetriable(err),
 		)
-		return fmt.Errorf("unexpected failure invoking pgbackrest stanza-create: %w", err)
+		return err
 	}
 
 	contextLogger.Trace("pgbackrest stanza-create command execution completed")
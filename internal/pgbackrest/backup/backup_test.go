@@ -85,6 +85,27 @@ var _ = Describe("GetPgbackrestBackupOptions", func() {
 			To(ContainSubstring(" --annotation foo=bar "))
 	})
 
+	It("should control the validation of the page checksums", func(ctx SpecContext) {
+		backupConfig := cnpgApiV1.BackupPluginConfiguration{Name: metadata.PluginName}
+		checksumPage := false
+		pluginConfig.Data = &pgbackrestApi.DataBackupConfiguration{
+			ChecksumPage: &checksumPage,
+		}
+		command := NewBackupCommand(pluginConfig, &backupConfig, pgDataDir)
+
+		options, err := command.GetPgbackrestBackupOptions(ctx, backupName, stanza)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(ContainElement("--no-checksum-page"))
+
+		checksumPage = true
+		options, err = command.GetPgbackrestBackupOptions(ctx, backupName, stanza)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(ContainElement("--checksum-page"))
+		Expect(options).ToNot(ContainElement("--no-checksum-page"))
+	})
+
 	It("should include Full backup retention", func(ctx SpecContext) {
 		backupConfig := cnpgApiV1.BackupPluginConfiguration{Name: metadata.PluginName, Parameters: map[string]string{"type": "full"}}
 		retention := pgbackrestApi.PgbackrestRetention{
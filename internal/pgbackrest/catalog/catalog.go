@@ -57,7 +57,7 @@ func (catalog *Catalog) LatestBackupInfo() *PgbackrestBackup {
 
 	// Skip errored backups and return the latest valid one
 	for i := len(catalog.Backups) - 1; i >= 0; i-- {
-		if catalog.Backups[i].isBackupDone() {
+		if catalog.Backups[i].IsDone() {
 			return &catalog.Backups[i]
 		}
 	}
@@ -93,7 +93,7 @@ func (catalog *Catalog) FirstRecoverabilityPoint() *time.Time {
 
 	// Skip errored backups and return the first valid one
 	for _, pgbackrestBackup := range catalog.Backups {
-		if !pgbackrestBackup.isBackupDone() {
+		if !pgbackrestBackup.IsDone() {
 			continue
 		}
 		stop := time.Unix(pgbackrestBackup.Time.Stop, 0)
@@ -121,9 +121,12 @@ type recoveryTargetAdapter interface {
 }
 
 // FindBackupInfo finds the backup info that should be used to file
-// a PITR request via target parameters specified within `RecoveryTarget`
+// a PITR request via target parameters specified within `RecoveryTarget`.
+// Only the backups whose WAL is part of the history of the target timeline
+// are chosen, the history being read with getHistory.
 func (catalog *Catalog) FindBackupInfo(
 	recoveryTarget recoveryTargetAdapter,
+	getHistory TimelineHistoryGetter,
 ) (*PgbackrestBackup, error) {
 	// TODO: Right now specific backup is used but there is no support for full PITR.
 	// Maybe just let pgbackrest handle things? That would require taking restore type
@@ -132,7 +135,7 @@ func (catalog *Catalog) FindBackupInfo(
 	// Check that BackupID is not empty. In such case, always use the
 	// backup ID provided by the user.
 	if recoveryTarget.GetBackupID() != "" {
-		return catalog.findBackupFromID(recoveryTarget.GetBackupID())
+		return catalog.FindBackupFromID(recoveryTarget.GetBackupID())
 	}
 
 	// The user has not specified any backup ID. As a result we need
@@ -145,26 +148,33 @@ func (catalog *Catalog) FindBackupInfo(
 	// plugin behavior.
 	targetTimeline, err := strconv.ParseInt(recoveryTarget.GetTargetTLI(), 10, 64)
 	if err != nil {
-		targetTimeline = -1
+		targetTimeline = LatestTimelineID
+	}
+
+	// Backups taken on a timeline which was later forked are only usable
+	// when the target timeline descends from it after the end of the backup
+	history, err := catalog.timelineHistory(targetTimeline, getHistory)
+	if err != nil {
+		return nil, err
 	}
 
 	// The first step is to check any time based research
 	if t := recoveryTarget.GetTargetTime(); t != "" {
-		return catalog.findClosestBackupFromTargetTime(t, targetTimeline)
+		return catalog.findClosestBackupFromTargetTime(t, history)
 	}
 
 	// The second step is to check any LSN based research
 	if t := recoveryTarget.GetTargetLSN(); t != "" {
-		return catalog.findClosestBackupFromTargetLSN(t, targetTimeline)
+		return catalog.findClosestBackupFromTargetLSN(t, history)
 	}
 
 	// The fallback is to use the latest available backup in chronological order
-	return catalog.findLatestBackupFromTimeline(targetTimeline), nil
+	return catalog.findLatestBackupFromTimeline(history), nil
 }
 
 func (catalog *Catalog) findClosestBackupFromTargetLSN(
 	targetLSNString string,
-	targetTimeline int64,
+	history *TimelineHistory,
 ) (*PgbackrestBackup, error) {
 	targetLSN := types.LSN(targetLSNString)
 	if _, err := targetLSN.Parse(); err != nil {
@@ -172,14 +182,10 @@ func (catalog *Catalog) findClosestBackupFromTargetLSN(
 	}
 	for i := len(catalog.Backups) - 1; i >= 0; i-- {
 		pgbackrestBackup := catalog.Backups[i]
-		startTimeline, err := pgbackrestBackup.startTimeline()
-		if err != nil {
-			continue
-		}
-		if !pgbackrestBackup.isBackupDone() {
+		if !pgbackrestBackup.IsDone() {
 			continue
 		}
-		if (startTimeline <= targetTimeline || targetTimeline == LatestTimelineID) &&
+		if history.contains(&pgbackrestBackup) &&
 			types.LSN(pgbackrestBackup.LSN.Stop).Less(targetLSN) {
 			return &catalog.Backups[i], nil
 		}
@@ -189,27 +195,21 @@ func (catalog *Catalog) findClosestBackupFromTargetLSN(
 
 func (catalog *Catalog) findClosestBackupFromTargetTime(
 	targetTimeString string,
-	targetTimeline int64,
+	history *TimelineHistory,
 ) (*PgbackrestBackup, error) {
-	var startTimeline int64
 	targetTime, err := types.ParseTargetTime(nil, targetTimeString)
 	if err != nil {
 		return nil, fmt.Errorf("while parsing recovery target targetTime: %s", err.Error())
 	}
 	for i := len(catalog.Backups) - 1; i >= 0; i-- {
 		pgbackrestBackup := catalog.Backups[i]
-		startTimeline, err = pgbackrestBackup.startTimeline()
-		if err != nil {
+		if !pgbackrestBackup.IsDone() {
 			continue
 		}
-		if !pgbackrestBackup.isBackupDone() {
-			continue
-		}
-		// Backups are iterated from newest to oldest, so the first backup that spans
-		// the timeline is the latest one unless it has finished after the specified
-		// restore time.
-		if (startTimeline <= targetTimeline ||
-			targetTimeline == LatestTimelineID) &&
+		// Backups are iterated from newest to oldest, so the first backup that is
+		// part of the history is the latest one unless it has finished after the
+		// specified restore time.
+		if history.contains(&pgbackrestBackup) &&
 			!time.Unix(pgbackrestBackup.Time.Stop, 0).After(targetTime) {
 			return &catalog.Backups[i], nil
 		}
@@ -217,21 +217,15 @@ func (catalog *Catalog) findClosestBackupFromTargetTime(
 	return nil, nil
 }
 
-func (catalog *Catalog) findLatestBackupFromTimeline(targetTimeline int64) *PgbackrestBackup {
-	var err error
-	var startTimeline int64
+func (catalog *Catalog) findLatestBackupFromTimeline(history *TimelineHistory) *PgbackrestBackup {
 	for i := len(catalog.Backups) - 1; i >= 0; i-- {
 		pgbackrestBackup := catalog.Backups[i]
-		startTimeline, err = pgbackrestBackup.startTimeline()
-		if err != nil {
+		if !pgbackrestBackup.IsDone() {
 			continue
 		}
-		if !pgbackrestBackup.isBackupDone() {
-			continue
-		}
-		// Backups are iterated from newest to oldest, so the first backup that spans
-		// the timeline is the latest one.
-		if startTimeline <= targetTimeline || targetTimeline == LatestTimelineID {
+		// Backups are iterated from newest to oldest, so the first backup that is
+		// part of the history is the latest one.
+		if history.contains(&pgbackrestBackup) {
 			return &catalog.Backups[i]
 		}
 	}
@@ -239,12 +233,13 @@ func (catalog *Catalog) findLatestBackupFromTimeline(targetTimeline int64) *Pgba
 	return nil
 }
 
-func (catalog *Catalog) findBackupFromID(backupID string) (*PgbackrestBackup, error) {
+// FindBackupFromID finds the completed backup with the given ID
+func (catalog *Catalog) FindBackupFromID(backupID string) (*PgbackrestBackup, error) {
 	if backupID == "" {
 		return nil, fmt.Errorf("no backupID provided")
 	}
 	for _, pgbackrestBackup := range catalog.Backups {
-		if !pgbackrestBackup.isBackupDone() {
+		if !pgbackrestBackup.IsDone() {
 			continue
 		}
 		if pgbackrestBackup.ID == backupID {
@@ -279,7 +274,7 @@ type PgbackrestBackupLSN struct {
 // PgbackrestBackupDatabase contains identifying metadata of the database in the stanza
 type PgbackrestBackupDatabase struct {
 	ID       int    `json:"id"`
-	RepoKey  int    `json:"repo_key"`
+	RepoKey  int    `json:"repo-key"`
 	SystemID int64  `json:"system-id,omitempty"`
 	Version  string `json:"version,omitempty"`
 }
@@ -311,6 +306,27 @@ type PgbackrestBackupTime struct {
 	Stop int64 `json:"stop"`
 }
 
+// PgbackrestBackupRepositoryInfo represents the size of a backup in the repository
+type PgbackrestBackupRepositoryInfo struct {
+	// The size of the backup, including the files it references in prior backups
+	Size int64 `json:"size"`
+
+	// The size of the files stored by the backup itself
+	Delta int64 `json:"delta"`
+}
+
+// PgbackrestBackupInfo represents the sizes of a backup
+type PgbackrestBackupInfo struct {
+	// The size of the database when the backup was taken
+	Size int64 `json:"size"`
+
+	// The size of the database files copied by the backup
+	Delta int64 `json:"delta"`
+
+	// The size of the backup in the repository, after compression
+	Repository PgbackrestBackupRepositoryInfo `json:"repository"`
+}
+
 // PgbackrestBackup represent a backup as created by pgbackrest
 type PgbackrestBackup struct {
 	Annotations map[string]string `json:"annotation,omitempty"`
@@ -318,6 +334,10 @@ type PgbackrestBackup struct {
 	Time PgbackrestBackupTime       `json:"timestamp"`
 	WAL  PgbackrestBackupWALArchive `json:"archive"`
 	LSN  PgbackrestBackupLSN        `json:"lsn"`
+	Info PgbackrestBackupInfo       `json:"info"`
+
+	// The database and the repository of the backup
+	Database PgbackrestBackupDatabase `json:"database"`
 
 	// The ID of the backup - reusing pgbackrest's label
 	ID string `json:"label"`
@@ -326,6 +346,11 @@ type PgbackrestBackup struct {
 
 	// Backup type
 	Type string `json:"type"`
+
+	// Whether pgBackRest detected page checksum errors in the copied files
+	Error bool `json:"error,omitempty"`
+	// The files with page checksum errors, only listed for a single backup set
+	ErrorList []string `json:"error-list,omitempty"`
 }
 
 // Catalog represents a catalog of archive and backup storages of a specific stanza
@@ -366,15 +391,21 @@ func NewSingleBackupCatalogFromPgbackrestInfo(rawJSON string) (*Catalog, error)
 	return NewCatalogFromPgbackrestInfo(rawJSON)
 }
 
-func (b *PgbackrestBackup) isBackupDone() bool {
+// IsDone tells whether the backup completed
+func (b *PgbackrestBackup) IsDone() bool {
 	return b.Time.Start != 0 && b.Time.Stop != 0
 }
 
+// HasChecksumErrors tells whether pgBackRest detected page checksum errors
+// while copying the files of the backup
+func (b *PgbackrestBackup) HasChecksumErrors() bool {
+	return b.Error || len(b.ErrorList) > 0
+}
+
 func (b *PgbackrestBackup) startTimeline() (int64, error) {
 	return strconv.ParseInt(b.WAL.Start[:8], 16, 0)
 }
 
-func (b *PgbackrestBackup) stopTimeline() (int64, error) { // nolint: unused
-	// TODO: Is this method needed?
+func (b *PgbackrestBackup) stopTimeline() (int64, error) {
 	return strconv.ParseInt(b.WAL.Stop[:8], 16, 0)
 }
@@ -131,6 +131,19 @@ var _ = Describe("pgbackrest info parsing", func() {
 		Expect(result.Databases[0].SystemID).To(Equal(int64(7487970936345972767)))
 	})
 
+	It("must parse the sizes of the backups", func() {
+		result, err := NewCatalogFromPgbackrestInfo(pgbackrestInfoOutput)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Backups[1].Info.Size).To(Equal(int64(30690569)))
+		Expect(result.Backups[1].Info.Delta).To(Equal(int64(24834)))
+		Expect(result.Backups[1].Info.Repository.Size).To(Equal(int64(3661992)))
+		Expect(result.Backups[1].Info.Repository.Delta).To(Equal(int64(1324)))
+		Expect(result.Backups[1].Database.RepoKey).To(Equal(1))
+		Expect(result.RepositoryUsage()).To(Equal([]RepositoryUsage{
+			{Repository: 1, Backups: 3, Size: 3661879 + 1324 + 1440},
+		}))
+	})
+
 	It("must extract the latest backup id", func() {
 		result, err := NewCatalogFromPgbackrestInfo(pgbackrestInfoOutput)
 		Expect(err).ToNot(HaveOccurred())
@@ -167,6 +180,40 @@ var _ = Describe("pgbackrest info parsing", func() {
 		Expect(result.Status.Message).To(Equal("missing stanza path"))
 	})
 
+	It("reports the page checksum errors of a backup set", func() {
+		const checksumErrorsOutput = `[
+  {
+    "archive": [],
+    "backup": [
+      {
+        "archive": {
+          "start": "000000010000000000000006",
+          "stop": "000000010000000000000006"
+        },
+        "database": { "id": 1, "repo-key": 1 },
+        "error": true,
+        "error-list": [ "base/5/16384", "base/5/16391" ],
+        "label": "20250331-142029F",
+        "timestamp": { "start": 1743430829, "stop": 1743430841 },
+        "type": "full"
+      }
+    ],
+    "cipher": "none",
+    "db": [],
+    "name": "cluster-example-pgbackrest",
+    "status": { "code": 0, "message": "ok" }
+  }
+]`
+		result, err := NewSingleBackupCatalogFromPgbackrestInfo(checksumErrorsOutput)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Backups[0].HasChecksumErrors()).To(BeTrue())
+		Expect(result.Backups[0].ErrorList).To(Equal([]string{"base/5/16384", "base/5/16391"}))
+
+		result, err = NewCatalogFromPgbackrestInfo(pgbackrestInfoOutput)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Backups[0].HasChecksumErrors()).To(BeFalse())
+	})
+
 	// It("can find the closest backup info when there is one", func() {
 	// 	recoveryTarget := &v1.RecoveryTarget{TargetTime: time.Now().Format("2006-01-02 15:04:04")}
 	// 	closestBackupInfo, err := catalog.FindBackupInfo(recoveryTarget)
@@ -0,0 +1,175 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/types"
+)
+
+// ErrTimelineHistoryNotFound is returned when a timeline has no history
+// file in the archive
+var ErrTimelineHistoryNotFound = errors.New("timeline history not found")
+
+// TimelineHistoryGetter gets the history of a timeline from the archive,
+// returning ErrTimelineHistoryNotFound when the timeline doesn't exist
+type TimelineHistoryGetter func(timeline int64) (*TimelineHistory, error)
+
+// TimelineSwitch is a timeline the history went through, and the LSN where it
+// was switched off
+type TimelineSwitch struct {
+	Timeline    int64
+	SwitchPoint types.LSN
+}
+
+// TimelineHistory is the history of a timeline, as written by PostgreSQL
+// in its .history file
+type TimelineHistory struct {
+	// The timeline
+	Timeline int64
+
+	// The timelines it was forked from, oldest first
+	Switches []TimelineSwitch
+}
+
+// TimelineHistoryFileName gets the name of the history file of a timeline
+func TimelineHistoryFileName(timeline int64) string {
+	return fmt.Sprintf("%08X.history", timeline)
+}
+
+// ParseTimelineHistory parses the content of the history file of a timeline
+func ParseTimelineHistory(timeline int64, content string) (*TimelineHistory, error) {
+	history := &TimelineHistory{Timeline: timeline}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Each line is made of the parent timeline, the switch point and the
+		// reason of the switch
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid line in the history of timeline %d: %q", timeline, line)
+		}
+		parent, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeline in the history of timeline %d: %w", timeline, err)
+		}
+		switchPoint := types.LSN(fields[1])
+		if _, err := switchPoint.Parse(); err != nil {
+			return nil, fmt.Errorf("invalid switch point in the history of timeline %d: %w", timeline, err)
+		}
+
+		history.Switches = append(history.Switches, TimelineSwitch{Timeline: parent, SwitchPoint: switchPoint})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// TimelineAt returns the timeline the history was on at the given LSN
+func (history *TimelineHistory) TimelineAt(lsn types.LSN) int64 {
+	for _, timelineSwitch := range history.Switches {
+		if lsn.Less(timelineSwitch.SwitchPoint) {
+			return timelineSwitch.Timeline
+		}
+	}
+	return history.Timeline
+}
+
+// contains checks whether the WAL of the backup, from its start to its stop,
+// is part of the history
+func (history *TimelineHistory) contains(backup *PgbackrestBackup) bool {
+	startTimeline, err := backup.startTimeline()
+	if err != nil {
+		return false
+	}
+	stopTimeline, err := backup.stopTimeline()
+	if err != nil {
+		return false
+	}
+
+	return history.TimelineAt(types.LSN(backup.LSN.Start)) == startTimeline &&
+		history.TimelineAt(types.LSN(backup.LSN.Stop)) == stopTimeline
+}
+
+// latestTimeline finds the latest timeline of the archive, like PostgreSQL
+// does when recovering to the latest timeline: starting from the latest one
+// of the archived WAL files and backups, it looks for the history files of
+// the following ones
+func (catalog *Catalog) latestTimeline(getHistory TimelineHistoryGetter) (int64, error) {
+	latest := int64(1)
+	for _, archive := range catalog.Archive {
+		if len(archive.Max) < 8 {
+			continue
+		}
+		if timeline, err := strconv.ParseInt(archive.Max[:8], 16, 64); err == nil {
+			latest = max(latest, timeline)
+		}
+	}
+	for i := range catalog.Backups {
+		if timeline, err := catalog.Backups[i].stopTimeline(); err == nil {
+			latest = max(latest, timeline)
+		}
+	}
+
+	for {
+		_, err := getHistory(latest + 1)
+		if errors.Is(err, ErrTimelineHistoryNotFound) {
+			return latest, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		latest++
+	}
+}
+
+// timelineHistory gets the history of the target timeline, resolving the
+// latest one
+func (catalog *Catalog) timelineHistory(
+	targetTimeline int64,
+	getHistory TimelineHistoryGetter,
+) (*TimelineHistory, error) {
+	if targetTimeline == LatestTimelineID {
+		var err error
+		if targetTimeline, err = catalog.latestTimeline(getHistory); err != nil {
+			return nil, fmt.Errorf("while looking for the latest timeline: %w", err)
+		}
+	}
+
+	// The first timeline has no history
+	if targetTimeline <= 1 {
+		return &TimelineHistory{Timeline: 1}, nil
+	}
+
+	history, err := getHistory(targetTimeline)
+	if err != nil {
+		return nil, fmt.Errorf("while getting the history of timeline %d: %w", targetTimeline, err)
+	}
+	return history, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog
+
+import (
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/types"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseTimelineHistory", func() {
+	It("parses the timelines and their switch points", func() {
+		history, err := ParseTimelineHistory(3, "1\t0/5000000\tno recovery target specified\n\n"+
+			"2\t0/9000000\tat restore point \"before_upgrade\"\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(history.Switches).To(Equal([]TimelineSwitch{
+			{Timeline: 1, SwitchPoint: "0/5000000"},
+			{Timeline: 2, SwitchPoint: "0/9000000"},
+		}))
+		Expect(history.TimelineAt("0/4FFFFFF")).To(Equal(int64(1)))
+		Expect(history.TimelineAt("0/5000000")).To(Equal(int64(2)))
+		Expect(history.TimelineAt("0/A000000")).To(Equal(int64(3)))
+	})
+
+	It("rejects invalid lines", func() {
+		_, err := ParseTimelineHistory(2, "1\tnot-an-lsn\treason\n")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("names the history files", func() {
+		Expect(TimelineHistoryFileName(10)).To(Equal("0000000A.history"))
+	})
+})
+
+var _ = Describe("FindBackupInfo with forked timelines", func() {
+	// Timeline 2 forked from timeline 1 at 0/5000000, which then kept running
+	// and was backed up, and timeline 3 forked from timeline 2 at 0/9000000
+	histories := map[int64]string{
+		2: "1\t0/5000000\tno recovery target specified\n",
+		3: "1\t0/5000000\tno recovery target specified\n2\t0/9000000\tno recovery target specified\n",
+	}
+	getHistory := func(timeline int64) (*TimelineHistory, error) {
+		content, ok := histories[timeline]
+		if !ok {
+			return nil, ErrTimelineHistoryNotFound
+		}
+		return ParseTimelineHistory(timeline, content)
+	}
+
+	newBackup := func(id, wal string, lsn types.LSN, stop time.Time) PgbackrestBackup {
+		return PgbackrestBackup{
+			ID:   id,
+			WAL:  PgbackrestBackupWALArchive{Start: wal, Stop: wal},
+			LSN:  PgbackrestBackupLSN{Start: string(lsn), Stop: string(lsn)},
+			Time: PgbackrestBackupTime{Start: stop.Unix() - 10, Stop: stop.Unix()},
+		}
+	}
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	catalog := &Catalog{
+		Backups: []PgbackrestBackup{
+			newBackup("tl1-before-fork", "000000010000000000000002", "0/2000100", day.Add(1*time.Hour)),
+			newBackup("tl1-after-fork", "000000010000000000000006", "0/6000100", day.Add(2*time.Hour)),
+			newBackup("tl2-before-fork", "000000020000000000000007", "0/7000100", day.Add(3*time.Hour)),
+			newBackup("tl2-after-fork", "00000002000000000000000A", "0/A000100", day.Add(4*time.Hour)),
+		},
+	}
+
+	find := func(target *cnpgv1.RecoveryTarget) string {
+		backup, err := catalog.FindBackupInfo(target, getHistory)
+		Expect(err).ToNot(HaveOccurred())
+		if backup == nil {
+			return ""
+		}
+		return backup.ID
+	}
+
+	It("only chooses the backups which are part of the target timeline", func() {
+		Expect(find(&cnpgv1.RecoveryTarget{TargetTLI: "1"})).To(Equal("tl1-after-fork"))
+		Expect(find(&cnpgv1.RecoveryTarget{TargetTLI: "2"})).To(Equal("tl2-after-fork"))
+		Expect(find(&cnpgv1.RecoveryTarget{TargetTLI: "3"})).To(Equal("tl2-before-fork"))
+	})
+
+	It("skips the backups of a sibling timeline when looking for a time", func() {
+		Expect(find(&cnpgv1.RecoveryTarget{
+			TargetTLI:  "2",
+			TargetTime: day.Add(150 * time.Minute).Format("2006-01-02 15:04:05Z07:00"),
+		})).To(Equal("tl1-before-fork"))
+	})
+
+	It("skips the backups of a sibling timeline when looking for an LSN", func() {
+		Expect(find(&cnpgv1.RecoveryTarget{TargetTLI: "3", TargetLSN: "0/B000000"})).To(Equal("tl2-before-fork"))
+	})
+
+	It("resolves the latest timeline from the history files", func() {
+		Expect(find(&cnpgv1.RecoveryTarget{TargetTLI: "latest"})).To(Equal("tl2-before-fork"))
+		Expect(find(&cnpgv1.RecoveryTarget{})).To(Equal("tl2-before-fork"))
+	})
+
+	It("fails when the target timeline has no history", func() {
+		_, err := catalog.FindBackupInfo(&cnpgv1.RecoveryTarget{TargetTLI: "4"}, getHistory)
+		Expect(err).To(MatchError(ErrTimelineHistoryNotFound))
+	})
+})
@@ -0,0 +1,85 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog
+
+import (
+	"slices"
+	"time"
+)
+
+// RepositoryUsage is the space used by the backups of a stanza in a repository
+type RepositoryUsage struct {
+	// The key of the repository, starting from 1
+	Repository int
+
+	// The number of completed backups
+	Backups int
+
+	// The size of the files stored by the backups
+	Size int64
+}
+
+// RepositoryUsage sums up the space used by the completed backups in each
+// repository. As incremental and differential backups reference the files of
+// prior backups, the size stored by each backup is summed. The usage is
+// sorted by repository.
+func (catalog *Catalog) RepositoryUsage() []RepositoryUsage {
+	var result []RepositoryUsage
+	for i := range catalog.Backups {
+		backup := &catalog.Backups[i]
+		if !backup.IsDone() {
+			continue
+		}
+
+		index := slices.IndexFunc(result, func(usage RepositoryUsage) bool {
+			return usage.Repository == backup.Database.RepoKey
+		})
+		if index < 0 {
+			result = append(result, RepositoryUsage{Repository: backup.Database.RepoKey})
+			index = len(result) - 1
+		}
+		result[index].Backups++
+		result[index].Size += backup.Info.Repository.Delta
+	}
+
+	slices.SortFunc(result, func(a, b RepositoryUsage) int {
+		return a.Repository - b.Repository
+	})
+	return result
+}
+
+// DatabaseGrowthPerDay is the trend of the database size in bytes per day,
+// from the first to the latest completed backup. It is zero when there are
+// less than two backups.
+func (catalog *Catalog) DatabaseGrowthPerDay() int64 {
+	var first, last *PgbackrestBackup
+	for i := range catalog.Backups {
+		if !catalog.Backups[i].IsDone() {
+			continue
+		}
+		if first == nil {
+			first = &catalog.Backups[i]
+		}
+		last = &catalog.Backups[i]
+	}
+	if first == nil || last.Time.Stop <= first.Time.Stop {
+		return 0
+	}
+
+	elapsed := time.Duration(last.Time.Stop-first.Time.Stop) * time.Second
+	return int64(float64(last.Info.Size-first.Info.Size) / elapsed.Hours() * 24)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package catalog
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("storage usage", func() {
+	newBackup := func(repository int, stop int64, databaseSize int64, repositoryDelta int64) PgbackrestBackup {
+		return PgbackrestBackup{
+			Time:     PgbackrestBackupTime{Start: stop - 10, Stop: stop},
+			Database: PgbackrestBackupDatabase{ID: 1, RepoKey: repository},
+			Info: PgbackrestBackupInfo{
+				Size:       databaseSize,
+				Repository: PgbackrestBackupRepositoryInfo{Delta: repositoryDelta},
+			},
+		}
+	}
+
+	It("sums up the size stored by the backups of each repository", func() {
+		backupCatalog := &Catalog{
+			Backups: []PgbackrestBackup{
+				newBackup(2, 1000, 100, 50),
+				newBackup(1, 1000, 100, 40),
+				newBackup(1, 2000, 100, 5),
+				{Database: PgbackrestBackupDatabase{RepoKey: 1}},
+			},
+		}
+		Expect(backupCatalog.RepositoryUsage()).To(Equal([]RepositoryUsage{
+			{Repository: 1, Backups: 2, Size: 45},
+			{Repository: 2, Backups: 1, Size: 50},
+		}))
+	})
+
+	It("computes the growth of the database per day", func() {
+		backupCatalog := &Catalog{
+			Backups: []PgbackrestBackup{
+				newBackup(1, 86400, 1000, 0),
+				newBackup(1, 86400*2, 1500, 0),
+				newBackup(1, 86400*3, 3000, 0),
+			},
+		}
+		Expect(backupCatalog.DatabaseGrowthPerDay()).To(BeEquivalentTo(1000))
+	})
+
+	It("has no growth with a single backup", func() {
+		backupCatalog := &Catalog{Backups: []PgbackrestBackup{newBackup(1, 86400, 1000, 0)}}
+		Expect(backupCatalog.DatabaseGrowthPerDay()).To(BeZero())
+	})
+})
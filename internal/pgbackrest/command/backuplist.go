@@ -43,7 +43,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
 
@@ -78,11 +77,14 @@ func executeQueryCommand(
 
 	var stdoutBuffer bytes.Buffer
 	var stderrBuffer bytes.Buffer
-	cmd := exec.Command("pgbackrest", options...) // #nosec G204
-	cmd.Env = env
-	cmd.Stdout = &stdoutBuffer
-	cmd.Stderr = &stderrBuffer
-	err = cmd.Run()
+	err = Run(ctx, Execution{
+		Command: CommandInfo,
+		Options: options,
+		Env:     env,
+		Timeout: pgbackrestConfiguration.GetCommandTimeout(CommandInfo),
+		Stdout:  &stdoutBuffer,
+		Stderr:  &stderrBuffer,
+	})
 	if err != nil {
 		contextLogger.Error(err,
 			"Can't extract backup list",
@@ -26,6 +26,9 @@ import (
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
 )
 
+// LockPath is the directory where pgBackRest keeps its lock files
+const LockPath = "/controller/tmp/pgbackrest"
+
 // CloudWalRestoreOptions returns the options needed to execute the pgbackrest command successfully
 func CloudWalRestoreOptions(
 	ctx context.Context,
@@ -48,15 +51,18 @@ func CloudWalRestoreOptions(
 		return nil, err
 	}
 
-	stanza := clusterName
-	if len(configuration.Stanza) != 0 {
-		stanza = configuration.Stanza
-	}
+	stanza := configuration.GetStanza(clusterName)
 	options = append(
 		options,
 		"--stanza",
 		stanza)
 
+	if configuration.Wal != nil {
+		err = ValidateCommandArgs(CommandArchiveGet, configuration.Wal.RestoreAdditionalCommandArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
 	options = configuration.Wal.AppendAdditionalArchiveGetCommandArgs(options)
 
 	return options, nil
@@ -188,6 +194,12 @@ func appendCloudProviderOptions(
 				utils.FormatRepoFlag(repoIndex, "s3-uri-style"),
 				repository.AWS.URIStyle)
 		}
+		if len(repository.AWS.KMSKeyID) > 0 {
+			options = append(
+				options,
+				utils.FormatRepoFlag(repoIndex, "s3-kms-key-id"),
+				repository.AWS.KMSKeyID)
+		}
 	}
 	return options, nil
 }
@@ -173,3 +173,51 @@ var _ = Describe("PgbackrestRetention", func() {
 				))
 	})
 })
+
+var _ = Describe("appendCloudProviderOptions", func() {
+	It("should pass the KMS key used for server-side encryption", func(ctx SpecContext) {
+		options, err := appendCloudProviderOptions(ctx, nil, 1, pgbackrestApi.PgbackrestRepository{
+			Bucket:          "bucket-name",
+			DestinationPath: "/",
+			PgbackrestCredentials: pgbackrestApi.PgbackrestCredentials{
+				AWS: &pgbackrestApi.S3Credentials{
+					KMSKeyID: "arn:aws:kms:eu-west-1:123456789012:key/backup",
+				},
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Join(options, " ")).To(
+			ContainSubstring("--repo2-s3-kms-key-id arn:aws:kms:eu-west-1:123456789012:key/backup"))
+	})
+
+	It("should not pass a KMS key when none is configured", func(ctx SpecContext) {
+		options, err := appendCloudProviderOptions(ctx, nil, 0, pgbackrestApi.PgbackrestRepository{
+			Bucket:                "bucket-name",
+			DestinationPath:       "/",
+			PgbackrestCredentials: pgbackrestApi.PgbackrestCredentials{AWS: &pgbackrestApi.S3Credentials{}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Join(options, " ")).ToNot(ContainSubstring("kms-key-id"))
+	})
+})
+
+var _ = Describe("ConfigurationOptions", func() {
+	It("should split the global and stanza options and leave console logging alone", func(ctx SpecContext) {
+		configuration := &pgbackrestApi.PgbackrestConfiguration{
+			Repositories: []pgbackrestApi.PgbackrestRepository{
+				{
+					Bucket:          "bucket-name",
+					DestinationPath: "/",
+				},
+			},
+		}
+
+		globalOptions, stanzaOptions, err := ConfigurationOptions(ctx, configuration, "/var/lib/postgres/pgdata")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Join(globalOptions, " ")).To(Equal(
+			"--lock-path /controller/tmp/pgbackrest --log-level-stderr warn " +
+				"--repo1-type s3 --repo1-s3-bucket bucket-name --repo1-path /"))
+		Expect(strings.Join(stanzaOptions, " ")).To(Equal(
+			"--pg1-path /var/lib/postgres/pgdata --pg1-user postgres --pg1-socket-path /controller/run/"))
+	})
+})
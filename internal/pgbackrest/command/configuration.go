@@ -0,0 +1,64 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"context"
+	"slices"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+)
+
+// ConfigurationOptions returns the options shared by the commands the plugin runs,
+// split between the ones belonging to the global section of a pgBackRest
+// configuration file and the ones belonging to the stanza section. They are
+// built the same way as the command line of those commands.
+//
+// Console logging is left to the pgBackRest default, as the configuration is
+// meant to be used interactively too.
+func ConfigurationOptions(
+	ctx context.Context,
+	configuration *pgbackrestApi.PgbackrestConfiguration,
+	pgDataDirectory string,
+) (globalOptions []string, stanzaOptions []string, err error) {
+	globalOptions = []string{"--lock-path", LockPath}
+
+	globalOptions, err = AppendLogOptionsFromConfiguration(ctx, globalOptions, configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	if index := slices.Index(globalOptions, "--log-level-console"); index >= 0 {
+		globalOptions = slices.Delete(globalOptions, index, index+2)
+	}
+
+	globalOptions, err = AppendCloudProviderOptionsFromConfiguration(ctx, globalOptions, configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	globalOptions, err = AppendRetentionOptionsFromConfiguration(ctx, globalOptions, configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stanzaOptions, err = AppendStanzaOptionsFromConfiguration(ctx, nil, configuration, pgDataDirectory, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return globalOptions, stanzaOptions, nil
+}
@@ -1,5 +1,6 @@
 /*
 Copyright The CloudNativePG Contributors
+Copyright 2025, Opera Norway AS
 
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
@@ -17,74 +18,282 @@ limitations under the License.
 package command
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
 )
 
+// ErrorCategory groups the pgBackRest errors by their cause
+type ErrorCategory string
+
 const (
-	// TODO: Map pgbackrest error codes.
-	// Probably all codes are documented here:
-	// https://github.com/pgbackrest/pgbackrest/blob/c2f64bb03bdbb2ce883041118dbcfd79da3f1307/src/build/error/error.yaml
+	// ErrorCategoryLock is used when pgBackRest couldn't acquire the lock of the stanza
+	ErrorCategoryLock ErrorCategory = "lock"
+
+	// ErrorCategoryTimeout is used when an operation didn't complete in time
+	ErrorCategoryTimeout ErrorCategory = "timeout"
+
+	// ErrorCategoryNetwork is used when the repository, or PostgreSQL, couldn't be reached
+	ErrorCategoryNetwork ErrorCategory = "network"
+
+	// ErrorCategoryTerminated is used when pgBackRest was stopped or terminated by a signal
+	ErrorCategoryTerminated ErrorCategory = "terminated"
+
+	// ErrorCategoryResource is used when the system ran out of resources
+	ErrorCategoryResource ErrorCategory = "resource"
 
-	// Connectivity to csp was ok but operation still failed error code
-	// https://docs.pgbarman.org/release/3.10.0/barman-cloud-restore.1.html
-	operationErrorCode = 1
+	// ErrorCategoryNotFound is used when a file, a backup set or a database is missing
+	ErrorCategoryNotFound ErrorCategory = "not-found"
 
-	// Network related error
-	// https://docs.pgbarman.org/release/3.10.0/barman-cloud-restore.1.html
-	networkErrorCode = 2
+	// ErrorCategoryCrypto is used when the repository couldn't be encrypted or decrypted
+	ErrorCategoryCrypto ErrorCategory = "crypto"
 
-	// CLI related error
-	// https://docs.pgbarman.org/release/3.10.0/barman-cloud-restore.1.html
-	cliErrorCode = 3
+	// ErrorCategoryRepository is used when the content of the repository is invalid or
+	// doesn't match the cluster
+	ErrorCategoryRepository ErrorCategory = "repository"
 
-	// General barman cloud errors
-	// https://docs.pgbarman.org/release/3.10.0/barman-cloud-restore.1.html
-	generalErrorCode = 4
+	// ErrorCategoryConfiguration is used when the options of the command are invalid
+	ErrorCategoryConfiguration ErrorCategory = "configuration"
+
+	// ErrorCategoryDatabase is used when PostgreSQL isn't in the state the command expects
+	ErrorCategoryDatabase ErrorCategory = "database"
+
+	// ErrorCategoryFileSystem is used when a local file or path couldn't be accessed
+	ErrorCategoryFileSystem ErrorCategory = "filesystem"
+
+	// ErrorCategoryUnknown is used for the internal errors of pgBackRest and for the
+	// exit codes it doesn't document
+	ErrorCategoryUnknown ErrorCategory = "unknown"
 )
 
-// errorDescriptions are the human descriptions of the error codes
-var errorDescriptions = map[int]string{
-	operationErrorCode: "Operation error",
-	networkErrorCode:   "Network error",
-	cliErrorCode:       "CLI argument parsing error",
-	generalErrorCode:   "General error",
+// retriableCategories are the categories of the errors which are expected to go away
+// when the command is run again later
+var retriableCategories = []ErrorCategory{
+	ErrorCategoryLock,
+	ErrorCategoryTimeout,
+	ErrorCategoryNetwork,
+	ErrorCategoryTerminated,
+	ErrorCategoryResource,
+}
+
+// categoryReasons are the descriptions of the error categories, telling the user
+// what to look at
+var categoryReasons = map[ErrorCategory]string{
+	ErrorCategoryLock:          "another pgBackRest command is running on the stanza",
+	ErrorCategoryTimeout:       "the operation timed out",
+	ErrorCategoryNetwork:       "the repository or PostgreSQL could not be reached, check the endpoints and the network",
+	ErrorCategoryTerminated:    "pgBackRest was terminated",
+	ErrorCategoryResource:      "the system ran out of resources",
+	ErrorCategoryNotFound:      "a required file, backup set or database is missing",
+	ErrorCategoryCrypto:        "the repository could not be decrypted, check the encryption key",
+	ErrorCategoryRepository:    "the repository content is invalid or belongs to another cluster",
+	ErrorCategoryConfiguration: "the pgBackRest options are invalid, check the Archive configuration",
+	ErrorCategoryDatabase:      "PostgreSQL is not in the expected state",
+	ErrorCategoryFileSystem:    "a local file or directory could not be accessed",
+	ErrorCategoryUnknown:       "unexpected pgBackRest failure",
 }
 
-// CloudRestoreError is raised when pgbackrest restore fails
-type CloudRestoreError struct {
-	// The exit code returned by pgbackrest
+// errorCode describes an exit code of pgBackRest
+type errorCode struct {
+	// name is the name of the error in the pgBackRest sources
+	name string
+
+	// category is the category of the error
+	category ErrorCategory
+
+	// reason, when set, replaces the description of the category
+	reason string
+}
+
+// errorCodes maps the exit codes of pgBackRest to their description.
+// See https://github.com/pgbackrest/pgbackrest/blob/main/src/build/error/error.yaml
+var errorCodes = map[int]errorCode{
+	25:  {name: "assert", category: ErrorCategoryUnknown},
+	26:  {name: "checksum", category: ErrorCategoryRepository},
+	27:  {name: "config", category: ErrorCategoryConfiguration},
+	28:  {name: "file-invalid", category: ErrorCategoryRepository},
+	29:  {name: "format", category: ErrorCategoryRepository},
+	30:  {name: "command-required", category: ErrorCategoryConfiguration},
+	31:  {name: "option-invalid", category: ErrorCategoryConfiguration},
+	32:  {name: "option-invalid-value", category: ErrorCategoryConfiguration},
+	33:  {name: "option-invalid-range", category: ErrorCategoryConfiguration},
+	34:  {name: "option-invalid-pair", category: ErrorCategoryConfiguration},
+	35:  {name: "option-duplicate", category: ErrorCategoryConfiguration},
+	36:  {name: "option-negate", category: ErrorCategoryConfiguration},
+	37:  {name: "option-required", category: ErrorCategoryConfiguration},
+	38:  {name: "pg-running", category: ErrorCategoryDatabase, reason: "PostgreSQL is running"},
+	39:  {name: "protocol", category: ErrorCategoryNetwork},
+	40:  {name: "path-not-empty", category: ErrorCategoryFileSystem},
+	41:  {name: "file-open", category: ErrorCategoryFileSystem},
+	42:  {name: "file-read", category: ErrorCategoryFileSystem},
+	43:  {name: "param-required", category: ErrorCategoryConfiguration},
+	44:  {name: "archive-mismatch", category: ErrorCategoryRepository},
+	45:  {name: "archive-duplicate", category: ErrorCategoryRepository},
+	46:  {name: "version-not-supported", category: ErrorCategoryConfiguration},
+	47:  {name: "path-create", category: ErrorCategoryFileSystem},
+	48:  {name: "command-invalid", category: ErrorCategoryConfiguration},
+	49:  {name: "host-connect", category: ErrorCategoryNetwork},
+	50:  {name: "lock-acquire", category: ErrorCategoryLock},
+	51:  {name: "backup-mismatch", category: ErrorCategoryRepository},
+	52:  {name: "file-sync", category: ErrorCategoryFileSystem},
+	53:  {name: "path-open", category: ErrorCategoryFileSystem},
+	54:  {name: "path-sync", category: ErrorCategoryFileSystem},
+	55:  {name: "file-missing", category: ErrorCategoryNotFound},
+	56:  {name: "db-connect", category: ErrorCategoryNetwork},
+	57:  {name: "db-query", category: ErrorCategoryDatabase},
+	58:  {name: "db-mismatch", category: ErrorCategoryRepository},
+	59:  {name: "db-timeout", category: ErrorCategoryTimeout},
+	60:  {name: "file-remove", category: ErrorCategoryFileSystem},
+	61:  {name: "path-remove", category: ErrorCategoryFileSystem},
+	62:  {name: "stop", category: ErrorCategoryConfiguration, reason: "pgBackRest was stopped with the stop command"},
+	63:  {name: "term", category: ErrorCategoryTerminated},
+	64:  {name: "file-write", category: ErrorCategoryFileSystem},
+	66:  {name: "protocol-timeout", category: ErrorCategoryTimeout},
+	67:  {name: "feature-not-supported", category: ErrorCategoryConfiguration},
+	68:  {name: "archive-command-invalid", category: ErrorCategoryConfiguration},
+	69:  {name: "link-expected", category: ErrorCategoryFileSystem},
+	70:  {name: "link-destination", category: ErrorCategoryFileSystem},
+	71:  {name: "tablespace-in-pgdata", category: ErrorCategoryConfiguration},
+	72:  {name: "host-invalid", category: ErrorCategoryConfiguration},
+	73:  {name: "path-missing", category: ErrorCategoryNotFound},
+	74:  {name: "file-move", category: ErrorCategoryFileSystem},
+	75:  {name: "backup-set-invalid", category: ErrorCategoryNotFound, reason: "no valid backup set was found"},
+	76:  {name: "tablespace-map", category: ErrorCategoryConfiguration},
+	77:  {name: "path-type", category: ErrorCategoryFileSystem},
+	78:  {name: "link-map", category: ErrorCategoryConfiguration},
+	79:  {name: "file-close", category: ErrorCategoryFileSystem},
+	80:  {name: "db-missing", category: ErrorCategoryNotFound},
+	81:  {name: "db-invalid", category: ErrorCategoryDatabase},
+	82:  {name: "archive-timeout", category: ErrorCategoryTimeout, reason: "the WAL was not archived in time"},
+	83:  {name: "file-mode", category: ErrorCategoryFileSystem},
+	84:  {name: "option-multiple-value", category: ErrorCategoryConfiguration},
+	85:  {name: "protocol-output-required", category: ErrorCategoryUnknown},
+	86:  {name: "link-open", category: ErrorCategoryFileSystem},
+	87:  {name: "archive-disabled", category: ErrorCategoryDatabase, reason: "WAL archiving is disabled"},
+	88:  {name: "file-owner", category: ErrorCategoryFileSystem},
+	89:  {name: "user-missing", category: ErrorCategoryConfiguration},
+	90:  {name: "option-command", category: ErrorCategoryConfiguration},
+	91:  {name: "group-missing", category: ErrorCategoryConfiguration},
+	92:  {name: "path-exists", category: ErrorCategoryFileSystem},
+	93:  {name: "file-exists", category: ErrorCategoryFileSystem},
+	94:  {name: "memory", category: ErrorCategoryResource},
+	95:  {name: "crypto", category: ErrorCategoryCrypto},
+	96:  {name: "param-invalid", category: ErrorCategoryConfiguration},
+	97:  {name: "path-close", category: ErrorCategoryFileSystem},
+	98:  {name: "file-info", category: ErrorCategoryFileSystem},
+	99:  {name: "json-format", category: ErrorCategoryRepository},
+	100: {name: "kernel", category: ErrorCategoryResource},
+	101: {name: "service", category: ErrorCategoryNetwork},
+	102: {name: "execute", category: ErrorCategoryResource},
+	103: {name: "repo-invalid", category: ErrorCategoryRepository},
+	104: {name: "command", category: ErrorCategoryUnknown},
+	105: {name: "access", category: ErrorCategoryFileSystem, reason: "access to a file or to the repository was denied"},
+	122: {name: "runtime", category: ErrorCategoryUnknown},
+	123: {name: "invalid", category: ErrorCategoryUnknown},
+	124: {name: "unhandled", category: ErrorCategoryUnknown},
+	125: {name: "unknown", category: ErrorCategoryUnknown},
+}
+
+// PgbackrestError is returned when a pgBackRest command exits with an error
+type PgbackrestError struct {
+	// Command is the pgBackRest command which failed, e.g. "backup"
+	Command string
+
+	// ExitCode is the exit code of pgBackRest, -1 when it was killed by a signal
 	ExitCode int
 
-	// This is true when pgbackrest can return significant error codes
-	HasRestoreErrorCodes bool
+	// Name is the name pgBackRest gives to the error, e.g. "lock-acquire",
+	// empty for the exit codes pgBackRest doesn't document
+	Name string
+
+	// Category is the category of the error
+	Category ErrorCategory
+
+	// Stderr contains the last lines pgBackRest wrote to stderr
+	Stderr []string
+
+	// Err is the error returned when running the command
+	Err error
 }
 
-// Error implements the error interface
-func (err *CloudRestoreError) Error() string {
-	msg, ok := errorDescriptions[err.ExitCode]
-	if !ok {
-		msg = "Generic failure"
+// NewPgbackrestError builds the error describing a failed pgBackRest command,
+// given the error returned when running it and the last lines written to stderr.
+// The errors not caused by the exit status of pgBackRest, e.g. when the binary
+// can't be found, are returned as they are.
+func NewPgbackrestError(command string, err error, stderr []string) error {
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		return err
+	}
+
+	result := &PgbackrestError{
+		Command:  command,
+		ExitCode: exitError.ExitCode(),
+		Category: ErrorCategoryUnknown,
+		Stderr:   stderr,
+		Err:      err,
+	}
+	if code, ok := errorCodes[result.ExitCode]; ok {
+		result.Name = code.name
+		result.Category = code.category
+	} else if result.ExitCode < 0 {
+		result.Category = ErrorCategoryTerminated
 	}
 
-	return fmt.Sprintf("%s (exit code %v)", msg, err.ExitCode)
+	return result
 }
 
-// IsRetriable returns true whether the error is temporary, and
-// it could be a good idea to retry the restore later
-func (err *CloudRestoreError) IsRetriable() bool {
-	return (err.ExitCode == networkErrorCode || err.ExitCode == generalErrorCode) && err.HasRestoreErrorCodes
+// Reason describes the cause of the error, telling the user what to look at
+func (err *PgbackrestError) Reason() string {
+	if code, ok := errorCodes[err.ExitCode]; ok && code.reason != "" {
+		return code.reason
+	}
+	return categoryReasons[err.Category]
 }
 
-// UnmarshalPgbackrestRestoreExitCode returns the correct error
-// for a certain pgbackrest exit code
-func UnmarshalPgbackrestRestoreExitCode(_ context.Context, exitCode int) error {
-	if exitCode == 0 {
-		return nil
+// Message returns the error message pgBackRest wrote to stderr, if any
+func (err *PgbackrestError) Message() string {
+	for _, line := range slices.Backward(err.Stderr) {
+		if _, message, found := strings.Cut(line, "ERROR: "); found {
+			return message
+		}
+	}
+	if len(err.Stderr) > 0 {
+		return err.Stderr[len(err.Stderr)-1]
 	}
+	return ""
+}
 
-	return &CloudRestoreError{
-		ExitCode:             exitCode,
-		HasRestoreErrorCodes: true,
+// Error implements the error interface
+func (err *PgbackrestError) Error() string {
+	description := fmt.Sprintf("exit code %d", err.ExitCode)
+	if err.Name != "" {
+		description = fmt.Sprintf("%s error, exit code %d", err.Name, err.ExitCode)
 	}
+
+	msg := fmt.Sprintf("pgbackrest %s failed (%s): %s", err.Command, description, err.Reason())
+	if message := err.Message(); message != "" {
+		msg += ": " + message
+	}
+	return msg
+}
+
+// Unwrap returns the error returned when running the command
+func (err *PgbackrestError) Unwrap() error {
+	return err.Err
+}
+
+// IsRetriable returns true whether the error is temporary, and
+// it could be a good idea to retry the command later
+func (err *PgbackrestError) IsRetriable() bool {
+	return slices.Contains(retriableCategories, err.Category)
+}
+
+// IsRetriable returns true when the error was returned by a pgBackRest command
+// which could succeed when retried later
+func IsRetriable(err error) bool {
+	var pgbackrestError *PgbackrestError
+	return errors.As(err, &pgbackrestError) && pgbackrestError.IsRetriable()
 }
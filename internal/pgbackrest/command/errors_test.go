@@ -0,0 +1,77 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PgbackrestError", func() {
+	exitError := func(code int) error {
+		return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	}
+
+	It("maps the documented exit codes to their category", func() {
+		err := NewPgbackrestError(CommandBackup, exitError(50), nil)
+
+		var pgbackrestError *PgbackrestError
+		Expect(errors.As(err, &pgbackrestError)).To(BeTrue())
+		Expect(pgbackrestError.Name).To(Equal("lock-acquire"))
+		Expect(pgbackrestError.Category).To(Equal(ErrorCategoryLock))
+		Expect(pgbackrestError.IsRetriable()).To(BeTrue())
+		Expect(IsRetriable(fmt.Errorf("while taking backup: %w", err))).To(BeTrue())
+	})
+
+	It("reports configuration and repository errors as not retriable", func() {
+		Expect(IsRetriable(NewPgbackrestError(CommandBackup, exitError(31), nil))).To(BeFalse())
+		Expect(IsRetriable(NewPgbackrestError(CommandArchivePush, exitError(44), nil))).To(BeFalse())
+		Expect(IsRetriable(NewPgbackrestError(CommandRestore, exitError(95), nil))).To(BeFalse())
+	})
+
+	It("includes the reason and the pgBackRest error message", func() {
+		err := NewPgbackrestError(CommandArchivePush, exitError(82), []string{
+			"P00   WARN: some warning",
+			"P00  ERROR: [082]: WAL segment 000000010000000000000001 was not archived before the 60000ms timeout",
+			"P00   INFO: archive-push command end: aborted with exception [082]",
+		})
+
+		Expect(err).To(MatchError(
+			"pgbackrest archive-push failed (archive-timeout error, exit code 82): " +
+				"the WAL was not archived in time: " +
+				"[082]: WAL segment 000000010000000000000001 was not archived before the 60000ms timeout"))
+		Expect(IsRetriable(err)).To(BeTrue())
+	})
+
+	It("describes the exit codes pgBackRest doesn't document", func() {
+		err := NewPgbackrestError(CommandRestore, exitError(3), []string{"something went wrong"})
+
+		Expect(err).To(MatchError(
+			"pgbackrest restore failed (exit code 3): unexpected pgBackRest failure: something went wrong"))
+		Expect(IsRetriable(err)).To(BeFalse())
+	})
+
+	It("keeps the errors not caused by the exit status", func() {
+		sentinel := errors.New("executable not found")
+		Expect(NewPgbackrestError(CommandBackup, sentinel, nil)).To(BeIdenticalTo(sentinel))
+		Expect(IsRetriable(sentinel)).To(BeFalse())
+	})
+})
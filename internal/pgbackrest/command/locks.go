@@ -0,0 +1,107 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// ReleaseStaleLocks removes the pgBackRest lock files under the given path
+// which were written by a process which is gone, like a pgBackRest process
+// killed together with the previous sidecar. The lock files are never locked
+// here, so that a pgBackRest process starting at the same time can still take
+// its lock. It is meant to be called before any pgBackRest process is run.
+func ReleaseStaleLocks(ctx context.Context, lockPath string) error {
+	contextLogger := log.FromContext(ctx)
+
+	lockFiles, err := filepath.Glob(filepath.Join(lockPath, "*.lock"))
+	if err != nil {
+		return fmt.Errorf("while listing the lock files: %w", err)
+	}
+
+	var errs []error
+	for _, lockFile := range lockFiles {
+		stale, err := isStaleLock(lockFile)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !stale {
+			continue
+		}
+		if err := os.Remove(lockFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("while removing %s: %w", lockFile, err))
+			continue
+		}
+		contextLogger.Info("Released a pgbackrest lock left by an interrupted command", "lockFile", lockFile)
+	}
+	return errors.Join(errs...)
+}
+
+// isStaleLock tells whether the process which wrote the given lock file is
+// gone. A lock file without a PID is not considered stale, as the process
+// may not have written it yet.
+func isStaleLock(lockFile string) (bool, error) {
+	content, err := os.ReadFile(lockFile) // #nosec G304
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("while reading %s: %w", lockFile, err)
+	}
+
+	pid, ok := lockPID(content)
+	if !ok {
+		return false, nil
+	}
+
+	err = syscall.Kill(pid, 0)
+	return errors.Is(err, syscall.ESRCH), nil
+}
+
+// lockPID extracts the PID from the content of a pgBackRest lock file, which
+// is either a JSON object with a pid field or starts with a line holding the
+// PID, depending on the pgBackRest version
+func lockPID(content []byte) (int, bool) {
+	var data struct {
+		PID int `json:"pid"`
+	}
+	if err := json.Unmarshal(content, &data); err == nil {
+		return data.PID, data.PID > 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(string(bytes.TrimSpace(scanner.Bytes())))
+	if err != nil {
+		return 0, false
+	}
+	return pid, pid > 0
+}
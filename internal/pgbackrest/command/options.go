@@ -0,0 +1,364 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+)
+
+// The pgBackRest commands accepting user-provided options
+const (
+	CommandBackup      = "backup"
+	CommandRestore     = "restore"
+	CommandArchivePush = "archive-push"
+	CommandArchiveGet  = "archive-get"
+)
+
+// The pgBackRest commands the plugin runs without user-provided options
+const (
+	CommandStanzaCreate = "stanza-create"
+	CommandInfo         = "info"
+	CommandVerify       = "verify"
+)
+
+var allCommands = []string{CommandBackup, CommandRestore, CommandArchivePush, CommandArchiveGet}
+
+// indexedOptionPattern matches the index of the repository and PostgreSQL options,
+// e.g. "repo1-path" or "pg2-port"
+var indexedOptionPattern = regexp.MustCompile(`^(repo|pg)[0-9]+-`)
+
+// optionCommands maps the pgBackRest options, without the index of the repository
+// and PostgreSQL options, to the commands accepting them
+var optionCommands = map[string][]string{
+	// general options
+	"buffer-size":             allCommands,
+	"cmd":                     allCommands,
+	"cmd-ssh":                 allCommands,
+	"compress-level-network":  allCommands,
+	"config":                  allCommands,
+	"config-include-path":     allCommands,
+	"config-path":             allCommands,
+	"io-timeout":              allCommands,
+	"job-retry":               allCommands,
+	"job-retry-interval":      allCommands,
+	"lock-path":               allCommands,
+	"neutral-umask":           allCommands,
+	"process-max":             allCommands,
+	"protocol-timeout":        allCommands,
+	"sck-keep-alive":          allCommands,
+	"stanza":                  allCommands,
+	"tcp-keep-alive-count":    allCommands,
+	"tcp-keep-alive-idle":     allCommands,
+	"tcp-keep-alive-interval": allCommands,
+
+	// log options
+	"log-level-console": allCommands,
+	"log-level-file":    allCommands,
+	"log-level-stderr":  allCommands,
+	"log-path":          allCommands,
+	"log-subprocess":    allCommands,
+	"log-timestamp":     allCommands,
+
+	// repository options
+	"repo-cipher-pass":               allCommands,
+	"repo-cipher-type":               allCommands,
+	"repo-path":                      allCommands,
+	"repo-s3-bucket":                 allCommands,
+	"repo-s3-endpoint":               allCommands,
+	"repo-s3-key":                    allCommands,
+	"repo-s3-key-secret":             allCommands,
+	"repo-s3-key-type":               allCommands,
+	"repo-s3-kms-key-id":             allCommands,
+	"repo-s3-region":                 allCommands,
+	"repo-s3-requester-pays":         allCommands,
+	"repo-s3-role":                   allCommands,
+	"repo-s3-sse-customer-key":       allCommands,
+	"repo-s3-token":                  allCommands,
+	"repo-s3-uri-style":              allCommands,
+	"repo-storage-ca-file":           allCommands,
+	"repo-storage-ca-path":           allCommands,
+	"repo-storage-host":              allCommands,
+	"repo-storage-port":              allCommands,
+	"repo-storage-tag":               allCommands,
+	"repo-storage-upload-chunk-size": allCommands,
+	"repo-storage-verify-tls":        allCommands,
+	"repo-type":                      allCommands,
+	"repo-block":                     {CommandBackup},
+	"repo-bundle":                    {CommandBackup},
+	"repo-bundle-limit":              {CommandBackup},
+	"repo-bundle-size":               {CommandBackup},
+	"repo-hardlink":                  {CommandBackup},
+	"repo-retention-archive":         {CommandBackup},
+	"repo-retention-archive-type":    {CommandBackup},
+	"repo-retention-diff":            {CommandBackup},
+	"repo-retention-full":            {CommandBackup},
+	"repo-retention-full-type":       {CommandBackup},
+	"repo-retention-history":         {CommandBackup},
+	"repo":                           {CommandBackup, CommandRestore, CommandArchiveGet},
+
+	// stanza options
+	"pg-path":        allCommands,
+	"pg-database":    {CommandBackup, CommandArchivePush},
+	"pg-port":        {CommandBackup, CommandArchivePush},
+	"pg-socket-path": {CommandBackup, CommandArchivePush},
+	"pg-user":        {CommandBackup, CommandArchivePush},
+
+	// archive options
+	"archive-async":          {CommandArchivePush, CommandArchiveGet},
+	"archive-get-queue-max":  {CommandArchiveGet},
+	"archive-header-check":   {CommandArchivePush},
+	"archive-missing-retry":  {CommandArchiveGet},
+	"archive-mode-check":     {CommandBackup, CommandArchivePush},
+	"archive-push-queue-max": {CommandArchivePush},
+	"archive-timeout":        {CommandBackup, CommandArchivePush, CommandArchiveGet},
+	"spool-path":             {CommandArchivePush, CommandArchiveGet},
+	"compress-level":         {CommandBackup, CommandArchivePush},
+	"compress-type":          {CommandBackup, CommandArchivePush},
+
+	// backup options
+	"annotation":              {CommandBackup},
+	"archive-check":           {CommandBackup},
+	"archive-copy":            {CommandBackup},
+	"backup-standby":          {CommandBackup},
+	"checksum-page":           {CommandBackup},
+	"db-timeout":              {CommandBackup},
+	"exclude":                 {CommandBackup},
+	"expire-auto":             {CommandBackup},
+	"manifest-save-threshold": {CommandBackup},
+	"online":                  {CommandBackup},
+	"page-header-check":       {CommandBackup},
+	"resume":                  {CommandBackup},
+	"start-fast":              {CommandBackup},
+	"stop-auto":               {CommandBackup},
+	"delta":                   {CommandBackup, CommandRestore},
+	"force":                   {CommandBackup, CommandRestore},
+	"type":                    {CommandBackup, CommandRestore},
+
+	// restore options
+	"archive-mode":       {CommandRestore},
+	"db-exclude":         {CommandRestore},
+	"db-include":         {CommandRestore},
+	"link-all":           {CommandRestore},
+	"link-map":           {CommandRestore},
+	"recovery-option":    {CommandRestore},
+	"set":                {CommandRestore},
+	"tablespace-map":     {CommandRestore},
+	"tablespace-map-all": {CommandRestore},
+	"target":             {CommandRestore},
+	"target-action":      {CommandRestore},
+	"target-exclusive":   {CommandRestore},
+	"target-timeline":    {CommandRestore},
+}
+
+// reservation describes an option the plugin manages, which can't be overridden
+type reservation struct {
+	// commands is the list of commands for which the option is reserved, nil
+	// meaning all of them
+	commands []string
+
+	// reason tells the user where the option is configured instead
+	reason string
+}
+
+const (
+	reservedByPlugin     = "it is managed by the plugin"
+	reservedByRepository = "it is set from the repositories of the Archive"
+	reservedByRecovery   = "the recovery is configured by CloudNativePG"
+)
+
+// reservedOptions lists the options the plugin manages
+var reservedOptions = map[string]reservation{
+	"config":              {reason: reservedByPlugin},
+	"config-include-path": {reason: reservedByPlugin},
+	"config-path":         {reason: reservedByPlugin},
+	"lock-path":           {reason: reservedByPlugin},
+	"stanza":              {reason: "use the stanza parameter of the plugin"},
+	"log-level-console":   {reason: "the plugin reserves stdout for the pgBackRest output"},
+	"log-level-stderr":    {reason: "use log.levelStderr in the Archive"},
+
+	"repo-cipher-pass":         {reason: reservedByRepository},
+	"repo-cipher-type":         {reason: reservedByRepository},
+	"repo-path":                {reason: reservedByRepository},
+	"repo-s3-bucket":           {reason: reservedByRepository},
+	"repo-s3-endpoint":         {reason: reservedByRepository},
+	"repo-s3-key":              {reason: reservedByRepository},
+	"repo-s3-key-secret":       {reason: reservedByRepository},
+	"repo-s3-key-type":         {reason: reservedByRepository},
+	"repo-s3-kms-key-id":       {reason: reservedByRepository},
+	"repo-s3-region":           {reason: reservedByRepository},
+	"repo-s3-sse-customer-key": {reason: reservedByRepository},
+	"repo-s3-token":            {reason: reservedByRepository},
+	"repo-s3-uri-style":        {reason: reservedByRepository},
+	"repo-storage-ca-file":     {reason: reservedByRepository},
+	"repo-storage-verify-tls":  {reason: reservedByRepository},
+	"repo-type":                {reason: reservedByRepository},
+
+	"pg-path":        {reason: reservedByPlugin},
+	"pg-socket-path": {reason: reservedByPlugin},
+	"pg-user":        {reason: reservedByPlugin},
+
+	"annotation":    {commands: []string{CommandBackup}, reason: "use data.tags in the Archive"},
+	"archive-check": {commands: []string{CommandBackup}, reason: reservedByPlugin},
+	"checksum-page": {commands: []string{CommandBackup}, reason: "use data.checksumPage in the Archive"},
+
+	"process-max": {
+		commands: []string{CommandBackup, CommandRestore},
+		reason:   "use data.jobs or restore.jobs in the Archive",
+	},
+	"compress-type": {
+		commands: []string{CommandBackup, CommandArchivePush},
+		reason:   "use compression in the Archive",
+	},
+	"archive-push-queue-max": {
+		commands: []string{CommandArchivePush},
+		reason:   "use wal.archivePushQueueMax in the Archive",
+	},
+
+	"set":              {commands: []string{CommandRestore}, reason: "the backup is chosen by the plugin"},
+	"type":             {commands: []string{CommandRestore}, reason: reservedByRecovery},
+	"target":           {commands: []string{CommandRestore}, reason: reservedByRecovery},
+	"target-action":    {commands: []string{CommandRestore}, reason: reservedByRecovery},
+	"target-exclusive": {commands: []string{CommandRestore}, reason: reservedByRecovery},
+	"target-timeline":  {commands: []string{CommandRestore}, reason: reservedByRecovery},
+	"recovery-option":  {commands: []string{CommandRestore}, reason: reservedByRecovery},
+}
+
+// OptionError is returned when a user-provided option is not accepted
+type OptionError struct {
+	// Command is the pgBackRest command the option was meant for
+	Command string
+
+	// Option is the option as provided by the user
+	Option string
+
+	// Reason describes why the option was rejected
+	Reason string
+}
+
+// Error implements the error interface
+func (err *OptionError) Error() string {
+	return fmt.Sprintf("invalid pgbackrest %s option %q: %s", err.Command, err.Option, err.Reason)
+}
+
+// validateOption checks a single option name, without the leading dashes
+func validateOption(command string, name string) string {
+	normalized := indexedOptionPattern.ReplaceAllString(name, "$1-")
+	commands, ok := optionCommands[normalized]
+	if !ok {
+		// boolean options can be negated, and any option can be reset to its default
+		for _, prefix := range []string{"no-", "reset-"} {
+			if trimmed, found := strings.CutPrefix(normalized, prefix); found {
+				normalized = trimmed
+				commands, ok = optionCommands[normalized]
+				break
+			}
+		}
+	}
+	if !ok {
+		return "unknown option"
+	}
+
+	if reserved, found := reservedOptions[normalized]; found &&
+		(reserved.commands == nil || slices.Contains(reserved.commands, command)) {
+		return "reserved, " + reserved.reason
+	}
+
+	if !slices.Contains(commands, command) {
+		return fmt.Sprintf("not valid for the %s command", command)
+	}
+
+	return ""
+}
+
+// ValidateCommandArgs checks the additional command line arguments provided for
+// the given command. Each argument must be an option in the "--name" or
+// "--name=value" form, accepted by the command and not managed by the plugin.
+func ValidateCommandArgs(command string, args []string) error {
+	var errs []error
+	for _, arg := range args {
+		option, ok := strings.CutPrefix(arg, "--")
+		if !ok {
+			errs = append(errs, &OptionError{
+				Command: command,
+				Option:  arg,
+				Reason:  "arguments must be in the --name or --name=value form",
+			})
+			continue
+		}
+
+		name, _, _ := strings.Cut(option, "=")
+		if reason := validateOption(command, name); reason != "" {
+			errs = append(errs, &OptionError{Command: command, Option: arg, Reason: reason})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateCommandParameters checks the options provided for the given command as
+// name/value pairs, like the parameters of a Backup
+func ValidateCommandParameters(command string, parameters map[string]string) error {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var errs []error
+	for _, name := range names {
+		if reason := validateOption(command, name); reason != "" {
+			errs = append(errs, &OptionError{Command: command, Option: name, Reason: reason})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateConfiguration checks the additional command line arguments of every
+// command in the configuration
+func ValidateConfiguration(configuration *pgbackrestApi.PgbackrestConfiguration) error {
+	var errs []error
+	if configuration.Data != nil {
+		if err := ValidateCommandArgs(CommandBackup, configuration.Data.AdditionalCommandArgs); err != nil {
+			errs = append(errs, fmt.Errorf("data.additionalCommandArgs: %w", err))
+		}
+	}
+	if configuration.Restore != nil {
+		if err := ValidateCommandArgs(CommandRestore, configuration.Restore.AdditionalCommandArgs); err != nil {
+			errs = append(errs, fmt.Errorf("restore.additionalCommandArgs: %w", err))
+		}
+	}
+	if configuration.Wal != nil {
+		if err := ValidateCommandArgs(CommandArchivePush, configuration.Wal.ArchiveAdditionalCommandArgs); err != nil {
+			errs = append(errs, fmt.Errorf("wal.archiveAdditionalCommandArgs: %w", err))
+		}
+		if configuration.Wal.ArchivePushQueueMax != nil && configuration.Log != nil &&
+			slices.Contains([]string{"off", "error"}, configuration.Log.LevelStderr) {
+			// the dropped WAL files are reported by pgBackRest as warnings
+			errs = append(errs, errors.New(
+				"log.levelStderr: wal.archivePushQueueMax requires at least the warn level to detect dropped WAL files"))
+		}
+		if err := ValidateCommandArgs(CommandArchiveGet, configuration.Wal.RestoreAdditionalCommandArgs); err != nil {
+			errs = append(errs, fmt.Errorf("wal.restoreAdditionalCommandArgs: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
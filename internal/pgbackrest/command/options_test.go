@@ -0,0 +1,147 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateCommandArgs", func() {
+	It("accepts valid options", func() {
+		Expect(ValidateCommandArgs(CommandBackup, []string{
+			"--compress-level=3",
+			"--repo1-retention-full=2",
+			"--no-archive-copy",
+			"--start-fast",
+		})).To(Succeed())
+		Expect(ValidateCommandArgs(CommandArchiveGet, []string{"--archive-async", "--archive-get-queue-max=1GiB"})).
+			To(Succeed())
+	})
+
+	It("rejects unknown options", func() {
+		err := ValidateCommandArgs(CommandBackup, []string{"--not-an-option"})
+		Expect(err).To(MatchError(ContainSubstring(`"--not-an-option": unknown option`)))
+	})
+
+	It("rejects arguments which are not options", func() {
+		err := ValidateCommandArgs(CommandBackup, []string{"backup"})
+		Expect(err).To(MatchError(ContainSubstring("--name or --name=value form")))
+	})
+
+	It("rejects options reserved by the plugin", func() {
+		err := ValidateCommandArgs(CommandArchivePush, []string{"--stanza=other", "--repo2-s3-bucket=bucket"})
+		Expect(err).To(MatchError(ContainSubstring(`"--stanza=other": reserved`)))
+		Expect(err).To(MatchError(ContainSubstring(`"--repo2-s3-bucket=bucket": reserved`)))
+	})
+
+	It("rejects the options set from the fields of the Archive", func() {
+		for command, args := range map[string][]string{
+			CommandBackup:      {"--checksum-page", "--no-checksum-page", "--process-max=4", "--compress-type=zst"},
+			CommandRestore:     {"--process-max=4"},
+			CommandArchivePush: {"--compress-type=lz4", "--archive-push-queue-max=1GiB"},
+		} {
+			for _, arg := range args {
+				Expect(ValidateCommandArgs(command, []string{arg})).To(
+					MatchError(ContainSubstring(`%q: reserved, use `, arg)), "%s %s", command, arg)
+			}
+		}
+	})
+
+	It("rejects options reserved for a single command only for that command", func() {
+		Expect(ValidateCommandArgs(CommandBackup, []string{"--type=full"})).To(Succeed())
+		Expect(ValidateCommandArgs(CommandRestore, []string{"--type=time"})).
+			To(MatchError(ContainSubstring("reserved, the recovery is configured by CloudNativePG")))
+	})
+
+	It("rejects options which are not valid for the command", func() {
+		err := ValidateCommandArgs(CommandArchivePush, []string{"--start-fast"})
+		Expect(err).To(MatchError(ContainSubstring("not valid for the archive-push command")))
+	})
+
+	It("checks negated and reset options", func() {
+		Expect(ValidateCommandArgs(CommandBackup, []string{"--reset-compress-level"})).To(Succeed())
+		Expect(ValidateCommandArgs(CommandBackup, []string{"--no-lock-path"})).
+			To(MatchError(ContainSubstring("reserved")))
+	})
+})
+
+var _ = Describe("ValidateCommandParameters", func() {
+	It("accepts valid parameters", func() {
+		Expect(ValidateCommandParameters(CommandBackup, map[string]string{
+			"type":       "incr",
+			"start-fast": "y",
+		})).To(Succeed())
+	})
+
+	It("reports every invalid parameter", func() {
+		err := ValidateCommandParameters(CommandBackup, map[string]string{
+			"stanza":      "other",
+			"target-name": "point",
+		})
+		Expect(err).To(MatchError(And(
+			ContainSubstring(`"stanza": reserved, use the stanza parameter of the plugin`),
+			ContainSubstring(`"target-name": unknown option`),
+		)))
+	})
+})
+
+var _ = Describe("ValidateConfiguration", func() {
+	It("reports the field holding the invalid option", func() {
+		err := ValidateConfiguration(&pgbackrestApi.PgbackrestConfiguration{
+			Data: &pgbackrestApi.DataBackupConfiguration{
+				AdditionalCommandArgs: []string{"--start-fast"},
+			},
+			Wal: &pgbackrestApi.WalBackupConfiguration{
+				RestoreAdditionalCommandArgs: []string{"--archive-push-queue-max=1GiB"},
+			},
+		})
+		Expect(err).To(MatchError(And(
+			ContainSubstring("wal.restoreAdditionalCommandArgs"),
+			ContainSubstring("not valid for the archive-get command"),
+		)))
+		Expect(err).ToNot(MatchError(ContainSubstring("data.additionalCommandArgs")))
+	})
+
+	It("rejects archive-push-queue-max, set by wal.archivePushQueueMax", func() {
+		err := ValidateConfiguration(&pgbackrestApi.PgbackrestConfiguration{
+			Wal: &pgbackrestApi.WalBackupConfiguration{
+				ArchiveAdditionalCommandArgs: []string{"--archive-push-queue-max=2GiB"},
+			},
+		})
+		Expect(err).To(MatchError(And(
+			ContainSubstring("wal.archiveAdditionalCommandArgs"),
+			ContainSubstring("reserved, use wal.archivePushQueueMax in the Archive"),
+		)))
+	})
+
+	It("requires the dropped WAL files to be logged with wal.archivePushQueueMax", func() {
+		queueMax := resource.MustParse("1Gi")
+		configuration := &pgbackrestApi.PgbackrestConfiguration{
+			Wal: &pgbackrestApi.WalBackupConfiguration{ArchivePushQueueMax: &queueMax},
+			Log: &pgbackrestApi.LogConfiguration{LevelStderr: "error"},
+		}
+		Expect(ValidateConfiguration(configuration)).To(MatchError(ContainSubstring("log.levelStderr")))
+
+		configuration.Log.LevelStderr = "info"
+		Expect(ValidateConfiguration(configuration)).To(Succeed())
+	})
+})
@@ -0,0 +1,296 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/execlog"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+// PgbackrestExecutable is the name of the pgBackRest binary
+const PgbackrestExecutable = "pgbackrest"
+
+const (
+	// stderrTailLines is the number of stderr lines kept to describe a failure
+	stderrTailLines = 10
+
+	// terminationGracePeriod is how long a pgBackRest process has to exit after
+	// being asked to terminate, before being killed
+	terminationGracePeriod = 10 * time.Second
+)
+
+// ErrSupervisorDraining is returned when starting a command while the
+// supervisor is draining
+var ErrSupervisorDraining = errors.New("the pgbackrest processes are being drained")
+
+// errCommandTimeout is the cause of the cancellation of the commands which timed out
+var errCommandTimeout = errors.New("pgbackrest command timed out")
+
+// Execution describes a pgBackRest command to be run
+type Execution struct {
+	// Command is the pgBackRest command, e.g. "backup"
+	Command string
+
+	// Options are the command line arguments of pgBackRest, including the command
+	Options []string
+
+	// Env is the environment of the pgBackRest process
+	Env []string
+
+	// Timeout limits the duration of the command, zero meaning no limit
+	Timeout time.Duration
+
+	// Detached commands are not terminated when the context is cancelled, but
+	// only when they time out or when the supervisor is drained. It is meant
+	// for the commands which are better completed than interrupted.
+	Detached bool
+
+	// Stdout receives the output of the command. When nil, it is logged.
+	Stdout io.Writer
+
+	// Stderr receives the errors of the command. When nil, they are logged.
+	Stderr io.Writer
+}
+
+// Supervisor runs the pgBackRest processes, in their own process group, so
+// that they can be terminated together with their children
+type Supervisor struct {
+	executable string
+
+	mu       sync.Mutex
+	draining bool
+	nextID   int
+	running  map[int]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewSupervisor creates a new process supervisor
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		executable: PgbackrestExecutable,
+		running:    make(map[int]context.CancelFunc),
+	}
+}
+
+// DefaultSupervisor is the supervisor used to run the pgBackRest commands
+var DefaultSupervisor = NewSupervisor()
+
+// Run runs the given pgBackRest command with the default supervisor
+func Run(ctx context.Context, execution Execution) error {
+	return DefaultSupervisor.Run(ctx, execution)
+}
+
+// track registers a running process, returning its identifier
+func (s *Supervisor) track(cancel context.CancelFunc) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.draining {
+		return 0, ErrSupervisorDraining
+	}
+
+	s.nextID++
+	s.running[s.nextID] = cancel
+	s.wg.Add(1)
+	return s.nextID, nil
+}
+
+// untrack removes a process which has exited
+func (s *Supervisor) untrack(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, id)
+	s.wg.Done()
+}
+
+// Run runs the given pgBackRest command, logging its output. When the context
+// is cancelled, or the command times out, pgBackRest and the processes it
+// started are terminated. When it fails, the returned error is a
+// *PgbackrestError including the last lines pgBackRest wrote to stderr.
+func (s *Supervisor) Run(ctx context.Context, execution Execution) error {
+	contextLogger := log.FromContext(ctx).WithName("pgbackrest " + execution.Command)
+
+	processCtx := ctx
+	if execution.Detached {
+		processCtx = context.WithoutCancel(ctx)
+	}
+	processCtx, cancel := context.WithCancel(processCtx)
+	defer cancel()
+	if execution.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		processCtx, cancelTimeout = context.WithTimeoutCause(processCtx, execution.Timeout, errCommandTimeout)
+		defer cancelTimeout()
+	}
+
+	id, err := s.track(cancel)
+	if err != nil {
+		return err
+	}
+	defer s.untrack(id)
+
+	cmd := exec.CommandContext(processCtx, s.executable, execution.Options...) // #nosec G204
+	cmd.Env = execution.Env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = terminationGracePeriod
+
+	stdoutWriter := &lineWriter{
+		logger: contextLogger.WithValues(execlog.PipeKey, execlog.StdOut),
+		output: execution.Stdout,
+	}
+	stderrWriter := &lineWriter{
+		logger:    contextLogger.WithValues(execlog.PipeKey, execlog.StdErr),
+		output:    execution.Stderr,
+		tailLines: stderrTailLines,
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	err = cmd.Run()
+	stdoutWriter.flush()
+	stderrWriter.flush()
+
+	if processCtx.Err() != nil && cmd.Process != nil {
+		// pgBackRest is gone, make sure the processes it started are gone too.
+		// Their locks are released by the kernel once they exit.
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	if err == nil {
+		return nil
+	}
+
+	err = NewPgbackrestError(execution.Command, err, stderrWriter.lines)
+	var pgbackrestError *PgbackrestError
+	if errors.As(err, &pgbackrestError) {
+		switch {
+		case errors.Is(context.Cause(processCtx), errCommandTimeout):
+			pgbackrestError.Category = ErrorCategoryTimeout
+		case processCtx.Err() != nil:
+			pgbackrestError.Category = ErrorCategoryTerminated
+		}
+	}
+	return err
+}
+
+// Drain waits for the running commands to complete, refusing new ones. The
+// commands still running after the grace period are terminated.
+func (s *Supervisor) Drain(ctx context.Context, gracePeriod time.Duration) {
+	contextLogger := log.FromContext(ctx)
+
+	s.mu.Lock()
+	s.draining = true
+	running := len(s.running)
+	s.mu.Unlock()
+
+	if running == 0 {
+		return
+	}
+	contextLogger.Info("Waiting for the running pgbackrest commands to complete", "count", running)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(gracePeriod):
+	}
+
+	s.mu.Lock()
+	contextLogger.Info("Terminating the pgbackrest commands still running", "count", len(s.running))
+	for _, cancel := range s.running {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	<-done
+}
+
+// lineWriter splits the output of a command in lines, logging them and
+// keeping the last ones
+type lineWriter struct {
+	logger log.Logger
+
+	// output, when set, receives the output instead of the logger
+	output io.Writer
+
+	// tailLines is the number of lines to keep
+	tailLines int
+	lines     []string
+	partial   []byte
+}
+
+// Write implements the io.Writer interface
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.output != nil {
+		if _, err := w.output.Write(p); err != nil {
+			return 0, err
+		}
+		if w.tailLines == 0 {
+			return len(p), nil
+		}
+	}
+
+	w.partial = append(w.partial, p...)
+	for {
+		index := bytes.IndexByte(w.partial, '\n')
+		if index < 0 {
+			break
+		}
+		w.line(string(w.partial[:index]))
+		w.partial = w.partial[index+1:]
+	}
+	return len(p), nil
+}
+
+// flush handles the last line, when not terminated by a line break
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.line(string(w.partial))
+		w.partial = nil
+	}
+}
+
+// line handles a complete line
+func (w *lineWriter) line(line string) {
+	if w.output == nil && line != "" {
+		w.logger.Info(line)
+	}
+
+	if w.tailLines == 0 {
+		return
+	}
+	if len(w.lines) == w.tailLines {
+		w.lines = w.lines[1:]
+	}
+	w.lines = append(w.lines, line)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Supervisor", func() {
+	var supervisor *Supervisor
+
+	BeforeEach(func() {
+		supervisor = NewSupervisor()
+		supervisor.executable = "sh"
+	})
+
+	script := func(script string) Execution {
+		return Execution{Command: CommandArchiveGet, Options: []string{"-c", script}}
+	}
+
+	It("keeps the last stderr lines of a failing command", func(ctx SpecContext) {
+		err := supervisor.Run(ctx, script(
+			`for i in $(seq 1 20); do echo "line $i" >&2; done; printf "ERROR: [055]: missing" >&2; exit 55`))
+
+		var pgbackrestError *PgbackrestError
+		Expect(errors.As(err, &pgbackrestError)).To(BeTrue())
+		Expect(pgbackrestError.Stderr).To(HaveLen(stderrTailLines))
+		Expect(pgbackrestError.Stderr[stderrTailLines-1]).To(Equal("ERROR: [055]: missing"))
+		Expect(pgbackrestError.Category).To(Equal(ErrorCategoryNotFound))
+		Expect(pgbackrestError.Message()).To(Equal("[055]: missing"))
+	})
+
+	It("passes the output to the given writers", func(ctx SpecContext) {
+		var stdout bytes.Buffer
+		execution := script(`echo '{"a": 1}'`)
+		execution.Stdout = &stdout
+
+		Expect(supervisor.Run(ctx, execution)).To(Succeed())
+		Expect(stdout.String()).To(Equal("{\"a\": 1}\n"))
+	})
+
+	It("terminates the process group when the command times out", func(ctx SpecContext) {
+		pidFile := filepath.Join(GinkgoT().TempDir(), "child.pid")
+		execution := script(`sleep 30 & echo $! > ` + pidFile + `; wait`)
+		execution.Timeout = 200 * time.Millisecond
+
+		err := supervisor.Run(ctx, execution)
+
+		var pgbackrestError *PgbackrestError
+		Expect(errors.As(err, &pgbackrestError)).To(BeTrue())
+		Expect(pgbackrestError.Category).To(Equal(ErrorCategoryTimeout))
+		Expect(pgbackrestError.IsRetriable()).To(BeTrue())
+
+		content, err := os.ReadFile(pidFile) // #nosec G304
+		Expect(err).ToNot(HaveOccurred())
+		childPid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		Expect(err).ToNot(HaveOccurred())
+		// the killed child may be left as a zombie until it is reaped by init
+		Eventually(func() string {
+			stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(childPid), "stat")) // #nosec G304
+			if err != nil {
+				return "gone"
+			}
+			_, state, _ := strings.Cut(string(stat), ") ")
+			return state[:1]
+		}).Should(BeElementOf("gone", "Z"))
+	})
+
+	It("terminates the command when the context is cancelled", func(ctx SpecContext) {
+		runCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+
+		err := supervisor.Run(runCtx, script("sleep 30"))
+
+		var pgbackrestError *PgbackrestError
+		Expect(errors.As(err, &pgbackrestError)).To(BeTrue())
+		Expect(pgbackrestError.Category).To(Equal(ErrorCategoryTerminated))
+	})
+
+	It("lets detached commands complete when the context is cancelled", func(ctx SpecContext) {
+		runCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		execution := script("sleep 0.2")
+		execution.Detached = true
+		Expect(supervisor.Run(runCtx, execution)).To(Succeed())
+	})
+
+	It("drains the running commands and refuses new ones", func(ctx SpecContext) {
+		execution := script("sleep 30")
+		execution.Detached = true
+
+		result := make(chan error, 1)
+		go func() {
+			result <- supervisor.Run(ctx, execution)
+		}()
+		Eventually(func() int {
+			supervisor.mu.Lock()
+			defer supervisor.mu.Unlock()
+			return len(supervisor.running)
+		}).Should(Equal(1))
+
+		supervisor.Drain(ctx, 100*time.Millisecond)
+
+		var pgbackrestError *PgbackrestError
+		Expect(errors.As(<-result, &pgbackrestError)).To(BeTrue())
+		Expect(pgbackrestError.Category).To(Equal(ErrorCategoryTerminated))
+		Expect(supervisor.Run(ctx, script("true"))).To(MatchError(ErrSupervisorDraining))
+	})
+})
+
+var _ = Describe("ReleaseStaleLocks", func() {
+	It("removes the lock files written by a process which is gone", func(ctx SpecContext) {
+		exited := exec.Command("true")
+		Expect(exited.Run()).To(Succeed())
+		gonePID := strconv.Itoa(exited.Process.Pid)
+		livePID := strconv.Itoa(os.Getpid())
+
+		lockPath := GinkgoT().TempDir()
+		lockFiles := map[string]string{
+			"stanza-archive-1.lock": gonePID + "\n",
+			"stanza-backup-1.lock":  `{"execId":"1-abc","pid":` + gonePID + `}`,
+			"stanza-archive-2.lock": livePID + "\n",
+			"stanza-backup-2.lock":  `{"execId":"2-abc","pid":` + livePID + `}`,
+			"stanza-restore.lock":   "",
+		}
+		for name, content := range lockFiles {
+			Expect(os.WriteFile(filepath.Join(lockPath, name), []byte(content), 0o600)).To(Succeed())
+		}
+
+		Expect(ReleaseStaleLocks(ctx, lockPath)).To(Succeed())
+		Expect(filepath.Join(lockPath, "stanza-archive-1.lock")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(lockPath, "stanza-backup-1.lock")).ToNot(BeAnExistingFile())
+		Expect(filepath.Join(lockPath, "stanza-archive-2.lock")).To(BeAnExistingFile())
+		Expect(filepath.Join(lockPath, "stanza-backup-2.lock")).To(BeAnExistingFile())
+		Expect(filepath.Join(lockPath, "stanza-restore.lock")).To(BeAnExistingFile())
+	})
+})
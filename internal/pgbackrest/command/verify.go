@@ -0,0 +1,254 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+)
+
+const (
+	// VerifyStatusOk is the status of a stanza whose repository has no invalid files
+	VerifyStatusOk = "ok"
+
+	// VerifyStatusError is the status of a stanza whose repository has invalid files
+	VerifyStatusError = "error"
+
+	// verifyBackupStatusValid is the status of a valid backup set
+	verifyBackupStatusValid = "valid"
+
+	// verifyBackupStatusInProgress is the status of a backup set still being taken
+	verifyBackupStatusInProgress = "in-progress"
+)
+
+var (
+	verifyArchivePattern = regexp.MustCompile(
+		`^archiveId: (\S+), total WAL checked: (\d+), total valid WAL: (\d+)$`)
+	verifyBackupPattern = regexp.MustCompile(
+		`^backup: (\S+), status: ([^,]+), total files checked: (\d+), total valid files: (\d+)$`)
+	verifyErrorsPattern = regexp.MustCompile(
+		`^missing: (\d+), checksum invalid: (\d+), size invalid: (\d+), other: (\d+)$`)
+)
+
+// VerifyFiles counts the files checked by pgBackRest in a backup set or a WAL archive
+type VerifyFiles struct {
+	Checked         int64
+	Valid           int64
+	Missing         int64
+	ChecksumInvalid int64
+	SizeInvalid     int64
+	Other           int64
+}
+
+// VerifyArchive is the result of the verification of a WAL archive
+type VerifyArchive struct {
+	// ArchiveID is the ID of the WAL archive, e.g. "17-1"
+	ArchiveID string
+	VerifyFiles
+}
+
+// IsValid tells whether all the WAL files of the archive are valid
+func (archive VerifyArchive) IsValid() bool {
+	return archive.Checked == archive.Valid
+}
+
+// VerifyBackup is the result of the verification of a backup set
+type VerifyBackup struct {
+	// Label is the label of the backup set
+	Label string
+
+	// Status is the status of the backup set, e.g. "valid" or "invalid"
+	Status string
+	VerifyFiles
+}
+
+// IsValid tells whether the backup set is valid. The backup sets still
+// being taken are not reported as invalid.
+func (backup VerifyBackup) IsValid() bool {
+	return backup.Status == verifyBackupStatusValid || backup.Status == verifyBackupStatusInProgress
+}
+
+// VerifyResult is the result of the verification of a stanza
+type VerifyResult struct {
+	// Stanza is the verified stanza
+	Stanza string
+
+	// Status is the overall status, "ok" or "error"
+	Status string
+
+	// Archives are the WAL archives which were checked
+	Archives []VerifyArchive
+
+	// Backups are the backup sets which were checked
+	Backups []VerifyBackup
+}
+
+// InvalidArchives returns the WAL archives having files which are not valid
+func (result *VerifyResult) InvalidArchives() []VerifyArchive {
+	var archives []VerifyArchive
+	for _, archive := range result.Archives {
+		if !archive.IsValid() {
+			archives = append(archives, archive)
+		}
+	}
+	return archives
+}
+
+// InvalidBackups returns the backup sets which are not valid
+func (result *VerifyResult) InvalidBackups() []VerifyBackup {
+	var backups []VerifyBackup
+	for _, backup := range result.Backups {
+		if !backup.IsValid() {
+			backups = append(backups, backup)
+		}
+	}
+	return backups
+}
+
+// Verify checks the backup sets and the WAL archives of the stanza in the
+// repositories. pgBackRest fails when it finds invalid files, so the result is
+// returned whenever its output can be parsed.
+func Verify(
+	ctx context.Context,
+	pgbackrestConfiguration *pgbackrestApi.PgbackrestConfiguration,
+	stanza string,
+	env []string,
+) (*VerifyResult, error) {
+	contextLogger := log.FromContext(ctx).WithName("pgbackrest")
+
+	//nolint:prealloc
+	options := []string{CommandVerify, "--output", "text", "--verbose", "--lock-path", LockPath}
+
+	options, err := AppendCloudProviderOptionsFromConfiguration(ctx, options, pgbackrestConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err = AppendLogOptionsFromConfiguration(ctx, options, pgbackrestConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	options = append(options, "--stanza", stanza)
+
+	var stdoutBuffer bytes.Buffer
+	var stderrBuffer bytes.Buffer
+	runErr := Run(ctx, Execution{
+		Command: CommandVerify,
+		Options: options,
+		Env:     env,
+		Timeout: pgbackrestConfiguration.GetCommandTimeout(CommandVerify),
+		Stdout:  &stdoutBuffer,
+		Stderr:  &stderrBuffer,
+	})
+
+	result, err := parseVerifyOutput(stdoutBuffer.String())
+	if err != nil {
+		if runErr != nil {
+			err = runErr
+		}
+		contextLogger.Error(err,
+			"Can't verify the repository",
+			"command", "pgbackrest",
+			"options", options,
+			"stdout", stdoutBuffer.String(),
+			"stderr", stderrBuffer.String())
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// parseVerifyOutput parses the text output of "pgbackrest verify --verbose"
+func parseVerifyOutput(output string) (*VerifyResult, error) {
+	var result VerifyResult
+
+	// the missing and invalid files are reported on the line following
+	// the archive or backup they belong to
+	var files *VerifyFiles
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if stanza, ok := strings.CutPrefix(line, "stanza: "); ok {
+			result.Stanza = stanza
+			continue
+		}
+		if status, ok := strings.CutPrefix(line, "status: "); ok {
+			result.Status = status
+			continue
+		}
+
+		if match := verifyArchivePattern.FindStringSubmatch(line); match != nil {
+			result.Archives = append(result.Archives, VerifyArchive{
+				ArchiveID: match[1],
+				VerifyFiles: VerifyFiles{
+					Checked: parseVerifyCount(match[2]),
+					Valid:   parseVerifyCount(match[3]),
+				},
+			})
+			files = &result.Archives[len(result.Archives)-1].VerifyFiles
+			continue
+		}
+
+		if match := verifyBackupPattern.FindStringSubmatch(line); match != nil {
+			result.Backups = append(result.Backups, VerifyBackup{
+				Label:  match[1],
+				Status: match[2],
+				VerifyFiles: VerifyFiles{
+					Checked: parseVerifyCount(match[3]),
+					Valid:   parseVerifyCount(match[4]),
+				},
+			})
+			files = &result.Backups[len(result.Backups)-1].VerifyFiles
+			continue
+		}
+
+		if match := verifyErrorsPattern.FindStringSubmatch(line); match != nil && files != nil {
+			files.Missing = parseVerifyCount(match[1])
+			files.ChecksumInvalid = parseVerifyCount(match[2])
+			files.SizeInvalid = parseVerifyCount(match[3])
+			files.Other = parseVerifyCount(match[4])
+			files = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if result.Status != VerifyStatusOk && result.Status != VerifyStatusError {
+		return nil, fmt.Errorf("unexpected pgbackrest verify output: missing stanza status")
+	}
+
+	return &result, nil
+}
+
+// parseVerifyCount parses a count matched by the verify output patterns
+func parseVerifyCount(value string) int64 {
+	count, _ := strconv.ParseInt(value, 10, 64)
+	return count
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseVerifyOutput", func() {
+	It("parses the backup sets and WAL archives with their errors", func() {
+		result, err := parseVerifyOutput(`stanza: main
+status: error
+  archiveId: 17-1, total WAL checked: 12, total valid WAL: 11
+    missing: 0, checksum invalid: 1, size invalid: 0, other: 0
+  archiveId: 17-2, total WAL checked: 4, total valid WAL: 4
+    missing: 0, checksum invalid: 0, size invalid: 0, other: 0
+  backup: 20250101-010000F, status: valid, total files checked: 1000, total valid files: 1000
+    missing: 0, checksum invalid: 0, size invalid: 0, other: 0
+  backup: 20250102-010000F, status: invalid, total files checked: 1000, total valid files: 997
+    missing: 2, checksum invalid: 0, size invalid: 1, other: 0
+  backup: 20250103-010000F, status: manifest missing, total files checked: 0, total valid files: 0
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Stanza).To(Equal("main"))
+		Expect(result.Status).To(Equal(VerifyStatusError))
+		Expect(result.Archives).To(HaveLen(2))
+		Expect(result.Backups).To(HaveLen(3))
+
+		Expect(result.InvalidArchives()).To(Equal([]VerifyArchive{{
+			ArchiveID: "17-1",
+			VerifyFiles: VerifyFiles{
+				Checked:         12,
+				Valid:           11,
+				ChecksumInvalid: 1,
+			},
+		}}))
+		Expect(result.InvalidBackups()).To(Equal([]VerifyBackup{
+			{
+				Label:  "20250102-010000F",
+				Status: "invalid",
+				VerifyFiles: VerifyFiles{
+					Checked:     1000,
+					Valid:       997,
+					Missing:     2,
+					SizeInvalid: 1,
+				},
+			},
+			{
+				Label:  "20250103-010000F",
+				Status: "manifest missing",
+			},
+		}))
+	})
+
+	It("doesn't report the backup sets being taken as invalid", func() {
+		result, err := parseVerifyOutput(`stanza: main
+status: ok
+  backup: 20250101-010000F, status: in-progress, total files checked: 0, total valid files: 0
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Status).To(Equal(VerifyStatusOk))
+		Expect(result.InvalidBackups()).To(BeEmpty())
+	})
+
+	It("fails when the output has no status", func() {
+		_, err := parseVerifyOutput("ERROR: [055]: unable to load info file\n")
+		Expect(err).To(HaveOccurred())
+	})
+})
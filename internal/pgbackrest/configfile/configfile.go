@@ -0,0 +1,132 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var repoOptionPattern = regexp.MustCompile(`^repo([0-9]+)-`)
+
+// File is a pgBackRest configuration file. Options are stored as "key=value" lines.
+type File struct {
+	// Stanza is the name of the stanza section
+	Stanza string
+
+	// Global holds the options of the global section
+	Global []string
+
+	// StanzaOptions holds the options of the stanza section
+	StanzaOptions []string
+}
+
+// FromOptions converts command line options into configuration file lines.
+// Arguments not starting with "--", such as the command name, are skipped.
+func FromOptions(options []string) []string {
+	var lines []string
+	for i := 0; i < len(options); i++ {
+		option, ok := strings.CutPrefix(options[i], "--")
+		if !ok {
+			continue
+		}
+
+		if key, value, found := strings.Cut(option, "="); found {
+			lines = append(lines, key+"="+value)
+			continue
+		}
+
+		if i+1 < len(options) && !strings.HasPrefix(options[i+1], "--") {
+			lines = append(lines, option+"="+options[i+1])
+			i++
+			continue
+		}
+
+		// boolean options
+		if negated, found := strings.CutPrefix(option, "no-"); found {
+			lines = append(lines, negated+"=n")
+		} else {
+			lines = append(lines, option+"=y")
+		}
+	}
+	return lines
+}
+
+// FromEnv converts the pgBackRest options set as environment variables into
+// configuration file lines. PGBACKREST_CONFIG is skipped, as it points to a
+// configuration file itself.
+func FromEnv(env []string) []string {
+	var lines []string
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		option, ok := strings.CutPrefix(name, "PGBACKREST_")
+		if !ok || option == "CONFIG" {
+			continue
+		}
+		lines = append(lines, strings.ReplaceAll(strings.ToLower(option), "_", "-")+"="+value)
+	}
+	return lines
+}
+
+// Render renders the configuration file. The repository options of the global
+// section are grouped by repository, after the other global options.
+func (f File) Render() string {
+	var builder strings.Builder
+
+	var generalOptions []string
+	repoOptions := make(map[int][]string)
+	for _, line := range f.Global {
+		match := repoOptionPattern.FindStringSubmatch(line)
+		if match == nil {
+			generalOptions = append(generalOptions, line)
+			continue
+		}
+		// the pattern only matches digits
+		repo, _ := strconv.Atoi(match[1])
+		repoOptions[repo] = append(repoOptions[repo], line)
+	}
+
+	builder.WriteString("[global]\n")
+	writeLines(&builder, generalOptions)
+
+	repos := make([]int, 0, len(repoOptions))
+	for repo := range repoOptions {
+		repos = append(repos, repo)
+	}
+	slices.Sort(repos)
+	for _, repo := range repos {
+		fmt.Fprintf(&builder, "\n# repo%d\n", repo)
+		writeLines(&builder, repoOptions[repo])
+	}
+
+	if len(f.Stanza) > 0 {
+		fmt.Fprintf(&builder, "\n[%s]\n", f.Stanza)
+		writeLines(&builder, f.StanzaOptions)
+	}
+
+	return builder.String()
+}
+
+func writeLines(builder *strings.Builder, lines []string) {
+	for _, line := range lines {
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configfile
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FromOptions", func() {
+	It("converts separate, inline and boolean options", func() {
+		Expect(FromOptions([]string{
+			"backup",
+			"--stanza", "main",
+			"--repo1-storage-verify-tls=n",
+			"--no-archive-check",
+			"--start-fast",
+		})).To(Equal([]string{
+			"stanza=main",
+			"repo1-storage-verify-tls=n",
+			"archive-check=n",
+			"start-fast=y",
+		}))
+	})
+})
+
+var _ = Describe("FromEnv", func() {
+	It("converts the pgBackRest variables only", func() {
+		Expect(FromEnv([]string{
+			"PATH=/usr/bin",
+			"PGBACKREST_REPO1_S3_REGION=eu-west-1",
+			"PGBACKREST_CONFIG=/tmp/pgbackrest.conf",
+		})).To(Equal([]string{"repo1-s3-region=eu-west-1"}))
+	})
+})
+
+var _ = Describe("Render", func() {
+	It("groups the repository options and renders the stanza section", func() {
+		file := File{
+			Stanza: "main",
+			Global: []string{
+				"repo2-type=s3",
+				"lock-path=/controller/tmp/pgbackrest",
+				"repo1-type=s3",
+				"repo10-type=s3",
+				"repo1-path=/main",
+			},
+			StanzaOptions: []string{"pg1-path=/var/lib/postgresql/data/pgdata"},
+		}
+
+		Expect(file.Render()).To(Equal(`[global]
+lock-path=/controller/tmp/pgbackrest
+
+# repo1
+repo1-type=s3
+repo1-path=/main
+
+# repo2
+repo2-type=s3
+
+# repo10
+repo10-type=s3
+
+[main]
+pg1-path=/var/lib/postgresql/data/pgdata
+`))
+	})
+})
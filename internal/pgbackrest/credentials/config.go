@@ -0,0 +1,105 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/utils"
+)
+
+// SecretsConfigDirectory is the memory-backed directory where the pgBackRest
+// configuration files holding the secure options of each operation are written.
+// The operator mounts an emptyDir volume with the Memory medium there, so the
+// secrets never reach the disk.
+const SecretsConfigDirectory = "/var/run/secrets/pgbackrest.cnpg.opera.com/config"
+
+// secretsConfigDirectory is where the configuration files are written, only
+// changed by the tests
+var secretsConfigDirectory = SecretsConfigDirectory
+
+// secureOptions collects the pgBackRest secure options of an operation, e.g. the S3
+// keys and the cipher passphrase. They are written to a configuration file instead
+// of being exposed in the environment of the pgBackRest processes.
+type secureOptions []string
+
+// add appends a repo-scoped secure option. Values spanning multiple lines can't be
+// represented in a pgBackRest configuration file and are rejected.
+func (o *secureOptions) add(repoIndex int, option string, value []byte) error {
+	if strings.ContainsAny(string(value), "\r\n") {
+		return fmt.Errorf("the value of repo%d-%s must not contain line breaks", repoIndex+1, option)
+	}
+	*o = append(*o, utils.FormatRepoConfigOption(repoIndex, option, string(value)))
+	return nil
+}
+
+// write stores the secure options in a new configuration file, readable by the
+// current user only, and points pgBackRest to it via PGBACKREST_CONFIG, which is
+// equivalent to passing --config to every command run with the returned environment.
+// The returned function removes the file and must be called once the operation
+// is over.
+func (o secureOptions) write(ctx context.Context, env []string) ([]string, func(), error) {
+	if len(o) == 0 {
+		return env, func() {}, nil
+	}
+
+	path, cleanup, err := WriteConfigurationFile(ctx, "[global]\n"+strings.Join(o, "\n")+"\n")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append(env, utils.FormatEnv("CONFIG", path)), cleanup, nil
+}
+
+// WriteConfigurationFile writes a pgBackRest configuration file holding secure
+// options for a single operation. The file is created in SecretsConfigDirectory,
+// readable by the current user only. The returned function removes it and must
+// be called once the operation is over.
+func WriteConfigurationFile(ctx context.Context, content string) (string, func(), error) {
+	if err := os.MkdirAll(secretsConfigDirectory, 0o700); err != nil {
+		return "", nil, fmt.Errorf("while creating directory %s: %w", secretsConfigDirectory, err)
+	}
+
+	// CreateTemp opens the file with mode 0600
+	file, err := os.CreateTemp(secretsConfigDirectory, "pgbackrest-*.conf")
+	if err != nil {
+		return "", nil, fmt.Errorf("while creating pgbackrest configuration file: %w", err)
+	}
+
+	cleanup := func() {
+		if err := os.Remove(file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.FromContext(ctx).Error(err, "while removing pgbackrest configuration file", "path", file.Name())
+		}
+	}
+
+	_, err = file.WriteString(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("while writing pgbackrest configuration file: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
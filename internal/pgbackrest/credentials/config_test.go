@@ -0,0 +1,102 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("secureOptions", func() {
+	BeforeEach(func() {
+		previous := secretsConfigDirectory
+		secretsConfigDirectory = filepath.Join(GinkgoT().TempDir(), "config")
+		DeferCleanup(func() {
+			secretsConfigDirectory = previous
+		})
+	})
+
+	It("rejects values with line breaks", func() {
+		var options secureOptions
+		Expect(options.add(0, "s3-key", []byte("key\nrepo1-path=/other"))).
+			To(MatchError(ContainSubstring("repo1-s3-key must not contain line breaks")))
+		Expect(options.add(1, "s3-key-secret", []byte("secret\r"))).
+			To(MatchError(ContainSubstring("repo2-s3-key-secret must not contain line breaks")))
+		Expect(options).To(BeEmpty())
+	})
+
+	It("leaves the environment untouched without options", func(ctx SpecContext) {
+		var options secureOptions
+		env, cleanup, err := options.write(ctx, []string{"PGBACKREST_STANZA=main"})
+		Expect(err).ToNot(HaveOccurred())
+		cleanup()
+		Expect(env).To(Equal([]string{"PGBACKREST_STANZA=main"}))
+		Expect(secretsConfigDirectory).ToNot(BeADirectory())
+	})
+
+	It("writes the options to a file readable by the current user only", func(ctx SpecContext) {
+		var options secureOptions
+		Expect(options.add(0, "s3-key", []byte("key"))).To(Succeed())
+		Expect(options.add(0, "s3-key-secret", []byte("secret"))).To(Succeed())
+
+		env, cleanup, err := options.write(ctx, []string{"PGBACKREST_STANZA=main"})
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(cleanup)
+
+		Expect(env).To(HaveLen(2))
+		Expect(env[0]).To(Equal("PGBACKREST_STANZA=main"))
+		path, found := strings.CutPrefix(env[1], "PGBACKREST_CONFIG=")
+		Expect(found).To(BeTrue())
+		Expect(filepath.Dir(path)).To(Equal(secretsConfigDirectory))
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+		content, err := os.ReadFile(path) // #nosec G304
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("[global]\nrepo1-s3-key=key\nrepo1-s3-key-secret=secret\n"))
+	})
+
+	It("removes the file once the operation is over", func(ctx SpecContext) {
+		options := secureOptions{"repo1-s3-key=key"}
+		env, cleanup, err := options.write(ctx, nil)
+		Expect(err).ToNot(HaveOccurred())
+		path := strings.TrimPrefix(env[0], "PGBACKREST_CONFIG=")
+		Expect(path).To(BeARegularFile())
+
+		cleanup()
+		Expect(path).ToNot(BeAnExistingFile())
+		// a second call doesn't fail
+		cleanup()
+	})
+
+	It("gives each operation its own file", func(ctx SpecContext) {
+		options := secureOptions{"repo1-s3-key=key"}
+		first, cleanupFirst, err := options.write(ctx, nil)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(cleanupFirst)
+		second, cleanupSecond, err := options.write(ctx, nil)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(cleanupSecond)
+
+		Expect(first).ToNot(Equal(second))
+	})
+})
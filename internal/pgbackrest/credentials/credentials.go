@@ -23,6 +23,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	corev1 "k8s.io/api/core/v1"
@@ -42,6 +44,9 @@ const (
 	// PgBackRestEndpointCACertificateFileName is the base name of the file in
 	// which the pgBackRest endpoint CA certificate is stored.
 	PgBackRestEndpointCACertificateFileName = "pgbackrest-ca.crt"
+
+	awsRoleARNEnv              = "AWS_ROLE_ARN"
+	awsWebIdentityTokenFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
 )
 
 // PgBackRestBackupEndpointCACertificateLocation returns the file path where
@@ -59,77 +64,115 @@ func PgBackRestRestoreEndpointCACertificateLocation(repoIndex int) string {
 }
 
 // EnvSetBackupCloudCredentials sets the AWS environment variables needed for backups
-// given the configuration inside the cluster
+// given the configuration inside the cluster. The secure options are written to a
+// configuration file, and the returned function, which removes it, must be called
+// once the operation is over.
 func EnvSetBackupCloudCredentials(
 	ctx context.Context,
 	c client.Client,
 	namespace string,
 	configuration *pgbackrestApi.PgbackrestConfiguration,
 	env []string,
-) ([]string, error) {
+) ([]string, func(), error) {
+	env, options, err := getBackupCloudCredentials(ctx, c, namespace, configuration, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return options.write(ctx, env)
+}
+
+// GetBackupCloudCredentials returns the environment variables and the secure options,
+// as configuration file lines, needed for backups given the configuration inside the
+// cluster. Unlike EnvSetBackupCloudCredentials, the secure options aren't written
+// anywhere: this is meant to render a complete pgBackRest configuration file.
+func GetBackupCloudCredentials(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	configuration *pgbackrestApi.PgbackrestConfiguration,
+) ([]string, []string, error) {
+	return getBackupCloudCredentials(ctx, c, namespace, configuration, nil)
+}
+
+func getBackupCloudCredentials(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	configuration *pgbackrestApi.PgbackrestConfiguration,
+	env []string,
+) ([]string, secureOptions, error) {
 	for index, repo := range configuration.Repositories {
 		if repo.EndpointCA != nil {
 			caPath := PgBackRestBackupEndpointCACertificateLocation(index)
 			if err := writeEndpointCACertificate(ctx, c, repo.EndpointCA, namespace, caPath); err != nil {
-				return nil, fmt.Errorf("writing backup endpoint CA certificate: %w", err)
+				return nil, nil, fmt.Errorf("writing backup endpoint CA certificate: %w", err)
 			}
 			env = append(env, utils.FormatRepoEnv(index, "STORAGE_CA_FILE", caPath))
 		}
 	}
 
-	return envSetCloudCredentials(ctx, c, namespace, configuration, env)
+	return getCloudCredentials(ctx, c, namespace, configuration, env)
 }
 
 // EnvSetRestoreCloudCredentials sets the AWS environment variables needed for restores
-// given the configuration inside the cluster
+// given the configuration inside the cluster. The secure options are written to a
+// configuration file, and the returned function, which removes it, must be called
+// once the operation is over.
 func EnvSetRestoreCloudCredentials(
 	ctx context.Context,
 	c client.Client,
 	namespace string,
 	configuration *pgbackrestApi.PgbackrestConfiguration,
 	env []string,
-) ([]string, error) {
+) ([]string, func(), error) {
 	for index, repo := range configuration.Repositories {
 		if repo.EndpointCA != nil {
 			caPath := PgBackRestRestoreEndpointCACertificateLocation(index)
 			if err := writeEndpointCACertificate(ctx, c, repo.EndpointCA, namespace, caPath); err != nil {
-				return nil, fmt.Errorf("writing restore endpoint CA certificate: %w", err)
+				return nil, nil, fmt.Errorf("writing restore endpoint CA certificate: %w", err)
 			}
 			env = append(env, utils.FormatRepoEnv(index, "STORAGE_CA_FILE", caPath))
 		}
 	}
 
-	return envSetCloudCredentials(ctx, c, namespace, configuration, env)
+	env, options, err := getCloudCredentials(ctx, c, namespace, configuration, env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return options.write(ctx, env)
 }
 
-// envSetCloudCredentials sets the AWS environment variables given the configuration
-// inside the cluster
-func envSetCloudCredentials(
+// getCloudCredentials sets the AWS environment variables given the configuration
+// inside the cluster, and collects the secure options
+func getCloudCredentials(
 	ctx context.Context,
 	c client.Client,
 	namespace string,
 	configuration *pgbackrestApi.PgbackrestConfiguration,
 	env []string,
-) (envs []string, err error) {
+) (envs []string, options secureOptions, err error) {
 	for index, repo := range configuration.Repositories {
 		if repo.AWS != nil {
-			env, err = envSetAWSCredentials(ctx, c, namespace, repo.AWS, index, env)
+			env, err = envSetAWSCredentials(ctx, c, namespace, repo.AWS, index, env, &options)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 		if len(repo.Encryption) != 0 {
-			env, err = envSetEncryptionCredentials(ctx, c, repo.Encryption, repo.EncryptionKey, namespace, index, env)
+			env, err = envSetEncryptionCredentials(
+				ctx, c, repo.Encryption, repo.EncryptionKey, namespace, index, env, &options)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
-	return env, nil
+	return env, options, nil
 }
 
 // envSetAWSCredentials sets the AWS environment variables given the configuration
-// inside the cluster
+// inside the cluster. The keys are added to the secure options.
 func envSetAWSCredentials(
 	ctx context.Context,
 	client client.Client,
@@ -137,14 +180,17 @@ func envSetAWSCredentials(
 	s3credentials *pgbackrestApi.S3Credentials,
 	repoIndex int,
 	env []string,
+	options *secureOptions,
 ) ([]string, error) {
 	// check if AWS credentials are defined
 	if s3credentials == nil {
 		return nil, fmt.Errorf("missing S3 credentials")
 	}
 
+	keyType := s3credentials.GetKeyType()
+
 	// only check for AWS credential secrets if the key type is shared
-	if s3credentials.KeyType == pgbackrestApi.KeyTypeShared {
+	if keyType == pgbackrestApi.KeyTypeShared {
 		// Get access key ID
 		if s3credentials.AccessKeyIDReference == nil {
 			return nil, fmt.Errorf("missing access key ID")
@@ -173,18 +219,70 @@ func envSetAWSCredentials(
 			return nil, secretAccessErr
 		}
 
-		env = append(env, utils.FormatRepoEnv(repoIndex, "S3_KEY", string(accessKeyID)))
-		env = append(env, utils.FormatRepoEnv(repoIndex, "S3_KEY_SECRET", string(secretAccessKey)))
+		if err := options.add(repoIndex, "s3-key", accessKeyID); err != nil {
+			return nil, err
+		}
+		if err := options.add(repoIndex, "s3-key-secret", secretAccessKey); err != nil {
+			return nil, err
+		}
+	}
+
+	// the customer-provided key is a secure option, it can't be passed as an argument
+	if s3credentials.SSECustomerKeyReference != nil {
+		sseCustomerKey, err := extractValueFromSecret(
+			ctx,
+			client,
+			s3credentials.SSECustomerKeyReference,
+			namespace,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := options.add(repoIndex, "s3-sse-customer-key", sseCustomerKey); err != nil {
+			return nil, err
+		}
 	}
 
-	env = append(env, utils.FormatRepoEnv(repoIndex, "S3_KEY_TYPE", string(s3credentials.KeyType)))
+	if s3credentials.WebIdentity != nil {
+		var err error
+		if env, err = envSetWebIdentity(env, s3credentials.WebIdentity); err != nil {
+			return nil, err
+		}
+	}
+
+	env = append(env, utils.FormatRepoEnv(repoIndex, "S3_KEY_TYPE", string(keyType)))
 	env = append(env, utils.FormatRepoEnv(repoIndex, "S3_REGION", s3credentials.Region))
 
 	return env, nil
 }
 
+// envSetWebIdentity sets the environment variables pgBackRest reads to assume
+// a role with web identity credentials. The role is taken from AWS_ROLE_ARN, as
+// repo-s3-role is only valid with the auto key type. These variables are not
+// repository-scoped, so every repository has to assume the same role with a
+// token of the same audience.
+func envSetWebIdentity(env []string, webIdentity *pgbackrestApi.WebIdentity) ([]string, error) {
+	roleARNEnv := awsRoleARNEnv + "=" + webIdentity.RoleARN
+	tokenFileEnv := awsWebIdentityTokenFileEnv + "=" + webIdentity.GetTokenPath()
+	for _, variable := range env {
+		if strings.HasPrefix(variable, awsRoleARNEnv+"=") && variable != roleARNEnv {
+			return nil, fmt.Errorf("repositories using web identity must assume the same role, found %s and %s",
+				strings.TrimPrefix(variable, awsRoleARNEnv+"="), webIdentity.RoleARN)
+		}
+		if strings.HasPrefix(variable, awsWebIdentityTokenFileEnv+"=") && variable != tokenFileEnv {
+			return nil, fmt.Errorf("repositories using web identity must use the same audience, found %s and %s",
+				strings.TrimPrefix(variable, awsWebIdentityTokenFileEnv+"="), webIdentity.GetTokenPath())
+		}
+	}
+
+	if !slices.Contains(env, roleARNEnv) {
+		env = append(env, roleARNEnv, tokenFileEnv)
+	}
+	return env, nil
+}
+
 // envSetEncryptionCredentials sets the pgbackrest encryption environment variables given
-// the configuration inside the cluster
+// the configuration inside the cluster. The passphrase is added to the secure options.
 func envSetEncryptionCredentials(
 	ctx context.Context,
 	client client.Client,
@@ -193,6 +291,7 @@ func envSetEncryptionCredentials(
 	namespace string,
 	repoIndex int,
 	env []string,
+	options *secureOptions,
 ) ([]string, error) {
 	// check if encryption key is defined
 	if encryptionKeyRef == nil {
@@ -210,7 +309,9 @@ func envSetEncryptionCredentials(
 	}
 
 	env = append(env, utils.FormatRepoEnv(repoIndex, "CIPHER_TYPE", string(encryptionType)))
-	env = append(env, utils.FormatRepoEnv(repoIndex, "CIPHER_PASS", string(encryptionKey)))
+	if err := options.add(repoIndex, "cipher-pass", encryptionKey); err != nil {
+		return nil, err
+	}
 
 	return env, nil
 }
@@ -0,0 +1,64 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package credentials
+
+import (
+	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("envSetWebIdentity", func() {
+	const roleARN = "arn:aws:iam::123456789012:role/pgbackrest"
+
+	It("sets the role and the token of the default audience", func() {
+		env, err := envSetWebIdentity([]string{"PGBACKREST_REPO1_S3_BUCKET=backups"},
+			&pgbackrestApi.WebIdentity{RoleARN: roleARN})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(Equal([]string{
+			"PGBACKREST_REPO1_S3_BUCKET=backups",
+			"AWS_ROLE_ARN=" + roleARN,
+			"AWS_WEB_IDENTITY_TOKEN_FILE=" + pgbackrestApi.WebIdentityTokenDirectory + "/sts.amazonaws.com",
+		}))
+	})
+
+	It("sets the variables once for repositories sharing the role and the audience", func() {
+		webIdentity := &pgbackrestApi.WebIdentity{RoleARN: roleARN, Audience: "minio"}
+		env, err := envSetWebIdentity(nil, webIdentity)
+		Expect(err).ToNot(HaveOccurred())
+		env, err = envSetWebIdentity(env, webIdentity)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(env).To(Equal([]string{
+			"AWS_ROLE_ARN=" + roleARN,
+			"AWS_WEB_IDENTITY_TOKEN_FILE=" + pgbackrestApi.WebIdentityTokenDirectory + "/minio",
+		}))
+	})
+
+	It("rejects repositories assuming different roles", func() {
+		env, err := envSetWebIdentity(nil, &pgbackrestApi.WebIdentity{RoleARN: roleARN})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = envSetWebIdentity(env, &pgbackrestApi.WebIdentity{RoleARN: roleARN + "-other"})
+		Expect(err).To(MatchError(ContainSubstring("must assume the same role")))
+	})
+
+	It("rejects repositories using tokens of different audiences", func() {
+		env, err := envSetWebIdentity(nil, &pgbackrestApi.WebIdentity{RoleARN: roleARN})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = envSetWebIdentity(env, &pgbackrestApi.WebIdentity{RoleARN: roleARN, Audience: "minio"})
+		Expect(err).To(MatchError(ContainSubstring("must use the same audience")))
+	})
+})
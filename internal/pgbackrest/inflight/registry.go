@@ -0,0 +1,92 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package inflight keeps track of the WAL files being archived or restored,
+// so that overlapping batches don't run pgBackRest twice for the same file
+package inflight
+
+import (
+	"context"
+	"sync"
+)
+
+// key identifies an operation on a WAL file
+type key struct {
+	command string
+	walName string
+}
+
+// flight is an operation in progress
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+// Registry is the set of the operations in progress on WAL files.
+//
+// A nil Registry doesn't coordinate anything.
+type Registry struct {
+	mu      sync.Mutex
+	flights map[key]*flight
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{flights: make(map[key]*flight)}
+}
+
+// Do runs fn for the given command and WAL file, unless the same command is
+// already running for that file: in that case it waits for the result of the
+// running one instead. shared reports whether the result comes from an
+// operation started by another caller.
+func (r *Registry) Do(
+	ctx context.Context,
+	command string,
+	walName string,
+	fn func() error,
+) (shared bool, err error) {
+	if r == nil {
+		return false, fn()
+	}
+
+	k := key{command: command, walName: walName}
+
+	r.mu.Lock()
+	if running, ok := r.flights[k]; ok {
+		r.mu.Unlock()
+
+		select {
+		case <-running.done:
+			return true, running.err
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+
+	current := &flight{done: make(chan struct{})}
+	r.flights[k] = current
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.flights, k)
+		r.mu.Unlock()
+		close(current.done)
+	}()
+
+	current.err = fn()
+	return false, current.err
+}
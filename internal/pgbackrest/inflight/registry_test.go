@@ -0,0 +1,126 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflight
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	// running returns the number of operations in progress
+	running := func(registry *Registry) func() int {
+		return func() int {
+			registry.mu.Lock()
+			defer registry.mu.Unlock()
+			return len(registry.flights)
+		}
+	}
+
+	It("runs the operations without a registry", func(ctx SpecContext) {
+		var registry *Registry
+		calls := 0
+		shared, err := registry.Do(ctx, "archive-push", "000000010000000000000001", func() error {
+			calls++
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(shared).To(BeFalse())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("shares the result of the operation in progress", func(ctx SpecContext) {
+		registry := NewRegistry()
+		failure := errors.New("failed")
+		unblock := make(chan struct{})
+
+		leaderDone := make(chan error)
+		go func() {
+			defer GinkgoRecover()
+			shared, err := registry.Do(ctx, "archive-push", "000000010000000000000001", func() error {
+				<-unblock
+				return failure
+			})
+			Expect(shared).To(BeFalse())
+			leaderDone <- err
+		}()
+		Eventually(running(registry)).Should(Equal(1))
+
+		followerDone := make(chan error)
+		go func() {
+			defer GinkgoRecover()
+			shared, err := registry.Do(ctx, "archive-push", "000000010000000000000001", func() error {
+				Fail("the operation in progress should not be repeated")
+				return nil
+			})
+			Expect(shared).To(BeTrue())
+			followerDone <- err
+		}()
+		Consistently(followerDone).ShouldNot(Receive())
+
+		close(unblock)
+		Eventually(leaderDone).Should(Receive(MatchError(failure)))
+		Eventually(followerDone).Should(Receive(MatchError(failure)))
+		Expect(running(registry)()).To(BeZero())
+	})
+
+	It("runs different commands and files independently", func(ctx SpecContext) {
+		registry := NewRegistry()
+		unblock := make(chan struct{})
+		defer close(unblock)
+
+		go func() {
+			_, _ = registry.Do(ctx, "archive-push", "000000010000000000000001", func() error {
+				<-unblock
+				return nil
+			})
+		}()
+		Eventually(running(registry)).Should(Equal(1))
+
+		for _, operation := range [][2]string{
+			{"archive-get", "000000010000000000000001"},
+			{"archive-push", "000000010000000000000002"},
+		} {
+			shared, err := registry.Do(ctx, operation[0], operation[1], func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shared).To(BeFalse())
+		}
+	})
+
+	It("stops waiting when the context is cancelled", func(ctx SpecContext) {
+		registry := NewRegistry()
+		unblock := make(chan struct{})
+		defer close(unblock)
+
+		go func() {
+			_, _ = registry.Do(ctx, "archive-get", "000000010000000000000001", func() error {
+				<-unblock
+				return nil
+			})
+		}()
+		Eventually(running(registry)).Should(Equal(1))
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		shared, err := registry.Do(waitCtx, "archive-get", "000000010000000000000001", func() error { return nil })
+		Expect(shared).To(BeTrue())
+		Expect(err).To(MatchError(context.Canceled))
+	})
+})
@@ -0,0 +1,182 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package limiter limits the number of pgBackRest processes the sidecar runs
+// at once, giving priority to WAL archiving
+package limiter
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Priority is the priority of the work waiting for a slot
+type Priority int
+
+const (
+	// PriorityBackup is the priority of the backups
+	PriorityBackup Priority = iota
+
+	// PriorityPrefetch is the priority of the WAL files restored in advance
+	PriorityPrefetch
+
+	// PriorityRestore is the priority of the WAL files requested by PostgreSQL
+	PriorityRestore
+
+	// PriorityArchive is the priority of WAL archiving
+	PriorityArchive
+)
+
+// waiter is a request for slots waiting to be granted
+type waiter struct {
+	priority Priority
+	weight   int
+	ready    chan struct{}
+}
+
+// Limiter is a weighted semaphore serving the highest priority first. The
+// backups and the prefetched WAL files can't take the whole budget, so that
+// a WAL can always be archived or restored for PostgreSQL while a backup is
+// running. With a budget of one process, that WAL runs next to the backup,
+// exceeding the budget by one process.
+//
+// A nil Limiter doesn't limit anything.
+type Limiter struct {
+	budget int
+
+	mu      sync.Mutex
+	used    int
+	waiters []*waiter
+
+	// background is the number of slots used by the backups and the
+	// prefetched WAL files
+	background int
+}
+
+// New creates a limiter with the given budget, nil when the budget is not positive
+func New(budget int) *Limiter {
+	if budget <= 0 {
+		return nil
+	}
+	return &Limiter{budget: budget}
+}
+
+// isBackground tells whether the work with the given priority can wait for
+// WAL archiving and for the WAL files requested by PostgreSQL
+func isBackground(priority Priority) bool {
+	return priority < PriorityRestore
+}
+
+// limit is the number of slots that can be used by the work with the given
+// priority. The background work always leaves a slot to the rest.
+func (l *Limiter) limit(priority Priority) int {
+	if isBackground(priority) {
+		return max(l.budget-1, 1)
+	}
+	return max(l.budget, l.background+1)
+}
+
+// take marks the slots as used by the work with the given priority
+func (l *Limiter) take(priority Priority, weight int) {
+	l.used += weight
+	if isBackground(priority) {
+		l.background += weight
+	}
+}
+
+// give marks the slots used by the work with the given priority as free
+func (l *Limiter) give(priority Priority, weight int) {
+	l.used -= weight
+	if isBackground(priority) {
+		l.background -= weight
+	}
+}
+
+// Acquire waits for the given number of slots, returning the function releasing
+// them. Requests larger than the budget are reduced to the budget.
+func (l *Limiter) Acquire(ctx context.Context, priority Priority, weight int) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	weight = min(max(weight, 1), l.limit(priority), l.budget)
+
+	l.mu.Lock()
+	index := slices.IndexFunc(l.waiters, func(w *waiter) bool {
+		return w.priority < priority
+	})
+	if index < 0 {
+		index = len(l.waiters)
+	}
+	if index == 0 && l.used+weight <= l.limit(priority) {
+		l.take(priority, weight)
+		l.mu.Unlock()
+		return l.releaseFunc(priority, weight), nil
+	}
+
+	request := &waiter{priority: priority, weight: weight, ready: make(chan struct{})}
+	l.waiters = slices.Insert(l.waiters, index, request)
+	l.mu.Unlock()
+
+	select {
+	case <-request.ready:
+		return l.releaseFunc(priority, weight), nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		select {
+		case <-request.ready:
+			// granted in the meantime
+			l.give(priority, weight)
+		default:
+			l.waiters = slices.DeleteFunc(l.waiters, func(w *waiter) bool {
+				return w == request
+			})
+		}
+		l.grant()
+		return nil, ctx.Err()
+	}
+}
+
+// releaseFunc returns the function releasing the given number of slots once
+func (l *Limiter) releaseFunc(priority Priority, weight int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.give(priority, weight)
+			l.grant()
+		})
+	}
+}
+
+// grant serves the waiters in order, as long as they fit
+func (l *Limiter) grant() {
+	for len(l.waiters) > 0 {
+		next := l.waiters[0]
+		if l.used+next.weight > l.limit(next.priority) {
+			return
+		}
+
+		l.take(next.priority, next.weight)
+		l.waiters = l.waiters[1:]
+		close(next.ready)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package limiter
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	// acquireAsync requests slots in the background, sending the priority on
+	// the channel once they are granted
+	acquireAsync := func(
+		ctx context.Context,
+		limiter *Limiter,
+		priority Priority,
+		weight int,
+		granted chan<- Priority,
+	) {
+		go func() {
+			defer GinkgoRecover()
+			release, err := limiter.Acquire(ctx, priority, weight)
+			if err != nil {
+				return
+			}
+			granted <- priority
+			release()
+		}()
+	}
+
+	waiting := func(limiter *Limiter) func() int {
+		return func() int {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			return len(limiter.waiters)
+		}
+	}
+
+	It("doesn't limit anything without a budget", func(ctx SpecContext) {
+		limiter := New(0)
+		Expect(limiter).To(BeNil())
+
+		release, err := limiter.Acquire(ctx, PriorityBackup, 100)
+		Expect(err).ToNot(HaveOccurred())
+		release()
+	})
+
+	It("keeps a slot for WAL archiving", func(ctx SpecContext) {
+		limiter := New(4)
+
+		releaseBackup, err := limiter.Acquire(ctx, PriorityBackup, 8)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limiter.used).To(Equal(3))
+
+		releaseArchive, err := limiter.Acquire(ctx, PriorityArchive, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		releaseArchive()
+		releaseBackup()
+		Expect(limiter.used).To(BeZero())
+	})
+
+	It("archives and restores the WAL files while a backup holds its slots", func(ctx SpecContext) {
+		limiter := New(2)
+		releaseBackup, err := limiter.Acquire(ctx, PriorityBackup, 2)
+		Expect(err).ToNot(HaveOccurred())
+		defer releaseBackup()
+		Expect(limiter.used).To(Equal(1))
+
+		release, err := limiter.Acquire(ctx, PriorityArchive, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		granted := make(chan Priority, 2)
+		acquireAsync(ctx, limiter, PriorityRestore, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(1))
+		acquireAsync(ctx, limiter, PriorityArchive, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(2))
+
+		release()
+		Eventually(granted).Should(Receive(Equal(PriorityArchive)))
+		Eventually(granted).Should(Receive(Equal(PriorityRestore)))
+		Eventually(func() int {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			return limiter.used
+		}).Should(Equal(1))
+	})
+
+	It("archives a WAL next to a backup holding the only slot", func(ctx SpecContext) {
+		limiter := New(1)
+		releaseBackup, err := limiter.Acquire(ctx, PriorityBackup, 4)
+		Expect(err).ToNot(HaveOccurred())
+		defer releaseBackup()
+
+		release, err := limiter.Acquire(ctx, PriorityArchive, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		granted := make(chan Priority, 2)
+		acquireAsync(ctx, limiter, PriorityRestore, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(1))
+		acquireAsync(ctx, limiter, PriorityPrefetch, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(2))
+
+		release()
+		Eventually(granted).Should(Receive(Equal(PriorityRestore)))
+		Consistently(granted, 50*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("serves WAL archiving before the other work", func(ctx SpecContext) {
+		limiter := New(2)
+		release, err := limiter.Acquire(ctx, PriorityArchive, 2)
+		Expect(err).ToNot(HaveOccurred())
+
+		granted := make(chan Priority, 3)
+		acquireAsync(ctx, limiter, PriorityBackup, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(1))
+		acquireAsync(ctx, limiter, PriorityPrefetch, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(2))
+		acquireAsync(ctx, limiter, PriorityArchive, 1, granted)
+		Eventually(waiting(limiter)).Should(Equal(3))
+
+		release()
+		Expect(<-granted).To(Equal(PriorityArchive))
+		Expect(<-granted).To(Equal(PriorityPrefetch))
+		Expect(<-granted).To(Equal(PriorityBackup))
+	})
+
+	It("gives up waiting when the context is cancelled", func(ctx SpecContext) {
+		limiter := New(1)
+		release, err := limiter.Acquire(ctx, PriorityArchive, 1)
+		Expect(err).ToNot(HaveOccurred())
+
+		waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = limiter.Acquire(waitCtx, PriorityRestore, 1)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(waiting(limiter)()).To(BeZero())
+
+		release()
+		Expect(limiter.used).To(BeZero())
+	})
+})
@@ -18,13 +18,10 @@ package restorer
 
 import (
 	"context"
-	"errors"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 
-	"github.com/cloudnative-pg/machinery/pkg/execlog"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 
 	pgbackrestApi "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/api"
@@ -62,6 +59,12 @@ func (b *Command) GetRestoreConfiguration(
 			strconv.Itoa(int(*b.configuration.Restore.Jobs)))
 	}
 
+	err := pgbackrestCommand.ValidateCommandArgs(
+		pgbackrestCommand.CommandRestore, b.configuration.Restore.AdditionalCommandArgs)
+	if err != nil {
+		return nil, err
+	}
+
 	return b.configuration.Restore.AppendAdditionalRestoreCommandArgs(options), nil
 }
 
@@ -78,7 +81,7 @@ func (b *Command) GetPgbackrestRestoreOptions(
 		options,
 		"--stanza", stanza,
 		"--lock-path",
-		"/controller/tmp/pgbackrest")
+		pgbackrestCommand.LockPath)
 
 	options, err := b.GetRestoreConfiguration(options)
 	if err != nil {
@@ -133,16 +136,14 @@ func (b *Command) Restore(
 
 	log.Info("Starting pgbackrest restore", "options", options)
 
-	cmd := exec.Command("pgbackrest", options...) // #nosec G204
-	cmd.Env = env
-	err = execlog.RunStreaming(cmd, "pgbackrest restore")
+	err = pgbackrestCommand.Run(ctx, pgbackrestCommand.Execution{
+		Command: pgbackrestCommand.CommandRestore,
+		Options: options,
+		Env:     env,
+		Timeout: b.configuration.GetCommandTimeout(pgbackrestCommand.CommandRestore),
+	})
 	if err != nil {
-		var exitError *exec.ExitError
-		if errors.As(err, &exitError) {
-			err = pgbackrestCommand.UnmarshalPgbackrestRestoreExitCode(ctx, exitError.ExitCode())
-		}
-
-		log.Error(err, "Can't restore backup")
+		log.Error(err, "Can't restore backup", "retriable", pgbackrestCommand.IsRetriable(err))
 		return err
 	}
 	log.Info("Restore completed")
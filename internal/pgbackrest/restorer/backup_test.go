@@ -81,6 +81,15 @@ var _ = Describe("GetPgbackrestRestoreOptions", func() {
 			)
 	})
 
+	It("should not set a target", func(ctx SpecContext) {
+		command := NewRestoreCommand(pluginConfig, pgDataDir)
+
+		options, err := command.GetPgbackrestRestoreOptions(ctx, backupName, stanza)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).ToNot(ContainElement(HavePrefix("--type")))
+	})
+
 	It("should include job parallelism", func(ctx SpecContext) {
 		jobs := int32(4)
 		pluginConfig.Restore = &pgbackrestApi.DataRestoreConfiguration{
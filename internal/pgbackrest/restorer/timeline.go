@@ -0,0 +1,59 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restorer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/catalog"
+)
+
+// TimelineHistoryGetter returns a function getting the history files of the
+// timelines from the archive, downloading them into the given directory
+func (restorer *WALRestorer) TimelineHistoryGetter(
+	ctx context.Context,
+	directory string,
+	options []string,
+) catalog.TimelineHistoryGetter {
+	return func(timeline int64) (*catalog.TimelineHistory, error) {
+		fileName := catalog.TimelineHistoryFileName(timeline)
+		destinationPath := path.Join(directory, fileName)
+		defer func() {
+			_ = os.Remove(destinationPath)
+		}()
+
+		err := restorer.Restore(ctx, fileName, destinationPath, options)
+		if errors.Is(err, ErrWALNotFound) {
+			return nil, catalog.ErrTimelineHistoryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// G304: the file name is built from the timeline
+		//nolint:gosec
+		content, err := os.ReadFile(destinationPath)
+		if err != nil {
+			return nil, fmt.Errorf("while reading %s: %w", fileName, err)
+		}
+		return catalog.ParseTimelineHistory(timeline, string(content))
+	}
+}
@@ -27,9 +27,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/cloudnative-pg/machinery/pkg/execlog"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/spool"
 )
 
@@ -52,6 +54,19 @@ type WALRestorer struct {
 
 	// The environment that should be used to invoke pgbackrest archive-get
 	env []string
+
+	// The timeout of each pgbackrest archive-get, zero meaning no limit
+	timeout time.Duration
+
+	// Limits the number of pgBackRest processes running at once
+	limiter *limiter.Limiter
+
+	// Keeps the WAL files being restored by overlapping batches from being
+	// downloaded twice
+	inFlight *inflight.Registry
+
+	// Keeps the spool within its quota, prefetching less when it is near
+	janitor *spool.Janitor
 }
 
 // Result is the structure filled by the restore process on completion
@@ -77,6 +92,10 @@ func NewWALRestorer(
 	ctx context.Context,
 	env []string,
 	spoolDirectory string,
+	timeout time.Duration,
+	processLimiter *limiter.Limiter,
+	inFlight *inflight.Registry,
+	spoolJanitor *spool.Janitor,
 ) (restorer *WALRestorer, err error) {
 	contextLog := log.FromContext(ctx)
 	var walRecoverSpool *spool.WALSpool
@@ -87,8 +106,12 @@ func NewWALRestorer(
 	}
 
 	restorer = &WALRestorer{
-		spool: walRecoverSpool,
-		env:   env,
+		spool:    walRecoverSpool,
+		env:      env,
+		timeout:  timeout,
+		limiter:  processLimiter,
+		inFlight: inFlight,
+		janitor:  spoolJanitor,
 	}
 	return restorer, nil
 }
@@ -150,15 +173,25 @@ func (restorer *WALRestorer) ResetEndOfWalStream() error {
 }
 
 // RestoreList restores a list of WALs. The first WAL of the list will go directly into the
-// destination path, the others will be adopted by the spool
+// destination path, the others will be adopted by the spool, as long as it has room for them
 func (restorer *WALRestorer) RestoreList(
 	ctx context.Context,
 	fetchList []string,
 	destinationPath string,
 	options []string,
 ) (resultList []Result) {
-	resultList = make([]Result, len(fetchList))
 	contextLog := log.FromContext(ctx)
+	if len(fetchList) > 1 {
+		if prefetch := restorer.janitor.PrefetchLimit(len(fetchList) - 1); prefetch < len(fetchList)-1 {
+			contextLog.Info("Prefetching fewer WAL files as the spool is near its quota",
+				"requested", len(fetchList)-1,
+				"prefetch", prefetch,
+				"spoolQuota", restorer.janitor.Quota())
+			fetchList = fetchList[:prefetch+1]
+		}
+	}
+
+	resultList = make([]Result, len(fetchList))
 	var waitGroup sync.WaitGroup
 
 	for idx := range fetchList {
@@ -190,8 +223,14 @@ func (restorer *WALRestorer) RestoreList(
 				}
 			}
 
+			// The WAL PostgreSQL is waiting for goes before the prefetched ones
+			priority := limiter.PriorityPrefetch
+			if walIndex == 0 {
+				priority = limiter.PriorityRestore
+			}
+
 			result.StartTime = time.Now()
-			result.Err = restorer.Restore(ctx, fetchList[walIndex], result.DestinationPath, options)
+			result.Err = restorer.restoreOnce(ctx, priority, fetchList[walIndex], result.DestinationPath, options)
 			result.EndTime = time.Now()
 
 			elapsedWalTime := result.EndTime.Sub(result.StartTime)
@@ -240,6 +279,66 @@ func (restorer *WALRestorer) Restore(
 	ctx context.Context,
 	walName, destinationPath string,
 	baseOptions []string,
+) error {
+	return restorer.restore(ctx, limiter.PriorityRestore, walName, destinationPath, baseOptions)
+}
+
+// restoreOnce restores a WAL file from the object store, unless it is already
+// being restored by another batch. In that case it waits for that batch and,
+// when the WAL is needed by PostgreSQL, takes it from the spool.
+func (restorer *WALRestorer) restoreOnce(
+	ctx context.Context,
+	priority limiter.Priority,
+	walName, destinationPath string,
+	options []string,
+) error {
+	var shared bool
+	var err error
+	for {
+		shared, err = restorer.inFlight.Do(ctx, pgbackrestCommand.CommandArchiveGet, walName, func() error {
+			return restorer.restore(ctx, priority, walName, destinationPath, options)
+		})
+		// The request of the other batch being cancelled doesn't concern this
+		// one, which restores the WAL itself or waits for another batch again
+		if !shared || ctx.Err() != nil || !isCancellation(err) {
+			break
+		}
+	}
+	if err != nil || !shared || priority == limiter.PriorityPrefetch {
+		// A WAL prefetched by another batch is either in the spool already or
+		// has been restored for PostgreSQL
+		return err
+	}
+
+	wasInSpool, err := restorer.RestoreFromSpool(walName, destinationPath)
+	if err != nil {
+		return err
+	}
+	if wasInSpool {
+		return nil
+	}
+
+	// The other batch restored it to a different destination
+	return restorer.restore(ctx, priority, walName, destinationPath, options)
+}
+
+// isCancellation tells whether the error comes from the cancellation of the
+// request which restored the WAL file, rather than from the WAL file itself
+func isCancellation(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgbackrestError *pgbackrestCommand.PgbackrestError
+	return errors.As(err, &pgbackrestError) && pgbackrestError.Category == pgbackrestCommand.ErrorCategoryTerminated
+}
+
+// restore restores a WAL file from the object store, once the limiter lets it
+// run with the given priority
+func (restorer *WALRestorer) restore(
+	ctx context.Context,
+	priority limiter.Priority,
+	walName, destinationPath string,
+	baseOptions []string,
 ) error {
 	contextLogger := log.FromContext(ctx)
 
@@ -251,12 +350,18 @@ func (restorer *WALRestorer) Restore(
 	copy(options, baseOptions)
 	options = append(options, "archive-get", walName, destinationPath)
 
-	pgbackrestWalRestoreCmd := exec.Command(
-		"pgbackrest",
-		options...) // #nosec G204
-	pgbackrestWalRestoreCmd.Env = restorer.env
-
-	err := execlog.RunStreaming(pgbackrestWalRestoreCmd, "pgbackrest archive-get")
+	release, err := restorer.limiter.Acquire(ctx, priority, 1)
+	if err != nil {
+		return fmt.Errorf("while waiting to restore %q: %w", walName, err)
+	}
+	defer release()
+
+	err = pgbackrestCommand.Run(ctx, pgbackrestCommand.Execution{
+		Command: pgbackrestCommand.CommandArchiveGet,
+		Options: options,
+		Env:     restorer.env,
+		Timeout: restorer.timeout,
+	})
 	if err == nil {
 		return nil
 	}
@@ -274,16 +379,9 @@ func (restorer *WALRestorer) Restore(
 // returning ErrWALNotFound when the requested WAL is missing from the archive.
 func walRestoreError(walName string, err error) error {
 	var exitError *exec.ExitError
-	if !errors.As(err, &exitError) {
-		return fmt.Errorf("unexpected failure retrieving %q with %s: %w",
-			walName, "pgbackrest archive-get", err)
-	}
-
-	if exitError.ExitCode() == walNotFoundExitCode {
+	if errors.As(err, &exitError) && exitError.ExitCode() == walNotFoundExitCode {
 		return ErrWALNotFound
 	}
 
-	return fmt.Errorf("encountered an error: '%d' while executing %s",
-		exitError.ExitCode(),
-		"pgbackrest archive-get")
+	return fmt.Errorf("while retrieving %q: %w", walName, err)
 }
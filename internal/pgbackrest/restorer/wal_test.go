@@ -17,9 +17,16 @@ limitations under the License.
 package restorer
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -36,6 +43,12 @@ var _ = Describe("walRestoreError", func() {
 		Expect(walRestoreError(walName, exitError(walNotFoundExitCode))).To(MatchError(ErrWALNotFound))
 	})
 
+	It("returns ErrWALNotFound when the failure is reported as a pgbackrest error", func() {
+		err := pgbackrestCommand.NewPgbackrestError(
+			pgbackrestCommand.CommandArchiveGet, exitError(walNotFoundExitCode), nil)
+		Expect(walRestoreError(walName, err)).To(MatchError(ErrWALNotFound))
+	})
+
 	It("returns a generic error for other non-zero exit codes", func() {
 		err := walRestoreError(walName, exitError(103))
 
@@ -53,3 +66,96 @@ var _ = Describe("walRestoreError", func() {
 		Expect(err).NotTo(MatchError(ErrWALNotFound))
 	})
 })
+
+var _ = Describe("restoreOnce", func() {
+	const walName = "000000010000000000000001"
+
+	It("takes the WAL prefetched by another batch from the spool", func(ctx SpecContext) {
+		registry := inflight.NewRegistry()
+		restorer, err := NewWALRestorer(ctx, nil, GinkgoT().TempDir(), 0, nil, registry, nil)
+		Expect(err).ToNot(HaveOccurred())
+		destinationPath := filepath.Join(GinkgoT().TempDir(), "RECOVERYXLOG")
+
+		// another batch is prefetching the WAL into the spool
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		prefetchDone := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(prefetchDone)
+			_, err := registry.Do(ctx, pgbackrestCommand.CommandArchiveGet, walName, func() error {
+				close(started)
+				<-unblock
+				return os.WriteFile(restorer.spool.FileName(walName), []byte("wal"), 0o600)
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}()
+		Eventually(started).Should(BeClosed())
+
+		restoreDone := make(chan error)
+		go func() {
+			restoreDone <- restorer.restoreOnce(ctx, limiter.PriorityRestore, walName, destinationPath, nil)
+		}()
+		Consistently(restoreDone).ShouldNot(Receive())
+
+		close(unblock)
+		Eventually(restoreDone).Should(Receive(BeNil()))
+		Eventually(prefetchDone).Should(BeClosed())
+		Expect(destinationPath).To(BeAnExistingFile())
+		Expect(restorer.spool.FileName(walName)).ToNot(BeAnExistingFile())
+	})
+
+	It("shares the failure of the batch restoring the WAL", func(ctx SpecContext) {
+		registry := inflight.NewRegistry()
+		restorer, err := NewWALRestorer(ctx, nil, GinkgoT().TempDir(), 0, nil, registry, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		go func() {
+			_, _ = registry.Do(ctx, pgbackrestCommand.CommandArchiveGet, walName, func() error {
+				close(started)
+				<-unblock
+				return ErrWALNotFound
+			})
+		}()
+		Eventually(started).Should(BeClosed())
+
+		restoreDone := make(chan error)
+		go func() {
+			restoreDone <- restorer.restoreOnce(ctx, limiter.PriorityPrefetch, walName, "unused", nil)
+		}()
+		Consistently(restoreDone).ShouldNot(Receive())
+		close(unblock)
+		Eventually(restoreDone).Should(Receive(MatchError(ErrWALNotFound)))
+	})
+
+	It("restores the WAL again when the batch restoring it is cancelled", func(ctx SpecContext) {
+		registry := inflight.NewRegistry()
+		restorer, err := NewWALRestorer(ctx, nil, GinkgoT().TempDir(), 0, nil, registry, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		go func() {
+			_, _ = registry.Do(ctx, pgbackrestCommand.CommandArchiveGet, walName, func() error {
+				close(started)
+				<-unblock
+				return fmt.Errorf("while waiting to restore %q: %w", walName, context.Canceled)
+			})
+		}()
+		Eventually(started).Should(BeClosed())
+
+		restoreDone := make(chan error)
+		go func() {
+			restoreDone <- restorer.restoreOnce(ctx, limiter.PriorityPrefetch, walName, "unused", nil)
+		}()
+		Consistently(restoreDone).ShouldNot(Receive())
+		close(unblock)
+
+		// restoring the WAL itself fails, as there is no pgBackRest to run
+		var restoreErr error
+		Eventually(restoreDone).Should(Receive(&restoreErr))
+		Expect(restoreErr).ToNot(MatchError(context.Canceled))
+	})
+})
@@ -0,0 +1,363 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spool
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+)
+
+const (
+	// DefaultMaxAge is how long an entry stays in the spool when no maximum
+	// age is configured. Prefetched WAL files and archive markers are used
+	// within seconds, so older entries were abandoned.
+	DefaultMaxAge = time.Hour
+
+	// cleanInterval is how often the janitor cleans the spool
+	cleanInterval = time.Minute
+
+	// defaultWALFileSize is the size assumed for the WAL files to prefetch
+	// until one is found in the spool
+	defaultWALFileSize = int64(16 << 20)
+
+	// defaultCommandTimeout is how long an entry may still be written by the
+	// pgBackRest command creating it, when that command has no timeout. As
+	// the entries being written are modified continuously, it doesn't need
+	// to be longer than the download of a WAL file.
+	defaultCommandTimeout = time.Minute
+
+	// walFileNameLength is the length of the name of a WAL segment, made of
+	// the timeline and the position of the segment
+	walFileNameLength = 24
+)
+
+// Usage is the space taken by the files in the spool
+type Usage struct {
+	// The number of files in the spool
+	Files int
+
+	// The size of the files in the spool
+	Bytes int64
+}
+
+// entry is a file in the spool
+type entry struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// Janitor evicts the entries left in the spool: the WAL files before the one
+// PostgreSQL is replaying, the entries older than the maximum age and, once
+// the quota is exceeded, the prefetched WAL files which would be needed last,
+// followed by the oldest other entries. The entries which may still be
+// written by a pgBackRest command are kept within the quota.
+//
+// A nil Janitor doesn't clean anything.
+type Janitor struct {
+	spool  *WALSpool
+	maxAge time.Duration
+	quota  int64
+
+	mu sync.Mutex
+
+	// replayed is the position of the last WAL file requested by PostgreSQL
+	replayed string
+
+	// commandTimeout is the timeout of the commands writing into the spool,
+	// zero meaning defaultCommandTimeout
+	commandTimeout time.Duration
+
+	// usage is the space taken by the spool after the last cleaning
+	usage Usage
+
+	// walFileSize is the size of the largest WAL file last found in the spool
+	walFileSize int64
+}
+
+// NewJanitor creates a janitor for the given spool directory. A zero maxAge
+// means DefaultMaxAge, and a zero quota no quota.
+func NewJanitor(spoolDirectory string, maxAge time.Duration, quota int64) (*Janitor, error) {
+	spool, err := New(spoolDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Janitor{
+		spool:       spool,
+		maxAge:      maxAge,
+		quota:       max(quota, 0),
+		walFileSize: defaultWALFileSize,
+	}, nil
+}
+
+// walPosition returns the position of a WAL segment, which doesn't depend on
+// the timeline, or false when the name is not the one of a WAL segment
+func walPosition(name string) (string, bool) {
+	if len(name) != walFileNameLength {
+		return "", false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return "", false
+		}
+	}
+	return name[8:], true
+}
+
+// Replayed records the WAL file requested by PostgreSQL, so that the WAL
+// files before it are evicted
+func (j *Janitor) Replayed(walName string) {
+	if j == nil {
+		return
+	}
+
+	position, ok := walPosition(path.Base(walName))
+	if !ok {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.replayed = position
+}
+
+// SetCommandTimeout records the timeout of the pgBackRest commands writing
+// into the spool, zero meaning they have none
+func (j *Janitor) SetCommandTimeout(timeout time.Duration) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.commandTimeout = timeout
+}
+
+// Start cleans the spool periodically until the context is cancelled
+func (j *Janitor) Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithName("spool-janitor")
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	ticker := time.NewTicker(cleanInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.Clean(ctx); err != nil {
+			contextLogger.Error(err, "while cleaning the spool")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean evicts the stale entries of the spool and the ones exceeding the
+// quota, returning the space taken by the remaining ones
+func (j *Janitor) Clean(ctx context.Context) (Usage, error) {
+	if j == nil {
+		return Usage{}, nil
+	}
+	contextLogger := log.FromContext(ctx)
+
+	entries, err := j.spool.entries()
+	if err != nil {
+		return Usage{}, err
+	}
+
+	j.mu.Lock()
+	replayed := j.replayed
+	commandTimeout := j.commandTimeout
+	j.mu.Unlock()
+	if commandTimeout <= 0 {
+		commandTimeout = defaultCommandTimeout
+	}
+
+	var errs []error
+	evict := func(e entry, reason string) {
+		if err := j.spool.Remove(e.name); err != nil && !errors.Is(err, ErrorNonExistentFile) {
+			errs = append(errs, err)
+			return
+		}
+		contextLogger.Info("Evicted spool entry", "name", e.name, "size", e.size, "reason", reason)
+	}
+
+	now := time.Now()
+	kept := entries[:0]
+	for _, e := range entries {
+		position, isWAL := walPosition(e.name)
+		switch {
+		case isWAL && replayed != "" && position < replayed:
+			evict(e, "replayed")
+		case now.Sub(e.modTime) > j.maxAge:
+			evict(e, "maxAge")
+		default:
+			kept = append(kept, e)
+		}
+	}
+
+	usage := usageOf(kept)
+	if j.quota > 0 && usage.Bytes > j.quota {
+		// The WAL files which are further from the replay position are needed
+		// last, so they go first
+		slices.SortFunc(kept, compareEvictionOrder)
+		remaining := kept[:0]
+		for _, e := range kept {
+			// The entries modified recently may still be written by pgBackRest,
+			// or have just been fetched for PostgreSQL
+			isRecent := now.Sub(e.modTime) <= commandTimeout
+			if usage.Bytes > j.quota && e.size > 0 && !isRecent {
+				evict(e, "quota")
+				usage.Files--
+				usage.Bytes -= e.size
+				continue
+			}
+			remaining = append(remaining, e)
+		}
+		kept = remaining
+	}
+
+	j.record(kept)
+	return usage, errors.Join(errs...)
+}
+
+// compareEvictionOrder sorts the WAL files by decreasing position, before
+// the other files, like the history files, sorted from the oldest
+func compareEvictionOrder(a, b entry) int {
+	positionA, isWALA := walPosition(a.name)
+	positionB, isWALB := walPosition(b.name)
+	switch {
+	case isWALA && isWALB:
+		return -strings.Compare(positionA, positionB)
+	case isWALA:
+		return -1
+	case isWALB:
+		return 1
+	default:
+		return a.modTime.Compare(b.modTime)
+	}
+}
+
+// record keeps the usage of the spool and the size of its WAL files
+func (j *Janitor) record(entries []entry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.usage = usageOf(entries)
+
+	// The archive markers are empty, and the WAL files being downloaded are
+	// smaller than a segment
+	largest := int64(0)
+	for _, e := range entries {
+		if _, isWAL := walPosition(e.name); isWAL {
+			largest = max(largest, e.size)
+		}
+	}
+	if largest > 0 {
+		j.walFileSize = largest
+	}
+}
+
+// usageOf computes the space taken by the given entries
+func usageOf(entries []entry) Usage {
+	usage := Usage{Files: len(entries)}
+	for _, e := range entries {
+		usage.Bytes += e.size
+	}
+	return usage
+}
+
+// Usage returns the space taken by the spool after the last cleaning
+func (j *Janitor) Usage() Usage {
+	if j == nil {
+		return Usage{}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.usage
+}
+
+// Quota returns the maximum size of the spool, zero meaning no quota
+func (j *Janitor) Quota() int64 {
+	if j == nil {
+		return 0
+	}
+	return j.quota
+}
+
+// PrefetchLimit returns how many of the wanted WAL files can be prefetched
+// into the spool without exceeding the quota
+func (j *Janitor) PrefetchLimit(wanted int) int {
+	if j == nil || j.quota == 0 || wanted <= 0 {
+		return wanted
+	}
+
+	entries, err := j.spool.entries()
+	if err != nil {
+		return wanted
+	}
+	j.record(entries)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	available := j.quota - j.usage.Bytes
+	if available <= 0 {
+		return 0
+	}
+	return int(min(int64(wanted), available/j.walFileSize))
+}
+
+// entries lists the regular files in the spool
+func (spool *WALSpool) entries() ([]entry, error) {
+	dirEntries, err := os.ReadDir(spool.spoolDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// consumed in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{name: info.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+	return entries, nil
+}
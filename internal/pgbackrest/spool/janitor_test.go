@@ -0,0 +1,142 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spool
+
+import (
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Janitor", func() {
+	var spoolDirectory string
+
+	BeforeEach(func() {
+		spoolDirectory = GinkgoT().TempDir()
+	})
+
+	writeFile := func(name string, size int, age time.Duration) {
+		fileName := path.Join(spoolDirectory, name)
+		Expect(os.WriteFile(fileName, make([]byte, size), 0o600)).To(Succeed())
+		modTime := time.Now().Add(-age)
+		Expect(os.Chtimes(fileName, modTime, modTime)).To(Succeed())
+	}
+
+	listFiles := func() []string {
+		entries, err := os.ReadDir(spoolDirectory)
+		Expect(err).ToNot(HaveOccurred())
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	It("evicts the WAL files before the replay position", func(ctx SpecContext) {
+		janitor, err := NewJanitor(spoolDirectory, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("000000010000000000000001", 10, 0)
+		writeFile("000000020000000000000002", 10, 0)
+		writeFile("000000020000000000000004", 10, 0)
+		writeFile("end-of-wal-stream", 0, 0)
+
+		janitor.Replayed("000000020000000000000003")
+		usage, err := janitor.Clean(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listFiles()).To(ConsistOf("000000020000000000000004", "end-of-wal-stream"))
+		Expect(usage).To(Equal(Usage{Files: 2, Bytes: 10}))
+		Expect(janitor.Usage()).To(Equal(usage))
+	})
+
+	It("evicts the entries older than the maximum age", func(ctx SpecContext) {
+		janitor, err := NewJanitor(spoolDirectory, time.Minute, 0)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("000000010000000000000001", 0, time.Hour)
+		writeFile("000000010000000000000002", 10, 0)
+
+		_, err = janitor.Clean(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listFiles()).To(ConsistOf("000000010000000000000002"))
+	})
+
+	It("evicts the WAL files needed last to stay within the quota", func(ctx SpecContext) {
+		janitor, err := NewJanitor(spoolDirectory, 0, 25)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("000000010000000000000001", 10, 10*time.Minute)
+		writeFile("000000010000000000000002", 10, 10*time.Minute)
+		writeFile("000000010000000000000003", 10, 10*time.Minute)
+		writeFile("000000010000000000000004", 0, 10*time.Minute)
+
+		usage, err := janitor.Clean(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listFiles()).To(ConsistOf(
+			"000000010000000000000001",
+			"000000010000000000000002",
+			"000000010000000000000004",
+		))
+		Expect(usage).To(Equal(Usage{Files: 3, Bytes: 20}))
+	})
+
+	It("evicts the oldest other files after the WAL files, keeping the recent ones", func(ctx SpecContext) {
+		janitor, err := NewJanitor(spoolDirectory, 0, 25)
+		Expect(err).ToNot(HaveOccurred())
+		janitor.SetCommandTimeout(5 * time.Minute)
+
+		writeFile("000000010000000000000001", 10, 10*time.Minute)
+		writeFile("000000010000000000000002", 10, 2*time.Minute)
+		writeFile("00000002.history", 10, 20*time.Minute)
+		writeFile("000000010000000000000003.partial", 10, 30*time.Minute)
+		writeFile("000000020000000000000003.partial", 10, time.Minute)
+
+		usage, err := janitor.Clean(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(listFiles()).To(ConsistOf(
+			"000000010000000000000002",
+			"000000020000000000000003.partial",
+		))
+		Expect(usage).To(Equal(Usage{Files: 2, Bytes: 20}))
+	})
+
+	It("prefetches fewer WAL files when the quota is near", func() {
+		janitor, err := NewJanitor(spoolDirectory, 0, 35)
+		Expect(err).ToNot(HaveOccurred())
+
+		writeFile("000000010000000000000001", 10, 0)
+		writeFile("000000010000000000000002", 10, 0)
+		Expect(janitor.PrefetchLimit(4)).To(Equal(1))
+
+		writeFile("000000010000000000000003", 10, 0)
+		writeFile("000000010000000000000004", 10, 0)
+		Expect(janitor.PrefetchLimit(4)).To(Equal(0))
+	})
+
+	It("doesn't limit anything without a quota", func() {
+		janitor, err := NewJanitor(spoolDirectory, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(janitor.PrefetchLimit(4)).To(Equal(4))
+
+		var nilJanitor *Janitor
+		Expect(nilJanitor.PrefetchLimit(4)).To(Equal(4))
+		Expect(nilJanitor.Usage()).To(BeZero())
+	})
+})
@@ -20,12 +20,22 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
 )
 
 // PgbackrestServiceEnvVarPattern should match all service discovery environment variables injected to
 // the pod by Kubernetes for services with names starting with "pgbackrest".
 var PgbackrestServiceEnvVarPattern = regexp.MustCompile("^PGBACKREST_(?:[A-Z0-9]+_)*(?:PORT|SERVICE)")
 
+// PgbackrestSecureEnvVarPattern matches the variables setting the pgBackRest
+// secure options, like the repository keys, with or without a repository index.
+// The plugin writes these options to a configuration file readable by the
+// pgBackRest process only, so they aren't passed down in the environment.
+var PgbackrestSecureEnvVarPattern = regexp.MustCompile(
+	"^PGBACKREST_(?:REPO[0-9]*_)?(?:CIPHER_PASS|AZURE_KEY|S3_KEY|S3_KEY_SECRET|S3_SSE_CUSTOMER_KEY|S3_TOKEN|" +
+		"SFTP_PRIVATE_KEY_PASSPHRASE)=")
+
 // FormatEnv takes an environment variable name and its value. It returns a properly
 // formatted variable with a prefix used by pgBackRest to detect its config variables.
 // Returned value is ready to be passed as a part of the command's env array.
@@ -49,21 +59,69 @@ func FormatDbEnv(database int, env string, value string) string {
 	return fmt.Sprintf("PGBACKREST_PG%d_%s=%s", database, env, value)
 }
 
-func stripServicesFromEnv(env []string) (filteredEnv []string) {
+// FormatRepoConfigOption takes a zero-based repository index, an option name and
+// its value. It returns a properly formatted repo-scoped option ready to be written
+// to a pgBackRest configuration file.
+func FormatRepoConfigOption(repository int, option string, value string) string {
+	return fmt.Sprintf("repo%d-%s=%s", repository+1, option, value)
+}
+
+// allowedEnvNames lists the variables of the plugin process that are passed
+// down to pgBackRest as they are.
+var allowedEnvNames = []string{
+	"HOME",
+	"LANG",
+	"PATH",
+	"PGDATA",
+	"PGHOST",
+	"PGPORT",
+	"PGUSER",
+	"SSL_CERT_DIR",
+	"SSL_CERT_FILE",
+	"TMPDIR",
+	"TZ",
+	// Web identity credentials injected by the cloud provider, e.g. by EKS
+	// for IAM Roles for Service Accounts.
+	"AWS_ROLE_ARN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+}
+
+// isAllowedEnv reports whether the given variable may be passed down to pgBackRest.
+// Other than the allowed names, only the pgBackRest options that users set on the
+// sidecar and the locale settings are kept. PGBACKREST_CONFIG, pointing to the
+// configuration file meant for interactive use, is dropped: every operation is
+// given its own. So are the secure options, which would be readable from the
+// environment of every pgBackRest process.
+func isAllowedEnv(variable string) bool {
+	name, _, _ := strings.Cut(variable, "=")
+	if name == "PGBACKREST_CONFIG" {
+		return false
+	}
+	if strings.HasPrefix(name, "PGBACKREST_") {
+		return !PgbackrestServiceEnvVarPattern.MatchString(variable) &&
+			!PgbackrestSecureEnvVarPattern.MatchString(variable)
+	}
+	return strings.HasPrefix(name, "LC_") || slices.Contains(allowedEnvNames, name)
+}
+
+func filterEnv(env []string) (filteredEnv []string) {
 	for _, variable := range env {
-		if !PgbackrestServiceEnvVarPattern.MatchString(variable) {
+		if isAllowedEnv(variable) {
 			filteredEnv = append(filteredEnv, variable)
 		}
 	}
 	return filteredEnv
 }
 
-// SanitizedEnviron returns a copy of the environment variables list with variables
-// added for Kubernetes services with names starting from "pgbackrest" removed.
-// Those variables cause pgbackrest to output warnings during configuration parsing
-// and those messages always go to the standard output causing issues with
+// SanitizedEnviron returns a copy of the environment variables list restricted to
+// the variables pgBackRest needs. Everything else the sidecar was started with,
+// including the environment of the PostgreSQL container, is not passed down to the
+// pgBackRest processes, where it would be readable from /proc/<pid>/environ.
+// Variables added for Kubernetes services with names starting from "pgbackrest" are
+// removed too. Those variables cause pgbackrest to output warnings during configuration
+// parsing and those messages always go to the standard output causing issues with
 // "pgbackrest info" calls as those should only output JSON.
 // In addition, removing them makes log entries much clearer.
 func SanitizedEnviron() []string {
-	return stripServicesFromEnv(os.Environ())
+	return filterEnv(os.Environ())
 }
@@ -0,0 +1,64 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package utils
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("isAllowedEnv", func() {
+	DescribeTable("filters the variables passed down to pgBackRest",
+		func(variable string, allowed bool) {
+			Expect(isAllowedEnv(variable)).To(Equal(allowed))
+		},
+		Entry("allowed name", "PGDATA=/var/lib/postgresql/data/pgdata", true),
+		Entry("web identity role", "AWS_ROLE_ARN=arn:aws:iam::123456789012:role/pgbackrest", true),
+		Entry("locale setting", "LC_ALL=C.UTF-8", true),
+		Entry("pgBackRest option", "PGBACKREST_PROCESS_MAX=4", true),
+		Entry("repo-scoped pgBackRest option", "PGBACKREST_REPO1_S3_BUCKET=backups", true),
+		Entry("repo-scoped secure option", "PGBACKREST_REPO1_S3_KEY_SECRET=secret", false),
+		Entry("repo-scoped encryption key", "PGBACKREST_REPO2_CIPHER_PASS=secret", false),
+		Entry("secure option without a repository index", "PGBACKREST_REPO_S3_TOKEN=secret", false),
+		Entry("option named like a secure option", "PGBACKREST_REPO1_S3_KEY_TYPE=shared", true),
+		Entry("interactive configuration file", "PGBACKREST_CONFIG=/controller/pgbackrest.conf", false),
+		Entry("service port", "PGBACKREST_PORT=tcp://10.0.0.1:8432", false),
+		Entry("named service host", "PGBACKREST_REPO_SERVICE_HOST=10.0.0.1", false),
+		Entry("static AWS keys", "AWS_SECRET_ACCESS_KEY=secret", false),
+		Entry("PostgreSQL container variable", "POSTGRES_PASSWORD=secret", false),
+		Entry("name prefix of an allowed name", "PATHS=/tmp", false),
+	)
+})
+
+var _ = Describe("filterEnv", func() {
+	It("keeps the allowed variables in order", func() {
+		Expect(filterEnv([]string{
+			"HOME=/var/lib/postgresql",
+			"PGBACKREST_CONFIG=/controller/pgbackrest.conf",
+			"KUBERNETES_SERVICE_HOST=10.0.0.1",
+			"PGBACKREST_LOG_LEVEL_CONSOLE=info",
+			"TZ=UTC",
+		})).To(Equal([]string{
+			"HOME=/var/lib/postgresql",
+			"PGBACKREST_LOG_LEVEL_CONSOLE=info",
+			"TZ=UTC",
+		}))
+	})
+
+	It("returns nothing when no variable is allowed", func() {
+		Expect(filterEnv([]string{"PGBACKREST_CONFIG=/controller/pgbackrest.conf"})).To(BeEmpty())
+	})
+})
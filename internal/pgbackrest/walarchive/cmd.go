@@ -19,23 +19,31 @@ limitations under the License.
 package walarchive
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math"
-	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/cloudnative-pg/machinery/pkg/execlog"
 	"github.com/cloudnative-pg/machinery/pkg/fileutils"
 	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	pgbackrestCommand "github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/command"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/limiter"
 )
 
 const (
 	// ArchiveCommand defines the pgBackRest command for WAL archive upload
 	ArchiveCommand = "archive-push"
 	// PgbackrestExecutable defines the name of the pgBackRest binary
-	PgbackrestExecutable = "pgbackrest"
+	PgbackrestExecutable = pgbackrestCommand.PgbackrestExecutable
+
+	// droppedWALMessage is the warning of archive-push when a WAL file is
+	// dropped because the archive queue exceeded archive-push-queue-max
+	droppedWALMessage = "dropped WAL file"
 )
 
 // PgbackrestArchiver implements a WAL archiver based
@@ -44,6 +52,16 @@ type PgbackrestArchiver struct {
 	Env                 []string
 	Touch               func(walFile string) error
 	EmptyWalArchivePath string
+
+	// Timeout limits the duration of each archive-push, zero meaning no limit
+	Timeout time.Duration
+
+	// Limiter limits the number of pgBackRest processes running at once
+	Limiter *limiter.Limiter
+
+	// InFlight keeps the WAL files being archived by overlapping batches
+	// from being pushed twice
+	InFlight *inflight.Registry
 }
 
 // WALArchiverResult contains the result of the archival of one WAL
@@ -59,19 +77,44 @@ type WALArchiverResult struct {
 
 	// The time when pgbackrest archive-push ended
 	EndTime time.Time
+
+	// Whether the WAL was archived by another batch which was already pushing it
+	Shared bool
+
+	// Whether pgBackRest dropped the WAL instead of archiving it, because the
+	// archive queue exceeded archive-push-queue-max
+	Dropped bool
+}
+
+// logStderr logs the lines archive-push wrote to stderr, reporting whether
+// they tell that the WAL file was dropped
+func logStderr(ctx context.Context, walName string, stderr string) (dropped bool) {
+	contextLogger := log.FromContext(ctx)
+	for line := range strings.Lines(stderr) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.Contains(line, droppedWALMessage) {
+			dropped = true
+		}
+		contextLogger.Info(line, "walName", walName)
+	}
+	return dropped
 }
 
-// Archive archives a certain WAL file using pgbackrest archive-push.
+// Archive archives a certain WAL file using pgbackrest archive-push, reporting
+// whether pgBackRest dropped it because the archive queue was full.
 // See archiveWALFileList for the meaning of the parameters
 func (archiver *PgbackrestArchiver) Archive(
 	ctx context.Context,
 	walName string,
 	baseOptions []string,
-) error {
+) (dropped bool, err error) {
 	contextLogger := log.FromContext(ctx)
 	optionsLength := len(baseOptions)
 	if optionsLength >= math.MaxInt-2 {
-		return fmt.Errorf("can't archive wal file %v, options too long", walName)
+		return false, fmt.Errorf("can't archive wal file %v, options too long", walName)
 	}
 	options := make([]string, optionsLength, optionsLength+2)
 	copy(options, baseOptions)
@@ -82,25 +125,39 @@ func (archiver *PgbackrestArchiver) Archive(
 		"options", options,
 	)
 
-	pgbackrestWalArchiveCmd := exec.Command(PgbackrestExecutable, options...) // #nosec G204
-	pgbackrestWalArchiveCmd.Env = archiver.Env
-
-	err := execlog.RunStreaming(pgbackrestWalArchiveCmd, ArchiveCommand)
+	release, err := archiver.Limiter.Acquire(ctx, limiter.PriorityArchive, 1)
+	if err != nil {
+		return false, fmt.Errorf("while waiting to archive %s: %w", walName, err)
+	}
+	defer release()
+
+	// archive-push is left to complete when the request is cancelled, as
+	// interrupting it would only make PostgreSQL archive the WAL again
+	var stderr bytes.Buffer
+	err = pgbackrestCommand.Run(ctx, pgbackrestCommand.Execution{
+		Command:  ArchiveCommand,
+		Options:  options,
+		Env:      archiver.Env,
+		Timeout:  archiver.Timeout,
+		Detached: true,
+		Stderr:   &stderr,
+	})
+	dropped = logStderr(ctx, walName, stderr.String())
 	if err != nil {
 		contextLogger.Error(err, "Error invoking "+ArchiveCommand,
 			"walName", walName,
 			"options", options,
-			"exitCode", pgbackrestWalArchiveCmd.ProcessState.ExitCode(),
+			"retriable", pgbackrestCommand.IsRetriable(err),
 		)
-		return fmt.Errorf("unexpected failure invoking %s: %w", ArchiveCommand, err)
+		return false, fmt.Errorf("while archiving %s: %w", walName, err)
 	}
 
 	// Removes the `.check-empty-wal-archive` file inside PGDATA after the
 	// first successful archival of a WAL file.
 	if err := fileutils.RemoveFile(archiver.EmptyWalArchivePath); err != nil {
-		return fmt.Errorf("error while deleting the check WAL file flag: %w", err)
+		return dropped, fmt.Errorf("error while deleting the check WAL file flag: %w", err)
 	}
-	return nil
+	return dropped, nil
 }
 
 // ArchiveList archives a list of WAL files in parallel
@@ -119,14 +176,37 @@ func (archiver *PgbackrestArchiver) ArchiveList(
 			walStatus := &result[walIndex]
 			walStatus.WalName = walNames[walIndex]
 			walStatus.StartTime = time.Now()
-			walStatus.Err = archiver.Archive(ctx, walNames[walIndex], options)
+			walStatus.Shared, walStatus.Err = archiver.InFlight.Do(
+				ctx,
+				ArchiveCommand,
+				walNames[walIndex],
+				func() error {
+					var err error
+					if walStatus.Dropped, err = archiver.Archive(ctx, walNames[walIndex], options); err != nil {
+						return err
+					}
+					// Mark the WAL as archived before sharing the result, so
+					// that a batch waiting for it finds it in the spool. Only
+					// the batch pushing the WAL marks it: when it is the first
+					// WAL of this batch, PostgreSQL is told it is archived, and
+					// a marker left for the waiting batches would never be used.
+					if walIndex != 0 {
+						return archiver.Touch(walNames[walIndex])
+					}
+					return nil
+				})
 			walStatus.EndTime = time.Now()
-			if walStatus.Err == nil && walIndex != 0 {
-				walStatus.Err = archiver.Touch(walNames[walIndex])
-			}
 
 			elapsedWalTime := walStatus.EndTime.Sub(walStatus.StartTime)
-			if walStatus.Err != nil {
+			switch {
+			case walStatus.Dropped:
+				contextLog.Warning(
+					"Dropped WAL file as the archive queue is full: the archive is broken",
+					"walName", walStatus.WalName,
+					"startTime", walStatus.StartTime,
+					"endTime", walStatus.EndTime,
+					"elapsedWalTime", elapsedWalTime)
+			case walStatus.Err != nil:
 				contextLog.Warning(
 					"Failed archiving WAL: PostgreSQL will retry",
 					"walName", walStatus.WalName,
@@ -134,7 +214,7 @@ func (archiver *PgbackrestArchiver) ArchiveList(
 					"endTime", walStatus.EndTime,
 					"elapsedWalTime", elapsedWalTime,
 					"error", walStatus.Err)
-			} else {
+			default:
 				contextLog.Info(
 					"Archived WAL file",
 					"walName", walStatus.WalName,
@@ -0,0 +1,86 @@
+/*
+Copyright 2025, Opera Norway AS
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package walarchive
+
+import (
+	"sync"
+
+	"github.com/operasoftware/cnpg-plugin-pgbackrest/internal/pgbackrest/inflight"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logStderr", func() {
+	It("detects the WAL files dropped by pgBackRest", func(ctx SpecContext) {
+		Expect(logStderr(ctx, "000000010000000000000002",
+			"P00   WARN: dropped WAL file '000000010000000000000002' because archive queue exceeded 16MB\n",
+		)).To(BeTrue())
+	})
+
+	It("ignores the other messages", func(ctx SpecContext) {
+		Expect(logStderr(ctx, "000000010000000000000002",
+			"\nP00   WARN: option 'repo1-retention-full' is not set\n",
+		)).To(BeFalse())
+	})
+})
+
+var _ = Describe("ArchiveList", func() {
+	It("doesn't mark the WAL files another batch archived in the spool", func(ctx SpecContext) {
+		var mu sync.Mutex
+		var touched []string
+		registry := inflight.NewRegistry()
+		archiver := &PgbackrestArchiver{
+			Touch: func(walFile string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				touched = append(touched, walFile)
+				return nil
+			},
+			InFlight: registry,
+		}
+
+		// another batch is archiving the second WAL, as the first of its batch
+		started := make(chan struct{})
+		unblock := make(chan struct{})
+		go func() {
+			_, _ = registry.Do(ctx, ArchiveCommand, "000000010000000000000002", func() error {
+				close(started)
+				<-unblock
+				return nil
+			})
+		}()
+		Eventually(started).Should(BeClosed())
+
+		resultDone := make(chan []WALArchiverResult)
+		go func() {
+			resultDone <- archiver.ArchiveList(ctx,
+				[]string{"000000010000000000000001", "000000010000000000000002"}, nil)
+		}()
+		Consistently(resultDone).ShouldNot(Receive())
+		close(unblock)
+
+		var result []WALArchiverResult
+		Eventually(resultDone).Should(Receive(&result))
+		Expect(result[1].Shared).To(BeTrue())
+		Expect(result[1].Err).ToNot(HaveOccurred())
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(touched).To(BeEmpty())
+	})
+})